@@ -0,0 +1,102 @@
+package godbpf
+
+import (
+  "encoding/binary"
+  "fmt"
+  "image"
+  "image/color"
+)
+
+// maxBMPSize is the largest width and height of the bitmaps decodeBMP accepts,
+// well above those of the bitmaps found in game files.
+const maxBMPSize = 16384
+
+// decodeBMP decodes an uncompressed Windows bitmap with 8, 24 or 32 bits per
+// pixel, which covers the bitmaps found in game files.
+func decodeBMP(data []byte) (image.Image, error) {
+  if len(data) < 54 || data[0] != 'B' || data[1] != 'M' {
+    return nil, fmt.Errorf("Not a BMP image")
+  }
+
+  pixelOffset := binary.LittleEndian.Uint32(data[10:14])
+  headerSize := binary.LittleEndian.Uint32(data[14:18])
+  width := int(int32(binary.LittleEndian.Uint32(data[18:22])))
+  height := int(int32(binary.LittleEndian.Uint32(data[22:26])))
+  bitCount := binary.LittleEndian.Uint16(data[28:30])
+  compression := binary.LittleEndian.Uint32(data[30:34])
+  colorsUsed := binary.LittleEndian.Uint32(data[46:50])
+
+  // 3 is BI_BITFIELDS, which 32 bit images use with the standard BGRA masks.
+  if compression != 0 && !(compression == 3 && bitCount == 32) {
+    return nil, fmt.Errorf("Unsupported BMP compression %d", compression)
+  }
+
+  // A negative height indicates that the rows are stored from the top down.
+  topDown := height < 0
+  if topDown {
+    height = -height
+  }
+
+  if width <= 0 || height <= 0 || width > maxBMPSize || height > maxBMPSize {
+    return nil, fmt.Errorf("Invalid BMP size %dx%d", width, height)
+  }
+
+  var palette []color.NRGBA
+  switch bitCount {
+  case 8:
+    if colorsUsed == 0 || colorsUsed > 256 {
+      colorsUsed = 256
+    }
+
+    start := 14 + int(headerSize)
+    if start + int(colorsUsed) * 4 > len(data) {
+      return nil, fmt.Errorf("BMP palette is truncated")
+    }
+
+    palette = make([]color.NRGBA, colorsUsed)
+    for i := range palette {
+      p := data[start + i * 4:]
+      palette[i] = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF}
+    }
+  case 24, 32:
+  default:
+    return nil, fmt.Errorf("Unsupported BMP depth of %d bits", bitCount)
+  }
+
+  // Rows are padded to a multiple of 4 bytes.
+  stride := (width * int(bitCount) / 8 + 3) &^ 3
+  if uint64(pixelOffset) + uint64(stride) * uint64(height) > uint64(len(data)) {
+    return nil, fmt.Errorf("BMP pixel data is truncated")
+  }
+
+  img := image.NewNRGBA(image.Rect(0, 0, width, height))
+  for row := 0; row < height; row++ {
+    y := height - 1 - row
+    if topDown {
+      y = row
+    }
+
+    line := data[int(pixelOffset) + row * stride:]
+    for x := 0; x < width; x++ {
+      var c color.NRGBA
+      switch bitCount {
+      case 8:
+        index := int(line[x])
+        if index >= len(palette) {
+          return nil, fmt.Errorf("BMP palette index %d is out of range", index)
+        }
+        c = palette[index]
+      case 24:
+        p := line[x * 3:]
+        c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF}
+      case 32:
+        p := line[x * 4:]
+        c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: p[3]}
+      }
+
+      img.SetNRGBA(x, y, c)
+    }
+  }
+
+  return img, nil
+}
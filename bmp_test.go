@@ -0,0 +1,111 @@
+package godbpf
+
+import (
+  "encoding/binary"
+  "image/color"
+  "testing"
+)
+
+// buildBMP creates a bitmap with the provided pixel rows, stored from the
+// bottom up unless topDown is set.
+func buildBMP(width, height int, bitCount uint16, palette []color.NRGBA, pixels []byte, topDown bool) []byte {
+  paletteSize := len(palette) * 4
+  offset := 54 + paletteSize
+
+  data := make([]byte, offset + len(pixels))
+  data[0], data[1] = 'B', 'M'
+  binary.LittleEndian.PutUint32(data[2:], uint32(len(data)))
+  binary.LittleEndian.PutUint32(data[10:], uint32(offset))
+  binary.LittleEndian.PutUint32(data[14:], 40)
+  binary.LittleEndian.PutUint32(data[18:], uint32(width))
+  h := int32(height)
+  if topDown {
+    h = -h
+  }
+  binary.LittleEndian.PutUint32(data[22:], uint32(h))
+  binary.LittleEndian.PutUint16(data[26:], 1)
+  binary.LittleEndian.PutUint16(data[28:], bitCount)
+  binary.LittleEndian.PutUint32(data[46:], uint32(len(palette)))
+
+  for i, c := range palette {
+    copy(data[54 + i * 4:], []byte{c.B, c.G, c.R, 0})
+  }
+  copy(data[offset:], pixels)
+
+  return data
+}
+
+func TestDecodeBMP24(t *testing.T) {
+  // 2x2 pixels, rows padded to 8 bytes, bottom row first.
+  pixels := []byte{
+    0, 0, 255, 0, 255, 0, 0, 0,
+    255, 0, 0, 255, 255, 255, 0, 0,
+  }
+
+  img, e := decodeBMP(buildBMP(2, 2, 24, nil, pixels, false))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  expected := map[[2]int]color.NRGBA{
+    {0, 1}: {R: 255, A: 255},
+    {1, 1}: {G: 255, A: 255},
+    {0, 0}: {B: 255, A: 255},
+    {1, 0}: {R: 255, G: 255, B: 255, A: 255},
+  }
+
+  for p, c := range expected {
+    if got := color.NRGBAModel.Convert(img.At(p[0], p[1])); got != c {
+      t.Errorf("Pixel %v: expected %v, got %v", p, c, got)
+    }
+  }
+}
+
+func TestDecodeBMP8TopDown(t *testing.T) {
+  palette := []color.NRGBA{{R: 10, G: 20, B: 30, A: 255}, {R: 200, G: 100, B: 50, A: 255}}
+  pixels := []byte{0, 1, 0, 0, 1, 1, 0, 0}
+
+  img, e := decodeBMP(buildBMP(2, 2, 8, palette, pixels, true))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if got := color.NRGBAModel.Convert(img.At(0, 0)); got != palette[0] {
+    t.Errorf("Unexpected top left pixel %v", got)
+  }
+  if got := color.NRGBAModel.Convert(img.At(0, 1)); got != palette[1] {
+    t.Errorf("Unexpected bottom left pixel %v", got)
+  }
+}
+
+func TestDecodeBMPErrors(t *testing.T) {
+  valid := buildBMP(1, 1, 32, nil, []byte{1, 2, 3, 4}, false)
+
+  truncated := valid[:len(valid) - 2]
+
+  compressed := append([]byte(nil), valid...)
+  binary.LittleEndian.PutUint32(compressed[30:], 1)
+
+  depth := append([]byte(nil), valid...)
+  binary.LittleEndian.PutUint16(depth[28:], 4)
+
+  // A size whose pixel data would overflow an int, with a valid header.
+  huge := append([]byte(nil), valid...)
+  binary.LittleEndian.PutUint32(huge[18:], 0x7FFFFFFF)
+  binary.LittleEndian.PutUint32(huge[22:], 0x7FFFFFFF)
+
+  // A size within the limits but larger than the pixel data.
+  large := append([]byte(nil), valid...)
+  binary.LittleEndian.PutUint32(large[18:], maxBMPSize)
+  binary.LittleEndian.PutUint32(large[22:], maxBMPSize)
+
+  for name, data := range map[string][]byte{"short": valid[:20], "truncated": truncated, "compressed": compressed, "depth": depth, "huge": huge, "large": large} {
+    if _, e := decodeBMP(data); e == nil {
+      t.Errorf("Expected an error for the %s bitmap", name)
+    }
+  }
+
+  if img, e := decodeBMP(valid); e != nil || color.NRGBAModel.Convert(img.At(0, 0)) != (color.NRGBA{R: 3, G: 2, B: 1, A: 4}) {
+    t.Errorf("Unexpected 32 bit pixel, %v", e)
+  }
+}
@@ -0,0 +1,331 @@
+package cache
+
+import (
+  "encoding/gob"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// CACHE_VERSION is the version of the format written by Save.  Caches written
+// with another version can't be loaded.
+const CACHE_VERSION = 1
+
+// File holds the header fields and index of a DBPF file of the cached folder,
+// along with the size and modification time the file had when it was read.
+type File struct {
+  Path string
+  Size int64
+
+  // ModTime is the modification time of the file, in nanoseconds since the
+  // Unix epoch.
+  ModTime int64
+
+  // NotDBPF is set for files that aren't DBPF files, so that they aren't
+  // read again until they change.
+  NotDBPF bool
+
+  // Error holds the reason a file starting with the DBPF signature couldn't
+  // be read, or the reason the file couldn't be opened.  Such files have no
+  // entries.
+  Error string
+
+  // Unopened is set for files that couldn't be opened, such as locked files,
+  // so that they are tried again by the next Refresh even if they don't
+  // change.
+  Unopened bool
+
+  MajorVersion, MinorVersion uint32
+  IndexMajorVersion, IndexMinorVersion uint32
+  Created, Modified int64
+
+  Entries []*godbpf.IndexEntry
+}
+
+// Cache holds the indexes of the DBPF files found in a plugin folder, so that
+// they can be queried without reading the files again.
+type Cache struct {
+  files map[string]*File
+}
+
+// New creates an empty Cache.
+func New() *Cache {
+  return &Cache{files: make(map[string]*File)}
+}
+
+// stored is the form of a Cache written by Save.
+type stored struct {
+  Version int
+  Files []*File
+}
+
+// Load reads a Cache written by Save from the provided Reader.
+func Load(r io.Reader) (*Cache, error) {
+  s := new(stored)
+  if e := gob.NewDecoder(r).Decode(s); e != nil {
+    return nil, e
+  }
+
+  if s.Version != CACHE_VERSION {
+    return nil, fmt.Errorf("Unsupported cache version %d", s.Version)
+  }
+
+  c := New()
+  for _, f := range s.Files {
+    c.files[f.Path] = f
+  }
+
+  return c, nil
+}
+
+// Save writes the receiver to the provided Writer.
+func (c *Cache) Save(w io.Writer) error {
+  return gob.NewEncoder(w).Encode(&stored{Version: CACHE_VERSION, Files: c.Files()})
+}
+
+// Open loads the Cache stored in the named file.  A missing file, or one that
+// can't be loaded, such as one written with another version, gives an empty
+// Cache.
+func Open(name string) (*Cache, error) {
+  f, e := os.Open(name)
+  if os.IsNotExist(e) {
+    return New(), nil
+  }
+  if e != nil {
+    return nil, e
+  }
+  defer f.Close()
+
+  c, e := Load(f)
+  if e != nil {
+    return New(), nil
+  }
+
+  return c, nil
+}
+
+// WriteFile saves the receiver to the named file, replacing it only once the
+// cache is completely written.
+func (c *Cache) WriteFile(name string) error {
+  tmp := name + ".tmp"
+  f, e := os.Create(tmp)
+  if e != nil {
+    return e
+  }
+
+  if e := c.Save(f); e != nil {
+    f.Close()
+    os.Remove(tmp)
+    return e
+  }
+  if e := f.Close(); e != nil {
+    os.Remove(tmp)
+    return e
+  }
+
+  return os.Rename(tmp, name)
+}
+
+// Stats counts the files handled by Refresh.
+type Stats struct {
+  Added, Updated, Removed, Unchanged int
+}
+
+// Refresh brings the receiver up to date with the files of the named directory
+// and its subdirectories.  Files whose size and modification time haven't
+// changed keep their cached index, others are read again, and files that are
+// gone are removed.  The receiver covers a single folder, so files outside of
+// it are removed as well.
+func (c *Cache) Refresh(dir string) (*Stats, error) {
+  stats := new(Stats)
+  seen := make(map[string]bool)
+
+  e := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+    if err != nil || info.IsDir() {
+      return err
+    }
+
+    seen[path] = true
+    cached, found := c.files[path]
+    if found && !cached.Unopened && cached.Size == info.Size() && cached.ModTime == info.ModTime().UnixNano() {
+      stats.Unchanged++
+      return nil
+    }
+
+    c.files[path] = readFile(path, info)
+    if found {
+      stats.Updated++
+    } else {
+      stats.Added++
+    }
+    return nil
+  })
+  if e != nil {
+    return nil, e
+  }
+
+  for path := range c.files {
+    if !seen[path] {
+      delete(c.files, path)
+      stats.Removed++
+    }
+  }
+
+  return stats, nil
+}
+
+// readFile reads the header and index of the named file.  Failures are
+// recorded in the returned File rather than ending the Refresh.
+func readFile(path string, info os.FileInfo) *File {
+  f := &File{Path: path, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+
+  in, e := os.Open(path)
+  if e != nil {
+    f.Error, f.Unopened = e.Error(), true
+    return f
+  }
+  defer in.Close()
+
+  magic := make([]byte, 4)
+  if _, e := io.ReadFull(in, magic); e != nil || string(magic) != "DBPF" {
+    f.NotDBPF = true
+    return f
+  }
+
+  header, entries, e := godbpf.ReadIndex(in, f.Size)
+  if e != nil {
+    f.Error = e.Error()
+    return f
+  }
+
+  f.MajorVersion, f.MinorVersion = header.MajorVersion, header.MinorVersion
+  f.IndexMajorVersion, f.IndexMinorVersion = header.IndexMajorVersion, header.IndexMinorVersion
+  f.Created, f.Modified = header.CreatedDate.Unix(), header.ModifiedDate.Unix()
+  f.Entries = entries
+
+  return f
+}
+
+// Files returns the files of the receiver in the order the game loads them
+// in, as given by LoadOrderLess.
+func (c *Cache) Files() []*File {
+  result := make([]*File, 0, len(c.files))
+  for _, f := range c.files {
+    result = append(result, f)
+  }
+
+  sort.Slice(result, func(i, j int) bool { return LoadOrderLess(result[i].Path, result[j].Path) })
+  return result
+}
+
+// LoadOrderLess checks if the game loads the file with the first path before
+// the one with the second.  The game loads the files of a folder, sorted by
+// name, before moving on to each of its subfolders, also sorted by name, so
+// a.dat comes before z.dat, which comes before sub/a.dat.  Names are sorted
+// as Windows does, without regard to case and comparing their upper case
+// forms, so that Zeta.dat comes after alpha.dat and _a.dat after z.dat.
+func LoadOrderLess(a, b string) bool {
+  as, bs := strings.Split(filepath.ToSlash(a), "/"), strings.Split(filepath.ToSlash(b), "/")
+  for i := 0; i < len(as) && i < len(bs); i++ {
+    if as[i] == bs[i] {
+      continue
+    }
+
+    // A file of the folder both paths share comes before anything found in
+    // its subfolders.
+    aFile, bFile := i == len(as) - 1, i == len(bs) - 1
+    if aFile != bFile {
+      return aFile
+    }
+
+    if ua, ub := strings.ToUpper(as[i]), strings.ToUpper(bs[i]); ua != ub {
+      return ua < ub
+    }
+
+    // Names only differing by case can't both exist on Windows, but are
+    // still given a stable order.
+    return as[i] < bs[i]
+  }
+
+  return len(as) < len(bs)
+}
+
+// DBPFs returns the DBPF files of the receiver whose index could be read, in
+// load order.
+func (c *Cache) DBPFs() []*File {
+  var result []*File
+  for _, f := range c.Files() {
+    if !f.NotDBPF && f.Error == "" {
+      result = append(result, f)
+    }
+  }
+
+  return result
+}
+
+// Broken returns the files of the receiver that start with the DBPF signature
+// but whose index couldn't be read, in load order.
+func (c *Cache) Broken() []*File {
+  var result []*File
+  for _, f := range c.Files() {
+    if f.Error != "" {
+      result = append(result, f)
+    }
+  }
+
+  return result
+}
+
+// File returns the cached index of the file with the provided path, or nil.
+func (c *Cache) File(path string) *File {
+  return c.files[path]
+}
+
+// Location is an entry of a cached file.
+type Location struct {
+  Path string
+  Entry *godbpf.IndexEntry
+}
+
+// Read reads the data of the entry from its file, decompressing it if needed.
+func (l *Location) Read() ([]byte, error) {
+  f, e := os.Open(l.Path)
+  if e != nil {
+    return nil, e
+  }
+  defer f.Close()
+
+  return godbpf.ReadEntry(f, l.Entry)
+}
+
+// Select returns the entries of the cached DBPF files satisfying the provided
+// function, in load order.
+func (c *Cache) Select(match func(tgi *entry.DBPFEntryTGI) bool) []*Location {
+  var result []*Location
+  for _, f := range c.Files() {
+    for _, ie := range f.Entries {
+      if match(&ie.TGI) {
+        result = append(result, &Location{Path: f.Path, Entry: ie})
+      }
+    }
+  }
+
+  return result
+}
+
+// Find returns the entries with the provided TGI, in load order, so the last
+// one is the one the game uses.
+func (c *Cache) Find(tgi *entry.DBPFEntryTGI) []*Location {
+  return c.Select(tgi.Equals)
+}
+
+// FindByType returns the entries with the provided TypeId, in load order.
+func (c *Cache) FindByType(typeId uint32) []*Location {
+  return c.Select(func(tgi *entry.DBPFEntryTGI) bool { return tgi.TypeId == typeId })
+}
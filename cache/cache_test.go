@@ -0,0 +1,185 @@
+package cache
+
+import (
+  "bytes"
+  "encoding/gob"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func writeDBPF(t *testing.T, path string, instanceIds ...uint32) {
+  dbpf := godbpf.New()
+  dbpf.MajorVersion = 1
+  for _, iid := range instanceIds {
+    dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x1, InstanceId: iid}, []byte("exemplar data"))
+  }
+
+  buf := new(bytes.Buffer)
+  dbpf.Save(buf)
+  if e := ioutil.WriteFile(path, buf.Bytes(), 0644); e != nil {
+    t.Fatal(e)
+  }
+}
+
+func TestRefresh(t *testing.T) {
+  dir := t.TempDir()
+
+  os.Mkdir(filepath.Join(dir, "sub"), 0755)
+  writeDBPF(t, filepath.Join(dir, "a.dat"), 1, 2)
+  writeDBPF(t, filepath.Join(dir, "sub", "b.dat"), 2)
+  ioutil.WriteFile(filepath.Join(dir, "readme.txt"), []byte("text"), 0644)
+  ioutil.WriteFile(filepath.Join(dir, "broken.dat"), []byte("DBPF is too short"), 0644)
+
+  c := New()
+  stats, e := c.Refresh(dir)
+  if e != nil {
+    t.Fatal(e)
+  }
+  if *stats != (Stats{Added: 4}) {
+    t.Errorf("Unexpected stats %+v", stats)
+  }
+
+  if f := c.File(filepath.Join(dir, "readme.txt")); f == nil || !f.NotDBPF {
+    t.Errorf("Expected the text file to be remembered as not a DBPF file, got %+v", f)
+  }
+  if f := c.File(filepath.Join(dir, "broken.dat")); f == nil || f.Error == "" {
+    t.Errorf("Expected the broken file to be remembered with its error, got %+v", f)
+  }
+
+  a := c.File(filepath.Join(dir, "a.dat"))
+  if a == nil || a.MajorVersion != 1 || len(a.Entries) != 3 || !a.Entries[0].Compressed {
+    t.Fatalf("Unexpected file %+v", a)
+  }
+
+  // Change one file, remove another and add a third.
+  writeDBPF(t, filepath.Join(dir, "a.dat"), 1, 2, 3)
+  later := time.Now().Add(time.Hour)
+  os.Chtimes(filepath.Join(dir, "a.dat"), later, later)
+  os.Remove(filepath.Join(dir, "readme.txt"))
+  writeDBPF(t, filepath.Join(dir, "c.dat"), 3)
+
+  stats, e = c.Refresh(dir)
+  if e != nil {
+    t.Fatal(e)
+  }
+  if *stats != (Stats{Added: 1, Updated: 1, Removed: 1, Unchanged: 2}) {
+    t.Errorf("Unexpected stats %+v", stats)
+  }
+
+  locations := c.Find(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x1, InstanceId: 0x2})
+  if len(locations) != 2 || locations[0].Path != filepath.Join(dir, "a.dat") || locations[1].Path != filepath.Join(dir, "sub", "b.dat") {
+    t.Errorf("Unexpected locations %+v", locations)
+  }
+
+  if data, e := locations[1].Read(); e != nil || string(data) != "exemplar data" {
+    t.Errorf("Unexpected data %q, %v", data, e)
+  }
+
+  if exemplars := c.FindByType(0x6534284A); len(exemplars) != 5 {
+    t.Errorf("Unexpected exemplars %+v", exemplars)
+  }
+}
+
+func TestSaveAndLoad(t *testing.T) {
+  dir := t.TempDir()
+
+  writeDBPF(t, filepath.Join(dir, "a.dat"), 1)
+  c := New()
+  c.Refresh(dir)
+
+  name := filepath.Join(dir, "index.cache")
+  if e := c.WriteFile(name); e != nil {
+    t.Fatal(e)
+  }
+
+  loaded, e := Open(name)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(loaded.Files()) != 1 || len(loaded.Find(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x1, InstanceId: 0x1})) != 1 {
+    t.Errorf("Unexpected cache %+v", loaded.Files())
+  }
+
+  // The cache file itself is part of the folder now.
+  stats, e := loaded.Refresh(dir)
+  if e != nil || *stats != (Stats{Added: 1, Unchanged: 1}) {
+    t.Errorf("Unexpected stats %+v, %v", stats, e)
+  }
+
+  ioutil.WriteFile(name, []byte("garbage"), 0644)
+  if empty, e := Open(name); e != nil || len(empty.Files()) != 0 {
+    t.Errorf("Expected an empty cache for an unreadable file, got %v, %v", empty, e)
+  }
+  if empty, e := Open(filepath.Join(dir, "missing.cache")); e != nil || len(empty.Files()) != 0 {
+    t.Errorf("Expected an empty cache for a missing file, got %v, %v", empty, e)
+  }
+}
+
+func TestLoadOtherVersion(t *testing.T) {
+  buf := new(bytes.Buffer)
+  if e := gob.NewEncoder(buf).Encode(&stored{Version: CACHE_VERSION + 1}); e != nil {
+    t.Fatal(e)
+  }
+
+  if _, e := Load(buf); e == nil {
+    t.Error("Expected an error for a cache of another version")
+  }
+}
+
+func TestRefreshWithUnopenedFile(t *testing.T) {
+  dir := t.TempDir()
+  writeDBPF(t, filepath.Join(dir, "a.dat"), 1)
+  locked := filepath.Join(dir, "locked.dat")
+  if e := os.Symlink(filepath.Join(dir, "missing.dat"), locked); e != nil {
+    t.Skip(e)
+  }
+
+  c := New()
+  if _, e := c.Refresh(dir); e != nil {
+    t.Fatal(e)
+  }
+
+  if f := c.File(locked); f == nil || !f.Unopened || f.Error == "" {
+    t.Errorf("Expected the file to be remembered as unopened, got %+v", f)
+  }
+  if broken := c.Broken(); len(broken) != 1 || broken[0].Path != locked {
+    t.Errorf("Unexpected broken files %v", broken)
+  }
+  if dbpfs := c.DBPFs(); len(dbpfs) != 1 {
+    t.Errorf("Unexpected DBPF files %v", dbpfs)
+  }
+
+  // The file is tried again although it didn't change.
+  stats, e := c.Refresh(dir)
+  if e != nil || *stats != (Stats{Updated: 1, Unchanged: 1}) {
+    t.Errorf("Unexpected stats %+v, %v", stats, e)
+  }
+  if f := c.File(locked); f == nil || !f.Unopened {
+    t.Errorf("Expected the file to still be unopened, got %+v", f)
+  }
+}
+
+func TestFilesInLoadOrder(t *testing.T) {
+  c := New()
+  for _, path := range []string{"sub/z.dat", "z.dat", "sub/deeper/x.dat", "b/x.dat", "a.dat", "sub/a.dat", "sub.dat", "Zeta.dat", "_last.dat", "B/y.dat"} {
+    c.files[path] = &File{Path: path}
+  }
+
+  var paths []string
+  for _, f := range c.Files() {
+    paths = append(paths, f.Path)
+  }
+
+  expected := []string{"a.dat", "sub.dat", "z.dat", "Zeta.dat", "_last.dat", "B/y.dat", "b/x.dat", "sub/a.dat", "sub/z.dat", "sub/deeper/x.dat"}
+  if strings.Join(paths, ",") != strings.Join(expected, ",") {
+    t.Errorf("Expected %v, got %v", expected, paths)
+  }
+}
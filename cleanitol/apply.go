@@ -0,0 +1,154 @@
+package cleanitol
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+)
+
+// Move is a file moved by Apply.
+type Move struct {
+  From, To string
+}
+
+// Report describes what Apply did.
+type Report struct {
+  // Moved lists the files moved to the backup folder.
+  Moved []*Move
+
+  // NotFound lists the names of the list that didn't match any file.
+  NotFound []string
+
+  // Failed lists the files that matched but couldn't be moved, with the
+  // reason.
+  Failed []string
+}
+
+// Apply moves the files of the named plugin directory that are named by the
+// provided list to the named backup directory, keeping their path relative to
+// the plugin directory.  Files that would overwrite a file of the backup
+// directory are left in place.  The backup directory is skipped if it is
+// inside the plugin directory.
+func Apply(l *List, pluginDir, backupDir string) (*Report, error) {
+  backup, e := filepath.Abs(backupDir)
+  if e != nil {
+    return nil, e
+  }
+
+  var matches []string
+  found := make(map[*Entry]bool)
+  e = filepath.Walk(pluginDir, func(path string, info os.FileInfo, err error) error {
+    if err != nil {
+      return err
+    }
+
+    if info.IsDir() {
+      if abs, err := filepath.Abs(path); err == nil && abs == backup {
+        return filepath.SkipDir
+      }
+      return nil
+    }
+
+    if entry := l.find(path); entry != nil {
+      found[entry] = true
+      matches = append(matches, path)
+    }
+    return nil
+  })
+  if e != nil {
+    return nil, e
+  }
+
+  r := new(Report)
+  for _, entry := range l.Entries {
+    if !found[entry] {
+      r.NotFound = append(r.NotFound, entry.Name)
+    }
+  }
+
+  for _, path := range matches {
+    rel, err := filepath.Rel(pluginDir, path)
+    if err != nil {
+      return nil, err
+    }
+
+    to := filepath.Join(backupDir, rel)
+    if err := moveFile(path, to); err != nil {
+      r.Failed = append(r.Failed, fmt.Sprintf("%s: %v", path, err))
+      continue
+    }
+
+    r.Moved = append(r.Moved, &Move{From: path, To: to})
+  }
+
+  return r, nil
+}
+
+// moveFile moves a file, creating the directory it is moved to if needed.  It
+// fails rather than overwrite an existing file.
+func moveFile(from, to string) error {
+  if _, e := os.Stat(to); e == nil {
+    return fmt.Errorf("%s already exists", to)
+  }
+
+  if e := os.MkdirAll(filepath.Dir(to), 0755); e != nil {
+    return e
+  }
+
+  if e := os.Rename(from, to); e == nil {
+    return nil
+  }
+
+  // Renaming fails across file systems, in which case the file is copied.
+  in, e := os.Open(from)
+  if e != nil {
+    return e
+  }
+  defer in.Close()
+
+  out, e := os.OpenFile(to, os.O_WRONLY | os.O_CREATE | os.O_EXCL, 0644)
+  if e != nil {
+    return e
+  }
+
+  if _, e := io.Copy(out, in); e != nil {
+    out.Close()
+    os.Remove(to)
+    return e
+  }
+  if e := out.Close(); e != nil {
+    os.Remove(to)
+    return e
+  }
+
+  in.Close()
+  return os.Remove(from)
+}
+
+// Format writes the receiver to the provided Writer as text.
+func (r *Report) Format(w io.Writer) error {
+  bw := bufio.NewWriter(w)
+
+  fmt.Fprintf(bw, "Moved %d file(s)\n", len(r.Moved))
+  for _, m := range r.Moved {
+    fmt.Fprintf(bw, "  %s -> %s\n", m.From, m.To)
+  }
+
+  if len(r.NotFound) > 0 {
+    fmt.Fprintf(bw, "Not found: %d file(s)\n", len(r.NotFound))
+    for _, name := range r.NotFound {
+      fmt.Fprintf(bw, "  %s\n", name)
+    }
+  }
+
+  if len(r.Failed) > 0 {
+    fmt.Fprintf(bw, "Failed: %d file(s)\n", len(r.Failed))
+    for _, failure := range r.Failed {
+      fmt.Fprintf(bw, "  %s\n", failure)
+    }
+  }
+
+  return bw.Flush()
+}
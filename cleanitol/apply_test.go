@@ -0,0 +1,63 @@
+package cleanitol
+
+import (
+  "bytes"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestApply(t *testing.T) {
+  dir, e := ioutil.TempDir("", "godbpf")
+  if e != nil {
+    t.Fatal(e)
+  }
+  defer os.RemoveAll(dir)
+
+  plugins := filepath.Join(dir, "Plugins")
+  backup := filepath.Join(plugins, "Backup")
+  for _, name := range []string{"Lots/old.SC4Lot", "Lots/keep.SC4Lot", "Textures/OLD.dat", "clash.dat", "Backup/clash.dat"} {
+    path := filepath.Join(plugins, name)
+    os.MkdirAll(filepath.Dir(path), 0755)
+    ioutil.WriteFile(path, []byte(name), 0644)
+  }
+
+  l, _ := Parse(strings.NewReader("old.sc4lot\nold.dat\nclash.dat\nmissing.dat\n"))
+  r, e := Apply(l, plugins, backup)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(r.Moved) != 2 || len(r.NotFound) != 1 || r.NotFound[0] != "missing.dat" || len(r.Failed) != 1 {
+    t.Fatalf("Unexpected report %+v", r)
+  }
+
+  if data, e := ioutil.ReadFile(filepath.Join(backup, "Lots", "old.SC4Lot")); e != nil || string(data) != "Lots/old.SC4Lot" {
+    t.Errorf("Expected the file to be moved to the backup folder, got %q, %v", data, e)
+  }
+  if _, e := os.Stat(filepath.Join(plugins, "Lots", "old.SC4Lot")); e == nil {
+    t.Error("Expected the file to be removed from the plugin folder")
+  }
+  if _, e := os.Stat(filepath.Join(plugins, "clash.dat")); e != nil {
+    t.Error("Expected a file clashing with the backup folder to be left in place")
+  }
+  if data, _ := ioutil.ReadFile(filepath.Join(backup, "clash.dat")); string(data) != "Backup/clash.dat" {
+    t.Error("Expected the backup folder not to be cleaned")
+  }
+
+  buf := new(bytes.Buffer)
+  r.Format(buf)
+  for _, expected := range []string{"Moved 2 file(s)\n", "Not found: 1 file(s)\n  missing.dat\n", "Failed: 1 file(s)\n"} {
+    if !strings.Contains(buf.String(), expected) {
+      t.Errorf("Expected %q in the report:\n%s", expected, buf)
+    }
+  }
+}
+
+func TestApplyMissingFolder(t *testing.T) {
+  if _, e := Apply(new(List), filepath.Join(os.TempDir(), "missing-plugins"), os.TempDir()); e == nil {
+    t.Error("Expected an error for a missing plugin folder")
+  }
+}
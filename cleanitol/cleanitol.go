@@ -0,0 +1,112 @@
+package cleanitol
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "strings"
+)
+
+// A Cleanitol list is a text file naming the files to move out of the Plugins
+// folder, one per line.  Files are named without their directory, and match
+// wherever they are found in the folder, without regard to case.  Anything
+// following a semicolon is a comment.
+
+// Entry is a file named by a Cleanitol list.
+type Entry struct {
+  // Name is the name of the file.
+  Name string
+
+  // Comment is the comment following the name, without the semicolon.
+  Comment string
+}
+
+// List is a Cleanitol list.
+type List struct {
+  // Header holds the comment lines written before the entries.
+  Header []string
+
+  Entries []*Entry
+}
+
+// Parse reads a Cleanitol list from the provided Reader.  Comment lines are
+// kept in the Header when they come before the first entry, and dropped
+// otherwise.
+func Parse(r io.Reader) (*List, error) {
+  l := new(List)
+  scanner := bufio.NewScanner(r)
+
+  for scanner.Scan() {
+    line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+    name, comment := line, ""
+    if i := strings.Index(line, ";"); i >= 0 {
+      name, comment = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i + 1:])
+    }
+
+    if name == "" {
+      if len(l.Entries) == 0 && comment != "" {
+        l.Header = append(l.Header, comment)
+      }
+      continue
+    }
+
+    l.Entries = append(l.Entries, &Entry{Name: name, Comment: comment})
+  }
+
+  if e := scanner.Err(); e != nil {
+    return nil, e
+  }
+
+  return l, nil
+}
+
+// Format writes the receiver to the provided Writer.  Lines end with CRLF, as
+// expected by the Windows tools reading these lists.
+func (l *List) Format(w io.Writer) error {
+  bw := bufio.NewWriter(w)
+
+  for _, h := range l.Header {
+    fmt.Fprintf(bw, "; %s\r\n", h)
+  }
+  if len(l.Header) > 0 && len(l.Entries) > 0 {
+    bw.WriteString("\r\n")
+  }
+
+  for _, e := range l.Entries {
+    if e.Comment == "" {
+      fmt.Fprintf(bw, "%s\r\n", e.Name)
+    } else {
+      fmt.Fprintf(bw, "%s ; %s\r\n", e.Name, e.Comment)
+    }
+  }
+
+  return bw.Flush()
+}
+
+// Contains checks if the receiver names the file with the provided name, which
+// may include a directory.
+func (l *List) Contains(name string) bool {
+  return l.find(name) != nil
+}
+
+// find returns the entry naming the file with the provided name, or nil.
+func (l *List) find(name string) *Entry {
+  name = baseName(name)
+  for _, e := range l.Entries {
+    if strings.EqualFold(e.Name, name) {
+      return e
+    }
+  }
+
+  return nil
+}
+
+// baseName returns the part of a file name following its last slash or
+// backslash, so that Windows and Unix paths are handled alike.
+func baseName(name string) string {
+  if i := strings.LastIndexAny(name, "/\\"); i >= 0 {
+    return name[i + 1:]
+  }
+
+  return name
+}
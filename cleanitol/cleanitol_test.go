@@ -0,0 +1,62 @@
+package cleanitol
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+const sampleList = "\uFEFF; Remove the old versions\r\n" +
+  ";\r\n" +
+  "\r\n" +
+  "OldLot.SC4Lot\r\n" +
+  "  Old Textures.dat   ; replaced by the new textures\r\n" +
+  "; trailing comment\r\n"
+
+func TestParse(t *testing.T) {
+  l, e := Parse(strings.NewReader(sampleList))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(l.Header) != 1 || l.Header[0] != "Remove the old versions" {
+    t.Errorf("Unexpected header %q", l.Header)
+  }
+
+  if len(l.Entries) != 2 || l.Entries[0].Name != "OldLot.SC4Lot" || l.Entries[1].Name != "Old Textures.dat" || l.Entries[1].Comment != "replaced by the new textures" {
+    t.Errorf("Unexpected entries %+v", l.Entries)
+  }
+}
+
+func TestFormat(t *testing.T) {
+  l := &List{Header: []string{"Header"}, Entries: []*Entry{&Entry{Name: "a.dat"}, &Entry{Name: "b.dat", Comment: "note"}}}
+
+  buf := new(bytes.Buffer)
+  if e := l.Format(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  if buf.String() != "; Header\r\n\r\na.dat\r\nb.dat ; note\r\n" {
+    t.Errorf("Unexpected list %q", buf)
+  }
+
+  parsed, _ := Parse(buf)
+  if len(parsed.Entries) != 2 || parsed.Entries[1].Comment != "note" {
+    t.Errorf("Expected the list to be parsed back, got %+v", parsed.Entries)
+  }
+}
+
+func TestContains(t *testing.T) {
+  l, _ := Parse(strings.NewReader(sampleList))
+
+  for name, expected := range map[string]bool{
+    "oldlot.sc4lot": true,
+    "Plugins/Lots/OldLot.SC4Lot": true,
+    "C:\\Plugins\\old textures.DAT": true,
+    "OldLot.SC4Desc": false,
+  } {
+    if l.Contains(name) != expected {
+      t.Errorf("Expected Contains(%q) to be %v", name, expected)
+    }
+  }
+}
@@ -0,0 +1,192 @@
+package cleanitol
+
+import (
+  "sort"
+  "strings"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/cache"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// Scan records the TGIs provided by the files of a plugin folder, in the order
+// the game loads them, to find the files whose entries are overridden.
+type Scan struct {
+  // Files lists the files added to the scan, in load order.
+  Files []string
+
+  // Broken lists the files left out by FromCache and ScanFolder since their
+  // index couldn't be read.
+  Broken []*cache.File
+
+  tgis map[string][]entry.DBPFEntryTGI
+  providers map[entry.DBPFEntryTGI][]string
+}
+
+// NewScan creates an empty Scan.
+func NewScan() *Scan {
+  return &Scan{tgis: make(map[string][]entry.DBPFEntryTGI), providers: make(map[entry.DBPFEntryTGI][]string)}
+}
+
+// Add records the TGIs of the entries of the provided DBPF, except for the DIR
+// entry, as provided by the named file.  Files added later override those
+// added earlier.
+func (s *Scan) Add(name string, dbpf *godbpf.DBPF) {
+  var tgis []*entry.DBPFEntryTGI
+  for _, e := range dbpf.Entries() {
+    tgis = append(tgis, e.TGI)
+  }
+
+  s.add(name, tgis)
+}
+
+// AddFile records the TGIs of the entries of the provided cached file, as Add
+// does.
+func (s *Scan) AddFile(f *cache.File) {
+  var tgis []*entry.DBPFEntryTGI
+  for _, ie := range f.Entries {
+    tgis = append(tgis, &ie.TGI)
+  }
+
+  s.add(f.Path, tgis)
+}
+
+// add records the provided TGIs as provided by the named file.
+func (s *Scan) add(name string, tgis []*entry.DBPFEntryTGI) {
+  if _, found := s.tgis[name]; !found {
+    s.Files = append(s.Files, name)
+  }
+
+  for _, tgi := range tgis {
+    if tgi.Equals(entry.DIR_ENTRY_TGI) {
+      continue
+    }
+
+    s.tgis[name] = append(s.tgis[name], *tgi)
+    if p := s.providers[*tgi]; len(p) == 0 || p[len(p) - 1] != name {
+      s.providers[*tgi] = append(p, name)
+    }
+  }
+}
+
+// FromCache creates a Scan of the DBPF files of the provided Cache, added in
+// load order.  Files whose index couldn't be read are left out, and listed in
+// Broken.
+func FromCache(c *cache.Cache) *Scan {
+  s := NewScan()
+  for _, f := range c.DBPFs() {
+    s.AddFile(f)
+  }
+  s.Broken = c.Broken()
+
+  return s
+}
+
+// ScanFolder creates a Scan of the DBPF files found in the named directory and
+// its subdirectories, as FromCache does with a Cache of the directory.
+func ScanFolder(dir string) (*Scan, error) {
+  c := cache.New()
+  if _, e := c.Refresh(dir); e != nil {
+    return nil, e
+  }
+
+  return FromCache(c), nil
+}
+
+// Conflict is a TGI provided by more than one file.
+type Conflict struct {
+  TGI entry.DBPFEntryTGI
+
+  // Files lists the files providing the TGI in load order, so the last one
+  // is the one the game uses.
+  Files []string
+}
+
+// Conflicts returns the TGIs provided by more than one file, sorted by TGI.
+func (s *Scan) Conflicts() []*Conflict {
+  var result []*Conflict
+  for tgi, files := range s.providers {
+    if len(files) > 1 {
+      result = append(result, &Conflict{TGI: tgi, Files: files})
+    }
+  }
+
+  sort.Slice(result, func(i, j int) bool { return result[i].TGI.Less(&result[j].TGI) })
+  return result
+}
+
+// Superseded returns the files holding at least one entry whose entries are
+// all overridden by files loaded later, in load order.  The second value maps
+// each of them to the files overriding it.
+func (s *Scan) Superseded() ([]string, map[string][]string) {
+  var result []string
+  overriders := make(map[string][]string)
+
+  for _, name := range s.Files {
+    tgis := s.tgis[name]
+    if len(tgis) == 0 {
+      continue
+    }
+
+    by := make(map[string]bool)
+    superseded := true
+    for _, tgi := range tgis {
+      providers := s.providers[tgi]
+      winner := providers[len(providers) - 1]
+      if winner == name {
+        superseded = false
+        break
+      }
+      by[winner] = true
+    }
+
+    if superseded {
+      result = append(result, name)
+      for other := range by {
+        overriders[name] = append(overriders[name], other)
+      }
+      sort.Strings(overriders[name])
+    }
+  }
+
+  return result, overriders
+}
+
+// Generate creates a Cleanitol list naming the superseded files of the
+// receiver, each commented with the files overriding it.  Since a list names
+// files without their directory, superseded files sharing their name with a
+// file that is still in use are left out, and returned as the second value.
+func (s *Scan) Generate() (*List, []string) {
+  superseded, overriders := s.Superseded()
+
+  inUse := make(map[string]bool)
+  isSuperseded := make(map[string]bool)
+  for _, name := range superseded {
+    isSuperseded[name] = true
+  }
+  for _, name := range s.Files {
+    if !isSuperseded[name] {
+      inUse[strings.ToLower(baseName(name))] = true
+    }
+  }
+
+  l := &List{Header: []string{"Files whose entries are all overridden by files loaded later"}}
+  var skipped []string
+  for _, name := range superseded {
+    base := baseName(name)
+    if inUse[strings.ToLower(base)] {
+      skipped = append(skipped, name)
+      continue
+    }
+
+    if l.find(base) == nil {
+      var others []string
+      for _, other := range overriders[name] {
+        others = append(others, baseName(other))
+      }
+      l.Entries = append(l.Entries, &Entry{Name: base, Comment: "superseded by " + strings.Join(others, ", ")})
+    }
+  }
+
+  return l, skipped
+}
@@ -0,0 +1,109 @@
+package cleanitol
+
+import (
+  "bytes"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func sampleDBPF(instanceIds ...uint32) *godbpf.DBPF {
+  dbpf := godbpf.New()
+  for _, iid := range instanceIds {
+    dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x1, InstanceId: iid}, []byte("data"))
+  }
+
+  return dbpf
+}
+
+func sampleScan() *Scan {
+  s := NewScan()
+  s.Add("a/old.dat", sampleDBPF(1, 2))
+  s.Add("a/partial.dat", sampleDBPF(3, 4))
+  s.Add("b/empty.dat", sampleDBPF())
+  s.Add("b/new.dat", sampleDBPF(1, 3))
+  s.Add("c/newer.dat", sampleDBPF(2))
+  s.Add("c/shared.dat", sampleDBPF(5))
+  s.Add("d/shared.dat", sampleDBPF(5, 6))
+  return s
+}
+
+func TestConflicts(t *testing.T) {
+  conflicts := sampleScan().Conflicts()
+
+  if len(conflicts) != 4 {
+    t.Fatalf("Unexpected conflicts %+v", conflicts)
+  }
+
+  if c := conflicts[0]; c.TGI.InstanceId != 1 || len(c.Files) != 2 || c.Files[0] != "a/old.dat" || c.Files[1] != "b/new.dat" {
+    t.Errorf("Unexpected conflict %+v", c)
+  }
+  if c := conflicts[3]; c.TGI.InstanceId != 5 || c.Files[1] != "d/shared.dat" {
+    t.Errorf("Unexpected conflict %+v", c)
+  }
+}
+
+func TestSuperseded(t *testing.T) {
+  superseded, overriders := sampleScan().Superseded()
+
+  if len(superseded) != 2 || superseded[0] != "a/old.dat" || superseded[1] != "c/shared.dat" {
+    t.Errorf("Unexpected superseded files %v", superseded)
+  }
+
+  if by := overriders["a/old.dat"]; len(by) != 2 || by[0] != "b/new.dat" || by[1] != "c/newer.dat" {
+    t.Errorf("Unexpected overriders %v", by)
+  }
+}
+
+func TestGenerate(t *testing.T) {
+  l, skipped := sampleScan().Generate()
+
+  if len(l.Entries) != 1 || l.Entries[0].Name != "old.dat" || l.Entries[0].Comment != "superseded by new.dat, newer.dat" {
+    t.Errorf("Unexpected entries %+v", l.Entries)
+  }
+
+  if len(skipped) != 1 || skipped[0] != "c/shared.dat" {
+    t.Errorf("Expected a file sharing its name with a file in use to be skipped, got %v", skipped)
+  }
+}
+
+func TestScanFolder(t *testing.T) {
+  dir, e := ioutil.TempDir("", "godbpf")
+  if e != nil {
+    t.Fatal(e)
+  }
+  defer os.RemoveAll(dir)
+
+  // The game loads 2.dat before the files of the sub folder, although a
+  // plain sort of the paths puts it last.
+  os.Mkdir(filepath.Join(dir, "1-sub"), 0755)
+  for name, dbpf := range map[string]*godbpf.DBPF{"1.dat": sampleDBPF(1), "2.dat": sampleDBPF(1), "1-sub/3.dat": sampleDBPF(1)} {
+    buf := new(bytes.Buffer)
+    dbpf.Save(buf)
+    ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644)
+  }
+  ioutil.WriteFile(filepath.Join(dir, "readme.txt"), []byte("text"), 0644)
+  ioutil.WriteFile(filepath.Join(dir, "broken.dat"), []byte("DBPF is too short"), 0644)
+
+  s, e := ScanFolder(dir)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  expected := []string{filepath.Join(dir, "1.dat"), filepath.Join(dir, "2.dat")}
+  if superseded, _ := s.Superseded(); len(s.Files) != 3 || strings.Join(superseded, ",") != strings.Join(expected, ",") {
+    t.Errorf("Unexpected scan of %v, superseded %v", s.Files, superseded)
+  }
+  if len(s.Broken) != 1 || s.Broken[0].Path != filepath.Join(dir, "broken.dat") {
+    t.Errorf("Expected the broken file to be left out, got %v", s.Broken)
+  }
+
+  if _, e := ScanFolder(filepath.Join(dir, "missing")); e == nil {
+    t.Error("Expected an error for a missing folder")
+  }
+}
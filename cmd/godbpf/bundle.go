@@ -0,0 +1,91 @@
+package main
+
+import (
+  "flag"
+  "fmt"
+  "os"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/refs"
+)
+
+// runBundle implements the bundle command.
+func runBundle(args []string) error {
+  flags := flag.NewFlagSet("bundle", flag.ContinueOnError)
+  from := flags.String("from", "", "plugin folder providing the dependencies")
+  output := flags.String("o", "", "name of the DBPF file to write")
+  allowMissing := flags.Bool("allow-missing", false, "write the bundle even if some dependencies are missing")
+
+  names, e := parseInterspersed(flags, args)
+  if e != nil {
+    return e
+  }
+
+  if len(names) == 0 || *output == "" {
+    return fmt.Errorf("Expected at least one file and an output file")
+  }
+
+  var roots []*godbpf.DBPF
+  for _, name := range names {
+    dbpf, e := readDBPF(name)
+    if e != nil {
+      return e
+    }
+    roots = append(roots, dbpf)
+  }
+
+  var index *refs.Index
+  if *from != "" {
+    if index, e = scanFolder(*from); e != nil {
+      return e
+    }
+  }
+
+  bundle, missing, e := refs.Bundle(index, roots...)
+  if e != nil {
+    return e
+  }
+
+  for _, d := range missing {
+    fmt.Fprintf(os.Stderr, "%s: missing\n", d)
+  }
+
+  if len(missing) > 0 && !*allowMissing {
+    return fmt.Errorf("%d missing dependencies", len(missing))
+  }
+
+  return writeDBPF(bundle, *output)
+}
+
+// scanFolder creates an Index of the named plugin folder, warning about the
+// files that are left out since they can't be read.
+func scanFolder(dir string) (*refs.Index, error) {
+  index, e := refs.ScanFolder(dir)
+  if e != nil {
+    return nil, e
+  }
+
+  for _, f := range index.Broken {
+    fmt.Fprintf(os.Stderr, "%s: skipped: %s\n", f.Path, f.Error)
+  }
+
+  return index, nil
+}
+
+// parseInterspersed parses the provided arguments with the provided FlagSet,
+// allowing flags to follow the other arguments, which are returned.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+  var rest []string
+  for {
+    if e := flags.Parse(args); e != nil {
+      return nil, e
+    }
+
+    if flags.NArg() == 0 {
+      return rest, nil
+    }
+
+    rest = append(rest, flags.Arg(0))
+    args = flags.Args()[1:]
+  }
+}
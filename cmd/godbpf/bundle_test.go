@@ -0,0 +1,76 @@
+package main
+
+import (
+  "flag"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/refs"
+)
+
+func TestParseInterspersed(t *testing.T) {
+  flags := flag.NewFlagSet("test", flag.ContinueOnError)
+  from := flags.String("from", "", "")
+  output := flags.String("o", "", "")
+
+  rest, e := parseInterspersed(flags, []string{"root.SC4Lot", "--from", "Plugins/", "other.dat", "-o", "out.dat"})
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if strings.Join(rest, " ") != "root.SC4Lot other.dat" || *from != "Plugins/" || *output != "out.dat" {
+    t.Errorf("Unexpected arguments %v, %q, %q", rest, *from, *output)
+  }
+}
+
+func TestBundleCommand(t *testing.T) {
+  dir := t.TempDir()
+
+  plugins := filepath.Join(dir, "Plugins")
+  os.Mkdir(plugins, 0755)
+
+  // The root refers to its name, which is provided by a plugin.
+  nameKey := exemplar.NewProperty(refs.USER_VISIBLE_NAME_KEY, exemplar.Uint32, uint32(0x2026960B), uint32(0x6A231EAA), uint32(0x1))
+  ex := exemplar.New()
+  ex.Properties = []*exemplar.Property{nameKey}
+  data, _ := ex.Bytes()
+  root := godbpf.New()
+  root.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x1}, data)
+  rootFile := filepath.Join(dir, "root.SC4Lot")
+  writeDBPF(root, rootFile)
+
+  out := filepath.Join(dir, "out.dat")
+  if e := runBundle([]string{rootFile, "--from", plugins, "-o", out}); e == nil || !strings.Contains(e.Error(), "1 missing") {
+    t.Errorf("Expected an error for the missing name, got %v", e)
+  }
+  if _, e := os.Stat(out); e == nil {
+    t.Error("Expected no bundle to be written")
+  }
+
+  name := godbpf.New()
+  text := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: 0x2026960B, GroupId: 0x6A231EAA, InstanceId: 0x1})
+  text.SetData([]byte{4, 0, 0, 0x10, 'N', 0, 'a', 0, 'm', 0, 'e', 0})
+  name.AddEntry(text)
+  writeDBPF(name, filepath.Join(plugins, "names.dat"))
+
+  if e := runBundle([]string{rootFile, "--from", plugins, "-o", out}); e != nil {
+    t.Fatal(e)
+  }
+
+  bundle, e := readDBPF(out)
+  if e != nil {
+    t.Fatal(e)
+  }
+  if len(bundle.Entries()) != 3 {
+    t.Errorf("Expected the root, its name and the DIR entry, got %v", bundle.Entries())
+  }
+
+  if e := runBundle([]string{rootFile}); e == nil {
+    t.Error("Expected an error without an output file")
+  }
+}
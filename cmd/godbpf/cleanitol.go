@@ -0,0 +1,82 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+
+  "github.com/marcboudreau/godbpf/cleanitol"
+)
+
+// CLEANITOL_REPORT_NAME is the name of the report written to the backup folder
+// by the cleanitol apply command.
+const CLEANITOL_REPORT_NAME = "cleanitol-report.txt"
+
+// runCleanitol implements the cleanitol command.
+func runCleanitol(args []string) error {
+  switch {
+  case len(args) == 3 && args[0] == "generate":
+    return generateCleanitol(args[1], args[2])
+  case len(args) == 4 && args[0] == "apply":
+    return applyCleanitol(args[1], args[2], args[3])
+  }
+
+  return fmt.Errorf("Expected generate <plugin folder> <list> or apply <list> <plugin folder> <backup folder>")
+}
+
+// generateCleanitol writes a Cleanitol list of the superseded files of the
+// named plugin folder to the named file.
+func generateCleanitol(pluginDir, name string) error {
+  scan, e := cleanitol.ScanFolder(pluginDir)
+  if e != nil {
+    return e
+  }
+
+  for _, f := range scan.Broken {
+    fmt.Fprintf(os.Stderr, "%s: skipped: %s\n", f.Path, f.Error)
+  }
+
+  l, skipped := scan.Generate()
+  for _, s := range skipped {
+    fmt.Fprintf(os.Stderr, "%s is superseded but shares its name with a file in use\n", s)
+  }
+
+  buf := new(bytes.Buffer)
+  if e := l.Format(buf); e != nil {
+    return e
+  }
+
+  return ioutil.WriteFile(name, buf.Bytes(), 0644)
+}
+
+// applyCleanitol moves the files named by the named Cleanitol list out of the
+// named plugin folder, and writes a report to the backup folder and to the
+// standard output.
+func applyCleanitol(name, pluginDir, backupDir string) error {
+  f, e := os.Open(name)
+  if e != nil {
+    return e
+  }
+  defer f.Close()
+
+  l, e := cleanitol.Parse(f)
+  if e != nil {
+    return e
+  }
+
+  r, e := cleanitol.Apply(l, pluginDir, backupDir)
+  if e != nil {
+    return e
+  }
+
+  buf := new(bytes.Buffer)
+  r.Format(buf)
+  os.Stdout.Write(buf.Bytes())
+
+  if e := os.MkdirAll(backupDir, 0755); e != nil {
+    return e
+  }
+  return ioutil.WriteFile(filepath.Join(backupDir, CLEANITOL_REPORT_NAME), buf.Bytes(), 0644)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func TestCleanitolCommand(t *testing.T) {
+  dir := t.TempDir()
+
+  plugins := filepath.Join(dir, "Plugins")
+  os.Mkdir(plugins, 0755)
+  for _, name := range []string{"a-old.dat", "b-new.dat"} {
+    dbpf := godbpf.New()
+    e := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x3})
+    e.SetData([]byte(name))
+    dbpf.AddEntry(e)
+    writeDBPF(dbpf, filepath.Join(plugins, name))
+  }
+
+  list := filepath.Join(dir, "cleanitol.txt")
+  if e := runCleanitol([]string{"generate", plugins, list}); e != nil {
+    t.Fatal(e)
+  }
+
+  text, _ := ioutil.ReadFile(list)
+  if !strings.Contains(string(text), "a-old.dat ; superseded by b-new.dat\r\n") {
+    t.Errorf("Unexpected list:\n%s", text)
+  }
+
+  backup := filepath.Join(dir, "Backup")
+  if e := runCleanitol([]string{"apply", list, plugins, backup}); e != nil {
+    t.Fatal(e)
+  }
+
+  if _, e := os.Stat(filepath.Join(backup, "a-old.dat")); e != nil {
+    t.Error("Expected the superseded file to be moved")
+  }
+  if report, _ := ioutil.ReadFile(filepath.Join(backup, CLEANITOL_REPORT_NAME)); !strings.HasPrefix(string(report), "Moved 1 file(s)\n") {
+    t.Errorf("Unexpected report:\n%s", report)
+  }
+
+  if e := runCleanitol([]string{"apply", list}); e == nil {
+    t.Error("Expected an error for missing arguments")
+  }
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "flag"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "time"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+)
+
+// The files written by export are named after the TGI of their entry, such as
+// 6534284A-A8FBD372-12345678.qfs.json.  The .qfs marker indicates that the
+// entry is stored compressed, and the extension indicates the format: .json
+// or .yaml for exemplars and cohorts, and .bin for the data of any other entry.
+
+// runExport implements the export command.
+func runExport(args []string) error {
+  flags := flag.NewFlagSet("export", flag.ContinueOnError)
+  format := flags.String("format", "json", "text format of exemplars: json or yaml")
+  if e := flags.Parse(args); e != nil {
+    return e
+  }
+
+  if flags.NArg() != 2 {
+    return fmt.Errorf("Expected a file and a directory")
+  }
+
+  dbpf, e := readDBPF(flags.Arg(0))
+  if e != nil {
+    return e
+  }
+
+  return exportDirectory(dbpf, flags.Arg(1), *format)
+}
+
+// runImport implements the import command.
+func runImport(args []string) error {
+  if len(args) != 2 {
+    return fmt.Errorf("Expected a directory and a file")
+  }
+
+  dbpf, e := importDirectory(args[0])
+  if e != nil {
+    return e
+  }
+
+  return writeDBPF(dbpf, args[1])
+}
+
+// readDBPF reads the named DBPF file.
+func readDBPF(name string) (*godbpf.DBPF, error) {
+  f, e := os.Open(name)
+  if e != nil {
+    return nil, e
+  }
+  defer f.Close()
+
+  return godbpf.Parse(f)
+}
+
+// writeDBPF saves the provided DBPF to the named file.
+func writeDBPF(dbpf *godbpf.DBPF, name string) error {
+  buf := new(bytes.Buffer)
+  if e := dbpf.Save(buf); e != nil {
+    return e
+  }
+
+  return ioutil.WriteFile(name, buf.Bytes(), 0644)
+}
+
+// isExemplarType checks if entries of the provided type hold exemplars.
+func isExemplarType(typeId uint32) bool {
+  return typeId == exemplar.EXEMPLAR_TYPE_ID || typeId == exemplar.COHORT_TYPE_ID
+}
+
+// exportDirectory writes each entry of the provided DBPF, except the DIR entry,
+// to a file in the named directory.  Exemplars are written in the provided text
+// format; those that can't be decoded, or whose text wouldn't import as the
+// same data, are written as binary data.
+func exportDirectory(dbpf *godbpf.DBPF, dir, format string) error {
+  if format != "json" && format != "yaml" {
+    return fmt.Errorf("Unknown format %q", format)
+  }
+
+  if e := os.MkdirAll(dir, 0755); e != nil {
+    return e
+  }
+
+  // Each entry is written as soon as it's decompressed, so that only those
+  // being written are held in memory.
+  return dbpf.DecompressEach(context.Background(), 0, func(e *entry.DBPFEntry, compressed bool) error {
+    data := e.GetData()
+    name := fmt.Sprintf("%08X-%08X-%08X", e.TGI.TypeId, e.TGI.GroupId, e.TGI.InstanceId)
+    if compressed {
+      name += ".qfs"
+    }
+
+    extension := ".bin"
+    if isExemplarType(e.TGI.TypeId) {
+      if text, err := exemplarText(data, format); err == nil && importsAs(text, format, data) {
+        data, extension = text, "." + format
+      }
+    }
+
+    return ioutil.WriteFile(filepath.Join(dir, name + extension), data, 0644)
+  })
+}
+
+// exemplarText converts a binary exemplar to the provided text format.
+func exemplarText(data []byte, format string) ([]byte, error) {
+  ex, e := exemplar.Decode(bytes.NewReader(data))
+  if e != nil {
+    return nil, e
+  }
+
+  buf := new(bytes.Buffer)
+  if format == "yaml" {
+    e = ex.EncodeYAML(buf)
+  } else {
+    e = ex.EncodeJSON(buf)
+  }
+
+  return buf.Bytes(), e
+}
+
+// importsAs checks if importing the provided exemplar text gives back the
+// provided binary data, which isn't the case when the binary exemplar holds
+// anything the text formats leave out.
+func importsAs(text []byte, format string, data []byte) bool {
+  var ex *exemplar.Exemplar
+  var e error
+  if format == "yaml" {
+    ex, e = exemplar.DecodeYAML(bytes.NewReader(text))
+  } else {
+    ex, e = exemplar.DecodeJSON(bytes.NewReader(text))
+  }
+  if e != nil {
+    return false
+  }
+
+  imported, e := ex.Bytes()
+  return e == nil && bytes.Equal(imported, data)
+}
+
+// importDirectory builds a DBPF from the files of the named directory, as
+// written by exportDirectory.  Entries are added in the order of their TGI.
+func importDirectory(dir string) (*godbpf.DBPF, error) {
+  files, e := ioutil.ReadDir(dir)
+  if e != nil {
+    return nil, e
+  }
+
+  names := make([]string, 0, len(files))
+  for _, f := range files {
+    if !f.IsDir() {
+      names = append(names, f.Name())
+    }
+  }
+  sort.Strings(names)
+
+  dbpf := godbpf.New()
+  dbpf.MajorVersion = 1
+  dbpf.IndexMajorVersion = 7
+  dbpf.CreatedDate = time.Now()
+  dbpf.ModifiedDate = dbpf.CreatedDate
+
+  for _, name := range names {
+    tgi, compressed, extension, e := parseFileName(name)
+    if e != nil {
+      return nil, e
+    }
+
+    data, e := ioutil.ReadFile(filepath.Join(dir, name))
+    if e != nil {
+      return nil, e
+    }
+
+    var ex *exemplar.Exemplar
+    switch extension {
+    case ".json":
+      ex, e = exemplar.DecodeJSON(bytes.NewReader(data))
+    case ".yaml":
+      ex, e = exemplar.DecodeYAML(bytes.NewReader(data))
+    }
+    if e != nil {
+      return nil, fmt.Errorf("%s: %v", name, e)
+    }
+
+    if ex != nil {
+      if data, e = ex.Bytes(); e != nil {
+        return nil, fmt.Errorf("%s: %v", name, e)
+      }
+    }
+
+    if compressed {
+      dbpf.AddCompressedEntry(tgi, data)
+    } else {
+      en := entry.NewEntry(tgi)
+      en.SetData(data)
+      dbpf.AddEntry(en)
+    }
+  }
+
+  return dbpf, nil
+}
+
+// parseFileName extracts the TGI, compression marker and extension from the
+// name of a file written by exportDirectory.
+func parseFileName(name string) (tgi *entry.DBPFEntryTGI, compressed bool, extension string, e error) {
+  extension = filepath.Ext(name)
+  if extension != ".json" && extension != ".yaml" && extension != ".bin" {
+    return nil, false, "", fmt.Errorf("%s: unknown extension", name)
+  }
+
+  base := strings.TrimSuffix(name, extension)
+  if strings.HasSuffix(base, ".qfs") {
+    compressed = true
+    base = strings.TrimSuffix(base, ".qfs")
+  }
+
+  tgi = new(entry.DBPFEntryTGI)
+  if _, e := fmt.Sscanf(base, "%08X-%08X-%08X", &tgi.TypeId, &tgi.GroupId, &tgi.InstanceId); e != nil || len(base) != 26 {
+    return nil, false, "", fmt.Errorf("%s: expected a name like TTTTTTTT-GGGGGGGG-IIIIIIII%s", name, extension)
+  }
+
+  return tgi, compressed, extension, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+  "bytes"
+  "io/ioutil"
+  "path/filepath"
+  "sort"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+)
+
+func sampleDBPF(t *testing.T) *godbpf.DBPF {
+  ex := exemplar.New()
+  ex.Properties = []*exemplar.Property{
+    exemplar.NewProperty(0x10, exemplar.Uint32, uint32(0x10)),
+    exemplar.NewProperty(0x20, exemplar.String, "Sample"),
+  }
+  data, e := ex.Bytes()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  dbpf := godbpf.New()
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: 0xA8FBD372, InstanceId: 0x1}, data)
+
+  raw := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: 0x856DDBAC, GroupId: 0x6A386D26, InstanceId: 0x2})
+  raw.SetData([]byte("raw bytes"))
+  dbpf.AddEntry(raw)
+
+  broken := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x3})
+  broken.SetData([]byte("EQZT1###text"))
+  dbpf.AddEntry(broken)
+
+  return dbpf
+}
+
+func listFiles(t *testing.T, dir string) []string {
+  files, e := ioutil.ReadDir(dir)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  var names []string
+  for _, f := range files {
+    names = append(names, f.Name())
+  }
+  sort.Strings(names)
+
+  return names
+}
+
+func TestExportAndImport(t *testing.T) {
+  for _, format := range []string{"json", "yaml"} {
+    dir := t.TempDir()
+
+    original := sampleDBPF(t)
+    if e := exportDirectory(original, dir, format); e != nil {
+      t.Fatal(e)
+    }
+
+    names := listFiles(t, dir)
+    expected := []string{
+      "6534284A-00000001-00000003.bin",
+      "6534284A-A8FBD372-00000001.qfs." + format,
+      "856DDBAC-6A386D26-00000002.bin",
+    }
+    if strings.Join(names, ",") != strings.Join(expected, ",") {
+      t.Fatalf("Unexpected files %v", names)
+    }
+
+    text, _ := ioutil.ReadFile(filepath.Join(dir, expected[1]))
+    if !strings.Contains(string(text), "Exemplar Name") {
+      t.Errorf("Expected property names in:\n%s", text)
+    }
+
+    imported, e := importDirectory(dir)
+    if e != nil {
+      t.Fatal(e)
+    }
+
+    for _, en := range original.Entries() {
+      if en.TGI.Equals(entry.DIR_ENTRY_TGI) {
+        continue
+      }
+
+      want, _ := original.GetUncompressedData(en.TGI)
+      got, e := imported.GetUncompressedData(en.TGI)
+      if e != nil || !bytes.Equal(want, got) {
+        t.Errorf("Entry {%s} changed: %v", en.TGI, e)
+      }
+
+      if original.IsCompressed(en.TGI) != imported.IsCompressed(en.TGI) {
+        t.Errorf("Entry {%s} changed compression", en.TGI)
+      }
+    }
+  }
+}
+
+func TestExportKeepsUnconvertibleExemplars(t *testing.T) {
+  ex := exemplar.New()
+  ex.Properties = []*exemplar.Property{exemplar.NewProperty(0x20, exemplar.String, "Caf\xe9")}
+  data, e := ex.Bytes()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  // A trailing byte is kept by the binary data, but not by the text formats.
+  trailing := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x2})
+  trailing.SetData(append(append([]byte(nil), data...), 0x0))
+
+  dbpf := godbpf.New()
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x1}, data)
+  dbpf.AddEntry(trailing)
+
+  for _, format := range []string{"json", "yaml"} {
+    dir := t.TempDir()
+
+    if e := exportDirectory(dbpf, dir, format); e != nil {
+      t.Fatal(e)
+    }
+
+    expected := []string{"6534284A-00000001-00000001.qfs.bin", "6534284A-00000001-00000002.bin"}
+    if names := listFiles(t, dir); strings.Join(names, ",") != strings.Join(expected, ",") {
+      t.Errorf("Unexpected files %v", names)
+    }
+
+    imported, e := importDirectory(dir)
+    if e != nil {
+      t.Fatal(e)
+    }
+
+    if got, e := imported.GetUncompressedData(&entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x1}); e != nil || !bytes.Equal(got, data) {
+      t.Errorf("Entry changed: %v", e)
+    }
+  }
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+  if e := exportDirectory(godbpf.New(), "unused", "xml"); e == nil {
+    t.Error("Expected an error for an unknown format")
+  }
+}
+
+func TestImportErrors(t *testing.T) {
+  for name, content := range map[string]string{
+    "notes.txt": "",
+    "6534284A-A8FBD372.bin": "",
+    "6534284A-A8FBD372-00000001.json": "{",
+  } {
+    dir := t.TempDir()
+
+    ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+    if _, e := importDirectory(dir); e == nil || !strings.Contains(e.Error(), name) {
+      t.Errorf("Expected an error naming %s, got %v", name, e)
+    }
+  }
+}
+
+func TestExportImportCommands(t *testing.T) {
+  dir := t.TempDir()
+
+  file := filepath.Join(dir, "sample.dat")
+  if e := writeDBPF(sampleDBPF(t), file); e != nil {
+    t.Fatal(e)
+  }
+
+  out := filepath.Join(dir, "out")
+  if e := runExport([]string{"-format", "yaml", file, out}); e != nil {
+    t.Fatal(e)
+  }
+
+  rebuilt := filepath.Join(dir, "rebuilt.dat")
+  if e := runImport([]string{out, rebuilt}); e != nil {
+    t.Fatal(e)
+  }
+
+  dbpf, e := readDBPF(rebuilt)
+  if e != nil {
+    t.Fatal(e)
+  }
+  if len(dbpf.FindByType(exemplar.EXEMPLAR_TYPE_ID)) != 2 {
+    t.Errorf("Unexpected rebuilt entries %v", dbpf.Entries())
+  }
+
+  if e := runExport([]string{file}); e == nil {
+    t.Error("Expected an error for missing arguments")
+  }
+  if e := runImport([]string{out}); e == nil {
+    t.Error("Expected an error for missing arguments")
+  }
+}
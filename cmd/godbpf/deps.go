@@ -0,0 +1,58 @@
+package main
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "os"
+
+  "github.com/marcboudreau/godbpf/refs"
+)
+
+// runDeps implements the deps command.
+func runDeps(args []string) error {
+  if len(args) != 1 && len(args) != 2 {
+    return fmt.Errorf("Expected a file and an optional plugin folder")
+  }
+
+  dbpf, e := readDBPF(args[0])
+  if e != nil {
+    return e
+  }
+
+  var index *refs.Index
+  if len(args) == 2 {
+    if index, e = scanFolder(args[1]); e != nil {
+      return e
+    }
+  }
+
+  w := bufio.NewWriter(os.Stdout)
+  missing := printResolutions(w, refs.Resolve(dbpf, args[0], index))
+  if e := w.Flush(); e != nil {
+    return e
+  }
+
+  if missing > 0 {
+    return fmt.Errorf("%d missing dependencies", missing)
+  }
+
+  return nil
+}
+
+// printResolutions writes a line for each of the provided resolutions to the
+// provided Writer, and returns the number of missing dependencies.
+func printResolutions(w io.Writer, resolutions []*refs.Resolution) int {
+  missing := 0
+  for _, r := range resolutions {
+    file := r.File
+    if file == "" {
+      file = "missing"
+      missing++
+    }
+
+    fmt.Fprintf(w, "%s: %s\n", r.Dependency, file)
+  }
+
+  return missing
+}
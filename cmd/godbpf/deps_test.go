@@ -0,0 +1,51 @@
+package main
+
+import (
+  "bytes"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/refs"
+)
+
+func TestPrintResolutions(t *testing.T) {
+  from := &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x2}
+  resolutions := []*refs.Resolution{
+    &refs.Resolution{Dependency: &refs.Dependency{From: from, Description: "parent cohort"}, File: "cohorts.dat"},
+    &refs.Resolution{Dependency: &refs.Dependency{From: from, Description: "material texture 0x00000001"}},
+  }
+
+  buf := new(bytes.Buffer)
+  if missing := printResolutions(buf, resolutions); missing != 1 {
+    t.Errorf("Expected 1 missing dependency, got %d", missing)
+  }
+
+  expected := "6534284A-00000001-00000002 parent cohort: cohorts.dat\n" +
+    "6534284A-00000001-00000002 material texture 0x00000001: missing\n"
+  if buf.String() != expected {
+    t.Errorf("Unexpected output:\n%s", buf)
+  }
+}
+
+func TestDepsCommand(t *testing.T) {
+  dir := t.TempDir()
+
+  file := filepath.Join(dir, "sample.dat")
+  if e := writeDBPF(sampleDBPF(t), file); e != nil {
+    t.Fatal(e)
+  }
+
+  if e := runDeps([]string{file, dir}); e != nil {
+    t.Error(e)
+  }
+
+  if e := runDeps([]string{}); e == nil {
+    t.Error("Expected an error for missing arguments")
+  }
+  if e := runDeps([]string{file, filepath.Join(dir, "missing")}); e == nil || !strings.Contains(e.Error(), "missing") {
+    t.Errorf("Expected an error for a missing folder, got %v", e)
+  }
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "os"
+
+  "github.com/marcboudreau/godbpf/cache"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// runIndex implements the index command.
+func runIndex(args []string) error {
+  if len(args) < 2 {
+    return fmt.Errorf("Expected a cache file, a plugin folder and optional TGIs")
+  }
+
+  tgis := make([]*entry.DBPFEntryTGI, len(args) - 2)
+  for i, arg := range args[2:] {
+    tgi, e := parseTGI(arg)
+    if e != nil {
+      return e
+    }
+    tgis[i] = tgi
+  }
+
+  c, e := cache.Open(args[0])
+  if e != nil {
+    return e
+  }
+
+  stats, e := c.Refresh(args[1])
+  if e != nil {
+    return e
+  }
+
+  if e := c.WriteFile(args[0]); e != nil {
+    return e
+  }
+
+  w := bufio.NewWriter(os.Stdout)
+  printIndex(w, c, stats, tgis)
+  return w.Flush()
+}
+
+// parseTGI parses a TGI written as TTTTTTTT-GGGGGGGG-IIIIIIII.
+func parseTGI(s string) (*entry.DBPFEntryTGI, error) {
+  tgi := new(entry.DBPFEntryTGI)
+  if _, e := fmt.Sscanf(s, "%08X-%08X-%08X", &tgi.TypeId, &tgi.GroupId, &tgi.InstanceId); e != nil || len(s) != 26 {
+    return nil, fmt.Errorf("%s: expected a TGI like TTTTTTTT-GGGGGGGG-IIIIIIII", s)
+  }
+
+  return tgi, nil
+}
+
+// printIndex writes the outcome of a refresh of the provided cache, followed by
+// the files holding each of the provided TGIs, in load order.
+func printIndex(w io.Writer, c *cache.Cache, stats *cache.Stats, tgis []*entry.DBPFEntryTGI) {
+  fmt.Fprintf(w, "%d files: %d added, %d updated, %d removed, %d unchanged\n",
+    len(c.Files()), stats.Added, stats.Updated, stats.Removed, stats.Unchanged)
+
+  for _, tgi := range tgis {
+    locations := c.Find(tgi)
+    if len(locations) == 0 {
+      fmt.Fprintf(w, "%s: missing\n", formatTextconvTGI(tgi))
+    }
+
+    for _, l := range locations {
+      fmt.Fprintf(w, "%s: %s\n", formatTextconvTGI(tgi), l.Path)
+    }
+  }
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+  "bytes"
+  "os"
+  "path/filepath"
+  "testing"
+
+  "github.com/marcboudreau/godbpf/cache"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func TestParseTGI(t *testing.T) {
+  tgi, e := parseTGI("6534284A-A8FBD372-00000001")
+  if e != nil || !tgi.Equals(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0xA8FBD372, InstanceId: 0x1}) {
+    t.Errorf("Unexpected TGI %v, %v", tgi, e)
+  }
+
+  for _, s := range []string{"6534284A-A8FBD372", "6534284A-A8FBD372-000000012", "zz"} {
+    if _, e := parseTGI(s); e == nil {
+      t.Errorf("Expected an error for %q", s)
+    }
+  }
+}
+
+func TestIndexCommand(t *testing.T) {
+  dir := t.TempDir()
+
+  plugins := filepath.Join(dir, "Plugins")
+  os.Mkdir(plugins, 0755)
+  file := filepath.Join(plugins, "sample.dat")
+  if e := writeDBPF(sampleDBPF(t), file); e != nil {
+    t.Fatal(e)
+  }
+
+  name := filepath.Join(dir, "index.cache")
+  if e := runIndex([]string{name, plugins, "6534284A-A8FBD372-00000001"}); e != nil {
+    t.Fatal(e)
+  }
+
+  c, e := cache.Open(name)
+  if e != nil || c.File(file) == nil {
+    t.Fatalf("Expected the cache to hold %s, got %v", file, e)
+  }
+
+  stats, e := c.Refresh(plugins)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  buf := new(bytes.Buffer)
+  tgis := []*entry.DBPFEntryTGI{
+    &entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0xA8FBD372, InstanceId: 0x1},
+    &entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0xA8FBD372, InstanceId: 0x9},
+  }
+  printIndex(buf, c, stats, tgis)
+
+  expected := "1 files: 0 added, 0 updated, 0 removed, 1 unchanged\n" +
+    "6534284A-A8FBD372-00000001: " + file + "\n" +
+    "6534284A-A8FBD372-00000009: missing\n"
+  if buf.String() != expected {
+    t.Errorf("Unexpected output:\n%s", buf)
+  }
+
+  if e := runIndex([]string{name}); e == nil {
+    t.Error("Expected an error for missing arguments")
+  }
+  if e := runIndex([]string{name, plugins, "bad"}); e == nil {
+    t.Error("Expected an error for an invalid TGI")
+  }
+}
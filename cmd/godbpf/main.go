@@ -0,0 +1,104 @@
+// Command godbpf converts and inspects Simcity 4 DBPF files.
+package main
+
+import (
+  "fmt"
+  "os"
+  "sort"
+)
+
+// command is a subcommand of the godbpf tool.
+type command struct {
+  // usage describes the arguments of the command.
+  usage string
+
+  // summary describes what the command does in a single line.
+  summary string
+
+  // run executes the command with the arguments that follow its name.
+  run func(args []string) error
+}
+
+// commands lists the subcommands by name.
+var commands = map[string]*command{
+  "bundle": &command{
+    usage: "bundle [-from <plugin folder>] [-allow-missing] -o <output> <file>...",
+    summary: "Collect the entries of DBPF files and everything they depend on into a single file",
+    run: runBundle,
+  },
+  "cleanitol": &command{
+    usage: "cleanitol generate <plugin folder> <list> | cleanitol apply <list> <plugin folder> <backup folder>",
+    summary: "Write a Cleanitol list of superseded plugins, or move the files named by a list to a backup folder",
+    run: runCleanitol,
+  },
+  "deps": &command{
+    usage: "deps <file> [plugin folder]",
+    summary: "List the resources a DBPF file refers to and the files providing them",
+    run: runDeps,
+  },
+  "export": &command{
+    usage: "export [-format json|yaml] <file> <directory>",
+    summary: "Write the entries of a DBPF file to a directory, with exemplars as text",
+    run: runExport,
+  },
+  "import": &command{
+    usage: "import <directory> <file>",
+    summary: "Build a DBPF file from a directory written by export",
+    run: runImport,
+  },
+  "index": &command{
+    usage: "index <cache file> <plugin folder> [TTTTTTTT-GGGGGGGG-IIIIIIII]...",
+    summary: "Refresh the index cache of a plugin folder and list the files holding the provided TGIs",
+    run: runIndex,
+  },
+  "unpack": &command{
+    usage: "unpack <file> <directory>",
+    summary: "Write the entries and layout of a DBPF file to a directory, so that pack rebuilds it exactly",
+    run: runUnpack,
+  },
+  "pack": &command{
+    usage: "pack <directory> <file>",
+    summary: "Rebuild a DBPF file from a directory written by unpack",
+    run: runPack,
+  },
+  "textconv": &command{
+    usage: "textconv <file>",
+    summary: "Print a readable description of a DBPF file, for use as a git diff driver",
+    run: runTextconv,
+  },
+}
+
+func main() {
+  if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" {
+    usage()
+    return
+  }
+
+  cmd, found := commands[os.Args[1]]
+  if !found {
+    fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+    usage()
+    os.Exit(2)
+  }
+
+  if e := cmd.run(os.Args[2:]); e != nil {
+    fmt.Fprintf(os.Stderr, "godbpf %s: %v\n", os.Args[1], e)
+    os.Exit(1)
+  }
+}
+
+// usage prints the list of commands.
+func usage() {
+  names := make([]string, 0, len(commands))
+  for name := range commands {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  fmt.Fprintln(os.Stderr, "Usage: godbpf <command> [arguments]")
+  fmt.Fprintln(os.Stderr)
+  fmt.Fprintln(os.Stderr, "Commands:")
+  for _, name := range names {
+    fmt.Fprintf(os.Stderr, "  %s\n      %s\n", commands[name].usage, commands[name].summary)
+  }
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+  "fmt"
+  "io/ioutil"
+
+  "github.com/marcboudreau/godbpf/pack"
+)
+
+// runUnpack implements the unpack command.
+func runUnpack(args []string) error {
+  if len(args) != 2 {
+    return fmt.Errorf("Expected a file and a directory")
+  }
+
+  data, e := ioutil.ReadFile(args[0])
+  if e != nil {
+    return e
+  }
+
+  return pack.Unpack(data, args[1])
+}
+
+// runPack implements the pack command.
+func runPack(args []string) error {
+  if len(args) != 2 {
+    return fmt.Errorf("Expected a directory and a file")
+  }
+
+  data, e := pack.Pack(args[0])
+  if e != nil {
+    return e
+  }
+
+  return ioutil.WriteFile(args[1], data, 0644)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+  "bytes"
+  "io/ioutil"
+  "path/filepath"
+  "testing"
+)
+
+func TestUnpackPackCommands(t *testing.T) {
+  dir := t.TempDir()
+
+  file := filepath.Join(dir, "sample.dat")
+  if e := writeDBPF(sampleDBPF(t), file); e != nil {
+    t.Fatal(e)
+  }
+
+  out := filepath.Join(dir, "out")
+  if e := runUnpack([]string{file, out}); e != nil {
+    t.Fatal(e)
+  }
+
+  rebuilt := filepath.Join(dir, "rebuilt.dat")
+  if e := runPack([]string{out, rebuilt}); e != nil {
+    t.Fatal(e)
+  }
+
+  original, _ := ioutil.ReadFile(file)
+  packed, _ := ioutil.ReadFile(rebuilt)
+  if !bytes.Equal(original, packed) {
+    t.Error("Expected pack to rebuild the unpacked file exactly")
+  }
+
+  if e := runUnpack([]string{file}); e == nil {
+    t.Error("Expected an error for missing arguments")
+  }
+  if e := runPack([]string{out}); e == nil {
+    t.Error("Expected an error for missing arguments")
+  }
+  if e := runUnpack([]string{filepath.Join(dir, "missing.dat"), out}); e == nil {
+    t.Error("Expected an error for a missing file")
+  }
+}
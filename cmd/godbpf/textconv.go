@@ -0,0 +1,167 @@
+package main
+
+import (
+  "bufio"
+  "bytes"
+  "crypto/sha1"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strings"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/fsh"
+  "github.com/marcboudreau/godbpf/ltext"
+  "github.com/marcboudreau/godbpf/rul"
+)
+
+// TEXTCONV_INDENT prefixes the lines describing the content of an entry.
+const TEXTCONV_INDENT = "    "
+
+// runTextconv implements the textconv command.
+func runTextconv(args []string) error {
+  if len(args) != 1 {
+    return fmt.Errorf("Expected a file")
+  }
+
+  dbpf, e := readDBPF(args[0])
+  if e != nil {
+    return e
+  }
+
+  w := bufio.NewWriter(os.Stdout)
+  if e := textconv(dbpf, w); e != nil {
+    return e
+  }
+
+  return w.Flush()
+}
+
+// textconv writes a readable description of the provided DBPF to the provided
+// Writer.  The description only depends on the content of the entries, so the
+// timestamps, the order of the entries and the compressed bytes of compressed
+// entries don't show up as differences.  Entries are listed by TGI, each with its size and a
+// hash of its uncompressed data, followed by its decoded content.
+func textconv(dbpf *godbpf.DBPF, w io.Writer) error {
+  entries := dbpf.Entries()
+  sort.SliceStable(entries, func(i, j int) bool { return entries[i].TGI.Less(entries[j].TGI) })
+
+  fmt.Fprintf(w, "DBPF %d.%d, index %d.%d, %d entries\n", dbpf.MajorVersion, dbpf.MinorVersion, dbpf.IndexMajorVersion, dbpf.IndexMinorVersion, len(entries))
+
+  for _, e := range entries {
+    if e.TGI.Equals(entry.DIR_ENTRY_TGI) {
+      continue
+    }
+
+    data, compressed := e.GetData(), dbpf.IsCompressed(e.TGI)
+    if compressed {
+      uncompressed, err := godbpf.Decompress(data)
+      if err != nil {
+        fmt.Fprintf(w, "\n%s compressed, %d bytes, sha1 %x\n", formatTextconvTGI(e.TGI), len(data), sha1.Sum(data))
+        fmt.Fprintf(w, "%scan't be decompressed: %v\n", TEXTCONV_INDENT, err)
+        continue
+      }
+      data = uncompressed
+    }
+
+    kind, lines := describeEntry(e.TGI, data)
+    if compressed {
+      kind += ", compressed"
+    }
+
+    fmt.Fprintf(w, "\n%s %s, %d bytes, sha1 %x\n", formatTextconvTGI(e.TGI), kind, len(data), sha1.Sum(data))
+    for _, line := range lines {
+      fmt.Fprintf(w, "%s%s\n", TEXTCONV_INDENT, line)
+    }
+  }
+
+  return nil
+}
+
+// formatTextconvTGI returns the TGI of an entry as written by textconv.
+func formatTextconvTGI(tgi *entry.DBPFEntryTGI) string {
+  return fmt.Sprintf("%08X-%08X-%08X", tgi.TypeId, tgi.GroupId, tgi.InstanceId)
+}
+
+// describeEntry returns the kind of an entry and the lines describing its
+// uncompressed data.  Entries that aren't text are summarized by their first
+// bytes.
+func describeEntry(tgi *entry.DBPFEntryTGI, data []byte) (string, []string) {
+  switch tgi.TypeId {
+  case exemplar.EXEMPLAR_TYPE_ID, exemplar.COHORT_TYPE_ID:
+    kind := "exemplar"
+    if tgi.TypeId == exemplar.COHORT_TYPE_ID {
+      kind = "cohort"
+    }
+
+    text, e := exemplarText(data, "yaml")
+    if e != nil {
+      return kind, append([]string{fmt.Sprintf("can't be decoded: %v", e)}, summarize(data)...)
+    }
+    return kind, splitLines(string(text))
+  case ltext.LTEXT_TYPE_ID:
+    text, e := ltext.Decode(data)
+    if e != nil {
+      return "LTEXT", append([]string{fmt.Sprintf("can't be decoded: %v", e)}, summarize(data)...)
+    }
+    return "LTEXT", []string{fmt.Sprintf("%q", text)}
+  case rul.RUL_TYPE_ID:
+    return "RUL", splitLines(string(data))
+  case fsh.FSH_TYPE_ID:
+    return "FSH image", summarize(data)
+  }
+
+  if format := godbpf.DetectImageFormat(data); format != godbpf.UnknownImageFormat {
+    kind := format.String() + " image"
+    if img, _, e := godbpf.DecodeImage(data); e == nil {
+      kind += fmt.Sprintf(" %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+    }
+    return kind, nil
+  }
+
+  return "binary", summarize(data)
+}
+
+// splitLines splits text into lines, ignoring the difference between line
+// endings and a missing final line ending.
+func splitLines(text string) []string {
+  text = strings.Replace(text, "\r\n", "\n", -1)
+  text = strings.TrimSuffix(text, "\n")
+  if text == "" {
+    return nil
+  }
+
+  return strings.Split(text, "\n")
+}
+
+// summarize returns a line showing the first bytes of binary data.
+func summarize(data []byte) []string {
+  if len(data) == 0 {
+    return nil
+  }
+
+  const shown = 16
+  head := data
+  if len(head) > shown {
+    head = head[:shown]
+  }
+
+  line := fmt.Sprintf("% X", head)
+  if len(data) > shown {
+    line += " ..."
+  }
+
+  // Show the text that binary formats often start with, such as a signature.
+  end := bytes.IndexFunc(head, func(r rune) bool { return r < 0x20 || r > 0x7E })
+  if end < 0 {
+    end = len(head)
+  }
+  if end >= 4 {
+    line += fmt.Sprintf("  %q", head[:end])
+  }
+
+  return []string{line}
+}
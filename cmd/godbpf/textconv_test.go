@@ -0,0 +1,91 @@
+package main
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/ltext"
+  "github.com/marcboudreau/godbpf/rul"
+)
+
+func textconvString(t *testing.T, dbpf *godbpf.DBPF) string {
+  buf := new(bytes.Buffer)
+  if e := textconv(dbpf, buf); e != nil {
+    t.Fatal(e)
+  }
+
+  return buf.String()
+}
+
+func TestTextconv(t *testing.T) {
+  dbpf := sampleDBPF(t)
+  ltext.Write(dbpf, &entry.DBPFEntryTGI{TypeId: ltext.LTEXT_TYPE_ID, GroupId: 0x6A231EAA, InstanceId: 0x4}, "Sample name")
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: rul.RUL_TYPE_ID, GroupId: 0x1, InstanceId: 0x5}, []byte("[Section]\r\nKey = Value\r\n"))
+
+  text := textconvString(t, dbpf)
+
+  for _, expected := range []string{
+    "DBPF 0.0, index 0.0, 6 entries\n",
+    "\n0A5BCF4B-00000001-00000005 RUL, compressed, 24 bytes, sha1 ",
+    "\n    [Section]\n    Key = Value\n",
+    "\n2026960B-6A231EAA-00000004 LTEXT, compressed, 26 bytes, sha1 ",
+    "\n    \"Sample name\"\n",
+    "\n6534284A-00000001-00000003 exemplar, 12 bytes, sha1 ",
+    "    can't be decoded: Text exemplars are not supported\n    45 51 5A 54 31 23 23 23 74 65 78 74  \"EQZT1###text\"\n",
+    "\n6534284A-A8FBD372-00000001 exemplar, compressed, ",
+    "    properties:\n",
+    "\n856DDBAC-6A386D26-00000002 binary, 9 bytes, sha1 ",
+  } {
+    if !strings.Contains(text, expected) {
+      t.Errorf("Expected %q in:\n%s", expected, text)
+    }
+  }
+
+  if strings.Index(text, "0A5BCF4B") > strings.Index(text, "2026960B") || strings.Index(text, "2026960B") > strings.Index(text, "6534284A") {
+    t.Errorf("Expected the entries to be sorted by TGI:\n%s", text)
+  }
+
+  if strings.Contains(text, "E86B1EEF") {
+    t.Errorf("Expected the DIR entry to be left out:\n%s", text)
+  }
+}
+
+func TestTextconvIgnoresOrder(t *testing.T) {
+  first, second := godbpf.New(), godbpf.New()
+  a := &entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x3}
+  b := &entry.DBPFEntryTGI{TypeId: 0x4, GroupId: 0x5, InstanceId: 0x6}
+
+  first.AddCompressedEntry(a, []byte("first entry"))
+  plain := entry.NewEntry(b)
+  plain.SetData([]byte("second entry"))
+  first.AddEntry(plain)
+
+  second.AddEntry(plain)
+  second.AddCompressedEntry(a, []byte("first entry"))
+
+  if textconvString(t, first) != textconvString(t, second) {
+    t.Errorf("Expected the same description:\n%s\n%s", textconvString(t, first), textconvString(t, second))
+  }
+}
+
+func TestSummarize(t *testing.T) {
+  for data, expected := range map[string]string{
+    "": "",
+    "\x01\x02": "01 02",
+    "PNG\x00": "50 4E 47 00",
+    "0123456789ABCDEFG": "30 31 32 33 34 35 36 37 38 39 41 42 43 44 45 46 ...  \"0123456789ABCDEF\"",
+  } {
+    if actual := strings.Join(summarize([]byte(data)), ""); actual != expected {
+      t.Errorf("Expected %q for %q, got %q", expected, data, actual)
+    }
+  }
+}
+
+func TestTextconvCommand(t *testing.T) {
+  if e := runTextconv(nil); e == nil {
+    t.Error("Expected an error for missing arguments")
+  }
+}
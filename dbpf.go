@@ -2,18 +2,36 @@ package godbpf
 
 import (
     "io"
+    "io/ioutil"
     "time"
     "encoding/binary"
     "errors"
+    "fmt"
     "bytes"
     "container/list"
+    "sync"
     
     "github.com/marcboudreau/godbpf/entry"
     "github.com/marcboudreau/godbpf/qfs"
     "github.com/marcboudreau/godbpf/util"
 )
 
+// HEADER_SIZE is the size of the header that starts a DBPF file.
+const HEADER_SIZE = 96
+
+// INDEX_ENTRY_SIZE is the size of each record of a version 7.0 index: the TGI,
+// location and size of an entry.
+const INDEX_ENTRY_SIZE = 20
+
 // DBPF is a structure that encompasses all of the contents of a DBPF file.
+//
+// The methods of a DBPF can be called from several goroutines at once: those
+// that read its entries share a read lock, and those that add or change
+// entries take the write lock.  This only covers the DBPF itself.  The header
+// fields should be set before the DBPF is shared, and the entries returned by
+// Find, FindByType, Entries and GetDirEntry must not be read while another
+// goroutine changes them, such as with SetUncompressedData.  Code reading
+// entries while others write should work on a Snapshot instead.
 type DBPF struct {
   // The major version of the DBPF schema used.
   MajorVersion uint32
@@ -21,6 +39,12 @@ type DBPF struct {
   // The minor version of the DBPF schema used.
   MinorVersion uint32
 
+  // The user version and flags, which aren't used by the game but are kept so
+  // that they are saved unchanged.
+  UserMajorVersion uint32
+  UserMinorVersion uint32
+  Flags uint32
+
   // The major version of the index schema used in this DBPF instance.
   IndexMajorVersion uint32
 
@@ -36,6 +60,10 @@ type DBPF struct {
   // entries points to a list of DBPFEntry (exluding the DBPFDirEntry) if one
   // was created), contained in the DBPF instance.
   entries *list.List
+
+  // mutex guards entries, and the data of the DIR entry, which is the only
+  // data changed in place.
+  mutex sync.RWMutex
 }
 
 // New creates a new DBPF instance.
@@ -48,25 +76,38 @@ func New() *DBPF {
 
 // Len returns the number of entries in this DBPF instance.
 func (dbpf *DBPF) Len() uint32 {
+  dbpf.mutex.RLock()
+  defer dbpf.mutex.RUnlock()
+
   return uint32(dbpf.entries.Len())
 }
 
-// Parse creates a DBPF from the data read from the provided reader.
+// Parse creates a DBPF from the data read from the provided reader.  The index
+// may be found anywhere in the data, and entries are kept in the order they
+// appear in the index.
 func Parse(r io.Reader) (*DBPF, error) {
+  data, e := ioutil.ReadAll(r)
+  if e != nil {
+    return nil, e
+  }
+
+  if len(data) < HEADER_SIZE {
+    return nil, errors.New("DBPF header is truncated")
+  }
+
   dbpf := New()
-  count, offset, _ := parseHeader(r, dbpf)
-
-  contentBuf := make([]byte, offset)
-  if offset > 96 {
-    // Start reading in bytes at position 96 to account for the header data, that
-    // way all of the offset values read in the file will align in this slice as
-    // well.
-    if _, e := r.Read(contentBuf[96:]); e != nil {
-      return nil, e
-    }
+  count, offset, e := parseHeader(bytes.NewReader(data), dbpf)
+  if e != nil {
+    return nil, e
   }
 
-  dbpf.parseEntries(r, offset, count, contentBuf)
+  if uint64(offset) + uint64(count) * INDEX_ENTRY_SIZE > uint64(len(data)) {
+    return nil, fmt.Errorf("Index of %d entries at offset %d extends past the end of the data", count, offset)
+  }
+
+  if e := dbpf.parseEntries(bytes.NewReader(data[offset:]), count, data); e != nil {
+    return nil, e
+  }
 
   return dbpf, nil
 }
@@ -75,7 +116,7 @@ func Parse(r io.Reader) (*DBPF, error) {
 // in the provided DBPF instance.
 func parseHeader(r io.Reader, dbpf *DBPF) (count, offset uint32, e error) {
   magic := make([]byte, 4)
-  if _, e := r.Read(magic); e != nil {
+  if _, e := io.ReadFull(r, magic); e != nil {
     return 0, 0, e
   }
 
@@ -85,8 +126,9 @@ func parseHeader(r io.Reader, dbpf *DBPF) (count, offset uint32, e error) {
 
   binary.Read(r, binary.LittleEndian, &dbpf.MajorVersion)
   binary.Read(r, binary.LittleEndian, &dbpf.MinorVersion)
-
-  r.Read(make([]byte, 12))
+  binary.Read(r, binary.LittleEndian, &dbpf.UserMajorVersion)
+  binary.Read(r, binary.LittleEndian, &dbpf.UserMinorVersion)
+  binary.Read(r, binary.LittleEndian, &dbpf.Flags)
 
   var timestamp uint32
   binary.Read(r, binary.LittleEndian, &timestamp)
@@ -98,18 +140,22 @@ func parseHeader(r io.Reader, dbpf *DBPF) (count, offset uint32, e error) {
   binary.Read(r, binary.LittleEndian, &count)
   binary.Read(r, binary.LittleEndian, &offset)
 
-  // Gobble up the index size and 3 other  unused uint32 values
-  r.Read(make([]byte, 16))
+  // Gobble up the index size and the 3 hole index values
+  io.ReadFull(r, make([]byte, 16))
 
   binary.Read(r, binary.LittleEndian, &dbpf.IndexMinorVersion)
 
-  // Gobble up 10 unused uint32 values
-  r.Read(make([]byte, 40))
+  // Gobble up 8 unused uint32 values
+  if _, e := io.ReadFull(r, make([]byte, 32)); e != nil {
+    return 0, 0, e
+  }
 
   return count, offset, nil
 }
 
-func (dbpf *DBPF) parseEntries(r io.Reader, indexOffset, indexCount uint32, content []byte) {
+// parseEntries reads indexCount index records from the provided Reader and
+// creates an entry for each of them, holding its part of the provided content.
+func (dbpf *DBPF) parseEntries(r io.Reader, indexCount uint32, content []byte) error {
   for i := 0; i < int(indexCount); i++ {
     var typeId, groupId, instanceId, location, size uint32
 
@@ -120,40 +166,59 @@ func (dbpf *DBPF) parseEntries(r io.Reader, indexOffset, indexCount uint32, cont
     binary.Read(r, binary.LittleEndian, &size)
 
     entry := &entry.DBPFEntry{TGI: &entry.DBPFEntryTGI{TypeId: typeId, GroupId: groupId, InstanceId: instanceId}}
+    if uint64(location) + uint64(size) > uint64(len(content)) {
+      return fmt.Errorf("Entry {%s} at offset %d extends past the end of the data", entry.TGI, location)
+    }
     entry.SetData(content[location:location + size])
 
     dbpf.entries.PushBack(entry)
   }
+
+  return nil
 }
 
+// Save writes the receiver to the provided Writer: the header, followed by the
+// data of each entry and the index.
 func (dbpf *DBPF) Save(w io.Writer) error {
+  dbpf.mutex.RLock()
+  defer dbpf.mutex.RUnlock()
+
+  contentBuf := dbpf.encodeContent()
+  dbpf.encodeHeader(w, uint32(dbpf.entries.Len()), uint32(contentBuf.Len() + HEADER_SIZE))
+
+  // Write the file entries
+  contentBuf.WriteTo(w)
+
+  // // Write the index table
+  dbpf.encodeIndex(w)
+
+  return nil
+}
+
+// encodeHeader writes the header fields of the receiver to the provided
+// Writer, for an index of count entries found at the provided offset.
+func (dbpf *DBPF) encodeHeader(w io.Writer, count, indexOffset uint32) {
   w.Write([]byte("DBPF"))
 
   binary.Write(w, binary.LittleEndian, dbpf.MajorVersion)
   binary.Write(w, binary.LittleEndian, dbpf.MinorVersion)
-
-  w.Write(make([]byte, 12))
+  binary.Write(w, binary.LittleEndian, dbpf.UserMajorVersion)
+  binary.Write(w, binary.LittleEndian, dbpf.UserMinorVersion)
+  binary.Write(w, binary.LittleEndian, dbpf.Flags)
 
   binary.Write(w, binary.LittleEndian, uint32(dbpf.CreatedDate.Unix()))
   binary.Write(w, binary.LittleEndian, uint32(dbpf.ModifiedDate.Unix()))
 
   binary.Write(w, binary.LittleEndian, dbpf.IndexMajorVersion)
-  binary.Write(w, binary.LittleEndian, dbpf.Len())
-
-  contentBuf := dbpf.encodeContent()
-
-  binary.Write(w, binary.LittleEndian, uint32(contentBuf.Len() + 96))
-  binary.Write(w, binary.LittleEndian, uint32(20 * dbpf.Len()))
-
-  w.Write(make([]byte, 48))
-
-  // Write the file entries
-  contentBuf.WriteTo(w)
+  binary.Write(w, binary.LittleEndian, count)
+  binary.Write(w, binary.LittleEndian, indexOffset)
+  binary.Write(w, binary.LittleEndian, uint32(INDEX_ENTRY_SIZE * count))
 
-  // // Write the index table
-  dbpf.encodeIndex(w)
+  // There is no hole index, since entries are written back to back.
+  w.Write(make([]byte, 12))
 
-  return nil
+  binary.Write(w, binary.LittleEndian, dbpf.IndexMinorVersion)
+  w.Write(make([]byte, 32))
 }
 
 func (dbpf *DBPF) encodeContent() *bytes.Buffer {
@@ -171,7 +236,7 @@ func (dbpf *DBPF) encodeContent() *bytes.Buffer {
 // encodeIndex encodes the index entries and writes the binary data to the
 // provided io.Writer.
 func (dbpf *DBPF) encodeIndex(w io.Writer) {
-  location := uint32(96)
+  location := uint32(HEADER_SIZE)
   for elem := dbpf.entries.Front(); elem != nil; elem = elem.Next() {
     if entry, ok := elem.Value.(*entry.DBPFEntry); ok {
       binary.Write(w, binary.LittleEndian, entry.TGI.TypeId)
@@ -188,39 +253,103 @@ func (dbpf *DBPF) encodeIndex(w io.Writer) {
 // AddEntry adds the provided DBPFEntry instance to the DBPF instance and creates
 // an entry in the DBPFIndex instance as well.
 func (dbpf *DBPF) AddEntry(e *entry.DBPFEntry) {
+  dbpf.mutex.Lock()
+  defer dbpf.mutex.Unlock()
+
   dbpf.entries.PushBack(e)
 }
 
+// AddCompressedEntry compresses the provided data and adds it to the receiver
+// as an entry with the provided TGI, recording its uncompressed size in the
+// DIR entry.
 func (dbpf *DBPF) AddCompressedEntry(tgi *entry.DBPFEntryTGI, uncompressedData []byte) {
   entry := &entry.DBPFEntry{TGI: tgi}
+  entry.SetData(Compress(uncompressedData))
+
+  dbpf.mutex.Lock()
+  defer dbpf.mutex.Unlock()
+
+  dbpf.entries.PushBack(entry)
+
+  dirEntry := dbpf.getDirEntry()
+  dirEntry.AddEntry(tgi, uint32(len(uncompressedData)))
+}
+
+// Compress encodes the provided data and prefixes it with the size of the
+// compressed stream, as it is stored in a compressed entry.
+func Compress(uncompressedData []byte) []byte {
   buf := new(bytes.Buffer)
   qfs.Encode(buf, uncompressedData)
 
   data := make([]byte, 4 + buf.Len())
   copy(data[0:4], util.WriteUint32(uint32(buf.Len())))
   copy(data[4:], buf.Bytes())
-  entry.SetData(data)
 
-  dbpf.entries.PushBack(entry)
-
-  dirEntry := dbpf.GetDirEntry()
-  dirEntry.AddEntry(tgi, uint32(len(uncompressedData)))
+  return data
 }
 
 // GetDirEntry locates and returns the DIR entry in the receiver.  If there isn't
 // one, then one is created.
 func (dbpf *DBPF) GetDirEntry() *entry.DBPFEntry {
-  dirEntry := dbpf.Find(entry.DIR_ENTRY_TGI)
+  dbpf.mutex.Lock()
+  defer dbpf.mutex.Unlock()
+
+  return dbpf.getDirEntry()
+}
+
+// getDirEntry implements GetDirEntry for callers holding the write lock.
+func (dbpf *DBPF) getDirEntry() *entry.DBPFEntry {
+  dirEntry := dbpf.find(entry.DIR_ENTRY_TGI)
   if dirEntry == nil {
     dirEntry = entry.CreateDirEntry()
-    dbpf.AddEntry(dirEntry)
+    dbpf.entries.PushBack(dirEntry)
   }
 
   return dirEntry
 }
 
+// Entries returns the entries of the receiver, including the DIR entry, in
+// the order they are stored.
+func (dbpf *DBPF) Entries() []*entry.DBPFEntry {
+  dbpf.mutex.RLock()
+  defer dbpf.mutex.RUnlock()
+
+  result := make([]*entry.DBPFEntry, 0, dbpf.entries.Len())
+  for elem := dbpf.entries.Front(); elem != nil; elem = elem.Next() {
+    if entry, ok := elem.Value.(*entry.DBPFEntry); ok {
+      result = append(result, entry)
+    }
+  }
+
+  return result
+}
+
+// FindByType returns the entries of the receiver that have the provided TypeId,
+// in the order they are stored.
+func (dbpf *DBPF) FindByType(typeId uint32) []*entry.DBPFEntry {
+  dbpf.mutex.RLock()
+  defer dbpf.mutex.RUnlock()
+
+  var result []*entry.DBPFEntry
+  for elem := dbpf.entries.Front(); elem != nil; elem = elem.Next() {
+    if entry, ok := elem.Value.(*entry.DBPFEntry); ok && entry.TGI.TypeId == typeId {
+      result = append(result, entry)
+    }
+  }
+
+  return result
+}
+
 // Find searches the index and returns the related DBPFEntry instance.
 func (dbpf *DBPF) Find(tgi *entry.DBPFEntryTGI) *entry.DBPFEntry {
+  dbpf.mutex.RLock()
+  defer dbpf.mutex.RUnlock()
+
+  return dbpf.find(tgi)
+}
+
+// find implements Find for callers holding a lock.
+func (dbpf *DBPF) find(tgi *entry.DBPFEntryTGI) *entry.DBPFEntry {
   for elem := dbpf.entries.Front(); elem != nil; elem = elem.Next() {
     if entry, ok := elem.Value.(*entry.DBPFEntry); ok {
       if entry.TGI.Equals(tgi) {
@@ -231,3 +360,86 @@ func (dbpf *DBPF) Find(tgi *entry.DBPFEntryTGI) *entry.DBPFEntry {
 
   return nil
 }
+
+// IsCompressed indicates whether the entry identified by the provided
+// DBPFEntryTGI is compressed, which is the case when the DIR entry holds a
+// record for it.
+func (dbpf *DBPF) IsCompressed(tgi *entry.DBPFEntryTGI) bool {
+  dbpf.mutex.RLock()
+  defer dbpf.mutex.RUnlock()
+
+  return dbpf.isCompressed(tgi)
+}
+
+// isCompressed implements IsCompressed for callers holding a lock.
+func (dbpf *DBPF) isCompressed(tgi *entry.DBPFEntryTGI) bool {
+  dirEntry := dbpf.find(entry.DIR_ENTRY_TGI)
+  if dirEntry == nil {
+    return false
+  }
+
+  _, found := dirEntry.GetUncompressedSize(tgi)
+  return found
+}
+
+// GetUncompressedData locates the entry identified by the provided DBPFEntryTGI
+// and returns its data, decompressing it first if needed.
+func (dbpf *DBPF) GetUncompressedData(tgi *entry.DBPFEntryTGI) ([]byte, error) {
+  dbpf.mutex.RLock()
+  e := dbpf.find(tgi)
+  if e == nil {
+    dbpf.mutex.RUnlock()
+    return nil, fmt.Errorf("No entry found with TGI {%s}", tgi)
+  }
+
+  stored, compressed := e.GetData(), dbpf.isCompressed(tgi)
+  dbpf.mutex.RUnlock()
+
+  // The data of an entry is replaced rather than changed in place, so it can
+  // be decompressed without holding the lock.
+  if !compressed {
+    return stored, nil
+  }
+
+  data, err := Decompress(stored)
+  if err != nil {
+    return nil, fmt.Errorf("Compressed entry {%s}: %v", tgi, err)
+  }
+
+  return data, nil
+}
+
+// Decompress decodes the data of a compressed entry, which is the reverse of
+// Compress.
+func Decompress(data []byte) ([]byte, error) {
+  if len(data) < 4 {
+    return nil, errors.New("Compressed data is too short")
+  }
+
+  // Skip over the compressed size that precedes the compressed stream.
+  return qfs.Decode(bytes.NewReader(data[4:]))
+}
+
+// SetUncompressedData replaces the data of the entry identified by the provided
+// DBPFEntryTGI.  If the entry is compressed, the new data is compressed as well
+// and the DIR entry is updated with its uncompressed size.  The entry keeps its
+// position in the receiver.
+func (dbpf *DBPF) SetUncompressedData(tgi *entry.DBPFEntryTGI, data []byte) error {
+  dbpf.mutex.Lock()
+  defer dbpf.mutex.Unlock()
+
+  e := dbpf.find(tgi)
+  if e == nil {
+    return fmt.Errorf("No entry found with TGI {%s}", tgi)
+  }
+
+  if !dbpf.isCompressed(tgi) {
+    e.SetData(data)
+    return nil
+  }
+
+  e.SetData(Compress(data))
+  dbpf.find(entry.DIR_ENTRY_TGI).SetUncompressedSize(tgi, uint32(len(data)))
+
+  return nil
+}
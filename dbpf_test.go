@@ -134,6 +134,54 @@ func TestParseDBPFWithDifferentModifiedDate(t *testing.T) {
   }
 }
 
+func TestParseDBPFWithEntry(t *testing.T) {
+  data := []byte{'D', 'B', 'P', 'F',
+                 1, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 224, 125, 223, 86,
+                 224, 125, 223, 86,
+                 7, 0, 0, 0,
+                 1, 0, 0, 0,
+                 100, 0, 0, 0,
+                 20, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 2, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 0, 0, 0, 0,
+                 'A', 'B', 'C', 'D',
+                 0x44, 0x0e, 0xb5, 0x7a,
+                 0x5e, 0x13, 0x86, 0x09,
+                 0x00, 0x40, 0xff, 0xff,
+                 96, 0, 0, 0,
+                 4, 0, 0, 0 }
+
+  // The header is 96 bytes long, so the data of the entry follows it directly.
+  dbpf, err := Parse(bytes.NewBuffer(data))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if dbpf.IndexMajorVersion != 7 || dbpf.IndexMinorVersion != 2 {
+    t.Errorf("Unexpected index version %d.%d", dbpf.IndexMajorVersion, dbpf.IndexMinorVersion)
+  }
+
+  tgi := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff4000 }
+  if actual := dbpf.Find(tgi); actual == nil || string(actual.GetData()) != "ABCD" {
+    t.Errorf("Unexpected entry %v", actual)
+  }
+}
+
 // func TestCreateNewDBPFWithAnEntry(t *testing.T) {
 //   dbpf := New()
 //   data := []byte{ 0xA5, 0x5A, 0xA5, 0x5A,
@@ -327,3 +375,225 @@ func TestSaveSingleEntryDBPF(t *testing.T) {
     }
   }
 }
+
+func TestGetUncompressedDataOfCompressedEntry(t *testing.T) {
+  dbpf := New()
+  tgi := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff4000 }
+  data := []byte{ 0x01, 0x02, 0x03, 0x04, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05, 0x06 }
+
+  dbpf.AddCompressedEntry(tgi, data)
+
+  if !dbpf.IsCompressed(tgi) {
+    t.Error()
+  }
+
+  actual, e := dbpf.GetUncompressedData(tgi)
+  if e != nil {
+    t.Error(e)
+  }
+
+  if !bytes.Equal(actual, data) {
+    t.Errorf("Expected %v but was %v", data, actual)
+  }
+}
+
+func TestGetUncompressedDataOfPlainEntry(t *testing.T) {
+  dbpf := New()
+  tgi := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff4000 }
+  data := []byte{ 0xAA, 0x55 }
+
+  e := entry.NewEntry(tgi)
+  e.SetData(data)
+  dbpf.AddEntry(e)
+
+  if dbpf.IsCompressed(tgi) {
+    t.Error()
+  }
+
+  if actual, err := dbpf.GetUncompressedData(tgi); err != nil || !bytes.Equal(actual, data) {
+    t.Error()
+  }
+
+  if _, err := dbpf.GetUncompressedData(entry.DIR_ENTRY_TGI); err == nil {
+    t.Error()
+  }
+}
+
+func TestSaveAndParseRoundTrip(t *testing.T) {
+  dbpf := New()
+  dbpf.MajorVersion = 1
+  dbpf.IndexMajorVersion = 7
+  tgi1 := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff4000 }
+  tgi2 := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff4005 }
+
+  e := entry.NewEntry(tgi1)
+  e.SetData([]byte{ 0x01, 0x02, 0x03 })
+  dbpf.AddEntry(e)
+  dbpf.AddCompressedEntry(tgi2, []byte{ 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA })
+
+  buffer := new(bytes.Buffer)
+  dbpf.Save(buffer)
+
+  parsed, err := Parse(buffer)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if parsed.Len() != 3 {
+    t.Errorf("Expected 3 entries but found %d", parsed.Len())
+  }
+
+  if actual := parsed.Find(tgi1); actual == nil || !bytes.Equal(actual.GetData(), []byte{ 0x01, 0x02, 0x03 }) {
+    t.Error()
+  }
+
+  if actual, err := parsed.GetUncompressedData(tgi2); err != nil || len(actual) != 6 {
+    t.Error()
+  }
+}
+
+func TestSetUncompressedData(t *testing.T) {
+  dbpf := New()
+  tgi1 := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff4000 }
+  tgi2 := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff4005 }
+  missing := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff400A }
+
+  e := entry.NewEntry(tgi1)
+  e.SetData([]byte{ 0x01 })
+  dbpf.AddEntry(e)
+  dbpf.AddCompressedEntry(tgi2, []byte{ 0x02 })
+
+  if err := dbpf.SetUncompressedData(tgi1, []byte{ 0x03, 0x04 }); err != nil {
+    t.Error(err)
+  }
+
+  if err := dbpf.SetUncompressedData(tgi2, []byte{ 0x05, 0x05, 0x05, 0x05, 0x05 }); err != nil {
+    t.Error(err)
+  }
+
+  if err := dbpf.SetUncompressedData(missing, []byte{ 0x06 }); err == nil {
+    t.Error()
+  }
+
+  if !bytes.Equal(dbpf.Find(tgi1).GetData(), []byte{ 0x03, 0x04 }) || dbpf.IsCompressed(tgi1) {
+    t.Error()
+  }
+
+  if actual, err := dbpf.GetUncompressedData(tgi2); err != nil || !bytes.Equal(actual, []byte{ 0x05, 0x05, 0x05, 0x05, 0x05 }) {
+    t.Error()
+  }
+
+  if size, _ := dbpf.GetDirEntry().GetUncompressedSize(tgi2); size != 5 {
+    t.Error()
+  }
+}
+
+func TestEntriesAndFindByType(t *testing.T) {
+  dbpf := New()
+  tgi1 := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff4000 }
+  tgi2 := &entry.DBPFEntryTGI{ TypeId: 0x6534284a, GroupId: 0x0986135e, InstanceId: 0xffff4005 }
+  tgi3 := &entry.DBPFEntryTGI{ TypeId: 0x7ab50e44, GroupId: 0x0986135e, InstanceId: 0xffff400A }
+
+  dbpf.AddEntry(entry.NewEntry(tgi1))
+  dbpf.AddCompressedEntry(tgi2, []byte{ 0x01 })
+  dbpf.AddEntry(entry.NewEntry(tgi3))
+
+  entries := dbpf.Entries()
+  if len(entries) != 4 || !entries[0].TGI.Equals(tgi1) || !entries[2].TGI.Equals(entry.DIR_ENTRY_TGI) || !entries[3].TGI.Equals(tgi3) {
+    t.Errorf("Unexpected entries %v", entries)
+  }
+
+  found := dbpf.FindByType(0x7ab50e44)
+  if len(found) != 2 || !found[0].TGI.Equals(tgi1) || !found[1].TGI.Equals(tgi3) {
+    t.Errorf("Unexpected entries %v", found)
+  }
+
+  if len(dbpf.FindByType(0x12345678)) != 0 {
+    t.Error()
+  }
+}
+
+func TestSaveKeepsHeaderFields(t *testing.T) {
+  dbpf := New()
+  dbpf.MajorVersion = 1
+  dbpf.MinorVersion = 1
+  dbpf.UserMajorVersion = 2
+  dbpf.UserMinorVersion = 3
+  dbpf.Flags = 4
+  dbpf.CreatedDate = time.Unix(1000, 0)
+  dbpf.ModifiedDate = time.Unix(2000, 0)
+  dbpf.IndexMajorVersion = 7
+  dbpf.IndexMinorVersion = 5
+
+  buf := new(bytes.Buffer)
+  dbpf.Save(buf)
+
+  if buf.Len() != HEADER_SIZE || buf.Bytes()[60] != 5 || buf.Bytes()[12] != 2 || buf.Bytes()[16] != 3 || buf.Bytes()[20] != 4 {
+    t.Fatalf("Unexpected header % X", buf.Bytes())
+  }
+
+  parsed, e := Parse(buf)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if parsed.MinorVersion != 1 || parsed.UserMajorVersion != 2 || parsed.UserMinorVersion != 3 || parsed.Flags != 4 || parsed.IndexMinorVersion != 5 {
+    t.Errorf("Unexpected header fields %+v", parsed)
+  }
+}
+
+func TestParseIndexBeforeData(t *testing.T) {
+  data := make([]byte, HEADER_SIZE + INDEX_ENTRY_SIZE + 3)
+  copy(data, "DBPF")
+  data[4] = 1
+  data[32] = 7
+  data[36] = 1
+  data[40] = HEADER_SIZE
+  data[44] = INDEX_ENTRY_SIZE
+
+  index := data[HEADER_SIZE:]
+  index[0], index[4], index[8] = 0x11, 0x22, 0x33
+  index[12] = HEADER_SIZE + INDEX_ENTRY_SIZE
+  index[16] = 3
+  copy(data[HEADER_SIZE + INDEX_ENTRY_SIZE:], "abc")
+
+  dbpf, e := Parse(bytes.NewReader(data))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  entries := dbpf.Entries()
+  if len(entries) != 1 || entries[0].TGI.InstanceId != 0x33 || string(entries[0].GetData()) != "abc" {
+    t.Errorf("Unexpected entries %v", entries)
+  }
+
+  // An entry that extends past the end of the data.
+  index[16] = 4
+  if _, e := Parse(bytes.NewReader(data)); e == nil {
+    t.Error("Expected an error for an entry past the end of the data")
+  }
+
+  // An index that extends past the end of the data.
+  index[16] = 3
+  data[36] = 2
+  if _, e := Parse(bytes.NewReader(data)); e == nil {
+    t.Error("Expected an error for an index past the end of the data")
+  }
+
+  if _, e := Parse(bytes.NewReader(data[:50])); e == nil {
+    t.Error("Expected an error for a truncated header")
+  }
+}
+
+func TestCompressAndDecompress(t *testing.T) {
+  data := []byte("compress me, compress me, compress me")
+
+  decompressed, e := Decompress(Compress(data))
+  if e != nil || !bytes.Equal(decompressed, data) {
+    t.Errorf("Unexpected decompressed data %q, %v", decompressed, e)
+  }
+
+  if _, e := Decompress([]byte{1, 2}); e == nil {
+    t.Error("Expected an error for truncated data")
+  }
+}
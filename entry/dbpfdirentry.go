@@ -29,6 +29,82 @@ func (e *DBPFEntry) AddEntry(tgi *DBPFEntryTGI, size uint32) {
   e.SetData(temp)
 }
 
+// GetUncompressedSize searches the receiver DBPFEntry for a record matching the
+// provided DBPFEntryTGI and returns the uncompressed size stored in it.  The
+// second return value indicates whether such a record was found.  Like
+// AddEntry, this method panics if the receiver isn't the DIR entry.
+func (e *DBPFEntry) GetUncompressedSize(tgi *DBPFEntryTGI) (uint32, bool) {
+  if !e.TGI.Equals(DIR_ENTRY_TGI) {
+    panic(fmt.Sprintf("dbpfdirentry.GetUncompressedSize() can only be called with a DBPFEntry that has this TGI: {%s}\n", DIR_ENTRY_TGI))
+  }
+
+  data := e.GetData()
+  for i := 0; i + 16 <= len(data); i += 16 {
+    record := &DBPFEntryTGI{
+      TypeId: util.ReadUint32(data[i:i + 4]),
+      GroupId: util.ReadUint32(data[i + 4:i + 8]),
+      InstanceId: util.ReadUint32(data[i + 8:i + 12]),
+    }
+
+    if record.Equals(tgi) {
+      return util.ReadUint32(data[i + 12:i + 16]), true
+    }
+  }
+
+  return 0, false
+}
+
+// UncompressedSizes returns the uncompressed sizes stored in the records of the
+// receiver DBPFEntry, by TGI.  When several records match a TGI, the first one
+// is used, as with GetUncompressedSize.  Like AddEntry, this method panics if
+// the receiver isn't the DIR entry.
+func (e *DBPFEntry) UncompressedSizes() map[DBPFEntryTGI]uint32 {
+  if !e.TGI.Equals(DIR_ENTRY_TGI) {
+    panic(fmt.Sprintf("dbpfdirentry.UncompressedSizes() can only be called with a DBPFEntry that has this TGI: {%s}\n", DIR_ENTRY_TGI))
+  }
+
+  data := e.GetData()
+  sizes := make(map[DBPFEntryTGI]uint32, len(data) / 16)
+  for i := 0; i + 16 <= len(data); i += 16 {
+    record := DBPFEntryTGI{
+      TypeId: util.ReadUint32(data[i:i + 4]),
+      GroupId: util.ReadUint32(data[i + 4:i + 8]),
+      InstanceId: util.ReadUint32(data[i + 8:i + 12]),
+    }
+
+    if _, found := sizes[record]; !found {
+      sizes[record] = util.ReadUint32(data[i + 12:i + 16])
+    }
+  }
+
+  return sizes
+}
+
+// SetUncompressedSize updates the uncompressed size stored in the record of the
+// receiver DBPFEntry that matches the provided DBPFEntryTGI, adding a record if
+// there isn't one.  Like AddEntry, this method panics if the receiver isn't the
+// DIR entry.
+func (e *DBPFEntry) SetUncompressedSize(tgi *DBPFEntryTGI, size uint32) {
+  if _, found := e.GetUncompressedSize(tgi); !found {
+    e.AddEntry(tgi, size)
+    return
+  }
+
+  data := e.GetData()
+  for i := 0; i + 16 <= len(data); i += 16 {
+    record := &DBPFEntryTGI{
+      TypeId: util.ReadUint32(data[i:i + 4]),
+      GroupId: util.ReadUint32(data[i + 4:i + 8]),
+      InstanceId: util.ReadUint32(data[i + 8:i + 12]),
+    }
+
+    if record.Equals(tgi) {
+      copy(data[i + 12:i + 16], util.WriteUint32(size))
+      return
+    }
+  }
+}
+
 // CreateDirEntry creates a DBPFEntry with the DBPFEntryTGI reserved for the DIR
 // entry.
 func CreateDirEntry() *DBPFEntry {
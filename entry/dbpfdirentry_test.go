@@ -49,3 +49,66 @@ func TestAddMoreEntries(t *testing.T) {
 
   CheckIfSlicesAreEqual(t, actual, expected)
 }
+
+func TestGetUncompressedSize(t *testing.T) {
+  dirEntry := CreateDirEntry()
+  someTgi := &DBPFEntryTGI{TypeId: 0xFFFF0000, GroupId: 0xEEEE0000, InstanceId: 0xDDDD0000}
+  someTgi2 := &DBPFEntryTGI{TypeId: 0x12345678, GroupId: 0x87654321, InstanceId: 0xFACDDBBE}
+  missingTgi := &DBPFEntryTGI{TypeId: 0x12345678, GroupId: 0x87654321, InstanceId: 0x00000000}
+
+  dirEntry.AddEntry(someTgi, 100)
+  dirEntry.AddEntry(someTgi2, 99)
+
+  if size, ok := dirEntry.GetUncompressedSize(someTgi2); !ok || size != 99 {
+    t.Error()
+  }
+
+  if _, ok := dirEntry.GetUncompressedSize(missingTgi); ok {
+    t.Error()
+  }
+}
+
+func TestUncompressedSizes(t *testing.T) {
+  dirEntry := CreateDirEntry()
+  someTgi := &DBPFEntryTGI{TypeId: 0xFFFF0000, GroupId: 0xEEEE0000, InstanceId: 0xDDDD0000}
+  someTgi2 := &DBPFEntryTGI{TypeId: 0x12345678, GroupId: 0x87654321, InstanceId: 0xFACDDBBE}
+
+  dirEntry.AddEntry(someTgi, 100)
+  dirEntry.AddEntry(someTgi2, 99)
+  dirEntry.AddEntry(someTgi, 200)
+
+  sizes := dirEntry.UncompressedSizes()
+  if len(sizes) != 2 || sizes[*someTgi] != 100 || sizes[*someTgi2] != 99 {
+    t.Error()
+  }
+
+  defer func() {
+    if r := recover(); r == nil {
+      t.Error()
+    }
+  }()
+
+  NewEntry(someTgi).UncompressedSizes()
+}
+
+func TestSetUncompressedSize(t *testing.T) {
+  dirEntry := CreateDirEntry()
+  someTgi := &DBPFEntryTGI{TypeId: 0xFFFF0000, GroupId: 0xEEEE0000, InstanceId: 0xDDDD0000}
+  someTgi2 := &DBPFEntryTGI{TypeId: 0x12345678, GroupId: 0x87654321, InstanceId: 0xFACDDBBE}
+
+  dirEntry.AddEntry(someTgi, 100)
+  dirEntry.SetUncompressedSize(someTgi, 200)
+  dirEntry.SetUncompressedSize(someTgi2, 300)
+
+  if size, ok := dirEntry.GetUncompressedSize(someTgi); !ok || size != 200 {
+    t.Error()
+  }
+
+  if size, ok := dirEntry.GetUncompressedSize(someTgi2); !ok || size != 300 {
+    t.Error()
+  }
+
+  if dirEntry.Size() != 32 {
+    t.Error()
+  }
+}
@@ -39,6 +39,14 @@ func (e *DBPFEntry) GetData() []byte {
   return e.data
 }
 
+// Clone returns a new DBPFEntry with a copy of the TGI of the receiver, which
+// shares its data rather than copying it.  This is safe as long as the data
+// isn't changed in place, since SetData replaces it.
+func (e *DBPFEntry) Clone() *DBPFEntry {
+  tgi := *e.TGI
+  return &DBPFEntry{TGI: &tgi, data: e.data}
+}
+
 // String returns a string representation of the receiver.
 func (e *DBPFEntry) String() string {
   return fmt.Sprintf("TGI: %v, data: %v\n", e.TGI, e.data)
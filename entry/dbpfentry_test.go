@@ -58,3 +58,19 @@ func TestDBPFEntryString(t *testing.T) {
     t.Error("Actual [" + actual + "] didn't match expected [" + expected + "]")
   }
 }
+
+func TestDBPFEntryClone(t *testing.T) {
+  tgi := &DBPFEntryTGI{TypeId: 0x33333333, GroupId: 0x66666666, InstanceId: 0x99999999}
+  entry := NewEntry(tgi)
+  entry.SetData([]byte{0x22})
+
+  clone := entry.Clone()
+  if clone.TGI == entry.TGI || !clone.TGI.Equals(tgi) || clone.Size() != 1 || clone.GetData()[0] != 0x22 {
+    t.Error()
+  }
+
+  entry.SetData([]byte{0x33, 0x44})
+  if clone.Size() != 1 || clone.GetData()[0] != 0x22 {
+    t.Error()
+  }
+}
@@ -38,6 +38,18 @@ func (tgi *DBPFEntryTGI) Equals(other *DBPFEntryTGI) bool {
   }
 }
 
+// Less checks if the receiver sorts before the provided DBPFEntryTGI, comparing
+// the TypeId first, then the GroupId, and then the InstanceId.
+func (tgi *DBPFEntryTGI) Less(other *DBPFEntryTGI) bool {
+  if tgi.TypeId != other.TypeId {
+    return tgi.TypeId < other.TypeId
+  }
+  if tgi.GroupId != other.GroupId {
+    return tgi.GroupId < other.GroupId
+  }
+  return tgi.InstanceId < other.InstanceId
+}
+
 // Bytes encodes the receiver as 4 consecutive unsigned 32-bit integers into the
 // provided byte slice.
 func (tgi *DBPFEntryTGI) Bytes(bytes []byte) {
@@ -53,6 +53,24 @@ func TestDBPFEntryTGIEquals(t *testing.T) {
   }
 }
 
+func TestDBPFEntryTGILess(t *testing.T) {
+  tgi := &DBPFEntryTGI{TypeId: 0x2, GroupId: 0x2, InstanceId: 0x2}
+
+  for _, other := range []*DBPFEntryTGI{
+    &DBPFEntryTGI{TypeId: 0x3, GroupId: 0x0, InstanceId: 0x0},
+    &DBPFEntryTGI{TypeId: 0x2, GroupId: 0x3, InstanceId: 0x0},
+    &DBPFEntryTGI{TypeId: 0x2, GroupId: 0x2, InstanceId: 0x3},
+  } {
+    if !tgi.Less(other) || other.Less(tgi) {
+      t.Errorf("Expected %s to sort before %s", tgi, other)
+    }
+  }
+
+  if tgi.Less(&DBPFEntryTGI{TypeId: 0x2, GroupId: 0x2, InstanceId: 0x2}) {
+    t.Error("Expected equal TGIs not to be less than each other")
+  }
+}
+
 func TestDBPFEntryBytes(t *testing.T) {
   bytes := make([]byte, 12)
   tgi := &DBPFEntryTGI{TypeId: 0x32F9A014, GroupId: 0x7B5CA68E, InstanceId: 0x2468ACE0}
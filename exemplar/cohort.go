@@ -0,0 +1,124 @@
+package exemplar
+
+import (
+  "bytes"
+  "fmt"
+  "sort"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// Source pairs a parsed DBPF with the name of the file it was read from, so
+// that resolved properties can be traced back to where they came from.
+type Source struct {
+  // Name identifies the file the DBPF was read from.
+  Name string
+
+  // DBPF is the parsed DBPF file.
+  DBPF *godbpf.DBPF
+}
+
+// ResolvedProperty is an effective property of an exemplar, annotated with the
+// file and the exemplar or cohort that provided it.
+type ResolvedProperty struct {
+  *Property
+
+  // Source is the name of the file that provided the property.
+  Source string
+
+  // TGI identifies the exemplar or cohort that provided the property.
+  TGI *entry.DBPFEntryTGI
+}
+
+// Resolver resolves the effective properties of exemplars by walking up their
+// cohort chains across a set of DBPF files.
+type Resolver struct {
+  sources []*Source
+}
+
+// NewResolver creates a Resolver over the provided sources.
+func NewResolver(sources ...*Source) *Resolver {
+  return &Resolver{sources: sources}
+}
+
+// AddSource adds a DBPF to the receiver.  Like the game's load order, sources
+// added later take precedence over the ones added before them when the same
+// TGI is found in more than one.
+func (r *Resolver) AddSource(name string, dbpf *godbpf.DBPF) {
+  r.sources = append(r.sources, &Source{Name: name, DBPF: dbpf})
+}
+
+// Find locates the exemplar or cohort identified by the provided DBPFEntryTGI
+// and decodes it.  It also returns the Source that holds it.  Both are nil if
+// no source holds that TGI.
+func (r *Resolver) Find(tgi *entry.DBPFEntryTGI) (*Exemplar, *Source, error) {
+  for i := len(r.sources) - 1; i >= 0; i-- {
+    source := r.sources[i]
+    if source.DBPF.Find(tgi) == nil {
+      continue
+    }
+
+    data, e := source.DBPF.GetUncompressedData(tgi)
+    if e != nil {
+      return nil, nil, e
+    }
+
+    ex, e := Decode(bytes.NewReader(data))
+    if e != nil {
+      return nil, nil, fmt.Errorf("Failed to decode {%s} in %s: %s", tgi, source.Name, e)
+    }
+
+    return ex, source, nil
+  }
+
+  return nil, nil, nil
+}
+
+// Resolve returns the effective properties of the exemplar identified by the
+// provided DBPFEntryTGI, sorted by property ID.  Properties missing from the
+// exemplar are taken from the closest cohort in its parent chain that defines
+// them.  An error is returned if the exemplar or any cohort in its chain can't
+// be found, or if the chain loops back on itself.
+func (r *Resolver) Resolve(tgi *entry.DBPFEntryTGI) ([]*ResolvedProperty, error) {
+  properties := make(map[uint32]*ResolvedProperty)
+  visited := make(map[entry.DBPFEntryTGI]bool)
+
+  for current := tgi; current != nil; {
+    if visited[*current] {
+      return nil, fmt.Errorf("Cohort chain of {%s} loops back to {%s}", tgi, current)
+    }
+    visited[*current] = true
+
+    ex, source, e := r.Find(current)
+    if e != nil {
+      return nil, e
+    }
+    if ex == nil {
+      if current == tgi {
+        return nil, fmt.Errorf("Exemplar {%s} not found", tgi)
+      }
+
+      return nil, fmt.Errorf("Cohort {%s} in the chain of {%s} not found", current, tgi)
+    }
+
+    for _, p := range ex.Properties {
+      if _, found := properties[p.ID]; !found {
+        properties[p.ID] = &ResolvedProperty{Property: p, Source: source.Name, TGI: current}
+      }
+    }
+
+    current = nil
+    if ex.HasParent() {
+      current = ex.Parent
+    }
+  }
+
+  result := make([]*ResolvedProperty, 0, len(properties))
+  for _, p := range properties {
+    result = append(result, p)
+  }
+  sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+  return result, nil
+}
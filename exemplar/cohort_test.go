@@ -0,0 +1,142 @@
+package exemplar
+
+import (
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func addExemplar(t *testing.T, dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI, ex *Exemplar, compressed bool) {
+  data, e := ex.Bytes()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if compressed {
+    dbpf.AddCompressedEntry(tgi, data)
+  } else {
+    e := entry.NewEntry(tgi)
+    e.SetData(data)
+    dbpf.AddEntry(e)
+  }
+}
+
+func TestResolveAcrossFiles(t *testing.T) {
+  cohortTgi := &entry.DBPFEntryTGI{TypeId: COHORT_TYPE_ID, GroupId: 0xB03697D1, InstanceId: 0x00000001}
+  grandTgi := &entry.DBPFEntryTGI{TypeId: COHORT_TYPE_ID, GroupId: 0xB03697D1, InstanceId: 0x00000002}
+  exemplarTgi := &entry.DBPFEntryTGI{TypeId: EXEMPLAR_TYPE_ID, GroupId: 0x12345678, InstanceId: 0x00000003}
+
+  base := godbpf.New()
+  grand := New()
+  grand.Cohort = true
+  grand.SetProperty(NewProperty(0x10, Uint32, uint32(1)))
+  grand.SetProperty(NewProperty(0x30, Uint32, uint32(30)))
+  addExemplar(t, base, grandTgi, grand, false)
+
+  plugin := godbpf.New()
+  cohort := New()
+  cohort.Cohort = true
+  cohort.Parent = grandTgi
+  cohort.SetProperty(NewProperty(0x10, Uint32, uint32(2)))
+  cohort.SetProperty(NewProperty(0x20, String, "Cohort"))
+  addExemplar(t, plugin, cohortTgi, cohort, true)
+
+  ex := New()
+  ex.Parent = cohortTgi
+  ex.SetProperty(NewProperty(0x10, Uint32, uint32(3)))
+  addExemplar(t, plugin, exemplarTgi, ex, false)
+
+  resolver := NewResolver()
+  resolver.AddSource("base.dat", base)
+  resolver.AddSource("plugin.dat", plugin)
+
+  properties, e := resolver.Resolve(exemplarTgi)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(properties) != 3 {
+    t.Fatalf("Expected 3 properties but got %d", len(properties))
+  }
+
+  expected := []struct {
+    id uint32
+    source string
+    tgi *entry.DBPFEntryTGI
+  }{
+    {0x10, "plugin.dat", exemplarTgi},
+    {0x20, "plugin.dat", cohortTgi},
+    {0x30, "base.dat", grandTgi},
+  }
+
+  for i, p := range properties {
+    if p.ID != expected[i].id || p.Source != expected[i].source || !p.TGI.Equals(expected[i].tgi) {
+      t.Errorf("Property %d: %v from %s {%s}", i, p.Property, p.Source, p.TGI)
+    }
+  }
+
+  if properties[0].Uint32Values()[0] != 3 {
+    t.Error()
+  }
+}
+
+func TestResolveLaterSourceTakesPrecedence(t *testing.T) {
+  tgi := &entry.DBPFEntryTGI{TypeId: EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x1}
+
+  first := godbpf.New()
+  ex := New()
+  ex.SetProperty(NewProperty(0x10, Uint32, uint32(1)))
+  addExemplar(t, first, tgi, ex, false)
+
+  second := godbpf.New()
+  ex = New()
+  ex.SetProperty(NewProperty(0x10, Uint32, uint32(2)))
+  addExemplar(t, second, tgi, ex, false)
+
+  resolver := NewResolver(&Source{Name: "first.dat", DBPF: first}, &Source{Name: "second.dat", DBPF: second})
+
+  properties, e := resolver.Resolve(tgi)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(properties) != 1 || properties[0].Source != "second.dat" || properties[0].Uint32Values()[0] != 2 {
+    t.Error()
+  }
+}
+
+func TestResolveMissingAndLoopingChains(t *testing.T) {
+  firstTgi := &entry.DBPFEntryTGI{TypeId: COHORT_TYPE_ID, GroupId: 0x1, InstanceId: 0x1}
+  secondTgi := &entry.DBPFEntryTGI{TypeId: COHORT_TYPE_ID, GroupId: 0x1, InstanceId: 0x2}
+  missingTgi := &entry.DBPFEntryTGI{TypeId: COHORT_TYPE_ID, GroupId: 0x1, InstanceId: 0x3}
+  orphanTgi := &entry.DBPFEntryTGI{TypeId: EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x4}
+
+  dbpf := godbpf.New()
+  first := New()
+  first.Parent = secondTgi
+  addExemplar(t, dbpf, firstTgi, first, false)
+
+  second := New()
+  second.Parent = firstTgi
+  addExemplar(t, dbpf, secondTgi, second, false)
+
+  orphan := New()
+  orphan.Parent = missingTgi
+  addExemplar(t, dbpf, orphanTgi, orphan, false)
+
+  resolver := NewResolver()
+  resolver.AddSource("test.dat", dbpf)
+
+  if _, e := resolver.Resolve(firstTgi); e == nil {
+    t.Error()
+  }
+
+  if _, e := resolver.Resolve(orphanTgi); e == nil {
+    t.Error()
+  }
+
+  if _, e := resolver.Resolve(missingTgi); e == nil {
+    t.Error()
+  }
+}
@@ -0,0 +1,431 @@
+package exemplar
+
+import (
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "io"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// EXEMPLAR_TYPE_ID is the TypeId used by exemplar entries.
+const EXEMPLAR_TYPE_ID uint32 = 0x6534284A
+
+// COHORT_TYPE_ID is the TypeId used by cohort entries.
+const COHORT_TYPE_ID uint32 = 0x05342861
+
+// ValueType identifies the type of the values held by a Property.
+type ValueType uint16
+
+const (
+  Uint8 ValueType = 0x0100
+  Uint16 ValueType = 0x0200
+  Uint32 ValueType = 0x0300
+  Sint32 ValueType = 0x0700
+  Sint64 ValueType = 0x0800
+  Float32 ValueType = 0x0900
+  Bool ValueType = 0x0B00
+  String ValueType = 0x0C00
+)
+
+// String returns the name of the receiver ValueType.
+func (t ValueType) String() string {
+  switch t {
+  case Uint8:
+    return "Uint8"
+  case Uint16:
+    return "Uint16"
+  case Uint32:
+    return "Uint32"
+  case Sint32:
+    return "Sint32"
+  case Sint64:
+    return "Sint64"
+  case Float32:
+    return "Float32"
+  case Bool:
+    return "Bool"
+  case String:
+    return "String"
+  }
+
+  return fmt.Sprintf("0x%04X", uint16(t))
+}
+
+// The key type values that indicate whether a property holds a single value or
+// a list of values.
+const (
+  singleKeyType uint16 = 0x0000
+  multiKeyType uint16 = 0x0080
+)
+
+// Property is a single property of an exemplar or cohort.  The Values slice
+// holds Go values whose type matches the property's ValueType: uint8, uint16,
+// uint32, int32, int64, float32, bool or, for String properties, a single
+// string.
+type Property struct {
+  // ID identifies the property.
+  ID uint32
+
+  // Type indicates the type of the values.
+  Type ValueType
+
+  // Multi indicates that the property is stored as a list of values, even if
+  // that list only holds a single value.  String properties are always stored
+  // this way.
+  Multi bool
+
+  // Values contains the values of the property.
+  Values []interface{}
+}
+
+// NewProperty creates a Property with the provided ID, ValueType and values.
+// The Property is marked as Multi whenever it isn't holding exactly one value
+// or when it is a String property.
+func NewProperty(id uint32, t ValueType, values ...interface{}) *Property {
+  return &Property{ID: id, Type: t, Multi: len(values) != 1 || t == String, Values: values}
+}
+
+// Uint32Values returns the values of the receiver converted to uint32 values.
+// Values that aren't numeric are returned as 0.
+func (p *Property) Uint32Values() []uint32 {
+  result := make([]uint32, len(p.Values))
+  for i, v := range p.Values {
+    switch n := v.(type) {
+    case uint8:
+      result[i] = uint32(n)
+    case uint16:
+      result[i] = uint32(n)
+    case uint32:
+      result[i] = n
+    case int32:
+      result[i] = uint32(n)
+    case int64:
+      result[i] = uint32(n)
+    case float32:
+      result[i] = uint32(n)
+    case bool:
+      if n {
+        result[i] = 1
+      }
+    }
+  }
+
+  return result
+}
+
+// StringValue returns the value of a String property, or an empty string if
+// the receiver isn't one.
+func (p *Property) StringValue() string {
+  if len(p.Values) > 0 {
+    if s, ok := p.Values[0].(string); ok {
+      return s
+    }
+  }
+
+  return ""
+}
+
+// String returns a string representation of the receiver.
+func (p *Property) String() string {
+  return fmt.Sprintf("0x%08X: %s %v", p.ID, p.Type, p.Values)
+}
+
+// Exemplar holds the decoded contents of an exemplar or cohort entry.
+type Exemplar struct {
+  // Cohort indicates that this is a cohort rather than an exemplar.
+  Cohort bool
+
+  // Parent identifies the parent cohort.  A TGI of all zeros means there is no
+  // parent cohort.
+  Parent *entry.DBPFEntryTGI
+
+  // Properties lists the properties in the order they are stored.
+  Properties []*Property
+}
+
+// New creates an empty exemplar without a parent cohort.
+func New() *Exemplar {
+  return &Exemplar{Parent: new(entry.DBPFEntryTGI)}
+}
+
+// HasParent indicates whether the receiver refers to a parent cohort.
+func (ex *Exemplar) HasParent() bool {
+  return ex.Parent != nil && !ex.Parent.Equals(new(entry.DBPFEntryTGI))
+}
+
+// Property returns the property with the provided ID, or nil if the receiver
+// doesn't have one.
+func (ex *Exemplar) Property(id uint32) *Property {
+  for _, p := range ex.Properties {
+    if p.ID == id {
+      return p
+    }
+  }
+
+  return nil
+}
+
+// SetProperty replaces the property with the same ID as the one provided, or
+// appends it if the receiver doesn't have one.
+func (ex *Exemplar) SetProperty(property *Property) {
+  for i, p := range ex.Properties {
+    if p.ID == property.ID {
+      ex.Properties[i] = property
+      return
+    }
+  }
+
+  ex.Properties = append(ex.Properties, property)
+}
+
+// RemoveProperty removes the property with the provided ID from the receiver.
+func (ex *Exemplar) RemoveProperty(id uint32) {
+  for i, p := range ex.Properties {
+    if p.ID == id {
+      ex.Properties = append(ex.Properties[:i], ex.Properties[i + 1:]...)
+      return
+    }
+  }
+}
+
+// signature returns the 8 byte signature that begins the binary encoding of
+// the receiver.
+func (ex *Exemplar) signature() string {
+  if ex.Cohort {
+    return "CQZB1###"
+  }
+
+  return "EQZB1###"
+}
+
+// Decode reads a binary exemplar or cohort from the provided Reader.
+func Decode(r io.Reader) (*Exemplar, error) {
+  signature := make([]byte, 8)
+  if _, e := io.ReadFull(r, signature); e != nil {
+    return nil, e
+  }
+
+  ex := New()
+  switch string(signature) {
+  case "EQZB1###":
+  case "CQZB1###":
+    ex.Cohort = true
+  case "EQZT1###", "CQZT1###":
+    return nil, errors.New("Text exemplars are not supported")
+  default:
+    return nil, errors.New("Invalid exemplar signature")
+  }
+
+  var count uint32
+  if e := binary.Read(r, binary.LittleEndian, ex.Parent); e != nil {
+    return nil, e
+  }
+  if e := binary.Read(r, binary.LittleEndian, &count); e != nil {
+    return nil, e
+  }
+
+  for i := uint32(0); i < count; i++ {
+    p, e := decodeProperty(r)
+    if e != nil {
+      return nil, e
+    }
+
+    ex.Properties = append(ex.Properties, p)
+  }
+
+  return ex, nil
+}
+
+// decodeProperty reads a single binary property from the provided Reader.
+func decodeProperty(r io.Reader) (*Property, error) {
+  var header struct {
+    ID uint32
+    Type ValueType
+    KeyType uint16
+    Unused uint8
+  }
+
+  if e := binary.Read(r, binary.LittleEndian, &header); e != nil {
+    return nil, e
+  }
+
+  p := &Property{ID: header.ID, Type: header.Type}
+  count := uint32(1)
+  if header.KeyType == multiKeyType {
+    p.Multi = true
+    if e := binary.Read(r, binary.LittleEndian, &count); e != nil {
+      return nil, e
+    }
+  } else if header.KeyType != singleKeyType {
+    return nil, fmt.Errorf("Invalid key type 0x%04X for property 0x%08X", header.KeyType, header.ID)
+  }
+
+  // The count comes from the data, so the values are gathered as they are
+  // read rather than allocated up front, lest a corrupt count exhaust memory.
+  if p.Type == String {
+    s := new(bytes.Buffer)
+    if n, e := io.CopyN(s, r, int64(count)); n < int64(count) {
+      if e == io.EOF {
+        e = io.ErrUnexpectedEOF
+      }
+      return nil, e
+    }
+
+    p.Values = []interface{}{s.String()}
+    return p, nil
+  }
+
+  for i := uint32(0); i < count; i++ {
+    v, e := decodeValue(r, p.Type)
+    if e == io.EOF {
+      return nil, io.ErrUnexpectedEOF
+    } else if e != nil {
+      return nil, e
+    }
+
+    p.Values = append(p.Values, v)
+  }
+
+  return p, nil
+}
+
+// decodeValue reads a single value of the provided ValueType.
+func decodeValue(r io.Reader, t ValueType) (interface{}, error) {
+  var v interface{}
+  switch t {
+  case Uint8:
+    v = new(uint8)
+  case Uint16:
+    v = new(uint16)
+  case Uint32:
+    v = new(uint32)
+  case Sint32:
+    v = new(int32)
+  case Sint64:
+    v = new(int64)
+  case Float32:
+    v = new(float32)
+  case Bool:
+    v = new(bool)
+  default:
+    return nil, fmt.Errorf("Unsupported value type %s", t)
+  }
+
+  if e := binary.Read(r, binary.LittleEndian, v); e != nil {
+    return nil, e
+  }
+
+  switch p := v.(type) {
+  case *uint8:
+    return *p, nil
+  case *uint16:
+    return *p, nil
+  case *uint32:
+    return *p, nil
+  case *int32:
+    return *p, nil
+  case *int64:
+    return *p, nil
+  case *float32:
+    return *p, nil
+  case *bool:
+    return *p, nil
+  }
+
+  return nil, nil
+}
+
+// Encode writes the binary encoding of the receiver to the provided Writer.
+func (ex *Exemplar) Encode(w io.Writer) error {
+  parent := ex.Parent
+  if parent == nil {
+    parent = new(entry.DBPFEntryTGI)
+  }
+
+  buf := new(bytes.Buffer)
+  buf.WriteString(ex.signature())
+  binary.Write(buf, binary.LittleEndian, parent)
+  binary.Write(buf, binary.LittleEndian, uint32(len(ex.Properties)))
+
+  for _, p := range ex.Properties {
+    if e := encodeProperty(buf, p); e != nil {
+      return e
+    }
+  }
+
+  _, e := buf.WriteTo(w)
+  return e
+}
+
+// Bytes returns the binary encoding of the receiver.
+func (ex *Exemplar) Bytes() ([]byte, error) {
+  buf := new(bytes.Buffer)
+  if e := ex.Encode(buf); e != nil {
+    return nil, e
+  }
+
+  return buf.Bytes(), nil
+}
+
+// encodeProperty writes the binary encoding of a single property to the
+// provided Buffer.
+func encodeProperty(buf *bytes.Buffer, p *Property) error {
+  keyType := singleKeyType
+  if p.Multi || p.Type == String {
+    keyType = multiKeyType
+  } else if len(p.Values) != 1 {
+    return fmt.Errorf("Property 0x%08X must hold exactly one value", p.ID)
+  }
+
+  binary.Write(buf, binary.LittleEndian, p.ID)
+  binary.Write(buf, binary.LittleEndian, p.Type)
+  binary.Write(buf, binary.LittleEndian, keyType)
+  buf.WriteByte(0)
+
+  if p.Type == String {
+    s := p.StringValue()
+    binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+    buf.WriteString(s)
+    return nil
+  }
+
+  if keyType == multiKeyType {
+    binary.Write(buf, binary.LittleEndian, uint32(len(p.Values)))
+  }
+
+  for _, v := range p.Values {
+    if !matchesType(v, p.Type) {
+      return fmt.Errorf("Value %v of property 0x%08X doesn't match type %s", v, p.ID, p.Type)
+    }
+
+    binary.Write(buf, binary.LittleEndian, v)
+  }
+
+  return nil
+}
+
+// matchesType checks that the Go type of the provided value is the one used to
+// represent values of the provided ValueType.
+func matchesType(v interface{}, t ValueType) bool {
+  switch v.(type) {
+  case uint8:
+    return t == Uint8
+  case uint16:
+    return t == Uint16
+  case uint32:
+    return t == Uint32
+  case int32:
+    return t == Sint32
+  case int64:
+    return t == Sint64
+  case float32:
+    return t == Float32
+  case bool:
+    return t == Bool
+  }
+
+  return false
+}
@@ -0,0 +1,147 @@
+package exemplar
+
+import (
+  "bytes"
+  "io"
+  "testing"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func TestDecodeBinaryExemplar(t *testing.T) {
+  data := []byte{ 'E', 'Q', 'Z', 'B', '1', '#', '#', '#',
+                  0x61, 0x28, 0x34, 0x05, // Parent TypeId
+                  0x11, 0x11, 0x11, 0x11, // Parent GroupId
+                  0x22, 0x22, 0x22, 0x22, // Parent InstanceId
+                  0x2, 0x0, 0x0, 0x0, // Property count
+                  0x10, 0x0, 0x0, 0x0, // Exemplar Type
+                  0x0, 0x3, 0x0, 0x0, 0x0,
+                  0x2, 0x0, 0x0, 0x0,
+                  0x20, 0x0, 0x0, 0x0, // Exemplar Name
+                  0x0, 0xC, 0x80, 0x0, 0x0,
+                  0x3, 0x0, 0x0, 0x0,
+                  'F', 'o', 'o' }
+
+  ex, e := Decode(bytes.NewReader(data))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if ex.Cohort {
+    t.Error()
+  }
+
+  expectedParent := &entry.DBPFEntryTGI{TypeId: COHORT_TYPE_ID, GroupId: 0x11111111, InstanceId: 0x22222222}
+  if !ex.HasParent() || !ex.Parent.Equals(expectedParent) {
+    t.Error()
+  }
+
+  if p := ex.Property(0x10); p == nil || p.Multi || p.Uint32Values()[0] != 2 {
+    t.Error()
+  }
+
+  if p := ex.Property(0x20); p == nil || p.StringValue() != "Foo" {
+    t.Error()
+  }
+
+  actual, e := ex.Bytes()
+  if e != nil {
+    t.Error(e)
+  }
+
+  if !bytes.Equal(actual, data) {
+    t.Errorf("Expected %v but was %v", data, actual)
+  }
+}
+
+func TestEncodeDecodeAllValueTypes(t *testing.T) {
+  ex := New()
+  ex.Cohort = true
+  ex.SetProperty(NewProperty(0x1, Uint8, uint8(1), uint8(2)))
+  ex.SetProperty(NewProperty(0x2, Uint16, uint16(0x1234)))
+  ex.SetProperty(NewProperty(0x3, Uint32, uint32(0x12345678)))
+  ex.SetProperty(NewProperty(0x4, Sint32, int32(-5)))
+  ex.SetProperty(NewProperty(0x5, Sint64, int64(-1 << 40)))
+  ex.SetProperty(NewProperty(0x6, Float32, float32(1.5), float32(-2.25)))
+  ex.SetProperty(NewProperty(0x7, Bool, true))
+  ex.SetProperty(NewProperty(0x8, String, "Bar"))
+
+  data, e := ex.Bytes()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  decoded, e := Decode(bytes.NewReader(data))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if !decoded.Cohort || decoded.HasParent() || len(decoded.Properties) != 8 {
+    t.Error()
+  }
+
+  for i, p := range decoded.Properties {
+    expected := ex.Properties[i]
+    if p.ID != expected.ID || p.Type != expected.Type || p.Multi != expected.Multi || len(p.Values) != len(expected.Values) {
+      t.Errorf("Property %v didn't match expected %v", p, expected)
+      continue
+    }
+
+    for j, v := range p.Values {
+      if v != expected.Values[j] {
+        t.Errorf("Property %v didn't match expected %v", p, expected)
+      }
+    }
+  }
+}
+
+func TestEncodeMismatchedValueType(t *testing.T) {
+  ex := New()
+  ex.SetProperty(NewProperty(0x1, Uint32, uint8(1)))
+
+  if _, e := ex.Bytes(); e == nil {
+    t.Error()
+  }
+}
+
+func TestDecodeInvalidSignature(t *testing.T) {
+  if _, e := Decode(bytes.NewReader([]byte("XXXXXXXX"))); e == nil {
+    t.Error()
+  }
+
+  if _, e := Decode(bytes.NewReader([]byte("EQZT1###"))); e == nil {
+    t.Error()
+  }
+}
+
+func TestDecodeCorruptValueCount(t *testing.T) {
+  for _, property := range [][]byte{
+    { 0x20, 0x0, 0x0, 0x0, 0x0, 0xC, 0x80, 0x0, 0x0, 0xF0, 0xFF, 0xFF, 0xFF, 'F', 'o', 'o' },
+    { 0x10, 0x0, 0x0, 0x0, 0x0, 0x3, 0x80, 0x0, 0x0, 0xF0, 0xFF, 0xFF, 0xFF, 0x2, 0x0, 0x0, 0x0 },
+  } {
+    data := append([]byte{ 'E', 'Q', 'Z', 'B', '1', '#', '#', '#',
+                           0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+                           0x1, 0x0, 0x0, 0x0 }, property...)
+
+    if _, e := Decode(bytes.NewReader(data)); e != io.ErrUnexpectedEOF {
+      t.Errorf("Expected io.ErrUnexpectedEOF, got %v", e)
+    }
+  }
+}
+
+func TestSetAndRemoveProperty(t *testing.T) {
+  ex := New()
+  ex.SetProperty(NewProperty(0x1, Uint32, uint32(1)))
+  ex.SetProperty(NewProperty(0x2, Uint32, uint32(2)))
+  ex.SetProperty(NewProperty(0x1, Uint32, uint32(3)))
+
+  if len(ex.Properties) != 2 || ex.Property(0x1).Uint32Values()[0] != 3 {
+    t.Error()
+  }
+
+  ex.RemoveProperty(0x1)
+
+  if len(ex.Properties) != 1 || ex.Property(0x1) != nil {
+    t.Error()
+  }
+}
@@ -0,0 +1,322 @@
+package exemplar
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "io"
+  "math"
+  "strconv"
+  "strings"
+  "unicode/utf8"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// document is the text form of an exemplar, shared by JSON and YAML.
+type document struct {
+  Cohort bool `json:"cohort"`
+  Parent *tgiDocument `json:"parent,omitempty"`
+  Properties []*propertyDocument `json:"properties"`
+}
+
+// tgiDocument is the text form of a TGI, with each identifier in hexadecimal.
+type tgiDocument struct {
+  Type string `json:"type"`
+  Group string `json:"group"`
+  Instance string `json:"instance"`
+}
+
+// propertyDocument is the text form of a property.  Property holds the name of
+// the property when it is known, and its ID in hexadecimal otherwise.  Multi is
+// only present when it differs from what NewProperty would choose.
+type propertyDocument struct {
+  Property string `json:"property"`
+  Type string `json:"type"`
+  Multi *bool `json:"multi,omitempty"`
+  Values []interface{} `json:"values"`
+}
+
+// formatHex returns the text form of an identifier.
+func formatHex(v uint32) string {
+  return fmt.Sprintf("0x%08X", v)
+}
+
+// toDocument converts the receiver to its text form.
+func (ex *Exemplar) toDocument() (*document, error) {
+  doc := &document{Cohort: ex.Cohort, Properties: []*propertyDocument{}}
+  if ex.HasParent() {
+    doc.Parent = &tgiDocument{formatHex(ex.Parent.TypeId), formatHex(ex.Parent.GroupId), formatHex(ex.Parent.InstanceId)}
+  }
+
+  for _, p := range ex.Properties {
+    pd := &propertyDocument{Property: formatHex(p.ID), Type: p.Type.String(), Values: []interface{}{}}
+    if name, found := PropertyName(p.ID); found {
+      pd.Property = name
+    }
+
+    if p.Multi != (len(p.Values) != 1 || p.Type == String) {
+      multi := p.Multi
+      pd.Multi = &multi
+    }
+
+    for _, v := range p.Values {
+      if !matchesType(v, p.Type) && !(p.Type == String && isString(v)) {
+        return nil, fmt.Errorf("Value %v of property 0x%08X doesn't match type %s", v, p.ID, p.Type)
+      }
+
+      // Text formats can't hold arbitrary bytes, which would be replaced by
+      // U+FFFD.
+      if s, ok := v.(string); ok && !utf8.ValidString(s) {
+        return nil, fmt.Errorf("Value %q of property 0x%08X isn't valid UTF-8", s, p.ID)
+      }
+
+      pd.Values = append(pd.Values, formatValue(v))
+    }
+
+    doc.Properties = append(doc.Properties, pd)
+  }
+
+  return doc, nil
+}
+
+// isString checks if the provided value is a string.
+func isString(v interface{}) bool {
+  _, ok := v.(string)
+  return ok
+}
+
+// formatValue returns the text form of a single value.  Uint32 values are shown
+// in hexadecimal since they are usually identifiers.  Float32 values use the
+// shortest form that gives back the same value, and those that can't be held
+// in a JSON number are written as strings.
+func formatValue(v interface{}) interface{} {
+  switch n := v.(type) {
+  case uint32:
+    return formatHex(n)
+  case float32:
+    f := float64(n)
+    switch {
+    case math.IsNaN(f):
+      return "NaN"
+    case math.IsInf(f, 1):
+      return "+Inf"
+    case math.IsInf(f, -1):
+      return "-Inf"
+    }
+    return json.Number(strconv.FormatFloat(f, 'g', -1, 32))
+  }
+
+  return v
+}
+
+// fromDocument converts the text form of an exemplar back to an Exemplar.
+func fromDocument(doc *document) (*Exemplar, error) {
+  ex := New()
+  ex.Cohort = doc.Cohort
+
+  if doc.Parent != nil {
+    ids := make([]uint32, 3)
+    for i, s := range []string{doc.Parent.Type, doc.Parent.Group, doc.Parent.Instance} {
+      id, e := strconv.ParseUint(s, 0, 32)
+      if e != nil {
+        return nil, fmt.Errorf("Invalid parent identifier %q", s)
+      }
+      ids[i] = uint32(id)
+    }
+
+    ex.Parent = &entry.DBPFEntryTGI{TypeId: ids[0], GroupId: ids[1], InstanceId: ids[2]}
+  }
+
+  for i, pd := range doc.Properties {
+    if pd == nil {
+      return nil, fmt.Errorf("Property %d is empty", i + 1)
+    }
+
+    p, e := pd.toProperty()
+    if e != nil {
+      return nil, e
+    }
+
+    ex.Properties = append(ex.Properties, p)
+  }
+
+  return ex, nil
+}
+
+// parseValueType returns the ValueType with the provided name.
+func parseValueType(name string) (ValueType, error) {
+  for _, t := range []ValueType{Uint8, Uint16, Uint32, Sint32, Sint64, Float32, Bool, String} {
+    if strings.EqualFold(t.String(), name) {
+      return t, nil
+    }
+  }
+
+  return 0, fmt.Errorf("Unknown value type %q", name)
+}
+
+// toProperty converts the text form of a property back to a Property.
+func (pd *propertyDocument) toProperty() (*Property, error) {
+  id, found := PropertyID(pd.Property)
+  if !found {
+    n, e := strconv.ParseUint(pd.Property, 0, 32)
+    if e != nil {
+      return nil, fmt.Errorf("Unknown property %q", pd.Property)
+    }
+    id = uint32(n)
+  }
+
+  t, e := parseValueType(pd.Type)
+  if e != nil {
+    return nil, fmt.Errorf("Property %s: %v", pd.Property, e)
+  }
+
+  values := make([]interface{}, len(pd.Values))
+  for i, v := range pd.Values {
+    if values[i], e = parseValue(v, t); e != nil {
+      return nil, fmt.Errorf("Property %s: %v", pd.Property, e)
+    }
+  }
+
+  if t == String && len(values) != 1 {
+    return nil, fmt.Errorf("Property %s: String properties hold exactly one value", pd.Property)
+  }
+
+  p := NewProperty(id, t, values...)
+  if pd.Multi != nil {
+    p.Multi = *pd.Multi
+  }
+
+  return p, nil
+}
+
+// parseValue converts a value decoded from text to the Go type used for the
+// provided ValueType.  Numbers may be given as JSON numbers or as strings,
+// including hexadecimal ones.
+func parseValue(v interface{}, t ValueType) (interface{}, error) {
+  if t == String {
+    if s, ok := v.(string); ok {
+      return s, nil
+    }
+    return nil, fmt.Errorf("Expected a string, got %v", v)
+  }
+
+  if t == Bool {
+    if b, ok := v.(bool); ok {
+      return b, nil
+    }
+    return nil, fmt.Errorf("Expected a boolean, got %v", v)
+  }
+
+  var text string
+  switch n := v.(type) {
+  case json.Number:
+    text = string(n)
+  case string:
+    text = n
+  default:
+    return nil, fmt.Errorf("Expected a number, got %v", v)
+  }
+
+  if t == Float32 {
+    f, e := strconv.ParseFloat(text, 32)
+    if e != nil {
+      return nil, fmt.Errorf("Invalid %s value %q", t, text)
+    }
+    return float32(f), nil
+  }
+
+  bits := map[ValueType]int{Uint8: 8, Uint16: 16, Uint32: 32, Sint32: 32, Sint64: 64}[t]
+  if bits == 0 {
+    return nil, fmt.Errorf("Unsupported value type %s", t)
+  }
+
+  if t == Sint32 || t == Sint64 {
+    n, e := strconv.ParseInt(text, 0, bits)
+    if e != nil {
+      return nil, fmt.Errorf("Invalid %s value %q", t, text)
+    }
+
+    if t == Sint32 {
+      return int32(n), nil
+    }
+    return n, nil
+  }
+
+  n, e := strconv.ParseUint(text, 0, bits)
+  if e != nil {
+    return nil, fmt.Errorf("Invalid %s value %q", t, text)
+  }
+
+  switch t {
+  case Uint8:
+    return uint8(n), nil
+  case Uint16:
+    return uint16(n), nil
+  }
+  return uint32(n), nil
+}
+
+// MarshalJSON returns the JSON form of the receiver.
+func (ex *Exemplar) MarshalJSON() ([]byte, error) {
+  doc, e := ex.toDocument()
+  if e != nil {
+    return nil, e
+  }
+
+  return json.Marshal(doc)
+}
+
+// UnmarshalJSON replaces the receiver with the exemplar held in the provided
+// JSON data.
+func (ex *Exemplar) UnmarshalJSON(data []byte) error {
+  decoder := json.NewDecoder(bytes.NewReader(data))
+  decoder.UseNumber()
+  decoder.DisallowUnknownFields()
+
+  doc := new(document)
+  if e := decoder.Decode(doc); e != nil {
+    return e
+  }
+
+  decoded, e := fromDocument(doc)
+  if e != nil {
+    return e
+  }
+
+  *ex = *decoded
+  return nil
+}
+
+// EncodeJSON writes the JSON form of the receiver to the provided Writer,
+// indented for readability.
+func (ex *Exemplar) EncodeJSON(w io.Writer) error {
+  data, e := ex.MarshalJSON()
+  if e != nil {
+    return e
+  }
+
+  buf := new(bytes.Buffer)
+  if e := json.Indent(buf, data, "", "  "); e != nil {
+    return e
+  }
+  buf.WriteString("\n")
+
+  _, e = buf.WriteTo(w)
+  return e
+}
+
+// DecodeJSON reads the JSON form of an exemplar from the provided Reader.
+func DecodeJSON(r io.Reader) (*Exemplar, error) {
+  buf := new(bytes.Buffer)
+  if _, e := buf.ReadFrom(r); e != nil {
+    return nil, e
+  }
+
+  ex := New()
+  if e := ex.UnmarshalJSON(buf.Bytes()); e != nil {
+    return nil, e
+  }
+
+  return ex, nil
+}
@@ -0,0 +1,138 @@
+package exemplar
+
+import (
+  "bytes"
+  "encoding/json"
+  "math"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// sampleExemplar returns an exemplar using every value type.
+func sampleExemplar() *Exemplar {
+  ex := New()
+  ex.Parent = &entry.DBPFEntryTGI{TypeId: COHORT_TYPE_ID, GroupId: 0xB03697D1, InstanceId: 0x00001234}
+  ex.Properties = []*Property{
+    NewProperty(0x00000010, Uint32, uint32(0x10)),
+    NewProperty(0x00000020, String, "Tree \"A\"\n"),
+    NewProperty(0x12345678, Uint8, uint8(1), uint8(255)),
+    &Property{ID: 0x12345679, Type: Uint16, Multi: true, Values: []interface{}{uint16(7)}},
+    NewProperty(0x1234567A, Sint32, int32(-5)),
+    NewProperty(0x1234567B, Sint64, int64(math.MaxInt64), int64(math.MinInt64)),
+    NewProperty(0x1234567C, Float32, float32(0.1), float32(-1e-30), float32(math.Inf(1)), float32(16)),
+    NewProperty(0x1234567D, Bool, true),
+    NewProperty(0x1234567E, Uint32),
+  }
+
+  return ex
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+  original := sampleExemplar()
+  binary, e := original.Bytes()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  buf := new(bytes.Buffer)
+  if e := original.EncodeJSON(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  text := buf.String()
+  for _, expected := range []string{`"property": "Exemplar Name"`, `"property": "0x12345679"`, `"multi": true`, `"0x00000010"`, `0.1`, `"+Inf"`, `9223372036854775807`} {
+    if !strings.Contains(text, expected) {
+      t.Errorf("Expected %s in:\n%s", expected, text)
+    }
+  }
+
+  decoded, e := DecodeJSON(strings.NewReader(text))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  again, e := decoded.Bytes()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if !bytes.Equal(binary, again) {
+    t.Error("Expected the JSON round trip to give back the original binary")
+  }
+}
+
+func TestJSONMarshalers(t *testing.T) {
+  data, e := json.Marshal([]*Exemplar{sampleExemplar()})
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  var decoded []*Exemplar
+  if e := json.Unmarshal(data, &decoded); e != nil {
+    t.Fatal(e)
+  }
+
+  if len(decoded) != 1 || len(decoded[0].Properties) != 9 || !decoded[0].HasParent() {
+    t.Errorf("Unexpected exemplars %+v", decoded)
+  }
+}
+
+func TestJSONAcceptsHandWrittenValues(t *testing.T) {
+  text := `{"cohort": true, "properties": [
+    {"property": "0x00000010", "type": "uint32", "values": [16, "0x20"]},
+    {"property": "OccupantGroups", "type": "Uint32", "values": ["4096"]}
+  ]}`
+
+  ex, e := DecodeJSON(strings.NewReader(text))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if !ex.Cohort || ex.HasParent() || len(ex.Properties) != 2 {
+    t.Fatalf("Unexpected exemplar %+v", ex)
+  }
+
+  if values := ex.Properties[0].Uint32Values(); values[0] != 16 || values[1] != 32 || !ex.Properties[0].Multi {
+    t.Errorf("Unexpected values %v", ex.Properties[0])
+  }
+
+  if ex.Properties[1].ID != 0xAA1DD396 || ex.Properties[1].Multi {
+    t.Errorf("Unexpected property %v", ex.Properties[1])
+  }
+}
+
+func TestJSONErrors(t *testing.T) {
+  tests := []string{
+    `{"properties": [{"property": "Nope", "type": "Uint32", "values": [1]}]}`,
+    `{"properties": [{"property": "0x1", "type": "Int", "values": [1]}]}`,
+    `{"properties": [{"property": "0x1", "type": "Uint8", "values": [256]}]}`,
+    `{"properties": [{"property": "0x1", "type": "Sint32", "values": [true]}]}`,
+    `{"properties": [{"property": "0x1", "type": "Bool", "values": [1]}]}`,
+    `{"properties": [{"property": "0x1", "type": "String", "values": ["a", "b"]}]}`,
+    `{"parent": {"type": "x", "group": "0", "instance": "0"}, "properties": []}`,
+    `{"properties": [], "extra": 1}`,
+    `{"properties": [null]}`,
+  }
+
+  for _, text := range tests {
+    if _, e := DecodeJSON(strings.NewReader(text)); e == nil {
+      t.Errorf("Expected an error for %s", text)
+    }
+  }
+
+  bad := New()
+  bad.Properties = []*Property{NewProperty(0x1, Uint8, uint32(1))}
+  if _, e := json.Marshal(bad); e == nil {
+    t.Error("Expected an error marshaling a mismatched value")
+  }
+
+  bad.Properties = []*Property{NewProperty(0x20, String, "Caf\xe9")}
+  if _, e := json.Marshal(bad); e == nil {
+    t.Error("Expected an error marshaling a string that isn't valid UTF-8")
+  }
+  if e := bad.EncodeYAML(new(bytes.Buffer)); e == nil {
+    t.Error("Expected an error encoding a string that isn't valid UTF-8")
+  }
+}
@@ -0,0 +1,47 @@
+package exemplar
+
+import (
+  "strings"
+)
+
+// PropertyNames maps the IDs of well known properties to the names used for
+// them in text files.  Entries can be added to it before converting exemplars
+// to text, as long as the names stay unique.
+var PropertyNames = map[uint32]string{
+  0x00000010: "Exemplar Type",
+  0x00000020: "Exemplar Name",
+  0x00000021: "Exemplar ID",
+  0x27812820: "Resource Key Type 0",
+  0x27812821: "Resource Key Type 1",
+  0x27812822: "Resource Key Type 2",
+  0x27812823: "Resource Key Type 3",
+  0x27812824: "Resource Key Type 4",
+  0x27812825: "Resource Key Type 5",
+  0x88EDC790: "LotConfigPropertySize",
+  0x88EDC793: "LotConfigPropertyZoneTypes",
+  0x88EDC795: "LotConfigPropertyWealthTypes",
+  0x88EDC796: "LotConfigPropertyPurposeTypes",
+  0x88EDC900: "LotConfigPropertyLotObject",
+  0x8A2602B8: "Item Icon",
+  0x8A416A99: "User Visible Name Key",
+  0xCA416AB5: "Item Description Key",
+  0xAA1DD396: "OccupantGroups",
+}
+
+// PropertyName returns the name of the property with the provided ID.
+func PropertyName(id uint32) (string, bool) {
+  name, found := PropertyNames[id]
+  return name, found
+}
+
+// PropertyID returns the ID of the property with the provided name.  Names are
+// matched without regard to case.
+func PropertyID(name string) (uint32, bool) {
+  for id, n := range PropertyNames {
+    if strings.EqualFold(n, name) {
+      return id, true
+    }
+  }
+
+  return 0, false
+}
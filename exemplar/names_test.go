@@ -0,0 +1,27 @@
+package exemplar
+
+import (
+  "testing"
+)
+
+func TestPropertyNames(t *testing.T) {
+  if name, found := PropertyName(0x00000020); !found || name != "Exemplar Name" {
+    t.Errorf("Unexpected name %q", name)
+  }
+
+  if _, found := PropertyName(0x12345678); found {
+    t.Error("Expected no name for an unknown property")
+  }
+
+  if id, found := PropertyID("exemplar name"); !found || id != 0x00000020 {
+    t.Errorf("Unexpected ID 0x%08X", id)
+  }
+
+  seen := make(map[string]uint32)
+  for id, name := range PropertyNames {
+    if other, found := seen[name]; found {
+      t.Errorf("Name %q is used by 0x%08X and 0x%08X", name, id, other)
+    }
+    seen[name] = id
+  }
+}
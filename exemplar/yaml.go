@@ -0,0 +1,456 @@
+package exemplar
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "io"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+// The YAML form of an exemplar holds the same document as the JSON form.  It
+// is written and read by the small YAML implementation below, which handles
+// block mappings and sequences, single line flow sequences, plain and quoted
+// scalars, and comments.  That covers the files written by EncodeYAML and the
+// usual ways of editing them by hand.
+
+// EncodeYAML writes the YAML form of the receiver to the provided Writer.
+func (ex *Exemplar) EncodeYAML(w io.Writer) error {
+  doc, e := ex.toDocument()
+  if e != nil {
+    return e
+  }
+
+  buf := new(bytes.Buffer)
+  fmt.Fprintf(buf, "cohort: %t\n", doc.Cohort)
+  if doc.Parent != nil {
+    fmt.Fprintf(buf, "parent:\n  type: %s\n  group: %s\n  instance: %s\n", doc.Parent.Type, doc.Parent.Group, doc.Parent.Instance)
+  }
+
+  if len(doc.Properties) == 0 {
+    buf.WriteString("properties: []\n")
+  } else {
+    buf.WriteString("properties:\n")
+  }
+
+  for _, pd := range doc.Properties {
+    fmt.Fprintf(buf, "  - property: %s\n", yamlScalar(pd.Property))
+    fmt.Fprintf(buf, "    type: %s\n", pd.Type)
+    if pd.Multi != nil {
+      fmt.Fprintf(buf, "    multi: %t\n", *pd.Multi)
+    }
+
+    values := make([]string, len(pd.Values))
+    for i, v := range pd.Values {
+      values[i] = yamlScalar(v)
+    }
+    fmt.Fprintf(buf, "    values: [%s]\n", strings.Join(values, ", "))
+  }
+
+  _, e = buf.WriteTo(w)
+  return e
+}
+
+// hexPattern matches the hexadecimal identifiers written without quotes.
+var hexPattern = regexp.MustCompile(`^0x[0-9A-Fa-f]+$`)
+
+// numberPattern matches the plain scalars that are read as numbers.
+var numberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// yamlScalar returns the YAML form of a single value.  Strings are quoted
+// unless they are hexadecimal identifiers.
+func yamlScalar(v interface{}) string {
+  switch s := v.(type) {
+  case string:
+    if hexPattern.MatchString(s) {
+      return s
+    }
+
+    quoted, _ := json.Marshal(s)
+    return string(quoted)
+  case json.Number:
+    return string(s)
+  }
+
+  return fmt.Sprint(v)
+}
+
+// DecodeYAML reads the YAML form of an exemplar from the provided Reader.
+func DecodeYAML(r io.Reader) (*Exemplar, error) {
+  buf := new(bytes.Buffer)
+  if _, e := buf.ReadFrom(r); e != nil {
+    return nil, e
+  }
+
+  tree, e := parseYAML(buf.String())
+  if e != nil {
+    return nil, e
+  }
+
+  // The tree only holds JSON compatible values, so the JSON decoding takes
+  // care of checking the document.
+  data, e := json.Marshal(tree)
+  if e != nil {
+    return nil, e
+  }
+
+  ex := New()
+  if e := ex.UnmarshalJSON(data); e != nil {
+    return nil, e
+  }
+
+  return ex, nil
+}
+
+// yamlLine is a non blank line of a YAML document, without its comment.
+type yamlLine struct {
+  number int
+  indent int
+  text string
+}
+
+// yamlParser builds a tree of map[string]interface{}, []interface{} and
+// scalar values from the lines of a YAML document.
+type yamlParser struct {
+  lines []yamlLine
+  pos int
+}
+
+// parseYAML parses a YAML document.
+func parseYAML(source string) (interface{}, error) {
+  p := new(yamlParser)
+  for i, raw := range strings.Split(strings.Replace(source, "\r\n", "\n", -1), "\n") {
+    text := stripComment(raw)
+    trimmed := strings.TrimLeft(text, " ")
+    if strings.TrimSpace(trimmed) == "" || trimmed == "---" {
+      continue
+    }
+
+    if strings.HasPrefix(trimmed, "\t") {
+      return nil, fmt.Errorf("Line %d: tabs can't be used for indentation", i + 1)
+    }
+
+    p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: strings.TrimRight(trimmed, " \t")})
+  }
+
+  if len(p.lines) == 0 {
+    return nil, fmt.Errorf("Empty YAML document")
+  }
+
+  value, e := p.block(p.lines[0].indent)
+  if e != nil {
+    return nil, e
+  }
+
+  if p.pos < len(p.lines) {
+    return nil, fmt.Errorf("Line %d: unexpected indentation", p.lines[p.pos].number)
+  }
+
+  return value, nil
+}
+
+// stripComment removes a comment from the provided line, ignoring # characters
+// inside quotes.
+func stripComment(line string) string {
+  var quote byte
+  for i := 0; i < len(line); i++ {
+    c := line[i]
+    switch {
+    case quote != 0:
+      if c == '\\' && quote == '"' {
+        i++
+      } else if c == quote {
+        quote = 0
+      }
+    case c == '"' || c == '\'':
+      quote = c
+    case c == '#' && (i == 0 || line[i - 1] == ' ' || line[i - 1] == '\t'):
+      return line[:i]
+    }
+  }
+
+  return line
+}
+
+// block parses the mapping or sequence whose lines start at the provided
+// indentation.
+func (p *yamlParser) block(indent int) (interface{}, error) {
+  line := p.lines[p.pos]
+  if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+    return p.sequence(indent)
+  }
+
+  return p.mapping(indent)
+}
+
+// sequence parses a block sequence.
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+  result := []interface{}{}
+  for p.pos < len(p.lines) {
+    line := p.lines[p.pos]
+    if line.indent < indent {
+      break
+    }
+    if line.indent > indent || !(line.text == "-" || strings.HasPrefix(line.text, "- ")) {
+      return nil, fmt.Errorf("Line %d: expected a sequence item", line.number)
+    }
+
+    rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+    if rest == "" {
+      p.pos++
+      if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+        result = append(result, nil)
+        continue
+      }
+
+      item, e := p.block(p.lines[p.pos].indent)
+      if e != nil {
+        return nil, e
+      }
+      result = append(result, item)
+      continue
+    }
+
+    // The item continues on the same line: treat its text as if it started a
+    // line of its own at the position it occupies.
+    itemIndent := line.indent + len(line.text) - len(rest)
+    if isMappingEntry(rest) || rest == "-" || strings.HasPrefix(rest, "- ") {
+      p.lines[p.pos] = yamlLine{number: line.number, indent: itemIndent, text: rest}
+      item, e := p.block(itemIndent)
+      if e != nil {
+        return nil, e
+      }
+      result = append(result, item)
+      continue
+    }
+
+    item, e := parseScalar(rest, line.number)
+    if e != nil {
+      return nil, e
+    }
+    result = append(result, item)
+    p.pos++
+  }
+
+  return result, nil
+}
+
+// isMappingEntry checks if the provided text starts with a key followed by a
+// colon.
+func isMappingEntry(text string) bool {
+  _, _, ok := splitKey(text)
+  return ok
+}
+
+// splitKey separates the key of a mapping entry from its value.
+func splitKey(text string) (key, value string, ok bool) {
+  if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+    end := closingQuote(text)
+    if end < 0 || !strings.HasPrefix(text[end + 1:], ":") {
+      return "", "", false
+    }
+
+    k, e := unquote(text[:end + 1])
+    if e != nil {
+      return "", "", false
+    }
+    rest := text[end + 2:]
+    if rest != "" && rest[0] != ' ' {
+      return "", "", false
+    }
+    return k, strings.TrimSpace(rest), true
+  }
+
+  for i := 0; i < len(text); i++ {
+    if text[i] == ':' && (i == len(text) - 1 || text[i + 1] == ' ') {
+      return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i + 1:]), i > 0
+    }
+  }
+
+  return "", "", false
+}
+
+// mapping parses a block mapping.
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+  result := make(map[string]interface{})
+  for p.pos < len(p.lines) {
+    line := p.lines[p.pos]
+    if line.indent < indent {
+      break
+    }
+    if line.indent > indent {
+      return nil, fmt.Errorf("Line %d: unexpected indentation", line.number)
+    }
+
+    key, value, ok := splitKey(line.text)
+    if !ok {
+      return nil, fmt.Errorf("Line %d: expected a key", line.number)
+    }
+    if _, found := result[key]; found {
+      return nil, fmt.Errorf("Line %d: duplicate key %q", line.number, key)
+    }
+    p.pos++
+
+    if value != "" {
+      v, e := parseScalar(value, line.number)
+      if e != nil {
+        return nil, e
+      }
+      result[key] = v
+      continue
+    }
+
+    // A nested block is either indented further, or is a sequence at the
+    // same indentation.
+    if p.pos < len(p.lines) {
+      next := p.lines[p.pos]
+      isSequence := next.text == "-" || strings.HasPrefix(next.text, "- ")
+      if next.indent > indent || (next.indent == indent && isSequence) {
+        v, e := p.block(next.indent)
+        if e != nil {
+          return nil, e
+        }
+        result[key] = v
+        continue
+      }
+    }
+
+    result[key] = nil
+  }
+
+  return result, nil
+}
+
+// parseScalar parses a scalar or a single line flow sequence.
+func parseScalar(text string, number int) (interface{}, error) {
+  if strings.HasPrefix(text, "[") {
+    if !strings.HasSuffix(text, "]") {
+      return nil, fmt.Errorf("Line %d: flow sequences must end on the same line", number)
+    }
+
+    result := []interface{}{}
+    items, e := splitFlow(text[1:len(text) - 1])
+    if e != nil {
+      return nil, fmt.Errorf("Line %d: %v", number, e)
+    }
+
+    for _, item := range items {
+      v, e := parseScalar(item, number)
+      if e != nil {
+        return nil, e
+      }
+      result = append(result, v)
+    }
+
+    return result, nil
+  }
+
+  if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+    if closingQuote(text) != len(text) - 1 {
+      return nil, fmt.Errorf("Line %d: unterminated or malformed quoted string", number)
+    }
+
+    s, e := unquote(text)
+    if e != nil {
+      return nil, fmt.Errorf("Line %d: %v", number, e)
+    }
+    return s, nil
+  }
+
+  if strings.HasPrefix(text, "{") {
+    return nil, fmt.Errorf("Line %d: flow mappings aren't supported", number)
+  }
+
+  switch text {
+  case "true", "True", "TRUE":
+    return true, nil
+  case "false", "False", "FALSE":
+    return false, nil
+  case "null", "Null", "NULL", "~":
+    return nil, nil
+  }
+
+  if numberPattern.MatchString(text) {
+    return json.Number(text), nil
+  }
+
+  return text, nil
+}
+
+// splitFlow splits the items of a flow sequence, respecting quotes.
+func splitFlow(text string) ([]string, error) {
+  var items []string
+  for {
+    text = strings.TrimSpace(text)
+    if text == "" {
+      return items, nil
+    }
+
+    // Commas inside a quoted item don't end it.
+    end := 0
+    if text[0] == '"' || text[0] == '\'' {
+      close := closingQuote(text)
+      if close < 0 {
+        return nil, fmt.Errorf("unterminated quoted string")
+      }
+
+      end = close + 1
+      if rest := strings.TrimSpace(text[end:]); rest != "" && rest[0] != ',' {
+        return nil, fmt.Errorf("expected ',' after %s", text[:end])
+      }
+    }
+
+    comma := strings.IndexByte(text[end:], ',')
+    if comma >= 0 {
+      comma += end
+    }
+
+    if comma < 0 {
+      items = append(items, strings.TrimSpace(text))
+      return items, nil
+    }
+
+    items = append(items, strings.TrimSpace(text[:comma]))
+    text = text[comma + 1:]
+  }
+}
+
+// closingQuote returns the index of the quote that closes the quoted string at
+// the start of the provided text, or -1 if there isn't one.
+func closingQuote(text string) int {
+  quote := text[0]
+  for i := 1; i < len(text); i++ {
+    switch {
+    case quote == '"' && text[i] == '\\':
+      i++
+    case quote == '\'' && text[i] == '\'' && i + 1 < len(text) && text[i + 1] == '\'':
+      i++
+    case text[i] == quote:
+      return i
+    }
+  }
+
+  return -1
+}
+
+// unquote returns the value of a single or double quoted string.
+func unquote(text string) (string, error) {
+  if text[0] == '\'' {
+    return strings.Replace(text[1:len(text) - 1], "''", "'", -1), nil
+  }
+
+  var s string
+  if e := json.Unmarshal([]byte(text), &s); e != nil {
+    // Fall back on Go's rules, which accept the YAML escapes that JSON lacks,
+    // such as \x41.
+    u, ue := strconv.Unquote(text)
+    if ue != nil {
+      return "", fmt.Errorf("Invalid quoted string %s", text)
+    }
+    return u, nil
+  }
+
+  return s, nil
+}
@@ -0,0 +1,125 @@
+package exemplar
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+func TestYAMLRoundTrip(t *testing.T) {
+  original := sampleExemplar()
+  binary, e := original.Bytes()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  buf := new(bytes.Buffer)
+  if e := original.EncodeYAML(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  text := buf.String()
+  for _, expected := range []string{
+    "cohort: false\n",
+    "parent:\n  type: 0x05342861\n",
+    "  - property: \"Exemplar Type\"\n    type: Uint32\n    values: [0x00000010]\n",
+    "    values: [\"Tree \\\"A\\\"\\n\"]\n",
+    "    multi: true\n",
+    "    values: []\n",
+  } {
+    if !strings.Contains(text, expected) {
+      t.Errorf("Expected %q in:\n%s", expected, text)
+    }
+  }
+
+  decoded, e := DecodeYAML(strings.NewReader(text))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  again, e := decoded.Bytes()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if !bytes.Equal(binary, again) {
+    t.Errorf("Expected the YAML round trip to give back the original binary from:\n%s", text)
+  }
+}
+
+func TestYAMLHandWritten(t *testing.T) {
+  text := "# A hand written cohort\r\n" +
+    "---\r\n" +
+    "cohort: true\r\n" +
+    "properties:\r\n" +
+    "- property: 'Exemplar Name'   # quoted with single quotes\r\n" +
+    "  type: String\r\n" +
+    "  values:\r\n" +
+    "    - 'It''s # not a comment'\r\n" +
+    "-\r\n" +
+    "  property: 0x00000010\r\n" +
+    "  type: Uint32\r\n" +
+    "  values: [ 16 , \"0x20\" ]\r\n"
+
+  ex, e := DecodeYAML(strings.NewReader(text))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if !ex.Cohort || len(ex.Properties) != 2 {
+    t.Fatalf("Unexpected exemplar %+v", ex)
+  }
+
+  if ex.Properties[0].StringValue() != "It's # not a comment" {
+    t.Errorf("Unexpected name %q", ex.Properties[0].StringValue())
+  }
+
+  if values := ex.Properties[1].Uint32Values(); len(values) != 2 || values[0] != 16 || values[1] != 32 {
+    t.Errorf("Unexpected values %v", values)
+  }
+}
+
+func TestYAMLErrors(t *testing.T) {
+  tests := []string{
+    "",
+    "cohort: true\n  extra: 1\n",
+    "cohort: true\ncohort: false\n",
+    "properties:\n  - property: 0x1\n    type: Uint32\n    values: [1\n",
+    "properties:\n  - property: 0x1\n    type: Uint32\n    values: {a: 1}\n",
+    "properties:\n  - property: \"0x1\n",
+    "just text\n",
+    "properties:\n\t- property: 0x1\n",
+    "properties:\n  - property: 0x1\n    type: Uint32\n    values: [\"a\" \"b\"]\n",
+    "properties:\n- ",
+  }
+
+  for _, text := range tests {
+    if _, e := DecodeYAML(strings.NewReader(text)); e == nil {
+      t.Errorf("Expected an error for %q", text)
+    }
+  }
+}
+
+func TestParseYAMLTree(t *testing.T) {
+  tree, e := parseYAML("a:\n  b: [1, -2.5e3, x, null]\n  \"c: d\": ~\nlist:\n  - - nested\n    - 2\n  - key: true\n")
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  root := tree.(map[string]interface{})
+  a := root["a"].(map[string]interface{})
+  if b := a["b"].([]interface{}); len(b) != 4 || b[2] != "x" || b[3] != nil {
+    t.Errorf("Unexpected flow sequence %v", b)
+  }
+  if v, found := a["c: d"]; !found || v != nil {
+    t.Errorf("Unexpected quoted key value %v", a)
+  }
+
+  list := root["list"].([]interface{})
+  if nested := list[0].([]interface{}); len(nested) != 2 || nested[0] != "nested" {
+    t.Errorf("Unexpected nested sequence %v", nested)
+  }
+  if m := list[1].(map[string]interface{}); m["key"] != true {
+    t.Errorf("Unexpected mapping item %v", m)
+  }
+}
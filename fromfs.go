@@ -0,0 +1,263 @@
+package godbpf
+
+import (
+  "bufio"
+  "bytes"
+  "fmt"
+  "io/fs"
+  "path"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// BuildRules describes how FromFS builds a DBPF from the files of a file
+// system.
+//
+// Without a Mapping, the files must be laid out by TGI as FileSystem shows
+// them: Type/Group/Instance, with each identifier written as 8 hexadecimal
+// digits.  The type may also be given by its name in TypeNames, and the
+// instance may be followed by an extension, such as exemplar/A8FBD372/00000001.json.
+// Files and directories whose name starts with a dot are ignored.
+//
+// With a Mapping, only the files it lists are added, under the TGIs it gives
+// them.  Each line of the mapping file holds a TGI, written as
+// TTTTTTTT-GGGGGGGG-IIIIIIII, followed by a space and the path of a file.
+// Empty lines and lines starting with # are ignored.
+type BuildRules struct {
+  // Mapping is the path of the mapping file in the file system, if any.
+  Mapping string
+
+  // TypeNames maps TypeIds to the names of their directories.  It defaults to
+  // the TypeNames variable.
+  TypeNames map[uint32]string
+
+  // Compress decides which entries are compressed.  The first rule whose
+  // pattern matches the path of a file applies, and files matched by none are
+  // stored uncompressed.
+  Compress []CompressRule
+
+  // Date is used as the created and modified dates of the DBPF, so that
+  // building the same files always gives the same bytes.  The zero value
+  // stands for the Unix epoch.
+  Date time.Time
+}
+
+// CompressRule tells whether the files matching a pattern are compressed.
+// Patterns use the syntax of path.Match.  Those holding a slash are matched
+// against the whole path of a file, and others against its base name only,
+// so that *.png matches PNG files in any directory.
+type CompressRule struct {
+  Pattern string
+  Compress bool
+}
+
+// matches checks if the receiver applies to the file with the provided path.
+func (rule *CompressRule) matches(name string) (bool, error) {
+  if !strings.Contains(rule.Pattern, "/") {
+    name = path.Base(name)
+  }
+
+  matched, e := path.Match(rule.Pattern, name)
+  if e != nil {
+    return false, fmt.Errorf("Invalid pattern %q: %v", rule.Pattern, e)
+  }
+
+  return matched, nil
+}
+
+// compress checks if the file with the provided path is to be compressed.
+func (rules *BuildRules) compress(name string) (bool, error) {
+  for i := range rules.Compress {
+    matched, e := rules.Compress[i].matches(name)
+    if e != nil || matched {
+      return rules.Compress[i].Compress, e
+    }
+  }
+
+  return false, nil
+}
+
+// buildFile is a file of the file system given to FromFS, along with the TGI
+// of its entry.
+type buildFile struct {
+  name string
+  tgi entry.DBPFEntryTGI
+}
+
+// FromFS builds a DBPF from the files of the provided file system, following
+// the provided rules, which may be nil to use the defaults.  The entries are
+// added in the order of their TGI.
+func FromFS(fsys fs.FS, rules *BuildRules) (*DBPF, error) {
+  if rules == nil {
+    rules = &BuildRules{}
+  }
+
+  var files []*buildFile
+  var e error
+  if rules.Mapping != "" {
+    files, e = readMapping(fsys, rules.Mapping)
+  } else {
+    files, e = walkLayout(fsys, rules)
+  }
+  if e != nil {
+    return nil, e
+  }
+
+  sort.Slice(files, func(i, j int) bool {
+    return files[i].tgi.Less(&files[j].tgi)
+  })
+
+  date := rules.Date
+  if date.IsZero() {
+    date = time.Unix(0, 0)
+  }
+
+  dbpf := New()
+  dbpf.MajorVersion = 1
+  dbpf.IndexMajorVersion = 7
+  dbpf.CreatedDate = date
+  dbpf.ModifiedDate = date
+
+  for i, f := range files {
+    if i > 0 && f.tgi.Equals(&files[i - 1].tgi) {
+      return nil, fmt.Errorf("%s and %s have the same TGI {%s}", files[i - 1].name, f.name, &f.tgi)
+    }
+
+    data, e := fs.ReadFile(fsys, f.name)
+    if e != nil {
+      return nil, e
+    }
+
+    compress, e := rules.compress(f.name)
+    if e != nil {
+      return nil, e
+    }
+
+    tgi := f.tgi
+    if compress {
+      dbpf.AddCompressedEntry(&tgi, data)
+    } else {
+      en := entry.NewEntry(&tgi)
+      en.SetData(data)
+      dbpf.AddEntry(en)
+    }
+  }
+
+  return dbpf, nil
+}
+
+// walkLayout returns the files of the provided file system, which are laid
+// out by TGI.
+func walkLayout(fsys fs.FS, rules *BuildRules) ([]*buildFile, error) {
+  typeNames := rules.TypeNames
+  if typeNames == nil {
+    typeNames = TypeNames
+  }
+
+  typeIds := make(map[string]uint32)
+  for typeId, name := range typeNames {
+    typeIds[name] = typeId
+  }
+
+  var files []*buildFile
+  e := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, e error) error {
+    if e != nil {
+      return e
+    }
+
+    if name != "." && strings.HasPrefix(d.Name(), ".") {
+      if d.IsDir() {
+        return fs.SkipDir
+      }
+      return nil
+    }
+
+    parts := strings.Split(name, "/")
+    if d.IsDir() {
+      if name != "." && len(parts) > 2 {
+        return fmt.Errorf("%s: expected a Type/Group/Instance layout", name)
+      }
+      return nil
+    }
+
+    if len(parts) != 3 {
+      return fmt.Errorf("%s: expected a Type/Group/Instance layout", name)
+    }
+
+    f := &buildFile{name: name}
+    typeId, found := typeIds[parts[0]]
+    if !found {
+      if typeId, found = parseHexId(parts[0]); !found {
+        return fmt.Errorf("%s: unknown type %q", name, parts[0])
+      }
+    }
+    f.tgi.TypeId = typeId
+
+    if f.tgi.GroupId, found = parseHexId(parts[1]); !found {
+      return fmt.Errorf("%s: invalid group %q", name, parts[1])
+    }
+
+    instance := parts[2]
+    if i := strings.Index(instance, "."); i >= 0 {
+      instance = instance[:i]
+    }
+    if f.tgi.InstanceId, found = parseHexId(instance); !found {
+      return fmt.Errorf("%s: invalid instance %q", name, instance)
+    }
+
+    files = append(files, f)
+    return nil
+  })
+
+  return files, e
+}
+
+// parseHexId parses an identifier written as 8 hexadecimal digits.
+func parseHexId(s string) (uint32, bool) {
+  if len(s) != 8 {
+    return 0, false
+  }
+
+  id, e := strconv.ParseUint(s, 16, 32)
+  return uint32(id), e == nil
+}
+
+// readMapping returns the files listed by the named mapping file.
+func readMapping(fsys fs.FS, mapping string) ([]*buildFile, error) {
+  data, e := fs.ReadFile(fsys, mapping)
+  if e != nil {
+    return nil, e
+  }
+
+  var files []*buildFile
+  scanner := bufio.NewScanner(bytes.NewReader(data))
+  for line := 1; scanner.Scan(); line++ {
+    text := strings.TrimSpace(scanner.Text())
+    if text == "" || strings.HasPrefix(text, "#") {
+      continue
+    }
+
+    fields := strings.SplitN(text, " ", 2)
+    ids := strings.Split(fields[0], "-")
+    if len(fields) != 2 || len(ids) != 3 {
+      return nil, fmt.Errorf("%s:%d: expected a TGI like TTTTTTTT-GGGGGGGG-IIIIIIII followed by a path", mapping, line)
+    }
+
+    f := &buildFile{name: strings.TrimSpace(fields[1])}
+    var valid [3]bool
+    f.tgi.TypeId, valid[0] = parseHexId(ids[0])
+    f.tgi.GroupId, valid[1] = parseHexId(ids[1])
+    f.tgi.InstanceId, valid[2] = parseHexId(ids[2])
+    if !valid[0] || !valid[1] || !valid[2] {
+      return nil, fmt.Errorf("%s:%d: invalid TGI %q", mapping, line, fields[0])
+    }
+
+    files = append(files, f)
+  }
+
+  return files, scanner.Err()
+}
@@ -0,0 +1,138 @@
+package godbpf
+
+import (
+  "bytes"
+  "testing"
+  "testing/fstest"
+  "time"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// contentEntries returns the entries of the provided DBPF, except the DIR
+// entry.
+func contentEntries(dbpf *DBPF) []*entry.DBPFEntry {
+  var entries []*entry.DBPFEntry
+  for _, e := range dbpf.Entries() {
+    if !e.TGI.Equals(entry.DIR_ENTRY_TGI) {
+      entries = append(entries, e)
+    }
+  }
+
+  return entries
+}
+
+func TestFromFSLayout(t *testing.T) {
+  fsys := fstest.MapFS{
+    "exemplar/A8FBD372/00000002.json": &fstest.MapFile{Data: []byte("second")},
+    "6534284A/A8FBD372/00000001": &fstest.MapFile{Data: []byte("first first first first")},
+    "png/6A386D26/00000003.png": &fstest.MapFile{Data: []byte("image")},
+    ".git/config": &fstest.MapFile{Data: []byte("ignored")},
+    "png/.keep": &fstest.MapFile{Data: []byte("ignored")},
+  }
+
+  rules := &BuildRules{Compress: []CompressRule{{Pattern: "*.png", Compress: false}, {Pattern: "*/A8FBD372/*", Compress: true}}}
+  dbpf, e := FromFS(fsys, rules)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  entries := contentEntries(dbpf)
+  if len(entries) != 3 || entries[0].TGI.InstanceId != 0x1 || entries[1].TGI.InstanceId != 0x2 || entries[2].TGI.TypeId != PNG_TYPE_ID {
+    t.Fatalf("Unexpected entries %v", entries)
+  }
+
+  if !dbpf.IsCompressed(entries[0].TGI) || !dbpf.IsCompressed(entries[1].TGI) || dbpf.IsCompressed(entries[2].TGI) {
+    t.Error("Expected the exemplars to be compressed and the image not to be")
+  }
+
+  if data, e := dbpf.GetUncompressedData(entries[1].TGI); e != nil || string(data) != "second" {
+    t.Errorf("Unexpected data %q, %v", data, e)
+  }
+
+  if dbpf.MajorVersion != 1 || dbpf.IndexMajorVersion != 7 || dbpf.ModifiedDate.Unix() != 0 {
+    t.Errorf("Unexpected header %+v", dbpf)
+  }
+
+  // Building the same files again gives the same bytes.
+  first, second := new(bytes.Buffer), new(bytes.Buffer)
+  dbpf.Save(first)
+  again, _ := FromFS(fsys, rules)
+  again.Save(second)
+  if !bytes.Equal(first.Bytes(), second.Bytes()) {
+    t.Error("Expected identical builds")
+  }
+}
+
+func TestFromFSRoundTrip(t *testing.T) {
+  dbpf := fsDBPF()
+  rebuilt, e := FromFS(FS(dbpf), &BuildRules{Date: time.Unix(1457490790, 0), Compress: []CompressRule{{Pattern: "6534284A/*/00000001", Compress: true}}})
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(contentEntries(rebuilt)) != 3 || rebuilt.ModifiedDate.Unix() != 1457490790 {
+    t.Fatalf("Unexpected entries %v", rebuilt.Entries())
+  }
+
+  for _, tgi := range []*entry.DBPFEntryTGI{
+    &entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0xA8FBD372, InstanceId: 0x1},
+    &entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0xA8FBD372, InstanceId: 0x2},
+    &entry.DBPFEntryTGI{TypeId: 0x12345678, GroupId: 0x1, InstanceId: 0x3},
+  } {
+    expected, _ := dbpf.GetUncompressedData(tgi)
+    if data, e := rebuilt.GetUncompressedData(tgi); e != nil || !bytes.Equal(data, expected) {
+      t.Errorf("Unexpected data %q for {%s}, %v", data, tgi, e)
+    }
+    if rebuilt.IsCompressed(tgi) != dbpf.IsCompressed(tgi) {
+      t.Errorf("Unexpected compression of {%s}", tgi)
+    }
+  }
+}
+
+func TestFromFSMapping(t *testing.T) {
+  fsys := fstest.MapFS{
+    "mapping.txt": &fstest.MapFile{Data: []byte("# Lot and its icon\r\n6534284A-A8FBD372-00000010 lots/my lot.json\n\n856DDBAC-6A386D26-00000010 icons/lot.png\n")},
+    "lots/my lot.json": &fstest.MapFile{Data: []byte("lot")},
+    "icons/lot.png": &fstest.MapFile{Data: []byte("icon")},
+    "unmapped.txt": &fstest.MapFile{Data: []byte("ignored")},
+  }
+
+  dbpf, e := FromFS(fsys, &BuildRules{Mapping: "mapping.txt", Compress: []CompressRule{{Pattern: "lots/*", Compress: true}}})
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  entries := contentEntries(dbpf)
+  if len(entries) != 2 || entries[0].TGI.TypeId != 0x6534284A || !dbpf.IsCompressed(entries[0].TGI) || dbpf.IsCompressed(entries[1].TGI) {
+    t.Fatalf("Unexpected entries %v", entries)
+  }
+
+  if data, e := dbpf.GetUncompressedData(entries[1].TGI); e != nil || string(data) != "icon" {
+    t.Errorf("Unexpected data %q, %v", data, e)
+  }
+}
+
+func TestFromFSErrors(t *testing.T) {
+  tests := map[string]struct {
+    fsys fstest.MapFS
+    rules *BuildRules
+  }{
+    "flat file": {fstest.MapFS{"file.dat": &fstest.MapFile{}}, nil},
+    "deep file": {fstest.MapFS{"6534284A/A8FBD372/00000001/x": &fstest.MapFile{}}, nil},
+    "unknown type": {fstest.MapFS{"unknown/A8FBD372/00000001": &fstest.MapFile{}}, nil},
+    "invalid group": {fstest.MapFS{"6534284A/A8FBD37/00000001": &fstest.MapFile{}}, nil},
+    "invalid instance": {fstest.MapFS{"6534284A/A8FBD372/0000000G": &fstest.MapFile{}}, nil},
+    "same TGI": {fstest.MapFS{"6534284A/A8FBD372/00000001": &fstest.MapFile{}, "exemplar/A8FBD372/00000001.json": &fstest.MapFile{}}, nil},
+    "invalid pattern": {fstest.MapFS{"6534284A/A8FBD372/00000001": &fstest.MapFile{}}, &BuildRules{Compress: []CompressRule{{Pattern: "[", Compress: true}}}},
+    "missing mapping": {fstest.MapFS{}, &BuildRules{Mapping: "mapping.txt"}},
+    "invalid mapping": {fstest.MapFS{"mapping.txt": &fstest.MapFile{Data: []byte("6534284A-A8FBD372 file")}}, &BuildRules{Mapping: "mapping.txt"}},
+    "mapped file missing": {fstest.MapFS{"mapping.txt": &fstest.MapFile{Data: []byte("6534284A-A8FBD372-00000001 file")}}, &BuildRules{Mapping: "mapping.txt"}},
+  }
+
+  for name, test := range tests {
+    if _, e := FromFS(test.fsys, test.rules); e == nil {
+      t.Errorf("%s: expected an error", name)
+    }
+  }
+}
@@ -0,0 +1,369 @@
+package godbpf
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "io/fs"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// TypeNames gives readable names to the types of entries commonly found in
+// DBPF files, for use as FileSystem.TypeNames.  The identifiers are spelled
+// out since the packages defining most of them import this one.
+var TypeNames = map[uint32]string{
+  0x00000000: "ui",
+  0x05342861: "cohort",
+  0x0A5BCF4B: "rul",
+  0x2026960B: "ltext",
+  0x296678F7: "path",
+  0x5AD0E817: "s3d",
+  0x6534284A: "exemplar",
+  0x7AB50E44: "fsh",
+  PNG_TYPE_ID: "png",
+  0xCA63E2A3: "lua",
+  0xEA5118B0: "effectdir",
+}
+
+// FileSystem exposes the entries of a DBPF as a read-only file system, in
+// which each entry is a file named Type/Group/Instance, with each identifier
+// written as 8 hexadecimal digits.  The DIR entry is left out, and when the
+// DBPF holds the same TGI more than once, the first entry is the one shown.
+//
+// The FileSystem works on a Snapshot of the DBPF taken by FS, so it's safe to
+// use while the DBPF changes, and later changes are only seen by a new
+// FileSystem.  The options must be set before the first use of the
+// FileSystem.
+type FileSystem struct {
+  // Raw gives files the data of the entries as stored.  Otherwise, the data of
+  // compressed entries is decompressed when their file is opened.
+  Raw bool
+
+  // TypeNames maps TypeIds to the names used for their directories instead of
+  // the hexadecimal form, such as the TypeNames variable.
+  TypeNames map[uint32]string
+
+  // dbpf is the snapshot of the DBPF.
+  dbpf *DBPF
+  once sync.Once
+  root *fsNode
+}
+
+// fsNode is a directory or file of a FileSystem.
+type fsNode struct {
+  name string
+
+  // children holds the content of a directory, sorted by name, and byName
+  // indexes it.
+  children []*fsNode
+  byName map[string]*fsNode
+
+  // entry and compressed describe the entry held by a file, and size gives
+  // the size of the file.
+  entry *entry.DBPFEntry
+  compressed bool
+  size int64
+}
+
+// FS returns a FileSystem holding the entries of the provided DBPF, which
+// implements fs.FS, fs.ReadDirFS, fs.ReadFileFS and fs.StatFS.
+func FS(dbpf *DBPF) *FileSystem {
+  return &FileSystem{dbpf: dbpf.Snapshot()}
+}
+
+// tree returns the root directory of the receiver, building it on first use.
+// Sizes come from the index, and from the DIR entry for compressed entries
+// unless Raw is set, so that no data is decompressed.
+func (fsys *FileSystem) tree() *fsNode {
+  fsys.once.Do(func() {
+    fsys.root = newDirNode(".")
+
+    dirEntry := fsys.dbpf.Find(entry.DIR_ENTRY_TGI)
+    for _, e := range fsys.dbpf.Entries() {
+      if e.TGI.Equals(entry.DIR_ENTRY_TGI) {
+        continue
+      }
+
+      file := &fsNode{name: fmt.Sprintf("%08X", e.TGI.InstanceId), entry: e, size: int64(len(e.GetData()))}
+      if dirEntry != nil {
+        var size uint32
+        if size, file.compressed = dirEntry.GetUncompressedSize(e.TGI); file.compressed && !fsys.Raw {
+          file.size = int64(size)
+        }
+      }
+
+      group := fsys.root.dir(fsys.typeName(e.TGI.TypeId)).dir(fmt.Sprintf("%08X", e.TGI.GroupId))
+      if _, found := group.byName[file.name]; !found {
+        group.add(file)
+      }
+    }
+
+    fsys.root.sort()
+  })
+
+  return fsys.root
+}
+
+// typeName returns the name of the directory of the entries with the provided
+// TypeId.
+func (fsys *FileSystem) typeName(typeId uint32) string {
+  if name, found := fsys.TypeNames[typeId]; found {
+    return name
+  }
+
+  return fmt.Sprintf("%08X", typeId)
+}
+
+// newDirNode creates an empty directory.
+func newDirNode(name string) *fsNode {
+  return &fsNode{name: name, byName: make(map[string]*fsNode)}
+}
+
+// dir returns the named subdirectory of the receiver, creating it if needed.
+func (n *fsNode) dir(name string) *fsNode {
+  if child, found := n.byName[name]; found {
+    return child
+  }
+
+  child := newDirNode(name)
+  n.add(child)
+  return child
+}
+
+// add adds a child to the receiver.
+func (n *fsNode) add(child *fsNode) {
+  n.children = append(n.children, child)
+  n.byName[child.name] = child
+}
+
+// sort sorts the content of the receiver and of its subdirectories by name.
+func (n *fsNode) sort() {
+  sort.Slice(n.children, func(i, j int) bool {
+    return n.children[i].name < n.children[j].name
+  })
+
+  for _, child := range n.children {
+    if child.byName != nil {
+      child.sort()
+    }
+  }
+}
+
+// isDir checks if the receiver is a directory.
+func (n *fsNode) isDir() bool {
+  return n.byName != nil
+}
+
+// lookup returns the node with the provided name, which must be a valid path
+// according to fs.ValidPath.
+func (fsys *FileSystem) lookup(op, name string) (*fsNode, error) {
+  if !fs.ValidPath(name) {
+    return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+  }
+
+  n := fsys.tree()
+  if name == "." {
+    return n, nil
+  }
+
+  for _, part := range strings.Split(name, "/") {
+    child, found := n.byName[part]
+    if !found {
+      return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+    }
+    n = child
+  }
+
+  return n, nil
+}
+
+// Open opens the named file or directory.
+func (fsys *FileSystem) Open(name string) (fs.File, error) {
+  n, e := fsys.lookup("open", name)
+  if e != nil {
+    return nil, e
+  }
+
+  info := fsys.info(n)
+  if n.isDir() {
+    return &fsDir{info: info, node: n, fsys: fsys}, nil
+  }
+
+  data, e := fsys.data(n)
+  if e != nil {
+    return nil, &fs.PathError{Op: "open", Path: name, Err: e}
+  }
+
+  return &fsFile{info: info, Reader: bytes.NewReader(data)}, nil
+}
+
+// ReadDir returns the content of the named directory, sorted by name.
+func (fsys *FileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+  n, e := fsys.lookup("readdir", name)
+  if e != nil {
+    return nil, e
+  }
+
+  if !n.isDir() {
+    return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("Not a directory")}
+  }
+
+  return fsys.dirEntries(n.children), nil
+}
+
+// ReadFile returns the content of the named file.
+func (fsys *FileSystem) ReadFile(name string) ([]byte, error) {
+  n, e := fsys.lookup("read", name)
+  if e != nil {
+    return nil, e
+  }
+
+  if n.isDir() {
+    return nil, &fs.PathError{Op: "read", Path: name, Err: fmt.Errorf("Is a directory")}
+  }
+
+  data, e := fsys.data(n)
+  if e != nil {
+    return nil, &fs.PathError{Op: "read", Path: name, Err: e}
+  }
+
+  return append([]byte(nil), data...), nil
+}
+
+// Stat describes the named file or directory.
+func (fsys *FileSystem) Stat(name string) (fs.FileInfo, error) {
+  n, e := fsys.lookup("stat", name)
+  if e != nil {
+    return nil, e
+  }
+
+  return fsys.info(n), nil
+}
+
+// data returns the content of the provided file, which may be shared with
+// the entry and must not be modified.
+func (fsys *FileSystem) data(n *fsNode) ([]byte, error) {
+  if !n.compressed || fsys.Raw {
+    return n.entry.GetData(), nil
+  }
+
+  data, e := Decompress(n.entry.GetData())
+  if e != nil {
+    return nil, fmt.Errorf("Compressed entry {%s}: %v", n.entry.TGI, e)
+  }
+
+  return data, nil
+}
+
+// info returns the description of the provided node.
+func (fsys *FileSystem) info(n *fsNode) *fsInfo {
+  return &fsInfo{node: n, modTime: fsys.dbpf.ModifiedDate}
+}
+
+// dirEntries returns the descriptions of the provided nodes.
+func (fsys *FileSystem) dirEntries(nodes []*fsNode) []fs.DirEntry {
+  entries := make([]fs.DirEntry, len(nodes))
+  for i, n := range nodes {
+    entries[i] = fs.FileInfoToDirEntry(fsys.info(n))
+  }
+
+  return entries
+}
+
+// fsInfo implements fs.FileInfo for the nodes of a FileSystem.  Files carry
+// the TGI of their entry as the value of Sys.
+type fsInfo struct {
+  node *fsNode
+  modTime time.Time
+}
+
+func (i *fsInfo) Name() string {
+  return i.node.name
+}
+
+func (i *fsInfo) Size() int64 {
+  return i.node.size
+}
+
+func (i *fsInfo) Mode() fs.FileMode {
+  if i.node.isDir() {
+    return fs.ModeDir | 0555
+  }
+
+  return 0444
+}
+
+func (i *fsInfo) ModTime() time.Time {
+  return i.modTime
+}
+
+func (i *fsInfo) IsDir() bool {
+  return i.node.isDir()
+}
+
+func (i *fsInfo) Sys() interface{} {
+  if i.node.entry != nil {
+    return i.node.entry.TGI
+  }
+
+  return nil
+}
+
+// fsFile is an open file of a FileSystem.  Besides reading, it supports
+// seeking as needed by http.FileServer.
+type fsFile struct {
+  *bytes.Reader
+  info *fsInfo
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+  return f.info, nil
+}
+
+func (f *fsFile) Close() error {
+  return nil
+}
+
+// fsDir is an open directory of a FileSystem.
+type fsDir struct {
+  info *fsInfo
+  node *fsNode
+  fsys *FileSystem
+
+  // offset is the number of children already returned by ReadDir.
+  offset int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+  return d.info, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+  return 0, &fs.PathError{Op: "read", Path: d.node.name, Err: fmt.Errorf("Is a directory")}
+}
+
+func (d *fsDir) Close() error {
+  return nil
+}
+
+// ReadDir returns the next n entries of the directory, or all the remaining
+// ones if n <= 0, following the contract of fs.ReadDirFile.
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+  remaining := d.node.children[d.offset:]
+  if n > 0 {
+    if len(remaining) == 0 {
+      return nil, io.EOF
+    }
+    if n < len(remaining) {
+      remaining = remaining[:n]
+    }
+  }
+
+  d.offset += len(remaining)
+  return d.fsys.dirEntries(remaining), nil
+}
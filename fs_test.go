@@ -0,0 +1,124 @@
+package godbpf
+
+import (
+  "bytes"
+  "io"
+  "io/fs"
+  "testing"
+  "testing/fstest"
+  "text/template"
+  "time"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func fsDBPF() *DBPF {
+  dbpf := New()
+  dbpf.ModifiedDate = time.Unix(1457490790, 0)
+
+  plain := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0xA8FBD372, InstanceId: 0x2})
+  plain.SetData([]byte("plain"))
+  dbpf.AddEntry(plain)
+
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0xA8FBD372, InstanceId: 0x1}, []byte("Hello {{.}} and {{.}}"))
+
+  other := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: 0x12345678, GroupId: 0x1, InstanceId: 0x3})
+  other.SetData([]byte("other"))
+  dbpf.AddEntry(other)
+
+  shadowed := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: 0x12345678, GroupId: 0x1, InstanceId: 0x3})
+  shadowed.SetData([]byte("shadowed"))
+  dbpf.AddEntry(shadowed)
+
+  return dbpf
+}
+
+func TestFS(t *testing.T) {
+  fsys := FS(fsDBPF())
+  if e := fstest.TestFS(fsys, "12345678/00000001/00000003", "6534284A/A8FBD372/00000001", "6534284A/A8FBD372/00000002"); e != nil {
+    t.Fatal(e)
+  }
+
+  data, e := fs.ReadFile(fsys, "6534284A/A8FBD372/00000001")
+  if e != nil || string(data) != "Hello {{.}} and {{.}}" {
+    t.Errorf("Unexpected data %q, %v", data, e)
+  }
+  if data, e := fs.ReadFile(fsys, "12345678/00000001/00000003"); e != nil || string(data) != "other" {
+    t.Errorf("Expected the first entry with a TGI to be shown, got %q, %v", data, e)
+  }
+
+  info, e := fs.Stat(fsys, "6534284A/A8FBD372/00000001")
+  if e != nil || info.Size() != 21 || info.IsDir() || !info.ModTime().Equal(time.Unix(1457490790, 0)) {
+    t.Errorf("Unexpected info %v, %v", info, e)
+  }
+  if tgi, ok := info.Sys().(*entry.DBPFEntryTGI); !ok || tgi.InstanceId != 0x1 {
+    t.Errorf("Expected the TGI of the entry, got %v", info.Sys())
+  }
+
+  if _, e := fsys.Open("6534284A/A8FBD372/00000009"); e == nil {
+    t.Error("Expected an error for a missing file")
+  }
+  if _, e := fsys.Open("/6534284A"); e == nil {
+    t.Error("Expected an error for an invalid path")
+  }
+  if _, e := fsys.ReadDir("6534284A/A8FBD372/00000001"); e == nil {
+    t.Error("Expected an error for reading a file as a directory")
+  }
+
+  var files []string
+  fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, e error) error {
+    if !d.IsDir() {
+      files = append(files, path)
+    }
+    return e
+  })
+  if len(files) != 3 {
+    t.Errorf("Unexpected files %v", files)
+  }
+
+  tmpl, e := template.ParseFS(fsys, "6534284A/*/00000001")
+  if e != nil {
+    t.Fatal(e)
+  }
+  buf := new(bytes.Buffer)
+  if e := tmpl.Execute(buf, "you"); e != nil || buf.String() != "Hello you and you" {
+    t.Errorf("Unexpected template output %q, %v", buf, e)
+  }
+}
+
+func TestFSOptions(t *testing.T) {
+  dbpf := fsDBPF()
+  fsys := FS(dbpf)
+  fsys.Raw = true
+  fsys.TypeNames = TypeNames
+  if e := fstest.TestFS(fsys, "12345678/00000001/00000003", "exemplar/A8FBD372/00000001"); e != nil {
+    t.Fatal(e)
+  }
+
+  data, e := fs.ReadFile(fsys, "exemplar/A8FBD372/00000001")
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  stored := dbpf.Find(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0xA8FBD372, InstanceId: 0x1}).GetData()
+  if !bytes.Equal(data, stored) {
+    t.Errorf("Expected the stored data, got %q", data)
+  }
+  if info, _ := fs.Stat(fsys, "exemplar/A8FBD372/00000001"); info.Size() != int64(len(stored)) {
+    t.Errorf("Expected the stored size, got %d", info.Size())
+  }
+
+  f, e := fsys.Open("exemplar")
+  if e != nil {
+    t.Fatal(e)
+  }
+  defer f.Close()
+
+  dir := f.(fs.ReadDirFile)
+  if entries, e := dir.ReadDir(1); e != nil || len(entries) != 1 || entries[0].Name() != "A8FBD372" || !entries[0].IsDir() {
+    t.Errorf("Unexpected entries %v, %v", entries, e)
+  }
+  if _, e := dir.ReadDir(1); e != io.EOF {
+    t.Errorf("Expected io.EOF, got %v", e)
+  }
+}
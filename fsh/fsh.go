@@ -0,0 +1,275 @@
+package fsh
+
+import (
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "image"
+  "image/color"
+  "io"
+  "io/ioutil"
+)
+
+// FSH_TYPE_ID is the TypeId used by FSH texture entries.
+const FSH_TYPE_ID uint32 = 0x7AB50E44
+
+// Format identifies how the pixels of a Bitmap are encoded.
+type Format byte
+
+const (
+  DXT1 Format = 0x60
+  DXT3 Format = 0x61
+  ARGB4444 Format = 0x6D
+  RGB565 Format = 0x78
+  ARGB1555 Format = 0x7E
+  ARGB8888 Format = 0x7D
+  RGB888 Format = 0x7F
+)
+
+// Bitmap is a single image stored in an FSH file.  Only the full size image
+// is decoded; any mipmaps that follow it are ignored.
+type Bitmap struct {
+  // Name is the 4 character name of the bitmap from the FSH directory.
+  Name string
+
+  // Format is the encoding of the bitmap's pixels.
+  Format Format
+
+  // Image is the decoded bitmap.
+  Image *image.NRGBA
+}
+
+// File holds the decoded contents of an FSH file.
+type File struct {
+  // DirectoryID is the 4 character identifier from the FSH header.
+  DirectoryID string
+
+  // Bitmaps lists the bitmaps in directory order.
+  Bitmaps []*Bitmap
+}
+
+// Decode reads an FSH file from the provided Reader.
+func Decode(r io.Reader) (*File, error) {
+  data, e := ioutil.ReadAll(r)
+  if e != nil {
+    return nil, e
+  }
+
+  if len(data) < 16 || string(data[0:4]) != "SHPI" {
+    return nil, errors.New("Invalid FSH magic number")
+  }
+
+  count := binary.LittleEndian.Uint32(data[8:12])
+  if uint64(len(data)) < 16 + 8 * uint64(count) {
+    return nil, errors.New("FSH directory is truncated")
+  }
+
+  file := &File{DirectoryID: string(data[12:16])}
+  for i := uint32(0); i < count; i++ {
+    dir := data[16 + 8 * i:24 + 8 * i]
+    offset := binary.LittleEndian.Uint32(dir[4:8])
+
+    bitmap, e := decodeBitmap(data, offset)
+    if e != nil {
+      return nil, fmt.Errorf("Bitmap %d: %s", i, e)
+    }
+
+    bitmap.Name = string(dir[0:4])
+    file.Bitmaps = append(file.Bitmaps, bitmap)
+  }
+
+  return file, nil
+}
+
+// decodeBitmap decodes the bitmap whose 16 byte header starts at the provided
+// offset in data.
+func decodeBitmap(data []byte, offset uint32) (*Bitmap, error) {
+  if uint64(offset) + 16 > uint64(len(data)) {
+    return nil, errors.New("Bitmap header is out of bounds")
+  }
+
+  header := data[offset:offset + 16]
+  format := Format(header[0])
+  width := int(binary.LittleEndian.Uint16(header[4:6]))
+  height := int(binary.LittleEndian.Uint16(header[6:8]))
+  pixels := data[offset + 16:]
+
+  var img *image.NRGBA
+  var e error
+  switch format {
+  case DXT1, DXT3:
+    img, e = decodeDXT(pixels, width, height, format == DXT3)
+  case ARGB8888, RGB888, ARGB4444, RGB565, ARGB1555:
+    img, e = decodeRaw(pixels, width, height, format)
+  default:
+    e = fmt.Errorf("Unsupported bitmap format 0x%02X", byte(format))
+  }
+
+  if e != nil {
+    return nil, e
+  }
+
+  return &Bitmap{Format: format, Image: img}, nil
+}
+
+// bytesPerPixel returns the size of a pixel in the uncompressed formats.
+func bytesPerPixel(format Format) int {
+  switch format {
+  case ARGB8888:
+    return 4
+  case RGB888:
+    return 3
+  }
+
+  return 2
+}
+
+// decodeRaw decodes uncompressed pixels, which are stored with their blue
+// component first.
+func decodeRaw(pixels []byte, width, height int, format Format) (*image.NRGBA, error) {
+  size := bytesPerPixel(format)
+  if len(pixels) < width * height * size {
+    return nil, errors.New("Bitmap data is truncated")
+  }
+
+  img := image.NewNRGBA(image.Rect(0, 0, width, height))
+  for y := 0; y < height; y++ {
+    for x := 0; x < width; x++ {
+      p := pixels[(y * width + x) * size:]
+
+      var c color.NRGBA
+      switch format {
+      case ARGB8888:
+        c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: p[3]}
+      case RGB888:
+        c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF}
+      case ARGB4444:
+        v := binary.LittleEndian.Uint16(p)
+        c = color.NRGBA{R: byte(v >> 8 & 0xF) * 0x11, G: byte(v >> 4 & 0xF) * 0x11, B: byte(v & 0xF) * 0x11, A: byte(v >> 12) * 0x11}
+      case RGB565:
+        c = rgb565(binary.LittleEndian.Uint16(p))
+      case ARGB1555:
+        v := binary.LittleEndian.Uint16(p)
+        c = color.NRGBA{R: expand5(v >> 10), G: expand5(v >> 5), B: expand5(v), A: 0}
+        if v & 0x8000 != 0 {
+          c.A = 0xFF
+        }
+      }
+
+      img.SetNRGBA(x, y, c)
+    }
+  }
+
+  return img, nil
+}
+
+// decodeDXT decodes DXT1 or DXT3 compressed pixels.  Both formats store the
+// image as 4x4 pixel blocks; DXT3 blocks begin with 8 bytes of explicit 4 bit
+// alpha values.
+func decodeDXT(pixels []byte, width, height int, explicitAlpha bool) (*image.NRGBA, error) {
+  blockSize := 8
+  if explicitAlpha {
+    blockSize = 16
+  }
+
+  blocksWide := (width + 3) / 4
+  blocksHigh := (height + 3) / 4
+  if len(pixels) < blocksWide * blocksHigh * blockSize {
+    return nil, errors.New("Bitmap data is truncated")
+  }
+
+  img := image.NewNRGBA(image.Rect(0, 0, width, height))
+  for by := 0; by < blocksHigh; by++ {
+    for bx := 0; bx < blocksWide; bx++ {
+      block := pixels[(by * blocksWide + bx) * blockSize:]
+
+      var alpha []byte
+      if explicitAlpha {
+        alpha = block[0:8]
+        block = block[8:]
+      }
+
+      palette := dxtPalette(binary.LittleEndian.Uint16(block[0:2]), binary.LittleEndian.Uint16(block[2:4]), !explicitAlpha)
+      indices := binary.LittleEndian.Uint32(block[4:8])
+
+      for i := 0; i < 16; i++ {
+        x, y := bx * 4 + i % 4, by * 4 + i / 4
+        if x >= width || y >= height {
+          continue
+        }
+
+        c := palette[indices >> uint(2 * i) & 0x3]
+        if explicitAlpha {
+          c.A = (alpha[i / 2] >> uint(4 * (i % 2)) & 0xF) * 0x11
+        }
+
+        img.SetNRGBA(x, y, c)
+      }
+    }
+  }
+
+  return img, nil
+}
+
+// dxtPalette builds the 4 colour palette of a DXT block from its two endpoint
+// colours.  When allowTransparent is set and the first colour isn't greater
+// than the second, the block uses 3 colours and transparent black.
+func dxtPalette(c0, c1 uint16, allowTransparent bool) [4]color.NRGBA {
+  var palette [4]color.NRGBA
+  palette[0] = rgb565(c0)
+  palette[1] = rgb565(c1)
+
+  if c0 > c1 || !allowTransparent {
+    palette[2] = mix(palette[0], palette[1], 2, 1)
+    palette[3] = mix(palette[0], palette[1], 1, 2)
+  } else {
+    palette[2] = mix(palette[0], palette[1], 1, 1)
+    palette[3] = color.NRGBA{}
+  }
+
+  return palette
+}
+
+// mix blends two colours using the provided weights.
+func mix(a, b color.NRGBA, wa, wb int) color.NRGBA {
+  total := wa + wb
+  return color.NRGBA{
+    R: byte((int(a.R) * wa + int(b.R) * wb) / total),
+    G: byte((int(a.G) * wa + int(b.G) * wb) / total),
+    B: byte((int(a.B) * wa + int(b.B) * wb) / total),
+    A: 0xFF,
+  }
+}
+
+// rgb565 converts a 16 bit 5:6:5 colour to an opaque NRGBA colour.
+func rgb565(v uint16) color.NRGBA {
+  g := byte(v >> 5 & 0x3F)
+  return color.NRGBA{R: expand5(v >> 11), G: g << 2 | g >> 4, B: expand5(v), A: 0xFF}
+}
+
+// expand5 scales the lowest 5 bits of the provided value to 8 bits.
+func expand5(v uint16) byte {
+  b := byte(v & 0x1F)
+  return b << 3 | b >> 2
+}
+
+// Image returns the first bitmap of the receiver, which is the one used for
+// textures.
+func (f *File) Image() (*image.NRGBA, error) {
+  if len(f.Bitmaps) == 0 {
+    return nil, errors.New("FSH file has no bitmaps")
+  }
+
+  return f.Bitmaps[0].Image, nil
+}
+
+// DecodeImage decodes FSH data and returns its first bitmap.
+func DecodeImage(data []byte) (*image.NRGBA, error) {
+  f, e := Decode(bytes.NewReader(data))
+  if e != nil {
+    return nil, e
+  }
+
+  return f.Image()
+}
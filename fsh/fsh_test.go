@@ -0,0 +1,113 @@
+package fsh
+
+import (
+  "bytes"
+  "encoding/binary"
+  "image/color"
+  "testing"
+)
+
+// CreateFSH builds an FSH file with a single bitmap of the provided format and
+// pixel data.
+func CreateFSH(format Format, width, height uint16, pixels []byte) []byte {
+  buf := new(bytes.Buffer)
+  buf.WriteString("SHPI")
+  binary.Write(buf, binary.LittleEndian, uint32(24 + 16 + len(pixels)))
+  binary.Write(buf, binary.LittleEndian, uint32(1))
+  buf.WriteString("G264")
+  buf.WriteString("0000")
+  binary.Write(buf, binary.LittleEndian, uint32(24))
+  buf.Write([]byte{ byte(format), 0, 0, 0 })
+  binary.Write(buf, binary.LittleEndian, width)
+  binary.Write(buf, binary.LittleEndian, height)
+  buf.Write(make([]byte, 8))
+  buf.Write(pixels)
+
+  return buf.Bytes()
+}
+
+func TestDecodeARGB8888(t *testing.T) {
+  data := CreateFSH(ARGB8888, 2, 1, []byte{ 0x10, 0x20, 0x30, 0xFF, 0x40, 0x50, 0x60, 0x80 })
+
+  f, e := Decode(bytes.NewReader(data))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if f.DirectoryID != "G264" || len(f.Bitmaps) != 1 || f.Bitmaps[0].Name != "0000" || f.Bitmaps[0].Format != ARGB8888 {
+    t.Fatalf("Unexpected file %+v", f)
+  }
+
+  img := f.Bitmaps[0].Image
+  if img.Bounds().Dx() != 2 || img.Bounds().Dy() != 1 {
+    t.Error()
+  }
+
+  if c := img.NRGBAAt(0, 0); c != (color.NRGBA{R: 0x30, G: 0x20, B: 0x10, A: 0xFF}) {
+    t.Errorf("Unexpected colour %v", c)
+  }
+
+  if c := img.NRGBAAt(1, 0); c != (color.NRGBA{R: 0x60, G: 0x50, B: 0x40, A: 0x80}) {
+    t.Errorf("Unexpected colour %v", c)
+  }
+}
+
+func TestDecodeDXT1(t *testing.T) {
+  // Pure red and pure blue endpoints, with the first row using each of the 4
+  // palette entries and the remaining rows using the first colour.
+  data := CreateFSH(DXT1, 4, 4, []byte{ 0x00, 0xF8, 0x1F, 0x00, 0xE4, 0x00, 0x00, 0x00 })
+
+  img, e := DecodeImage(data)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  expected := []color.NRGBA{
+    color.NRGBA{R: 0xFF, A: 0xFF},
+    color.NRGBA{B: 0xFF, A: 0xFF},
+    color.NRGBA{R: 0xAA, B: 0x55, A: 0xFF},
+    color.NRGBA{R: 0x55, B: 0xAA, A: 0xFF},
+  }
+
+  for x, c := range expected {
+    if actual := img.NRGBAAt(x, 0); actual != c {
+      t.Errorf("Pixel %d: expected %v but was %v", x, c, actual)
+    }
+  }
+
+  if actual := img.NRGBAAt(3, 3); actual != expected[0] {
+    t.Error()
+  }
+}
+
+func TestDecodeDXT3(t *testing.T) {
+  pixels := []byte{ 0xF0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+                    0x00, 0xF8, 0x1F, 0x00, 0x00, 0x00, 0x00, 0x00 }
+
+  img, e := DecodeImage(CreateFSH(DXT3, 4, 4, pixels))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if c := img.NRGBAAt(0, 0); c.A != 0 || c.R != 0xFF {
+    t.Errorf("Unexpected colour %v", c)
+  }
+
+  if c := img.NRGBAAt(1, 0); c.A != 0xFF {
+    t.Errorf("Unexpected colour %v", c)
+  }
+}
+
+func TestDecodeInvalidFSH(t *testing.T) {
+  if _, e := Decode(bytes.NewReader([]byte("XXXX"))); e == nil {
+    t.Error()
+  }
+
+  if _, e := DecodeImage(CreateFSH(Format(0x01), 1, 1, []byte{ 0 })); e == nil {
+    t.Error()
+  }
+
+  if _, e := DecodeImage(CreateFSH(ARGB8888, 4, 4, []byte{ 0 })); e == nil {
+    t.Error()
+  }
+}
@@ -0,0 +1,120 @@
+package godbpf
+
+import (
+  "image"
+  "image/color"
+)
+
+// MENU_ICON_SIZE is the width and height of each state of a menu button icon.
+const MENU_ICON_SIZE = 44
+
+// MENU_ICON_GROUP_ID is the GroupId of the PNG entries that hold menu button
+// icons.  Their InstanceId is the value of the Item Icon property of the
+// exemplar they belong to.
+const MENU_ICON_GROUP_ID uint32 = 0x6A386D26
+
+// The states of a menu button, in the order they appear from left to right in
+// a menu icon strip.
+const (
+  MenuIconNormal = iota
+  MenuIconHover
+  MenuIconPressed
+  MenuIconDisabled
+  menuIconStates
+)
+
+// MenuIconStrip builds a menu button icon from the provided image.  The image
+// is scaled to MENU_ICON_SIZE pixels square and drawn 4 times side by side: as
+// is for the normal state, brightened for the hover state, darkened and moved
+// down by a pixel for the pressed state, and greyed out for the disabled state.
+func MenuIconStrip(src image.Image) *image.NRGBA {
+  icon := scaleImage(src, MENU_ICON_SIZE, MENU_ICON_SIZE)
+  strip := image.NewNRGBA(image.Rect(0, 0, MENU_ICON_SIZE * menuIconStates, MENU_ICON_SIZE))
+
+  for state := 0; state < menuIconStates; state++ {
+    offsetX, offsetY := state * MENU_ICON_SIZE, 0
+    if state == MenuIconPressed {
+      offsetY = 1
+    }
+
+    for y := 0; y < MENU_ICON_SIZE; y++ {
+      for x := 0; x < MENU_ICON_SIZE; x++ {
+        if y + offsetY >= MENU_ICON_SIZE {
+          continue
+        }
+
+        c := icon.NRGBAAt(x, y)
+        switch state {
+        case MenuIconHover:
+          c = shade(c, 1.25)
+        case MenuIconPressed:
+          c = shade(c, 0.8)
+        case MenuIconDisabled:
+          grey := uint8((299 * uint32(c.R) + 587 * uint32(c.G) + 114 * uint32(c.B)) / 1000)
+          grey = grey / 2 + 0x40
+          c = color.NRGBA{R: grey, G: grey, B: grey, A: uint8(uint32(c.A) * 3 / 4)}
+        }
+
+        strip.SetNRGBA(offsetX + x, y + offsetY, c)
+      }
+    }
+  }
+
+  return strip
+}
+
+// shade multiplies the colour components of the provided colour by the
+// provided factor, leaving its alpha unchanged.
+func shade(c color.NRGBA, factor float64) color.NRGBA {
+  scale := func(v uint8) uint8 {
+    result := float64(v) * factor
+    if result > 0xFF {
+      return 0xFF
+    }
+    return uint8(result + 0.5)
+  }
+
+  return color.NRGBA{R: scale(c.R), G: scale(c.G), B: scale(c.B), A: c.A}
+}
+
+// scaleImage resizes the provided image to the provided size, averaging the
+// source pixels that fall within each destination pixel.
+func scaleImage(src image.Image, width, height int) *image.NRGBA {
+  dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+  b := src.Bounds()
+  if b.Empty() {
+    return dst
+  }
+
+  for y := 0; y < height; y++ {
+    y0 := b.Min.Y + y * b.Dy() / height
+    y1 := b.Min.Y + (y + 1) * b.Dy() / height
+    if y1 <= y0 {
+      y1 = y0 + 1
+    }
+
+    for x := 0; x < width; x++ {
+      x0 := b.Min.X + x * b.Dx() / width
+      x1 := b.Min.X + (x + 1) * b.Dx() / width
+      if x1 <= x0 {
+        x1 = x0 + 1
+      }
+
+      // Average with premultiplied alpha so that transparent pixels don't
+      // darken the edges.
+      var r, g, bl, a, n uint64
+      for sy := y0; sy < y1; sy++ {
+        for sx := x0; sx < x1; sx++ {
+          pr, pg, pb, pa := src.At(sx, sy).RGBA()
+          r, g, bl, a = r + uint64(pr), g + uint64(pg), bl + uint64(pb), a + uint64(pa)
+          n++
+        }
+      }
+
+      c := color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)}
+      dst.Set(x, y, c)
+    }
+  }
+
+  return dst
+}
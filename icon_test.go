@@ -0,0 +1,51 @@
+package godbpf
+
+import (
+  "image/color"
+  "testing"
+)
+
+func TestMenuIconStrip(t *testing.T) {
+  src := solidImage(88, 88, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+  strip := MenuIconStrip(src)
+
+  if strip.Bounds().Dx() != MENU_ICON_SIZE * 4 || strip.Bounds().Dy() != MENU_ICON_SIZE {
+    t.Fatalf("Unexpected strip size %v", strip.Bounds())
+  }
+
+  at := func(state, x, y int) color.NRGBA {
+    return strip.NRGBAAt(state * MENU_ICON_SIZE + x, y)
+  }
+
+  if c := at(MenuIconNormal, 10, 10); c != (color.NRGBA{R: 100, G: 100, B: 100, A: 255}) {
+    t.Errorf("Unexpected normal pixel %v", c)
+  }
+
+  if c := at(MenuIconHover, 10, 10); c.R != 125 || c.A != 255 {
+    t.Errorf("Unexpected hover pixel %v", c)
+  }
+
+  if c := at(MenuIconPressed, 10, 10); c.R != 80 {
+    t.Errorf("Unexpected pressed pixel %v", c)
+  }
+  if c := at(MenuIconPressed, 10, 0); c.A != 0 {
+    t.Errorf("Expected the pressed state to be moved down, got %v", c)
+  }
+
+  if c := at(MenuIconDisabled, 10, 10); c.R != c.G || c.G != c.B || c.A >= 255 {
+    t.Errorf("Unexpected disabled pixel %v", c)
+  }
+}
+
+func TestScaleImageAveragesPixels(t *testing.T) {
+  src := solidImage(2, 1, color.NRGBA{R: 200, A: 255})
+  src.SetNRGBA(1, 0, color.NRGBA{R: 0, A: 255})
+
+  if c := scaleImage(src, 1, 1).NRGBAAt(0, 0); c.R < 99 || c.R > 101 || c.A != 255 {
+    t.Errorf("Unexpected averaged pixel %v", c)
+  }
+
+  if c := scaleImage(src, 4, 2).NRGBAAt(3, 1); c.R != 0 {
+    t.Errorf("Unexpected enlarged pixel %v", c)
+  }
+}
@@ -0,0 +1,298 @@
+package iid
+
+import (
+  "encoding/json"
+  "fmt"
+  "io"
+  "sort"
+  "strconv"
+  "strings"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// ZOOM_LEVELS is the number of zoom levels the game renders.
+const ZOOM_LEVELS = 5
+
+// ROTATIONS is the number of rotations the game renders.
+const ROTATIONS = 4
+
+// Kind describes the block of instance IDs used by a resource.
+type Kind int
+
+const (
+  // Single is a resource using a single instance ID, such as an exemplar or
+  // an LTEXT entry.
+  Single Kind = iota
+
+  // Texture is a texture stored once per zoom level, with the zoom level
+  // added to an instance ID ending in 0.
+  Texture
+
+  // Model is a model stored once per zoom level and rotation, with the zoom
+  // level in the third hexadecimal digit and the rotation in the second one of
+  // an instance ID ending in 000.
+  Model
+)
+
+// String returns the name of the receiver.
+func (k Kind) String() string {
+  switch k {
+  case Single:
+    return "single"
+  case Texture:
+    return "texture"
+  case Model:
+    return "model"
+  }
+
+  return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Span returns the number of consecutive instance IDs reserved for a resource
+// of the receiver Kind.  Blocks are aligned on their span, so the low bits of
+// the instance IDs are free for the zoom level and rotation.
+func (k Kind) Span() uint32 {
+  switch k {
+  case Texture:
+    return 0x10
+  case Model:
+    return 0x1000
+  }
+
+  return 1
+}
+
+// InstanceIds returns the instance IDs used by a resource of the receiver Kind
+// whose block starts at the provided instance ID.
+func (k Kind) InstanceIds(base uint32) []uint32 {
+  switch k {
+  case Texture:
+    result := make([]uint32, 0, ZOOM_LEVELS)
+    for zoom := 0; zoom < ZOOM_LEVELS; zoom++ {
+      result = append(result, TextureInstanceId(base, zoom))
+    }
+    return result
+  case Model:
+    result := make([]uint32, 0, ZOOM_LEVELS * ROTATIONS)
+    for zoom := 0; zoom < ZOOM_LEVELS; zoom++ {
+      for rotation := 0; rotation < ROTATIONS; rotation++ {
+        result = append(result, ModelInstanceId(base, zoom, rotation))
+      }
+    }
+    return result
+  }
+
+  return []uint32{base}
+}
+
+// TextureInstanceId returns the instance ID of a texture at the provided zoom
+// level, from 0 to 4.
+func TextureInstanceId(base uint32, zoom int) uint32 {
+  return base + uint32(zoom)
+}
+
+// ModelInstanceId returns the instance ID of a model at the provided zoom
+// level, from 0 to 4, and rotation, from 0 to 3.
+func ModelInstanceId(base uint32, zoom, rotation int) uint32 {
+  return base + uint32(zoom) << 8 + uint32(rotation) << 4
+}
+
+// span is an inclusive range of instance IDs.
+type span struct {
+  first, last uint32
+}
+
+// Allocator hands out unused instance IDs from a reserved range.  Instance IDs
+// are used once they appear in one of the DBPFs or TGIs added to the
+// allocator, or once they have been allocated.
+type Allocator struct {
+  // First and Last are the first and last instance IDs of the reserved range.
+  First, Last uint32
+
+  // used holds the used instance IDs within the range, sorted and merged.
+  used []span
+}
+
+// New creates an Allocator for the provided range, which includes first and
+// last.
+func New(first, last uint32) (*Allocator, error) {
+  if first > last {
+    return nil, fmt.Errorf("Invalid range 0x%08X-0x%08X", first, last)
+  }
+
+  return &Allocator{First: first, Last: last}, nil
+}
+
+// Copy returns a copy of the receiver, which can be changed without affecting
+// it.
+func (a *Allocator) Copy() *Allocator {
+  return &Allocator{First: a.First, Last: a.Last, used: append([]span(nil), a.used...)}
+}
+
+// MarkUsed marks the instance IDs from first to last as used.  Those outside of
+// the reserved range are ignored.
+func (a *Allocator) MarkUsed(first, last uint32) {
+  if first < a.First {
+    first = a.First
+  }
+  if last > a.Last {
+    last = a.Last
+  }
+  if first > last {
+    return
+  }
+
+  // Find the spans that touch the new one and replace them with their union.
+  start := sort.Search(len(a.used), func(i int) bool { return uint64(a.used[i].last) + 1 >= uint64(first) })
+  end := start
+  for end < len(a.used) && uint64(a.used[end].first) <= uint64(last) + 1 {
+    if a.used[end].first < first {
+      first = a.used[end].first
+    }
+    if a.used[end].last > last {
+      last = a.used[end].last
+    }
+    end++
+  }
+
+  merged := append([]span{}, a.used[:start]...)
+  merged = append(merged, span{first, last})
+  a.used = append(merged, a.used[end:]...)
+}
+
+// AddTGIs marks the instance IDs of the provided TGIs as used.
+func (a *Allocator) AddTGIs(tgis []*entry.DBPFEntryTGI) {
+  for _, tgi := range tgis {
+    a.MarkUsed(tgi.InstanceId, tgi.InstanceId)
+  }
+}
+
+// AddDBPF marks the instance IDs of the entries of the provided DBPF as used.
+func (a *Allocator) AddDBPF(dbpf *godbpf.DBPF) {
+  for _, e := range dbpf.Entries() {
+    a.MarkUsed(e.TGI.InstanceId, e.TGI.InstanceId)
+  }
+}
+
+// IsUsed checks if any of the instance IDs from first to last is used.
+func (a *Allocator) IsUsed(first, last uint32) bool {
+  i := sort.Search(len(a.used), func(i int) bool { return a.used[i].last >= first })
+  return i < len(a.used) && a.used[i].first <= last
+}
+
+// Allocate returns the start of the lowest free block of instance IDs for a
+// resource of the provided Kind, and marks the whole block as used.
+func (a *Allocator) Allocate(kind Kind) (uint32, error) {
+  size := uint64(kind.Span())
+  alignUp := func(v uint64) uint64 {
+    return (v + size - 1) / size * size
+  }
+
+  candidate := alignUp(uint64(a.First))
+  for _, s := range a.used {
+    if candidate + size - 1 > uint64(a.Last) {
+      break
+    }
+
+    if uint64(s.last) < candidate {
+      continue
+    }
+    if uint64(s.first) > candidate + size - 1 {
+      break
+    }
+
+    candidate = alignUp(uint64(s.last) + 1)
+  }
+
+  if candidate + size - 1 > uint64(a.Last) {
+    return 0, fmt.Errorf("No free %s block left in 0x%08X-0x%08X", kind, a.First, a.Last)
+  }
+
+  base := uint32(candidate)
+  a.MarkUsed(base, base + uint32(size - 1))
+  return base, nil
+}
+
+// state is the persisted form of an Allocator.
+type state struct {
+  First string `json:"first"`
+  Last string `json:"last"`
+
+  // Used lists the used instance IDs, as single IDs or ranges such as
+  // 0x12340000-0x12340FFF.
+  Used []string `json:"used"`
+}
+
+// Save writes the state of the receiver to the provided Writer as JSON.
+func (a *Allocator) Save(w io.Writer) error {
+  s := &state{First: fmt.Sprintf("0x%08X", a.First), Last: fmt.Sprintf("0x%08X", a.Last), Used: []string{}}
+  for _, u := range a.used {
+    if u.first == u.last {
+      s.Used = append(s.Used, fmt.Sprintf("0x%08X", u.first))
+    } else {
+      s.Used = append(s.Used, fmt.Sprintf("0x%08X-0x%08X", u.first, u.last))
+    }
+  }
+
+  data, e := json.MarshalIndent(s, "", "  ")
+  if e != nil {
+    return e
+  }
+
+  _, e = w.Write(append(data, '\n'))
+  return e
+}
+
+// Load reads the state of an Allocator written by Save from the provided
+// Reader.
+func Load(r io.Reader) (*Allocator, error) {
+  s := new(state)
+  if e := json.NewDecoder(r).Decode(s); e != nil {
+    return nil, e
+  }
+
+  first, e := parseId(s.First)
+  if e != nil {
+    return nil, e
+  }
+  last, e := parseId(s.Last)
+  if e != nil {
+    return nil, e
+  }
+
+  a, e := New(first, last)
+  if e != nil {
+    return nil, e
+  }
+
+  for _, u := range s.Used {
+    parts := strings.SplitN(u, "-", 2)
+    from, e := parseId(parts[0])
+    if e != nil {
+      return nil, e
+    }
+
+    to := from
+    if len(parts) == 2 {
+      if to, e = parseId(parts[1]); e != nil {
+        return nil, e
+      }
+    }
+
+    a.MarkUsed(from, to)
+  }
+
+  return a, nil
+}
+
+// parseId parses an instance ID written in decimal or hexadecimal.
+func parseId(s string) (uint32, error) {
+  v, e := strconv.ParseUint(strings.TrimSpace(s), 0, 32)
+  if e != nil {
+    return 0, fmt.Errorf("Invalid instance ID %q", s)
+  }
+
+  return uint32(v), nil
+}
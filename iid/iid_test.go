@@ -0,0 +1,160 @@
+package iid
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func TestKindInstanceIds(t *testing.T) {
+  textures := Texture.InstanceIds(0x12340010)
+  if len(textures) != 5 || textures[0] != 0x12340010 || textures[4] != 0x12340014 {
+    t.Errorf("Unexpected texture instance IDs %X", textures)
+  }
+
+  models := Model.InstanceIds(0x12341000)
+  if len(models) != 20 || models[1] != 0x12341010 || models[4] != 0x12341100 || models[19] != 0x12341430 {
+    t.Errorf("Unexpected model instance IDs %X", models)
+  }
+
+  for _, k := range []Kind{Single, Texture, Model} {
+    for _, id := range k.InstanceIds(0x12340000) {
+      if id - 0x12340000 >= k.Span() {
+        t.Errorf("Instance ID 0x%08X of a %s is outside of its span", id, k)
+      }
+    }
+  }
+}
+
+func TestNewWithInvalidRange(t *testing.T) {
+  if _, e := New(0x2, 0x1); e == nil {
+    t.Error("Expected an error")
+  }
+}
+
+func TestMarkUsed(t *testing.T) {
+  a, _ := New(0x100, 0x1FF)
+  a.MarkUsed(0x110, 0x11F)
+  a.MarkUsed(0x130, 0x13F)
+  a.MarkUsed(0x120, 0x12F)
+  a.MarkUsed(0x0, 0x100)
+  a.MarkUsed(0x1F0, 0xFFFFFFFF)
+
+  expected := []span{{0x100, 0x100}, {0x110, 0x13F}, {0x1F0, 0x1FF}}
+  if len(a.used) != len(expected) {
+    t.Fatalf("Unexpected spans %X", a.used)
+  }
+  for i := range expected {
+    if a.used[i] != expected[i] {
+      t.Errorf("Unexpected spans %X", a.used)
+    }
+  }
+
+  if !a.IsUsed(0x101, 0x110) || a.IsUsed(0x101, 0x10F) || a.IsUsed(0x140, 0x1EF) {
+    t.Error("Unexpected result from IsUsed")
+  }
+}
+
+func TestAllocate(t *testing.T) {
+  a, _ := New(0x12340001, 0x12343FFF)
+
+  dbpf := godbpf.New()
+  for _, iid := range []uint32{0x12340005, 0x12341200, 0x99999999} {
+    e := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x1, InstanceId: iid})
+    e.SetData([]byte{})
+    dbpf.AddEntry(e)
+  }
+  a.AddDBPF(dbpf)
+  a.AddTGIs([]*entry.DBPFEntryTGI{&entry.DBPFEntryTGI{InstanceId: 0x12340010}})
+
+  for _, c := range []struct {
+    kind Kind
+    expected uint32
+  }{
+    {Single, 0x12340001},
+    {Texture, 0x12340020},
+    {Model, 0x12342000},
+    {Single, 0x12340002},
+    {Texture, 0x12340030},
+    {Model, 0x12343000},
+  } {
+    if iid, e := a.Allocate(c.kind); e != nil || iid != c.expected {
+      t.Errorf("Expected %s 0x%08X, got 0x%08X, %v", c.kind, c.expected, iid, e)
+    }
+  }
+
+  if _, e := a.Allocate(Model); e == nil {
+    t.Error("Expected an error once the range is full")
+  }
+
+  if iid, e := a.Allocate(Texture); e != nil || iid != 0x12340040 {
+    t.Errorf("Expected a texture to still fit, got 0x%08X, %v", iid, e)
+  }
+}
+
+func TestCopy(t *testing.T) {
+  a, _ := New(0x100, 0x1FF)
+  a.MarkUsed(0x100, 0x10F)
+
+  c := a.Copy()
+  c.MarkUsed(0x110, 0x11F)
+  if iid, e := c.Allocate(Single); e != nil || iid != 0x120 {
+    t.Errorf("Expected 0x120, got 0x%08X, %v", iid, e)
+  }
+
+  if !a.IsUsed(0x100, 0x10F) || a.IsUsed(0x110, 0x1FF) {
+    t.Error("Expected the original to be left unchanged")
+  }
+}
+
+func TestAllocateAtEndOfIdSpace(t *testing.T) {
+  a, _ := New(0xFFFFFFF0, 0xFFFFFFFF)
+  if iid, e := a.Allocate(Texture); e != nil || iid != 0xFFFFFFF0 {
+    t.Errorf("Unexpected texture 0x%08X, %v", iid, e)
+  }
+  if _, e := a.Allocate(Single); e == nil {
+    t.Error("Expected an error once the range is full")
+  }
+}
+
+func TestSaveAndLoad(t *testing.T) {
+  a, _ := New(0x12340000, 0x1234FFFF)
+  a.MarkUsed(0x12340005, 0x12340005)
+  a.Allocate(Model)
+
+  buf := new(bytes.Buffer)
+  if e := a.Save(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  for _, expected := range []string{`"first": "0x12340000"`, `"0x12340005"`, `"0x12341000-0x12341FFF"`} {
+    if !strings.Contains(buf.String(), expected) {
+      t.Errorf("Expected %s in:\n%s", expected, buf)
+    }
+  }
+
+  loaded, e := Load(buf)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if iid, e := loaded.Allocate(Model); e != nil || iid != 0x12342000 {
+    t.Errorf("Expected the loaded allocator to skip used blocks, got 0x%08X, %v", iid, e)
+  }
+}
+
+func TestLoadErrors(t *testing.T) {
+  for _, s := range []string{
+    `not json`,
+    `{"first": "x", "last": "0x1"}`,
+    `{"first": "0x2", "last": "0x1"}`,
+    `{"first": "0x1", "last": "0x2", "used": ["0x1-y"]}`,
+  } {
+    if _, e := Load(strings.NewReader(s)); e == nil {
+      t.Errorf("Expected an error for %s", s)
+    }
+  }
+}
@@ -0,0 +1,137 @@
+package godbpf
+
+import (
+  "bytes"
+  "fmt"
+  "image"
+  "image/jpeg"
+  "image/png"
+  "io/ioutil"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// PNG_TYPE_ID is the TypeId used by image entries, such as menu icons and UI
+// images.  Despite the name, some of these entries hold BMP or JFIF images.
+const PNG_TYPE_ID uint32 = 0x856DDBAC
+
+// ImageFormat identifies the encoding of an image entry.
+type ImageFormat int
+
+const (
+  UnknownImageFormat ImageFormat = iota
+  PNGImageFormat
+  BMPImageFormat
+  JFIFImageFormat
+)
+
+// String returns the name of the receiver ImageFormat.
+func (f ImageFormat) String() string {
+  switch f {
+  case PNGImageFormat:
+    return "PNG"
+  case BMPImageFormat:
+    return "BMP"
+  case JFIFImageFormat:
+    return "JFIF"
+  }
+
+  return "Unknown"
+}
+
+// DetectImageFormat determines the encoding of the provided image data from its
+// signature.
+func DetectImageFormat(data []byte) ImageFormat {
+  switch {
+  case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1A\n")):
+    return PNGImageFormat
+  case bytes.HasPrefix(data, []byte("BM")):
+    return BMPImageFormat
+  case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+    return JFIFImageFormat
+  }
+
+  return UnknownImageFormat
+}
+
+// DecodeImage decodes the provided PNG, BMP or JFIF image data.
+func DecodeImage(data []byte) (image.Image, ImageFormat, error) {
+  format := DetectImageFormat(data)
+
+  var img image.Image
+  var e error
+  switch format {
+  case PNGImageFormat:
+    img, e = png.Decode(bytes.NewReader(data))
+  case BMPImageFormat:
+    img, e = decodeBMP(data)
+  case JFIFImageFormat:
+    img, e = jpeg.Decode(bytes.NewReader(data))
+  default:
+    return nil, format, fmt.Errorf("Unrecognized image format")
+  }
+
+  if e != nil {
+    return nil, format, e
+  }
+
+  return img, format, nil
+}
+
+// ImageEntries returns the image entries of the receiver, in the order they are
+// stored.
+func (dbpf *DBPF) ImageEntries() []*entry.DBPFEntry {
+  return dbpf.FindByType(PNG_TYPE_ID)
+}
+
+// GetImage decodes the image entry identified by the provided DBPFEntryTGI.
+func (dbpf *DBPF) GetImage(tgi *entry.DBPFEntryTGI) (image.Image, ImageFormat, error) {
+  data, e := dbpf.GetUncompressedData(tgi)
+  if e != nil {
+    return nil, UnknownImageFormat, e
+  }
+
+  img, format, e := DecodeImage(data)
+  if e != nil {
+    return nil, format, fmt.Errorf("Image entry {%s}: %v", tgi, e)
+  }
+
+  return img, format, nil
+}
+
+// ReplaceImageData replaces the data of the image entry identified by the
+// provided DBPFEntryTGI with the provided encoded image, which must be a PNG,
+// BMP or JFIF image.  The entry keeps its TGI, position and compression state.
+func (dbpf *DBPF) ReplaceImageData(tgi *entry.DBPFEntryTGI, data []byte) error {
+  if _, _, e := DecodeImage(data); e != nil {
+    return e
+  }
+
+  return dbpf.SetUncompressedData(tgi, data)
+}
+
+// ReplaceImageFile replaces the data of the image entry identified by the
+// provided DBPFEntryTGI with the contents of the named PNG, BMP or JFIF file.
+func (dbpf *DBPF) ReplaceImageFile(tgi *entry.DBPFEntryTGI, name string) error {
+  data, e := ioutil.ReadFile(name)
+  if e != nil {
+    return e
+  }
+
+  if e := dbpf.ReplaceImageData(tgi, data); e != nil {
+    return fmt.Errorf("%s: %v", name, e)
+  }
+
+  return nil
+}
+
+// ReplaceImage encodes the provided image as a PNG and stores it in the image
+// entry identified by the provided DBPFEntryTGI.
+func (dbpf *DBPF) ReplaceImage(tgi *entry.DBPFEntryTGI, img image.Image) error {
+  buf := new(bytes.Buffer)
+  if e := png.Encode(buf, img); e != nil {
+    return e
+  }
+
+  return dbpf.SetUncompressedData(tgi, buf.Bytes())
+}
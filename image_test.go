@@ -0,0 +1,128 @@
+package godbpf
+
+import (
+  "bytes"
+  "image"
+  "image/color"
+  "image/jpeg"
+  "image/png"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+  buf := new(bytes.Buffer)
+  if e := png.Encode(buf, img); e != nil {
+    t.Fatal(e)
+  }
+  return buf.Bytes()
+}
+
+func solidImage(w, h int, c color.NRGBA) *image.NRGBA {
+  img := image.NewNRGBA(image.Rect(0, 0, w, h))
+  for y := 0; y < h; y++ {
+    for x := 0; x < w; x++ {
+      img.SetNRGBA(x, y, c)
+    }
+  }
+  return img
+}
+
+func TestDetectImageFormat(t *testing.T) {
+  red := solidImage(2, 2, color.NRGBA{R: 255, A: 255})
+  jpg := new(bytes.Buffer)
+  jpeg.Encode(jpg, red, nil)
+
+  tests := map[ImageFormat][]byte{
+    PNGImageFormat: encodePNG(t, red),
+    BMPImageFormat: buildBMP(1, 1, 24, nil, []byte{0, 0, 0, 0}, false),
+    JFIFImageFormat: jpg.Bytes(),
+    UnknownImageFormat: []byte("GIF89a"),
+  }
+
+  for format, data := range tests {
+    if DetectImageFormat(data) != format {
+      t.Errorf("Expected %s", format)
+    }
+
+    img, decoded, e := DecodeImage(data)
+    if decoded != format {
+      t.Errorf("Expected %s from DecodeImage, got %s", format, decoded)
+    }
+    if (format == UnknownImageFormat) != (e != nil) || (e == nil && img == nil) {
+      t.Errorf("Unexpected DecodeImage result for %s: %v", format, e)
+    }
+  }
+}
+
+func TestImageEntries(t *testing.T) {
+  dbpf := New()
+  icon := &entry.DBPFEntryTGI{TypeId: PNG_TYPE_ID, GroupId: 0x6A386D26, InstanceId: 0x1}
+  bitmap := &entry.DBPFEntryTGI{TypeId: PNG_TYPE_ID, GroupId: 0x46A006B0, InstanceId: 0x2}
+  other := &entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x1, InstanceId: 0x1}
+
+  dbpf.AddCompressedEntry(icon, encodePNG(t, solidImage(4, 4, color.NRGBA{G: 255, A: 255})))
+  e := entry.NewEntry(bitmap)
+  e.SetData(buildBMP(1, 1, 24, nil, []byte{255, 0, 0, 0}, false))
+  dbpf.AddEntry(e)
+  dbpf.AddEntry(entry.NewEntry(other))
+
+  entries := dbpf.ImageEntries()
+  if len(entries) != 2 || !entries[0].TGI.Equals(icon) || !entries[1].TGI.Equals(bitmap) {
+    t.Fatalf("Unexpected image entries %v", entries)
+  }
+
+  img, format, err := dbpf.GetImage(icon)
+  if err != nil || format != PNGImageFormat || img.Bounds().Dx() != 4 {
+    t.Errorf("Unexpected icon %v, %s, %v", img, format, err)
+  }
+
+  img, format, err = dbpf.GetImage(bitmap)
+  if err != nil || format != BMPImageFormat || color.NRGBAModel.Convert(img.At(0, 0)) != (color.NRGBA{B: 255, A: 255}) {
+    t.Errorf("Unexpected bitmap %v, %s, %v", img, format, err)
+  }
+
+  if _, _, err := dbpf.GetImage(other); err == nil {
+    t.Error("Expected an error decoding a non image entry")
+  }
+}
+
+func TestReplaceImage(t *testing.T) {
+  dbpf := New()
+  tgi := &entry.DBPFEntryTGI{TypeId: PNG_TYPE_ID, GroupId: 0x6A386D26, InstanceId: 0x1}
+  dbpf.AddCompressedEntry(tgi, encodePNG(t, solidImage(4, 4, color.NRGBA{G: 255, A: 255})))
+
+  if e := dbpf.ReplaceImage(tgi, solidImage(8, 2, color.NRGBA{R: 255, A: 255})); e != nil {
+    t.Fatal(e)
+  }
+  if img, _, e := dbpf.GetImage(tgi); e != nil || img.Bounds().Dx() != 8 || !dbpf.IsCompressed(tgi) {
+    t.Errorf("Unexpected replaced image %v, %v", img, e)
+  }
+
+  if e := dbpf.ReplaceImageData(tgi, []byte("not an image")); e == nil {
+    t.Error("Expected an error replacing with invalid data")
+  }
+
+  dir, e := ioutil.TempDir("", "godbpf")
+  if e != nil {
+    t.Fatal(e)
+  }
+  defer os.RemoveAll(dir)
+
+  name := filepath.Join(dir, "icon.bmp")
+  ioutil.WriteFile(name, buildBMP(3, 1, 24, nil, make([]byte, 12), false), 0644)
+  if e := dbpf.ReplaceImageFile(tgi, name); e != nil {
+    t.Fatal(e)
+  }
+  if img, format, e := dbpf.GetImage(tgi); e != nil || format != BMPImageFormat || img.Bounds().Dx() != 3 {
+    t.Errorf("Unexpected image from file %v, %s, %v", img, format, e)
+  }
+
+  if e := dbpf.ReplaceImageFile(tgi, filepath.Join(dir, "missing.png")); e == nil {
+    t.Error("Expected an error for a missing file")
+  }
+}
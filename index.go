@@ -0,0 +1,108 @@
+package godbpf
+
+import (
+  "errors"
+  "fmt"
+  "io"
+
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/util"
+)
+
+// IndexEntry is a record of the index of a DBPF file, along with the
+// compression state found in its DIR entry.
+type IndexEntry struct {
+  TGI entry.DBPFEntryTGI
+
+  // Location and Size give the position of the data of the entry in the file.
+  Location, Size uint32
+
+  // Compressed indicates that the DIR entry holds a record for the entry, in
+  // which case UncompressedSize holds the size it records.
+  Compressed bool
+  UncompressedSize uint32
+}
+
+// ReadIndex reads the header and index of a DBPF file of the provided size
+// from the provided ReaderAt, along with its DIR entry, without reading the
+// data of any other entry.  The returned DBPF holds the header fields and no
+// entries.  The index and the entries it describes must lie within the size,
+// so that ReadEntry never reads past the end of the file.
+func ReadIndex(r io.ReaderAt, size int64) (*DBPF, []*IndexEntry, error) {
+  if size < HEADER_SIZE {
+    return nil, nil, errors.New("DBPF header is truncated")
+  }
+
+  dbpf := New()
+  count, offset, e := parseHeader(io.NewSectionReader(r, 0, HEADER_SIZE), dbpf)
+  if e != nil {
+    return nil, nil, e
+  }
+
+  if int64(offset) + int64(count) * INDEX_ENTRY_SIZE > size {
+    return nil, nil, fmt.Errorf("Index of %d entries at offset %d extends past the end of the data", count, offset)
+  }
+
+  index := make([]byte, int64(count) * INDEX_ENTRY_SIZE)
+  if _, e := r.ReadAt(index, int64(offset)); e != nil {
+    return nil, nil, fmt.Errorf("Failed to read the index of %d entries at offset %d: %v", count, offset, e)
+  }
+
+  entries := make([]*IndexEntry, count)
+  var dir *IndexEntry
+  for i := range entries {
+    record := index[i * INDEX_ENTRY_SIZE:]
+    entries[i] = &IndexEntry{
+      TGI: entry.DBPFEntryTGI{TypeId: util.ReadUint32(record[0:4]), GroupId: util.ReadUint32(record[4:8]), InstanceId: util.ReadUint32(record[8:12])},
+      Location: util.ReadUint32(record[12:16]),
+      Size: util.ReadUint32(record[16:20]),
+    }
+
+    if int64(entries[i].Location) + int64(entries[i].Size) > size {
+      return nil, nil, fmt.Errorf("Entry {%s} at offset %d extends past the end of the data", &entries[i].TGI, entries[i].Location)
+    }
+
+    if dir == nil && entries[i].TGI.Equals(entry.DIR_ENTRY_TGI) {
+      dir = entries[i]
+    }
+  }
+
+  if dir == nil {
+    return dbpf, entries, nil
+  }
+
+  data := make([]byte, dir.Size)
+  if _, e := r.ReadAt(data, int64(dir.Location)); e != nil {
+    return nil, nil, fmt.Errorf("Failed to read the DIR entry at offset %d: %v", dir.Location, e)
+  }
+
+  dirEntry := entry.CreateDirEntry()
+  dirEntry.SetData(data)
+  sizes := dirEntry.UncompressedSizes()
+
+  for _, ie := range entries {
+    ie.UncompressedSize, ie.Compressed = sizes[ie.TGI]
+  }
+
+  return dbpf, entries, nil
+}
+
+// ReadEntry reads the data of the entry described by the provided IndexEntry
+// from the provided ReaderAt, decompressing it if needed.
+func ReadEntry(r io.ReaderAt, ie *IndexEntry) ([]byte, error) {
+  data := make([]byte, ie.Size)
+  if _, e := r.ReadAt(data, int64(ie.Location)); e != nil {
+    return nil, fmt.Errorf("Failed to read entry {%s}: %v", &ie.TGI, e)
+  }
+
+  if !ie.Compressed {
+    return data, nil
+  }
+
+  uncompressed, e := Decompress(data)
+  if e != nil {
+    return nil, fmt.Errorf("Compressed entry {%s}: %v", &ie.TGI, e)
+  }
+
+  return uncompressed, nil
+}
@@ -0,0 +1,89 @@
+package godbpf
+
+import (
+  "bytes"
+  "testing"
+  "time"
+
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/util"
+)
+
+func TestReadIndex(t *testing.T) {
+  dbpf := New()
+  dbpf.MajorVersion = 1
+  dbpf.IndexMajorVersion = 7
+  dbpf.ModifiedDate = time.Unix(1457490790, 0)
+
+  plain := entry.NewEntry(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x3})
+  plain.SetData([]byte("plain"))
+  dbpf.AddEntry(plain)
+  compressed := &entry.DBPFEntryTGI{TypeId: 0x4, GroupId: 0x5, InstanceId: 0x6}
+  dbpf.AddCompressedEntry(compressed, []byte("compressed compressed compressed"))
+
+  buf := new(bytes.Buffer)
+  dbpf.Save(buf)
+  r := bytes.NewReader(buf.Bytes())
+
+  header, entries, e := ReadIndex(r, r.Size())
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if header.MajorVersion != 1 || header.IndexMajorVersion != 7 || header.ModifiedDate.Unix() != 1457490790 || header.Len() != 0 {
+    t.Errorf("Unexpected header %+v", header)
+  }
+
+  if len(entries) != 3 || entries[0].Compressed || !entries[1].Compressed || entries[1].UncompressedSize != 32 {
+    t.Fatalf("Unexpected entries %+v", entries)
+  }
+  if entries[0].Location != HEADER_SIZE || entries[0].Size != 5 {
+    t.Errorf("Unexpected entry %+v", entries[0])
+  }
+
+  for _, ie := range entries {
+    if ie.TGI.Equals(entry.DIR_ENTRY_TGI) {
+      continue
+    }
+
+    data, e := ReadEntry(r, ie)
+    expected, _ := dbpf.GetUncompressedData(&ie.TGI)
+    if e != nil || !bytes.Equal(data, expected) {
+      t.Errorf("Unexpected data %q for {%s}, %v", data, &ie.TGI, e)
+    }
+  }
+}
+
+func TestReadIndexErrors(t *testing.T) {
+  dbpf := New()
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: 0x4, GroupId: 0x5, InstanceId: 0x6}, []byte("data"))
+  buf := new(bytes.Buffer)
+  dbpf.Save(buf)
+  data := buf.Bytes()
+
+  for name, d := range map[string][]byte{
+    "short header": data[:50],
+    "magic": append([]byte("XXXX"), data[4:]...),
+    "truncated index": data[:len(data) - 1],
+    "index past the end": withUint32(data, 40, 0xFFFFFF00),
+    "huge index": withUint32(data, 36, 0xFFFFFFFF),
+    "entry past the end": withUint32(data, len(data) - 4, 0xFFFFFFF0),
+  } {
+    if _, _, e := ReadIndex(bytes.NewReader(d), int64(len(d))); e == nil {
+      t.Errorf("Expected an error for the %s", name)
+    }
+  }
+
+  if _, e := ReadEntry(bytes.NewReader(data), &IndexEntry{Location: 1000, Size: 4}); e == nil {
+    t.Error("Expected an error for an entry past the end of the file")
+  }
+}
+
+// withUint32 returns a copy of the provided data with the uint32 at the
+// provided offset replaced.
+func withUint32(data []byte, offset int, v uint32) []byte {
+  result := append([]byte(nil), data...)
+  copy(result[offset:offset + 4], util.WriteUint32(v))
+
+  return result
+}
@@ -0,0 +1,184 @@
+package lot
+
+import (
+  "fmt"
+  "time"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+)
+
+// LOT_CONFIG_GROUP_ID is the GroupId used by LotConfig exemplars.
+const LOT_CONFIG_GROUP_ID uint32 = 0xA8FBD372
+
+// TILE_SIZE is the length in meters of one side of a tile.
+const TILE_SIZE = 16.0
+
+// firstPropObjectID is the object ID given to the first prop placed by a
+// Builder.  Each additional prop gets the next one.
+const firstPropObjectID uint32 = 0xA0000000
+
+// Builder assembles a lot and the DBPF holding it.  Objects are validated
+// against the lot size when Build is called.
+type Builder struct {
+  lot *Lot
+  buildings []*LotObject
+  props []*LotObject
+  textures []*LotObject
+  overlays []*LotObject
+  extra []*builderEntry
+}
+
+// builderEntry is an additional exemplar to include in the built DBPF.
+type builderEntry struct {
+  tgi *entry.DBPFEntryTGI
+  exemplar *exemplar.Exemplar
+}
+
+// NewBuilder creates a Builder for a lot with the provided name and size in
+// tiles.
+func NewBuilder(name string, width, depth uint8) *Builder {
+  l := New(width, depth)
+  l.Name = name
+
+  return &Builder{lot: l}
+}
+
+// SetZoning sets the zone types the lot can be grown or plopped in.
+func (b *Builder) SetZoning(zoneTypes ...uint8) {
+  b.lot.ZoneTypes = zoneTypes
+}
+
+// SetWealth sets the wealth types of the lot.
+func (b *Builder) SetWealth(wealthTypes ...uint8) {
+  b.lot.WealthTypes = wealthTypes
+}
+
+// SetPurpose sets the purpose types of the lot.
+func (b *Builder) SetPurpose(purposeTypes ...uint8) {
+  b.lot.PurposeTypes = purposeTypes
+}
+
+// PlaceBuilding places the building with the provided exemplar instance ID
+// centred at x, z (in meters) with a footprint of width by depth meters.
+func (b *Builder) PlaceBuilding(iid uint32, x, z, width, depth float64, rotation uint32) {
+  b.buildings = append(b.buildings, placeObject(Building, x, z, width, depth, rotation, iid))
+}
+
+// PlaceProp places the prop with the provided exemplar instance ID centred at
+// x, z (in meters) with a footprint of width by depth meters.
+func (b *Builder) PlaceProp(iid uint32, x, z, width, depth float64, rotation uint32) {
+  objectID := firstPropObjectID + uint32(len(b.props))
+  b.props = append(b.props, placeObject(Prop, x, z, width, depth, rotation, objectID, iid))
+}
+
+// PlaceTexture covers the tile at tileX, tileZ with the base texture with the
+// provided instance ID.
+func (b *Builder) PlaceTexture(iid uint32, tileX, tileZ int, rotation uint32) {
+  b.textures = append(b.textures, placeTile(iid, tileX, tileZ, rotation))
+}
+
+// PlaceOverlay lays the overlay texture with the provided instance ID over the
+// tile at tileX, tileZ.  Overlays are written after every base texture so that
+// they are drawn on top of them.
+func (b *Builder) PlaceOverlay(iid uint32, tileX, tileZ int, rotation uint32) {
+  b.overlays = append(b.overlays, placeTile(iid, tileX, tileZ, rotation))
+}
+
+// IncludeExemplar adds the provided exemplar, such as the exemplar of the
+// building placed on the lot, to the DBPF produced by Build.
+func (b *Builder) IncludeExemplar(tgi *entry.DBPFEntryTGI, ex *exemplar.Exemplar) {
+  b.extra = append(b.extra, &builderEntry{tgi: tgi, exemplar: ex})
+}
+
+// Lot returns the lot assembled so far.
+func (b *Builder) Lot() *Lot {
+  b.lot.Objects = nil
+  for _, objects := range [][]*LotObject{b.buildings, b.textures, b.overlays, b.props} {
+    b.lot.Objects = append(b.lot.Objects, objects...)
+  }
+
+  return b.lot
+}
+
+// Build validates the lot and returns a DBPF that holds its LotConfig exemplar,
+// under the provided instance ID, along with any included exemplars.
+func (b *Builder) Build(instanceID uint32) (*godbpf.DBPF, error) {
+  l := b.Lot()
+  if l.Width == 0 || l.Depth == 0 {
+    return nil, fmt.Errorf("Lot size %dx%d is invalid", l.Width, l.Depth)
+  }
+
+  maxX := FromMeters(float64(l.Width) * TILE_SIZE)
+  maxZ := FromMeters(float64(l.Depth) * TILE_SIZE)
+  for _, o := range l.Objects {
+    if o.X < 0 || o.X > maxX || o.Z < 0 || o.Z > maxZ {
+      return nil, fmt.Errorf("%s 0x%08X at %.2f, %.2f is outside of the lot", o.Type, o.InstanceID(), Meters(o.X), Meters(o.Z))
+    }
+  }
+
+  ex, e := l.ToExemplar()
+  if e != nil {
+    return nil, e
+  }
+
+  dbpf := godbpf.New()
+  dbpf.MajorVersion = 1
+  dbpf.IndexMajorVersion = 7
+  dbpf.CreatedDate = time.Now()
+  dbpf.ModifiedDate = dbpf.CreatedDate
+
+  tgi := &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: LOT_CONFIG_GROUP_ID, InstanceId: instanceID}
+  if e := addExemplar(dbpf, tgi, ex); e != nil {
+    return nil, e
+  }
+
+  for _, extra := range b.extra {
+    if e := addExemplar(dbpf, extra.tgi, extra.exemplar); e != nil {
+      return nil, e
+    }
+  }
+
+  return dbpf, nil
+}
+
+// addExemplar encodes the provided exemplar and adds it to the DBPF as a
+// compressed entry.
+func addExemplar(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI, ex *exemplar.Exemplar) error {
+  data, e := ex.Bytes()
+  if e != nil {
+    return e
+  }
+
+  dbpf.AddCompressedEntry(tgi, data)
+  return nil
+}
+
+// placeObject creates a LotObject centred at x, z with a bounding box of width
+// by depth meters.
+func placeObject(t ObjectType, x, z, width, depth float64, rotation uint32, iids ...uint32) *LotObject {
+  if rotation % 2 == 1 {
+    width, depth = depth, width
+  }
+
+  return &LotObject{
+    Type: t,
+    Rotation: rotation % 4,
+    X: FromMeters(x),
+    Z: FromMeters(z),
+    MinX: FromMeters(x - width / 2),
+    MinZ: FromMeters(z - depth / 2),
+    MaxX: FromMeters(x + width / 2),
+    MaxZ: FromMeters(z + depth / 2),
+    IIDs: iids,
+  }
+}
+
+// placeTile creates a texture LotObject that covers the tile at tileX, tileZ.
+func placeTile(iid uint32, tileX, tileZ int, rotation uint32) *LotObject {
+  x := (float64(tileX) + 0.5) * TILE_SIZE
+  z := (float64(tileZ) + 0.5) * TILE_SIZE
+
+  return placeObject(Texture, x, z, TILE_SIZE, TILE_SIZE, rotation, iid)
+}
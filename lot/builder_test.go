@@ -0,0 +1,93 @@
+package lot
+
+import (
+  "bytes"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+)
+
+func TestBuildLot(t *testing.T) {
+  b := NewBuilder("Corner Shop", 2, 1)
+  b.SetZoning(0x04)
+  b.SetWealth(0x01)
+  b.PlaceBuilding(0x10000000, 16, 8, 20, 10, 1)
+  b.PlaceProp(0x20000000, 2, 2, 1, 1, 0)
+  b.PlaceProp(0x20000001, 30, 2, 1, 1, 0)
+  b.PlaceOverlay(0x30000100, 1, 0, 0)
+  b.PlaceTexture(0x30000000, 0, 0, 0)
+  b.PlaceTexture(0x30000000, 1, 0, 0)
+
+  buildingTgi := &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x10000000}
+  b.IncludeExemplar(buildingTgi, exemplar.New())
+
+  dbpf, e := b.Build(0x40000000)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  buf := new(bytes.Buffer)
+  if e := dbpf.Save(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  parsed, e := godbpf.Parse(buf)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if parsed.Find(buildingTgi) == nil {
+    t.Error()
+  }
+
+  tgi := &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: LOT_CONFIG_GROUP_ID, InstanceId: 0x40000000}
+  data, e := parsed.GetUncompressedData(tgi)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  l, e := Decode(bytes.NewReader(data))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if l.Name != "Corner Shop" || l.Width != 2 || l.Depth != 1 || len(l.ZoneTypes) != 1 || len(l.Objects) != 6 {
+    t.Fatalf("Unexpected lot %+v", l)
+  }
+
+  expectedTypes := []ObjectType{Building, Texture, Texture, Texture, Prop, Prop}
+  for i, o := range l.Objects {
+    if o.Type != expectedTypes[i] {
+      t.Errorf("Object %d: expected %s but was %s", i, expectedTypes[i], o.Type)
+    }
+  }
+
+  building := l.Objects[0]
+  if Meters(building.MinX) != 11 || Meters(building.MaxX) != 21 || Meters(building.MinZ) != -2 || Meters(building.MaxZ) != 18 {
+    t.Errorf("Unexpected building bounds %+v", building)
+  }
+
+  if l.Objects[3].InstanceID() != 0x30000100 || Meters(l.Objects[3].X) != 24 {
+    t.Error()
+  }
+
+  if l.Objects[5].IIDs[0] != 0xA0000001 || l.Objects[5].InstanceID() != 0x20000001 {
+    t.Error()
+  }
+}
+
+func TestBuildLotWithObjectOutside(t *testing.T) {
+  b := NewBuilder("Out of Bounds", 1, 1)
+  b.PlaceProp(0x20000000, 20, 2, 1, 1, 0)
+
+  if _, e := b.Build(0x40000000); e == nil {
+    t.Error()
+  }
+
+  b = NewBuilder("Empty", 0, 1)
+  if _, e := b.Build(0x40000000); e == nil {
+    t.Error()
+  }
+}
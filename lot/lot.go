@@ -0,0 +1,341 @@
+package lot
+
+import (
+  "fmt"
+  "io"
+
+  "github.com/marcboudreau/godbpf/exemplar"
+)
+
+// The IDs of the exemplar properties that describe a lot.
+const (
+  EXEMPLAR_TYPE_PROPERTY uint32 = 0x00000010
+  EXEMPLAR_NAME_PROPERTY uint32 = 0x00000020
+  SIZE_PROPERTY uint32 = 0x88EDC790
+  ZONE_TYPES_PROPERTY uint32 = 0x88EDC793
+  WEALTH_TYPES_PROPERTY uint32 = 0x88EDC795
+  PURPOSE_TYPES_PROPERTY uint32 = 0x88EDC796
+
+  // LOT_OBJECT_PROPERTY is the ID of the first LotConfigPropertyLotObject
+  // property.  Each additional object uses the next ID, up to
+  // LAST_LOT_OBJECT_PROPERTY.
+  LOT_OBJECT_PROPERTY uint32 = 0x88EDC900
+  LAST_LOT_OBJECT_PROPERTY uint32 = 0x88EDCDFF
+)
+
+// LOT_CONFIG_EXEMPLAR_TYPE is the value of the Exemplar Type property for lot
+// configuration exemplars.
+const LOT_CONFIG_EXEMPLAR_TYPE uint32 = 0x00000010
+
+// ObjectType indicates what kind of object a LotObject places on the lot.
+type ObjectType uint32
+
+const (
+  Building ObjectType = 0x0
+  Prop ObjectType = 0x1
+  Texture ObjectType = 0x2
+  Fence ObjectType = 0x3
+  Flora ObjectType = 0x4
+  Water ObjectType = 0x5
+  Land ObjectType = 0x6
+  Network ObjectType = 0x7
+)
+
+// String returns the name of the receiver ObjectType.
+func (t ObjectType) String() string {
+  switch t {
+  case Building:
+    return "Building"
+  case Prop:
+    return "Prop"
+  case Texture:
+    return "Texture"
+  case Fence:
+    return "Fence"
+  case Flora:
+    return "Flora"
+  case Water:
+    return "Water"
+  case Land:
+    return "Land"
+  case Network:
+    return "Network"
+  }
+
+  return fmt.Sprintf("0x%X", uint32(t))
+}
+
+// lotObjectMinLen is the smallest number of values a LotConfigPropertyLotObject
+// property can hold: the 11 fixed values followed by at least one IID.
+const lotObjectMinLen = 12
+
+// LotObject is a single object placed on a lot.  Positions and bounds are in
+// fixed point meters with 16 fractional bits, measured from the lot's origin;
+// use Meters and FromMeters to convert them.
+type LotObject struct {
+  // Type indicates what kind of object this is.
+  Type ObjectType
+
+  // LOD holds the level of detail flags of the object.
+  LOD uint32
+
+  // Rotation is the orientation of the object, from 0 to 3.
+  Rotation uint32
+
+  // X, Y and Z give the position of the object.  Y is the height.
+  X, Y, Z int32
+
+  // MinX, MinZ, MaxX and MaxZ give the bounding box of the object.
+  MinX, MinZ, MaxX, MaxZ int32
+
+  // Usage holds the usage flags of the object.
+  Usage uint32
+
+  // IIDs holds the remaining values of the property.  For buildings and
+  // textures, this is the instance ID of the building exemplar or texture.
+  // Props are listed with an object ID followed by the prop's instance ID.
+  IIDs []uint32
+}
+
+// InstanceID returns the instance ID of the resource placed by the receiver,
+// which is the last of its IIDs.
+func (o *LotObject) InstanceID() uint32 {
+  if len(o.IIDs) == 0 {
+    return 0
+  }
+
+  return o.IIDs[len(o.IIDs) - 1]
+}
+
+// decodeLotObject creates a LotObject from the values of a
+// LotConfigPropertyLotObject property.
+func decodeLotObject(p *exemplar.Property) (*LotObject, error) {
+  values := p.Uint32Values()
+  if len(values) < lotObjectMinLen {
+    return nil, fmt.Errorf("Lot object property 0x%08X holds %d values, expected at least %d", p.ID, len(values), lotObjectMinLen)
+  }
+
+  o := &LotObject{
+    Type: ObjectType(values[0]),
+    LOD: values[1],
+    Rotation: values[2],
+    X: int32(values[3]),
+    Y: int32(values[4]),
+    Z: int32(values[5]),
+    MinX: int32(values[6]),
+    MinZ: int32(values[7]),
+    MaxX: int32(values[8]),
+    MaxZ: int32(values[9]),
+    Usage: values[10],
+  }
+  o.IIDs = make([]uint32, len(values) - 11)
+  copy(o.IIDs, values[11:])
+
+  return o, nil
+}
+
+// property encodes the receiver as a LotConfigPropertyLotObject property with
+// the provided ID.
+func (o *LotObject) property(id uint32) *exemplar.Property {
+  values := []interface{}{
+    uint32(o.Type), o.LOD, o.Rotation,
+    uint32(o.X), uint32(o.Y), uint32(o.Z),
+    uint32(o.MinX), uint32(o.MinZ), uint32(o.MaxX), uint32(o.MaxZ),
+    o.Usage,
+  }
+  for _, iid := range o.IIDs {
+    values = append(values, iid)
+  }
+
+  p := exemplar.NewProperty(id, exemplar.Uint32, values...)
+  p.Multi = true
+
+  return p
+}
+
+// Meters converts a fixed point lot coordinate to meters.
+func Meters(v int32) float64 {
+  return float64(v) / 65536
+}
+
+// FromMeters converts a distance in meters to a fixed point lot coordinate.
+func FromMeters(m float64) int32 {
+  return int32(m * 65536)
+}
+
+// Lot is the typed model of a LotConfig exemplar.  Properties that aren't
+// modelled by Lot are kept in the underlying Exemplar and written back
+// unchanged.
+type Lot struct {
+  // Name is the exemplar name of the lot.
+  Name string
+
+  // Width and Depth give the size of the lot in tiles.
+  Width, Depth uint8
+
+  // ZoneTypes, WealthTypes and PurposeTypes list the zoning, wealth and
+  // purpose values the lot can be built for.
+  ZoneTypes []uint8
+  WealthTypes []uint8
+  PurposeTypes []uint8
+
+  // Objects lists the objects placed on the lot, in property ID order.
+  Objects []*LotObject
+
+  // Exemplar is the exemplar holding the remaining properties of the lot.
+  Exemplar *exemplar.Exemplar
+}
+
+// New creates an empty lot of the provided size.
+func New(width, depth uint8) *Lot {
+  ex := exemplar.New()
+  ex.SetProperty(exemplar.NewProperty(EXEMPLAR_TYPE_PROPERTY, exemplar.Uint32, LOT_CONFIG_EXEMPLAR_TYPE))
+
+  return &Lot{Width: width, Depth: depth, Exemplar: ex}
+}
+
+// FromExemplar decodes the lot described by the provided LotConfig exemplar.
+func FromExemplar(ex *exemplar.Exemplar) (*Lot, error) {
+  l := &Lot{Exemplar: ex}
+
+  if p := ex.Property(EXEMPLAR_NAME_PROPERTY); p != nil {
+    l.Name = p.StringValue()
+  }
+
+  if p := ex.Property(SIZE_PROPERTY); p != nil {
+    size := p.Uint32Values()
+    if len(size) != 2 {
+      return nil, fmt.Errorf("Lot size property holds %d values, expected 2", len(size))
+    }
+
+    l.Width, l.Depth = uint8(size[0]), uint8(size[1])
+  }
+
+  l.ZoneTypes = uint8Values(ex.Property(ZONE_TYPES_PROPERTY))
+  l.WealthTypes = uint8Values(ex.Property(WEALTH_TYPES_PROPERTY))
+  l.PurposeTypes = uint8Values(ex.Property(PURPOSE_TYPES_PROPERTY))
+
+  for _, p := range ex.Properties {
+    if !isLotObjectProperty(p.ID) {
+      continue
+    }
+
+    o, e := decodeLotObject(p)
+    if e != nil {
+      return nil, e
+    }
+
+    l.Objects = append(l.Objects, o)
+  }
+
+  return l, nil
+}
+
+// Decode reads a binary LotConfig exemplar from the provided Reader and decodes
+// the lot it describes.
+func Decode(r io.Reader) (*Lot, error) {
+  ex, e := exemplar.Decode(r)
+  if e != nil {
+    return nil, e
+  }
+
+  return FromExemplar(ex)
+}
+
+// ToExemplar updates the receiver's Exemplar with the values of the receiver
+// and returns it.  The lot objects are renumbered from LOT_OBJECT_PROPERTY in
+// the order they appear in Objects.
+func (l *Lot) ToExemplar() (*exemplar.Exemplar, error) {
+  if len(l.Objects) > int(LAST_LOT_OBJECT_PROPERTY - LOT_OBJECT_PROPERTY) + 1 {
+    return nil, fmt.Errorf("Lot has %d objects, which is more than a lot can hold", len(l.Objects))
+  }
+
+  if l.Exemplar == nil {
+    l.Exemplar = exemplar.New()
+  }
+  ex := l.Exemplar
+
+  if l.Name != "" {
+    ex.SetProperty(exemplar.NewProperty(EXEMPLAR_NAME_PROPERTY, exemplar.String, l.Name))
+  }
+  ex.SetProperty(exemplar.NewProperty(SIZE_PROPERTY, exemplar.Uint8, l.Width, l.Depth))
+  setUint8Property(ex, ZONE_TYPES_PROPERTY, l.ZoneTypes)
+  setUint8Property(ex, WEALTH_TYPES_PROPERTY, l.WealthTypes)
+  setUint8Property(ex, PURPOSE_TYPES_PROPERTY, l.PurposeTypes)
+
+  properties := ex.Properties[:0]
+  for _, p := range ex.Properties {
+    if !isLotObjectProperty(p.ID) {
+      properties = append(properties, p)
+    }
+  }
+  ex.Properties = properties
+
+  for i, o := range l.Objects {
+    ex.Properties = append(ex.Properties, o.property(LOT_OBJECT_PROPERTY + uint32(i)))
+  }
+
+  return ex, nil
+}
+
+// Encode writes the receiver to the provided Writer as a binary LotConfig
+// exemplar.
+func (l *Lot) Encode(w io.Writer) error {
+  ex, e := l.ToExemplar()
+  if e != nil {
+    return e
+  }
+
+  return ex.Encode(w)
+}
+
+// ObjectsOfType returns the objects of the receiver that have the provided
+// ObjectType.
+func (l *Lot) ObjectsOfType(t ObjectType) []*LotObject {
+  var result []*LotObject
+  for _, o := range l.Objects {
+    if o.Type == t {
+      result = append(result, o)
+    }
+  }
+
+  return result
+}
+
+// isLotObjectProperty checks if the provided property ID falls within the range
+// reserved for LotConfigPropertyLotObject properties.
+func isLotObjectProperty(id uint32) bool {
+  return id >= LOT_OBJECT_PROPERTY && id <= LAST_LOT_OBJECT_PROPERTY
+}
+
+// uint8Values returns the values of the provided property as uint8 values.  A
+// nil property yields a nil slice.
+func uint8Values(p *exemplar.Property) []uint8 {
+  if p == nil {
+    return nil
+  }
+
+  values := p.Uint32Values()
+  result := make([]uint8, len(values))
+  for i, v := range values {
+    result[i] = uint8(v)
+  }
+
+  return result
+}
+
+// setUint8Property stores the provided values in a Uint8 property, or removes
+// the property if there are no values.
+func setUint8Property(ex *exemplar.Exemplar, id uint32, values []uint8) {
+  if len(values) == 0 {
+    ex.RemoveProperty(id)
+    return
+  }
+
+  p := &exemplar.Property{ID: id, Type: exemplar.Uint8, Multi: true}
+  for _, v := range values {
+    p.Values = append(p.Values, v)
+  }
+
+  ex.SetProperty(p)
+}
@@ -0,0 +1,119 @@
+package lot
+
+import (
+  "bytes"
+  "testing"
+
+  "github.com/marcboudreau/godbpf/exemplar"
+)
+
+func TestDecodeLotObjects(t *testing.T) {
+  ex := exemplar.New()
+  ex.SetProperty(exemplar.NewProperty(EXEMPLAR_TYPE_PROPERTY, exemplar.Uint32, LOT_CONFIG_EXEMPLAR_TYPE))
+  ex.SetProperty(exemplar.NewProperty(EXEMPLAR_NAME_PROPERTY, exemplar.String, "Test Lot"))
+  ex.SetProperty(exemplar.NewProperty(SIZE_PROPERTY, exemplar.Uint8, uint8(2), uint8(3)))
+  ex.SetProperty(exemplar.NewProperty(ZONE_TYPES_PROPERTY, exemplar.Uint8, uint8(1), uint8(2), uint8(3)))
+  ex.SetProperty(exemplar.NewProperty(0x12345678, exemplar.Uint32, uint32(42)))
+  ex.SetProperty(exemplar.NewProperty(LOT_OBJECT_PROPERTY, exemplar.Uint32,
+    uint32(0), uint32(0), uint32(1), uint32(0x00100000), uint32(0), uint32(0x00180000),
+    uint32(0x00080000), uint32(0x00080000), uint32(0x00180000), uint32(0x00280000), uint32(0), uint32(0xABCD0000)))
+  ex.SetProperty(exemplar.NewProperty(LOT_OBJECT_PROPERTY + 1, exemplar.Uint32,
+    uint32(1), uint32(0), uint32(3), uint32(0xFFFF0000), uint32(0), uint32(0x00010000),
+    uint32(0), uint32(0), uint32(0), uint32(0), uint32(0), uint32(0xA0000001), uint32(0x12340000)))
+
+  l, e := FromExemplar(ex)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if l.Name != "Test Lot" || l.Width != 2 || l.Depth != 3 || len(l.ZoneTypes) != 3 || len(l.Objects) != 2 {
+    t.Fatalf("Unexpected lot %+v", l)
+  }
+
+  building := l.Objects[0]
+  if building.Type != Building || building.Rotation != 1 || Meters(building.X) != 16 || Meters(building.Z) != 24 ||
+     Meters(building.MaxZ) != 40 || building.InstanceID() != 0xABCD0000 {
+    t.Errorf("Unexpected building %+v", building)
+  }
+
+  prop := l.Objects[1]
+  if prop.Type != Prop || prop.X != -0x10000 || len(prop.IIDs) != 2 || prop.InstanceID() != 0x12340000 {
+    t.Errorf("Unexpected prop %+v", prop)
+  }
+
+  if len(l.ObjectsOfType(Prop)) != 1 || len(l.ObjectsOfType(Texture)) != 0 {
+    t.Error()
+  }
+}
+
+func TestEncodeDecodeLot(t *testing.T) {
+  l := New(1, 2)
+  l.Name = "Round Trip"
+  l.ZoneTypes = []uint8{0x0F}
+  l.WealthTypes = []uint8{0x01, 0x02}
+  l.Objects = []*LotObject{
+    &LotObject{Type: Texture, X: FromMeters(8), Z: FromMeters(8), MaxX: FromMeters(16), MaxZ: FromMeters(16), IIDs: []uint32{0x5E4B0000}},
+    &LotObject{Type: Building, Rotation: 2, X: FromMeters(8), Z: FromMeters(16), IIDs: []uint32{0x1000}},
+  }
+
+  buf := new(bytes.Buffer)
+  if e := l.Encode(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  decoded, e := Decode(buf)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if decoded.Name != l.Name || decoded.Width != 1 || decoded.Depth != 2 || len(decoded.WealthTypes) != 2 || len(decoded.Objects) != 2 {
+    t.Fatalf("Unexpected lot %+v", decoded)
+  }
+
+  if decoded.Objects[0].Type != Texture || decoded.Objects[0].InstanceID() != 0x5E4B0000 || decoded.Objects[1].Rotation != 2 {
+    t.Error()
+  }
+
+  if decoded.Exemplar.Property(LOT_OBJECT_PROPERTY + 1) == nil {
+    t.Error()
+  }
+}
+
+func TestToExemplarRenumbersObjects(t *testing.T) {
+  l := New(1, 1)
+  l.Objects = []*LotObject{&LotObject{Type: Prop, IIDs: []uint32{1}}, &LotObject{Type: Prop, IIDs: []uint32{2}}}
+  l.ToExemplar()
+
+  l.Objects = l.Objects[1:]
+  ex, e := l.ToExemplar()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if ex.Property(LOT_OBJECT_PROPERTY) == nil || ex.Property(LOT_OBJECT_PROPERTY + 1) != nil {
+    t.Error()
+  }
+}
+
+func TestDecodeShortLotObject(t *testing.T) {
+  ex := exemplar.New()
+  ex.SetProperty(exemplar.NewProperty(LOT_OBJECT_PROPERTY, exemplar.Uint32, uint32(0), uint32(0)))
+
+  if _, e := FromExemplar(ex); e == nil {
+    t.Error()
+  }
+}
+
+func TestDecodeCorruptExemplar(t *testing.T) {
+  // A LotConfig exemplar whose single string property claims to hold nearly
+  // 4 GiB of data.
+  data := []byte{ 'E', 'Q', 'Z', 'B', '1', '#', '#', '#',
+                  0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+                  0x1, 0x0, 0x0, 0x0,
+                  0x20, 0x0, 0x0, 0x0, 0x0, 0xC, 0x80, 0x0, 0x0,
+                  0xF0, 0xFF, 0xFF, 0xFF, 'L', 'o', 't' }
+
+  if _, e := Decode(bytes.NewReader(data)); e == nil {
+    t.Error("Expected an error decoding a corrupt exemplar")
+  }
+}
@@ -0,0 +1,259 @@
+package lot
+
+import (
+  "bytes"
+  "encoding/base64"
+  "fmt"
+  "image"
+  "image/color"
+  "image/draw"
+  "image/png"
+  "io"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/fsh"
+)
+
+// TEXTURE_GROUP_ID is the GroupId of the FSH entries that hold lot textures.
+const TEXTURE_GROUP_ID uint32 = 0x0986135E
+
+// The colours used to render the parts of a lot.
+var (
+  groundColor = color.NRGBA{R: 0x7A, G: 0x9A, B: 0x5A, A: 0xFF}
+  missingTextureColor = color.NRGBA{R: 0xC8, G: 0xB4, B: 0x8C, A: 0xFF}
+  networkColor = color.NRGBA{R: 0x60, G: 0x60, B: 0x60, A: 0xCC}
+  buildingColor = color.NRGBA{R: 0xD0, G: 0x20, B: 0x20, A: 0xFF}
+  propColor = color.NRGBA{R: 0x20, G: 0x40, B: 0xD0, A: 0xFF}
+  otherColor = color.NRGBA{R: 0x10, G: 0x60, B: 0x10, A: 0xFF}
+)
+
+// LoadTextures finds the FSH textures referenced by the provided lot in the
+// provided DBPFs and decodes them, keyed by the instance ID used in the lot.
+// DBPFs later in the list take precedence.  Each texture is stored under 5
+// consecutive instance IDs, one per zoom level; the closest zoom available is
+// used.  Textures that can't be found are left out of the result.
+func LoadTextures(l *Lot, dbpfs ...*godbpf.DBPF) (map[uint32]image.Image, error) {
+  textures := make(map[uint32]image.Image)
+
+  for _, o := range l.ObjectsOfType(Texture) {
+    iid := o.InstanceID()
+    if _, found := textures[iid]; found {
+      continue
+    }
+
+    img, e := findTexture(iid, dbpfs)
+    if e != nil {
+      return nil, e
+    }
+
+    if img != nil {
+      textures[iid] = img
+    }
+  }
+
+  return textures, nil
+}
+
+// findTexture searches the DBPFs for the closest zoom level of the texture
+// with the provided instance ID.
+func findTexture(iid uint32, dbpfs []*godbpf.DBPF) (image.Image, error) {
+  for zoom := 4; zoom >= 0; zoom-- {
+    tgi := &entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: TEXTURE_GROUP_ID, InstanceId: iid + uint32(zoom)}
+
+    for i := len(dbpfs) - 1; i >= 0; i-- {
+      if dbpfs[i].Find(tgi) == nil {
+        continue
+      }
+
+      data, e := dbpfs[i].GetUncompressedData(tgi)
+      if e != nil {
+        return nil, e
+      }
+
+      img, e := fsh.DecodeImage(data)
+      if e != nil {
+        return nil, fmt.Errorf("Failed to decode texture {%s}: %s", tgi, e)
+      }
+
+      return img, nil
+    }
+  }
+
+  return nil, nil
+}
+
+// toPixels converts a fixed point lot coordinate to pixels, given the size of
+// a tile in pixels.
+func toPixels(v int32, tileSize int) int {
+  return int(Meters(v) * float64(tileSize) / TILE_SIZE)
+}
+
+// objectBounds returns the bounding box of the provided object in pixels.
+func objectBounds(o *LotObject, tileSize int) image.Rectangle {
+  return image.Rect(toPixels(o.MinX, tileSize), toPixels(o.MinZ, tileSize), toPixels(o.MaxX, tileSize), toPixels(o.MaxZ, tileSize))
+}
+
+// Render draws a top-down preview of the provided lot, with each tile taking
+// tileSize by tileSize pixels.  Base and overlay textures are drawn in lot
+// order using the provided texture images, then networks are shaded and the
+// bounding boxes of buildings, props and other objects are outlined.
+func Render(l *Lot, textures map[uint32]image.Image, tileSize int) *image.NRGBA {
+  img := image.NewNRGBA(image.Rect(0, 0, int(l.Width) * tileSize, int(l.Depth) * tileSize))
+  draw.Draw(img, img.Bounds(), image.NewUniform(groundColor), image.ZP, draw.Src)
+
+  for _, o := range l.ObjectsOfType(Texture) {
+    bounds := objectBounds(o, tileSize)
+    if texture, found := textures[o.InstanceID()]; found {
+      scaled := scaleAndRotate(texture, bounds, img.Bounds(), o.Rotation)
+      draw.Draw(img, scaled.Bounds(), scaled, scaled.Bounds().Min, draw.Over)
+    } else {
+      draw.Draw(img, bounds, image.NewUniform(missingTextureColor), image.ZP, draw.Over)
+    }
+  }
+
+  for _, o := range l.ObjectsOfType(Network) {
+    draw.Draw(img, objectBounds(o, tileSize), image.NewUniform(networkColor), image.ZP, draw.Over)
+  }
+
+  for _, o := range l.Objects {
+    switch o.Type {
+    case Texture, Network:
+    case Building:
+      outline(img, objectBounds(o, tileSize), buildingColor)
+    case Prop:
+      outline(img, objectBounds(o, tileSize), propColor)
+    default:
+      outline(img, objectBounds(o, tileSize), otherColor)
+    }
+  }
+
+  return img
+}
+
+// RenderPNG renders the provided lot and writes it to the Writer as a PNG
+// image.
+func RenderPNG(w io.Writer, l *Lot, textures map[uint32]image.Image, tileSize int) error {
+  return png.Encode(w, Render(l, textures, tileSize))
+}
+
+// RenderSVG renders the provided lot and writes it to the Writer as an SVG
+// image.  The texture images are embedded as PNG data.
+func RenderSVG(w io.Writer, l *Lot, textures map[uint32]image.Image, tileSize int) error {
+  buf := new(bytes.Buffer)
+  width, height := int(l.Width) * tileSize, int(l.Depth) * tileSize
+
+  fmt.Fprintf(buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+  fmt.Fprintf(buf, "  <rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", width, height, svgColor(groundColor))
+
+  for _, o := range l.ObjectsOfType(Texture) {
+    r := objectBounds(o, tileSize)
+    texture, found := textures[o.InstanceID()]
+    if !found {
+      fmt.Fprintf(buf, "  <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", r.Min.X, r.Min.Y, r.Dx(), r.Dy(), svgColor(missingTextureColor))
+      continue
+    }
+
+    // Only the part of the texture inside the lot is embedded.
+    scaled := scaleAndRotate(texture, r, image.Rect(0, 0, width, height), o.Rotation)
+    if scaled.Bounds().Empty() {
+      continue
+    }
+
+    encoded := new(bytes.Buffer)
+    if e := png.Encode(encoded, scaled); e != nil {
+      return e
+    }
+
+    v := scaled.Bounds()
+    fmt.Fprintf(buf, "  <image x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" href=\"data:image/png;base64,%s\"/>\n",
+      v.Min.X, v.Min.Y, v.Dx(), v.Dy(), base64.StdEncoding.EncodeToString(encoded.Bytes()))
+  }
+
+  for _, o := range l.ObjectsOfType(Network) {
+    r := objectBounds(o, tileSize)
+    fmt.Fprintf(buf, "  <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\" fill-opacity=\"%.2f\"/>\n",
+      r.Min.X, r.Min.Y, r.Dx(), r.Dy(), svgColor(networkColor), float64(networkColor.A) / 0xFF)
+  }
+
+  for _, o := range l.Objects {
+    c := otherColor
+    switch o.Type {
+    case Texture, Network:
+      continue
+    case Building:
+      c = buildingColor
+    case Prop:
+      c = propColor
+    }
+
+    r := objectBounds(o, tileSize)
+    fmt.Fprintf(buf, "  <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"none\" stroke=\"%s\"><title>%s 0x%08X</title></rect>\n",
+      r.Min.X, r.Min.Y, r.Dx(), r.Dy(), svgColor(c), o.Type, o.InstanceID())
+  }
+
+  buf.WriteString("</svg>\n")
+
+  _, e := buf.WriteTo(w)
+  return e
+}
+
+// svgColor formats the provided colour as an SVG colour, ignoring its alpha.
+func svgColor(c color.NRGBA) string {
+  return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// scaleAndRotate returns a copy of the provided image scaled to fill the bounds
+// rectangle using nearest neighbour sampling, after turning it clockwise by the
+// provided number of quarter turns.  Only the part of the bounds inside the
+// clip rectangle is computed, since the bounds come from the lot and can be
+// far larger than the rendered image.
+func scaleAndRotate(src image.Image, bounds, clip image.Rectangle, rotation uint32) *image.NRGBA {
+  r := bounds.Intersect(clip)
+  dst := image.NewNRGBA(r)
+  if r.Empty() {
+    return dst
+  }
+
+  b := src.Bounds()
+  for y := r.Min.Y; y < r.Max.Y; y++ {
+    for x := r.Min.X; x < r.Max.X; x++ {
+      // Map the destination pixel back into the unrotated unit square.
+      u := (float64(x - bounds.Min.X) + 0.5) / float64(bounds.Dx())
+      v := (float64(y - bounds.Min.Y) + 0.5) / float64(bounds.Dy())
+      switch rotation % 4 {
+      case 1:
+        u, v = v, 1 - u
+      case 2:
+        u, v = 1 - u, 1 - v
+      case 3:
+        u, v = 1 - v, u
+      }
+
+      sx := b.Min.X + int(u * float64(b.Dx()))
+      sy := b.Min.Y + int(v * float64(b.Dy()))
+      dst.Set(x, y, src.At(sx, sy))
+    }
+  }
+
+  return dst
+}
+
+// outline draws a 1 pixel border of the provided colour along the inside of
+// the rectangle.
+func outline(img *image.NRGBA, r image.Rectangle, c color.NRGBA) {
+  r = r.Intersect(img.Bounds())
+  if r.Empty() {
+    return
+  }
+
+  for x := r.Min.X; x < r.Max.X; x++ {
+    img.SetNRGBA(x, r.Min.Y, c)
+    img.SetNRGBA(x, r.Max.Y - 1, c)
+  }
+
+  for y := r.Min.Y; y < r.Max.Y; y++ {
+    img.SetNRGBA(r.Min.X, y, c)
+    img.SetNRGBA(r.Max.X - 1, y, c)
+  }
+}
@@ -0,0 +1,158 @@
+package lot
+
+import (
+  "bytes"
+  "encoding/binary"
+  "image"
+  "image/color"
+  "image/png"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/fsh"
+)
+
+// createSolidFSH builds a 2x2 ARGB8888 FSH file filled with a single colour.
+func createSolidFSH(c color.NRGBA) []byte {
+  buf := new(bytes.Buffer)
+  buf.WriteString("SHPI")
+  binary.Write(buf, binary.LittleEndian, uint32(56))
+  binary.Write(buf, binary.LittleEndian, uint32(1))
+  buf.WriteString("G264")
+  buf.WriteString("0000")
+  binary.Write(buf, binary.LittleEndian, uint32(24))
+  buf.Write([]byte{ byte(fsh.ARGB8888), 0, 0, 0, 2, 0, 2, 0 })
+  buf.Write(make([]byte, 8))
+  for i := 0; i < 4; i++ {
+    buf.Write([]byte{ c.B, c.G, c.R, c.A })
+  }
+
+  return buf.Bytes()
+}
+
+func createRenderLot() *Lot {
+  b := NewBuilder("Render", 2, 1)
+  b.PlaceTexture(0x30000000, 0, 0, 0)
+  b.PlaceTexture(0x30001000, 1, 0, 0)
+  b.PlaceBuilding(0x10000000, 8, 8, 8, 8, 0)
+  b.PlaceProp(0x20000000, 24, 8, 4, 4, 0)
+
+  return b.Lot()
+}
+
+func TestLoadTextures(t *testing.T) {
+  l := createRenderLot()
+  dbpf := godbpf.New()
+  red := color.NRGBA{R: 0xFF, A: 0xFF}
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: TEXTURE_GROUP_ID, InstanceId: 0x30000004}, createSolidFSH(red))
+
+  textures, e := LoadTextures(l, dbpf)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(textures) != 1 {
+    t.Fatalf("Expected 1 texture but found %d", len(textures))
+  }
+
+  if c := color.NRGBAModel.Convert(textures[0x30000000].At(0, 0)); c != red {
+    t.Errorf("Unexpected texture colour %v", c)
+  }
+}
+
+func TestRender(t *testing.T) {
+  l := createRenderLot()
+  red := color.NRGBA{R: 0xFF, A: 0xFF}
+  textures := map[uint32]image.Image{0x30000000: createSolid(red)}
+
+  img := Render(l, textures, 16)
+  if img.Bounds().Dx() != 32 || img.Bounds().Dy() != 16 {
+    t.Fatalf("Unexpected bounds %v", img.Bounds())
+  }
+
+  if c := img.NRGBAAt(1, 1); c != red {
+    t.Errorf("Expected the texture colour but was %v", c)
+  }
+
+  if c := img.NRGBAAt(17, 1); c != missingTextureColor {
+    t.Errorf("Expected the missing texture colour but was %v", c)
+  }
+
+  if c := img.NRGBAAt(4, 4); c != buildingColor {
+    t.Errorf("Expected the building outline but was %v", c)
+  }
+
+  if c := img.NRGBAAt(22, 6); c != propColor {
+    t.Errorf("Expected the prop outline but was %v", c)
+  }
+
+  buf := new(bytes.Buffer)
+  if e := RenderPNG(buf, l, textures, 16); e != nil {
+    t.Fatal(e)
+  }
+
+  if _, e := png.Decode(buf); e != nil {
+    t.Error(e)
+  }
+}
+
+func TestRenderSVG(t *testing.T) {
+  l := createRenderLot()
+  textures := map[uint32]image.Image{0x30000000: createSolid(color.NRGBA{R: 0xFF, A: 0xFF})}
+
+  buf := new(bytes.Buffer)
+  if e := RenderSVG(buf, l, textures, 16); e != nil {
+    t.Fatal(e)
+  }
+
+  svg := buf.String()
+  if !strings.HasPrefix(svg, "<svg ") || strings.Count(svg, "<image ") != 1 || !strings.Contains(svg, "Building 0x10000000") {
+    t.Errorf("Unexpected SVG %s", svg)
+  }
+}
+
+func TestScaleAndRotate(t *testing.T) {
+  src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+  marker := color.NRGBA{R: 0xFF, A: 0xFF}
+  src.SetNRGBA(0, 0, marker)
+
+  expected := []image.Point{{0, 0}, {3, 0}, {3, 3}, {0, 3}}
+  for rotation, p := range expected {
+    dst := scaleAndRotate(src, image.Rect(0, 0, 4, 4), image.Rect(0, 0, 4, 4), uint32(rotation))
+    if c := dst.NRGBAAt(p.X, p.Y); c != marker {
+      t.Errorf("Rotation %d: expected marker at %v", rotation, p)
+    }
+  }
+
+  dst := scaleAndRotate(src, image.Rect(-4, -4, 4, 4), image.Rect(0, 0, 2, 2), 0)
+  if dst.Bounds() != image.Rect(0, 0, 2, 2) || dst.NRGBAAt(0, 0) == marker {
+    t.Errorf("Unexpected clipped image %v", dst.Bounds())
+  }
+}
+
+func TestRenderHugeTexture(t *testing.T) {
+  l := createRenderLot()
+  textures := map[uint32]image.Image{0x30000000: createSolid(color.NRGBA{R: 0xFF, A: 0xFF})}
+
+  o := l.ObjectsOfType(Texture)[0]
+  o.MinX, o.MinZ, o.MaxX, o.MaxZ = -1 << 30, -1 << 30, 1 << 30, 1 << 30
+
+  img := Render(l, textures, 16)
+  if img.Bounds() != image.Rect(0, 0, 32, 16) || img.NRGBAAt(1, 1) != (color.NRGBA{R: 0xFF, A: 0xFF}) {
+    t.Errorf("Unexpected image %v", img.Bounds())
+  }
+
+  buf := new(bytes.Buffer)
+  if e := RenderSVG(buf, l, textures, 16); e != nil {
+    t.Fatal(e)
+  }
+  if !strings.Contains(buf.String(), "<image x=\"0\" y=\"0\" width=\"32\" height=\"16\"") {
+    t.Errorf("Unexpected SVG %s", buf.String())
+  }
+}
+
+func createSolid(c color.NRGBA) image.Image {
+  return image.NewUniform(c)
+}
@@ -0,0 +1,87 @@
+package ltext
+
+import (
+  "errors"
+  "fmt"
+  "unicode/utf16"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// LTEXT_TYPE_ID is the TypeId used by localized text entries, such as the
+// names and descriptions shown in the menus.
+const LTEXT_TYPE_ID uint32 = 0x2026960B
+
+// marker follows the character count in the header of an LTEXT entry.
+const marker = 0x1000
+
+// Decode returns the text held in the provided LTEXT data, which starts with
+// the number of UTF-16 code units followed by the 0x1000 marker, each stored
+// as 2 bytes.
+func Decode(data []byte) (string, error) {
+  if len(data) < 4 {
+    return "", errors.New("LTEXT data is too short")
+  }
+
+  count := int(data[0]) | int(data[1]) << 8
+  if m := int(data[2]) | int(data[3]) << 8; m != marker {
+    return "", fmt.Errorf("Invalid LTEXT marker 0x%04X", m)
+  }
+
+  if len(data) != 4 + count * 2 {
+    return "", fmt.Errorf("LTEXT data holds %d bytes of text, expected %d", len(data) - 4, count * 2)
+  }
+
+  units := make([]uint16, count)
+  for i := range units {
+    units[i] = uint16(data[4 + i * 2]) | uint16(data[5 + i * 2]) << 8
+  }
+
+  return string(utf16.Decode(units)), nil
+}
+
+// Encode returns the LTEXT data holding the provided text.
+func Encode(text string) ([]byte, error) {
+  units := utf16.Encode([]rune(text))
+  if len(units) > 0xFFFF {
+    return nil, fmt.Errorf("LTEXT can't hold more than %d characters", 0xFFFF)
+  }
+
+  data := make([]byte, 4 + len(units) * 2)
+  data[0], data[1] = byte(len(units)), byte(len(units) >> 8)
+  data[2], data[3] = byte(marker & 0xFF), byte(marker >> 8)
+  for i, u := range units {
+    data[4 + i * 2], data[5 + i * 2] = byte(u), byte(u >> 8)
+  }
+
+  return data, nil
+}
+
+// Read returns the text of the LTEXT entry identified by the provided
+// DBPFEntryTGI, decompressing it if needed.
+func Read(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI) (string, error) {
+  data, e := dbpf.GetUncompressedData(tgi)
+  if e != nil {
+    return "", e
+  }
+
+  return Decode(data)
+}
+
+// Write stores the provided text in the LTEXT entry identified by the provided
+// DBPFEntryTGI.  An existing entry keeps its position and compression state,
+// while a missing one is added as a compressed entry, like lua.Write does.
+func Write(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI, text string) error {
+  data, e := Encode(text)
+  if e != nil {
+    return e
+  }
+
+  if dbpf.Find(tgi) == nil {
+    dbpf.AddCompressedEntry(tgi, data)
+    return nil
+  }
+
+  return dbpf.SetUncompressedData(tgi, data)
+}
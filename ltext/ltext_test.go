@@ -0,0 +1,66 @@
+package ltext
+
+import (
+  "bytes"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func TestEncodeAndDecode(t *testing.T) {
+  data, e := Encode("Café 🏙")
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  expected := []byte{7, 0, 0x00, 0x10, 'C', 0, 'a', 0, 'f', 0, 0xE9, 0, ' ', 0, 0x3C, 0xD8, 0xD9, 0xDF}
+  if !bytes.Equal(data, expected) {
+    t.Errorf("Unexpected LTEXT data % X", data)
+  }
+
+  if text, e := Decode(data); e != nil || text != "Café 🏙" {
+    t.Errorf("Unexpected text %q, %v", text, e)
+  }
+}
+
+func TestDecodeErrors(t *testing.T) {
+  for _, data := range [][]byte{
+    {1, 0},
+    {1, 0, 0, 0x20, 'a', 0},
+    {2, 0, 0, 0x10, 'a', 0},
+  } {
+    if _, e := Decode(data); e == nil {
+      t.Errorf("Expected an error for % X", data)
+    }
+  }
+}
+
+func TestReadAndWrite(t *testing.T) {
+  dbpf := godbpf.New()
+  tgi := &entry.DBPFEntryTGI{TypeId: LTEXT_TYPE_ID, GroupId: 0x6A231EAA, InstanceId: 0x1}
+
+  if e := Write(dbpf, tgi, "Name"); e != nil {
+    t.Fatal(e)
+  }
+  if !dbpf.IsCompressed(tgi) {
+    t.Error("Expected a new entry to be compressed")
+  }
+
+  data, _ := Encode("Old")
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: LTEXT_TYPE_ID, GroupId: 0x6A231EAA, InstanceId: 0x2}, data)
+  compressed := &entry.DBPFEntryTGI{TypeId: LTEXT_TYPE_ID, GroupId: 0x6A231EAA, InstanceId: 0x2}
+  if e := Write(dbpf, compressed, "Description"); e != nil {
+    t.Fatal(e)
+  }
+
+  for tgi, expected := range map[*entry.DBPFEntryTGI]string{tgi: "Name", compressed: "Description"} {
+    if text, e := Read(dbpf, tgi); e != nil || text != expected {
+      t.Errorf("Expected %q, got %q, %v", expected, text, e)
+    }
+  }
+
+  if !dbpf.IsCompressed(compressed) {
+    t.Error("Expected the entry to stay compressed")
+  }
+}
@@ -0,0 +1,293 @@
+package lua
+
+import (
+  "fmt"
+  "strings"
+)
+
+// tokenKind identifies the kind of a token.
+type tokenKind int
+
+const (
+  tokenEOF tokenKind = iota
+  tokenName
+  tokenNumber
+  tokenString
+  tokenKeyword
+  tokenSymbol
+)
+
+// token is a single lexical element of a Lua script.
+type token struct {
+  kind tokenKind
+  text string
+  line int
+}
+
+// String returns the text used to name the receiver in error messages.
+func (t token) String() string {
+  switch t.kind {
+  case tokenEOF:
+    return "<eof>"
+  case tokenString:
+    return "string"
+  }
+
+  return t.text
+}
+
+// keywords lists the reserved words of Lua.
+var keywords = map[string]bool{
+  "and": true, "break": true, "do": true, "else": true, "elseif": true,
+  "end": true, "false": true, "for": true, "function": true, "if": true,
+  "in": true, "local": true, "nil": true, "not": true, "or": true,
+  "repeat": true, "return": true, "then": true, "true": true, "until": true,
+  "while": true,
+}
+
+// symbols lists the operators and punctuation of Lua, longest first so that
+// they can be matched greedily.
+var symbols = []string{
+  "...", "..", "==", "~=", "<=", ">=",
+  "+", "-", "*", "/", "%", "^", "#", "<", ">", "=",
+  "(", ")", "{", "}", "[", "]", ";", ":", ",", ".",
+}
+
+// lexer splits a Lua script into tokens.
+type lexer struct {
+  src string
+  pos int
+  line int
+}
+
+// errorf creates a SyntaxError for the current line of the lexer.
+func (l *lexer) errorf(format string, args ...interface{}) error {
+  return &SyntaxError{Line: l.line, Message: fmt.Sprintf(format, args...)}
+}
+
+// next returns the next token of the script.
+func (l *lexer) next() (token, error) {
+  if e := l.skipSpaceAndComments(); e != nil {
+    return token{}, e
+  }
+
+  if l.pos >= len(l.src) {
+    return token{kind: tokenEOF, line: l.line}, nil
+  }
+
+  c := l.src[l.pos]
+  switch {
+  case isLetter(c):
+    start := l.pos
+    for l.pos < len(l.src) && (isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+      l.pos++
+    }
+
+    text := l.src[start:l.pos]
+    if keywords[text] {
+      return token{kind: tokenKeyword, text: text, line: l.line}, nil
+    }
+    return token{kind: tokenName, text: text, line: l.line}, nil
+
+  case isDigit(c) || (c == '.' && l.pos + 1 < len(l.src) && isDigit(l.src[l.pos + 1])):
+    return l.number()
+
+  case c == '"' || c == '\'':
+    return l.shortString(c)
+
+  case c == '[' && l.longBracketLevel() >= 0:
+    line := l.line
+    text, e := l.longBracket()
+    if e != nil {
+      return token{}, e
+    }
+    return token{kind: tokenString, text: text, line: line}, nil
+  }
+
+  for _, s := range symbols {
+    if strings.HasPrefix(l.src[l.pos:], s) {
+      l.pos += len(s)
+      return token{kind: tokenSymbol, text: s, line: l.line}, nil
+    }
+  }
+
+  return token{}, l.errorf("unexpected symbol near '%c'", c)
+}
+
+// skipSpaceAndComments advances past whitespace and comments.
+func (l *lexer) skipSpaceAndComments() error {
+  for l.pos < len(l.src) {
+    c := l.src[l.pos]
+    switch {
+    case c == '\n':
+      l.line++
+      l.pos++
+    case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+      l.pos++
+    case strings.HasPrefix(l.src[l.pos:], "--"):
+      l.pos += 2
+      if l.pos < len(l.src) && l.src[l.pos] == '[' && l.longBracketLevel() >= 0 {
+        if _, e := l.longBracket(); e != nil {
+          return e
+        }
+        continue
+      }
+
+      for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+        l.pos++
+      }
+    default:
+      return nil
+    }
+  }
+
+  return nil
+}
+
+// longBracketLevel returns the level of the long bracket starting at the
+// current position, or -1 if there isn't one.
+func (l *lexer) longBracketLevel() int {
+  i := l.pos + 1
+  for i < len(l.src) && l.src[i] == '=' {
+    i++
+  }
+
+  if i < len(l.src) && l.src[i] == '[' {
+    return i - l.pos - 1
+  }
+
+  return -1
+}
+
+// longBracket reads a long string or long comment and returns its contents.
+func (l *lexer) longBracket() (string, error) {
+  level := l.longBracketLevel()
+  startLine := l.line
+  l.pos += level + 2
+
+  closing := "]" + strings.Repeat("=", level) + "]"
+  end := strings.Index(l.src[l.pos:], closing)
+  if end < 0 {
+    l.line = startLine
+    return "", l.errorf("unfinished long string/comment")
+  }
+
+  text := l.src[l.pos:l.pos + end]
+  l.line += strings.Count(text, "\n")
+  l.pos += end + len(closing)
+
+  return text, nil
+}
+
+// shortString reads a string delimited by single or double quotes.
+func (l *lexer) shortString(quote byte) (token, error) {
+  line := l.line
+  l.pos++
+
+  buf := new(strings.Builder)
+  for {
+    if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+      return token{}, l.errorf("unfinished string")
+    }
+
+    c := l.src[l.pos]
+    l.pos++
+
+    if c == quote {
+      return token{kind: tokenString, text: buf.String(), line: line}, nil
+    }
+
+    if c != '\\' {
+      buf.WriteByte(c)
+      continue
+    }
+
+    if l.pos >= len(l.src) {
+      return token{}, l.errorf("unfinished string")
+    }
+
+    c = l.src[l.pos]
+    l.pos++
+    switch c {
+    case 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\', '"', '\'', '[', ']':
+      buf.WriteByte(c)
+    case '\n':
+      l.line++
+      buf.WriteByte(c)
+    default:
+      if !isDigit(c) {
+        return token{}, l.errorf("invalid escape sequence '\\%c'", c)
+      }
+
+      value := int(c - '0')
+      for i := 0; i < 2 && l.pos < len(l.src) && isDigit(l.src[l.pos]); i++ {
+        value = value * 10 + int(l.src[l.pos] - '0')
+        l.pos++
+      }
+
+      if value > 255 {
+        return token{}, l.errorf("escape sequence too large")
+      }
+      buf.WriteByte(byte(value))
+    }
+  }
+}
+
+// number reads a numeric literal.
+func (l *lexer) number() (token, error) {
+  start := l.pos
+
+  if strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X") {
+    l.pos += 2
+    for l.pos < len(l.src) && isHexDigit(l.src[l.pos]) {
+      l.pos++
+    }
+
+    if l.pos == start + 2 {
+      return token{}, l.errorf("malformed number near '%s'", l.src[start:l.pos])
+    }
+  } else {
+    for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+      l.pos++
+    }
+
+    if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+      l.pos++
+      if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+        l.pos++
+      }
+
+      digits := l.pos
+      for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+        l.pos++
+      }
+
+      if l.pos == digits {
+        return token{}, l.errorf("malformed number near '%s'", l.src[start:l.pos])
+      }
+    }
+
+    if strings.Count(l.src[start:l.pos], ".") > 1 {
+      return token{}, l.errorf("malformed number near '%s'", l.src[start:l.pos])
+    }
+  }
+
+  // A number running straight into a name, such as 3x, is malformed.
+  if l.pos < len(l.src) && (isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+    return token{}, l.errorf("malformed number near '%s'", l.src[start:l.pos + 1])
+  }
+
+  return token{kind: tokenNumber, text: l.src[start:l.pos], line: l.line}, nil
+}
+
+func isLetter(c byte) bool {
+  return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+  return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+  return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
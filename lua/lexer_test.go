@@ -0,0 +1,72 @@
+package lua
+
+import (
+  "testing"
+)
+
+func tokenize(t *testing.T, src string) []token {
+  l := &lexer{src: src, line: 1}
+  var tokens []token
+  for {
+    tok, e := l.next()
+    if e != nil {
+      t.Fatal(e)
+    }
+
+    if tok.kind == tokenEOF {
+      return tokens
+    }
+    tokens = append(tokens, tok)
+  }
+}
+
+func TestLexerTokens(t *testing.T) {
+  tokens := tokenize(t, "local x = 0x1F .. 'a\\110' -- note\n y ~= 3.5e-2 ... [==[long]]\n]==]")
+
+  expected := []struct {
+    kind tokenKind
+    text string
+    line int
+  }{
+    {tokenKeyword, "local", 1}, {tokenName, "x", 1}, {tokenSymbol, "=", 1},
+    {tokenNumber, "0x1F", 1}, {tokenSymbol, "..", 1}, {tokenString, "an", 1},
+    {tokenName, "y", 2}, {tokenSymbol, "~=", 2}, {tokenNumber, "3.5e-2", 2},
+    {tokenSymbol, "...", 2}, {tokenString, "long]]\n", 2},
+  }
+
+  if len(tokens) != len(expected) {
+    t.Fatalf("Expected %d tokens, got %+v", len(expected), tokens)
+  }
+
+  for i, x := range expected {
+    if tokens[i].kind != x.kind || tokens[i].text != x.text || tokens[i].line != x.line {
+      t.Errorf("Token %d: expected %+v, got %+v", i, x, tokens[i])
+    }
+  }
+}
+
+func TestLexerLongComment(t *testing.T) {
+  tokens := tokenize(t, "--[[ first\nsecond ]] a\n--[=[\n]=] b")
+
+  if len(tokens) != 2 || tokens[0].text != "a" || tokens[0].line != 2 || tokens[1].text != "b" || tokens[1].line != 4 {
+    t.Errorf("Unexpected tokens %+v", tokens)
+  }
+}
+
+func TestLexerErrors(t *testing.T) {
+  for _, src := range []string{"'open", "\"line\nbreak\"", "[[never closed", "3x", "0x", "1.2.3", "@", "'\\q'", "'\\300'"} {
+    l := &lexer{src: src, line: 1}
+
+    var e error
+    for e == nil {
+      var tok token
+      if tok, e = l.next(); tok.kind == tokenEOF && e == nil {
+        break
+      }
+    }
+
+    if _, ok := e.(*SyntaxError); !ok {
+      t.Errorf("Expected a SyntaxError for %q, got %v", src, e)
+    }
+  }
+}
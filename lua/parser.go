@@ -0,0 +1,782 @@
+package lua
+
+import (
+  "fmt"
+  "strings"
+)
+
+// SyntaxError describes a syntax error found in a script.
+type SyntaxError struct {
+  // Name identifies the script, when known.
+  Name string
+
+  // Line is the line number where the error was found.
+  Line int
+
+  // Message describes the error.
+  Message string
+}
+
+// Error returns the error in the format used by the Lua compiler.
+func (e *SyntaxError) Error() string {
+  name := e.Name
+  if name == "" {
+    name = "script"
+  }
+
+  return fmt.Sprintf("%s:%d: %s", name, e.Line, e.Message)
+}
+
+// binaryPriority gives the left and right priority of each binary operator.
+// An operator whose right priority is lower than its left one is right
+// associative.
+var binaryPriority = map[string][2]int{
+  "or": {1, 1}, "and": {2, 2},
+  "<": {3, 3}, ">": {3, 3}, "<=": {3, 3}, ">=": {3, 3}, "~=": {3, 3}, "==": {3, 3},
+  "..": {5, 4},
+  "+": {6, 6}, "-": {6, 6},
+  "*": {7, 7}, "/": {7, 7}, "%": {7, 7},
+  "^": {10, 9},
+}
+
+// unaryPriority is the priority of the unary operators.
+const unaryPriority = 8
+
+// parser checks the syntax of a Lua script using the Lua 5.1 grammar, which
+// also accepts the scripts written for the Lua 5.0 interpreter used by the
+// game.  It doesn't build a syntax tree.
+type parser struct {
+  lex *lexer
+  tok token
+  ahead *token
+
+  // loops counts the loops enclosing the current position, for break.
+  loops int
+
+  // vararg indicates whether the enclosing function accepts '...'.
+  vararg bool
+}
+
+// Check checks the syntax of the provided Lua source code.  The provided name
+// is used in the returned *SyntaxError.
+func Check(name, source string) error {
+  p := &parser{lex: &lexer{src: source, line: 1}, vararg: true}
+
+  e := p.chunk()
+  if e == nil {
+    return nil
+  }
+
+  if syntaxError, ok := e.(*SyntaxError); ok {
+    syntaxError.Name = name
+  }
+
+  return e
+}
+
+// chunk parses the whole script.
+func (p *parser) chunk() error {
+  if strings.HasPrefix(p.lex.src, "#") {
+    // Skip a leading #! line, like the standalone interpreter.
+    for p.lex.pos < len(p.lex.src) && p.lex.src[p.lex.pos] != '\n' {
+      p.lex.pos++
+    }
+  }
+
+  if e := p.advance(); e != nil {
+    return e
+  }
+
+  if e := p.block(); e != nil {
+    return e
+  }
+
+  if p.tok.kind != tokenEOF {
+    return p.errorf("'<eof>' expected near '%s'", p.tok)
+  }
+
+  return nil
+}
+
+// errorf creates a SyntaxError for the line of the current token.
+func (p *parser) errorf(format string, args ...interface{}) error {
+  return &SyntaxError{Line: p.tok.line, Message: fmt.Sprintf(format, args...)}
+}
+
+// advance moves to the next token.
+func (p *parser) advance() error {
+  if p.ahead != nil {
+    p.tok = *p.ahead
+    p.ahead = nil
+    return nil
+  }
+
+  t, e := p.lex.next()
+  if e != nil {
+    return e
+  }
+
+  p.tok = t
+  return nil
+}
+
+// peek returns the token following the current one without consuming it.
+func (p *parser) peek() (token, error) {
+  if p.ahead == nil {
+    t, e := p.lex.next()
+    if e != nil {
+      return token{}, e
+    }
+
+    p.ahead = &t
+  }
+
+  return *p.ahead, nil
+}
+
+// is checks if the current token is the provided keyword or symbol.
+func (p *parser) is(text string) bool {
+  return (p.tok.kind == tokenKeyword || p.tok.kind == tokenSymbol) && p.tok.text == text
+}
+
+// accept consumes the current token if it is the provided keyword or symbol.
+func (p *parser) accept(text string) (bool, error) {
+  if !p.is(text) {
+    return false, nil
+  }
+
+  return true, p.advance()
+}
+
+// expect consumes the current token, which must be the provided keyword or
+// symbol.
+func (p *parser) expect(text string) error {
+  if !p.is(text) {
+    return p.errorf("'%s' expected near '%s'", text, p.tok)
+  }
+
+  return p.advance()
+}
+
+// expectMatch consumes the token that closes a construct opened on the
+// provided line, such as the end of a function.
+func (p *parser) expectMatch(text, opening string, line int) error {
+  if p.is(text) {
+    return p.advance()
+  }
+
+  if line == p.tok.line {
+    return p.expect(text)
+  }
+
+  return p.errorf("'%s' expected (to close '%s' at line %d) near '%s'", text, opening, line, p.tok)
+}
+
+// name consumes a name token.
+func (p *parser) name() error {
+  if p.tok.kind != tokenName {
+    return p.errorf("<name> expected near '%s'", p.tok)
+  }
+
+  return p.advance()
+}
+
+// blockFollows checks if the current token ends a block.
+func (p *parser) blockFollows() bool {
+  return p.tok.kind == tokenEOF || p.is("else") || p.is("elseif") || p.is("end") || p.is("until")
+}
+
+// block parses a sequence of statements, optionally ending with return or
+// break.
+func (p *parser) block() error {
+  for !p.blockFollows() {
+    if p.is("return") || p.is("break") {
+      if e := p.lastStatement(); e != nil {
+        return e
+      }
+
+      if _, e := p.accept(";"); e != nil {
+        return e
+      }
+
+      if !p.blockFollows() {
+        return p.errorf("'end' expected near '%s'", p.tok)
+      }
+
+      return nil
+    }
+
+    if e := p.statement(); e != nil {
+      return e
+    }
+
+    if _, e := p.accept(";"); e != nil {
+      return e
+    }
+  }
+
+  return nil
+}
+
+// lastStatement parses a return or break statement.
+func (p *parser) lastStatement() error {
+  if p.is("break") {
+    if p.loops == 0 {
+      return p.errorf("no loop to break near '%s'", p.tok)
+    }
+
+    return p.advance()
+  }
+
+  if e := p.advance(); e != nil {
+    return e
+  }
+
+  if p.blockFollows() || p.is(";") {
+    return nil
+  }
+
+  return p.expressionList()
+}
+
+// statement parses a single statement.
+func (p *parser) statement() error {
+  line := p.tok.line
+
+  switch {
+  case p.is("if"):
+    return p.ifStatement(line)
+
+  case p.is("while"):
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.expression(); e != nil {
+      return e
+    }
+    if e := p.expect("do"); e != nil {
+      return e
+    }
+    if e := p.loopBlock(); e != nil {
+      return e
+    }
+    return p.expectMatch("end", "while", line)
+
+  case p.is("do"):
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.block(); e != nil {
+      return e
+    }
+    return p.expectMatch("end", "do", line)
+
+  case p.is("for"):
+    return p.forStatement(line)
+
+  case p.is("repeat"):
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.loopBlock(); e != nil {
+      return e
+    }
+    if e := p.expectMatch("until", "repeat", line); e != nil {
+      return e
+    }
+    return p.expression()
+
+  case p.is("function"):
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.name(); e != nil {
+      return e
+    }
+    for p.is(".") {
+      if e := p.advance(); e != nil {
+        return e
+      }
+      if e := p.name(); e != nil {
+        return e
+      }
+    }
+    if ok, e := p.accept(":"); e != nil {
+      return e
+    } else if ok {
+      if e := p.name(); e != nil {
+        return e
+      }
+    }
+    return p.functionBody(line)
+
+  case p.is("local"):
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if ok, e := p.accept("function"); e != nil {
+      return e
+    } else if ok {
+      if e := p.name(); e != nil {
+        return e
+      }
+      return p.functionBody(line)
+    }
+    if e := p.nameList(); e != nil {
+      return e
+    }
+    if ok, e := p.accept("="); e != nil || !ok {
+      return e
+    }
+    return p.expressionList()
+  }
+
+  return p.expressionStatement()
+}
+
+// loopBlock parses the body of a loop, where break is allowed.
+func (p *parser) loopBlock() error {
+  p.loops++
+  e := p.block()
+  p.loops--
+
+  return e
+}
+
+// ifStatement parses an if statement with its elseif and else clauses.
+func (p *parser) ifStatement(line int) error {
+  for {
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.expression(); e != nil {
+      return e
+    }
+    if e := p.expect("then"); e != nil {
+      return e
+    }
+    if e := p.block(); e != nil {
+      return e
+    }
+
+    if !p.is("elseif") {
+      break
+    }
+  }
+
+  if ok, e := p.accept("else"); e != nil {
+    return e
+  } else if ok {
+    if e := p.block(); e != nil {
+      return e
+    }
+  }
+
+  return p.expectMatch("end", "if", line)
+}
+
+// forStatement parses a numeric or generic for loop.
+func (p *parser) forStatement(line int) error {
+  if e := p.advance(); e != nil {
+    return e
+  }
+  if e := p.name(); e != nil {
+    return e
+  }
+
+  if ok, e := p.accept("="); e != nil {
+    return e
+  } else if ok {
+    if e := p.expression(); e != nil {
+      return e
+    }
+    if e := p.expect(","); e != nil {
+      return e
+    }
+    if e := p.expression(); e != nil {
+      return e
+    }
+    if ok, e := p.accept(","); e != nil {
+      return e
+    } else if ok {
+      if e := p.expression(); e != nil {
+        return e
+      }
+    }
+  } else if p.is(",") || p.is("in") {
+    for p.is(",") {
+      if e := p.advance(); e != nil {
+        return e
+      }
+      if e := p.name(); e != nil {
+        return e
+      }
+    }
+    if e := p.expect("in"); e != nil {
+      return e
+    }
+    if e := p.expressionList(); e != nil {
+      return e
+    }
+  } else {
+    return p.errorf("'=' or 'in' expected near '%s'", p.tok)
+  }
+
+  if e := p.expect("do"); e != nil {
+    return e
+  }
+  if e := p.loopBlock(); e != nil {
+    return e
+  }
+
+  return p.expectMatch("end", "for", line)
+}
+
+// nameList parses a comma separated list of names.
+func (p *parser) nameList() error {
+  if e := p.name(); e != nil {
+    return e
+  }
+
+  for p.is(",") {
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.name(); e != nil {
+      return e
+    }
+  }
+
+  return nil
+}
+
+// functionBody parses the parameters and body of a function.
+func (p *parser) functionBody(line int) error {
+  if e := p.expect("("); e != nil {
+    return e
+  }
+
+  vararg := false
+  if !p.is(")") {
+    for {
+      if p.is("...") {
+        vararg = true
+        if e := p.advance(); e != nil {
+          return e
+        }
+        break
+      }
+
+      if e := p.name(); e != nil {
+        return p.errorf("<name> or '...' expected near '%s'", p.tok)
+      }
+
+      if ok, e := p.accept(","); e != nil {
+        return e
+      } else if !ok {
+        break
+      }
+    }
+  }
+
+  if e := p.expect(")"); e != nil {
+    return e
+  }
+
+  outerLoops, outerVararg := p.loops, p.vararg
+  p.loops, p.vararg = 0, vararg
+  e := p.block()
+  p.loops, p.vararg = outerLoops, outerVararg
+  if e != nil {
+    return e
+  }
+
+  return p.expectMatch("end", "function", line)
+}
+
+// expressionStatement parses an assignment or a function call.
+func (p *parser) expressionStatement() error {
+  assignable, call, e := p.suffixedExpression()
+  if e != nil {
+    return e
+  }
+
+  if !p.is("=") && !p.is(",") {
+    if !call {
+      return p.errorf("syntax error near '%s'", p.tok)
+    }
+    return nil
+  }
+
+  for {
+    if !assignable {
+      return p.errorf("syntax error near '%s'", p.tok)
+    }
+
+    if ok, e := p.accept(","); e != nil {
+      return e
+    } else if !ok {
+      break
+    }
+
+    if assignable, _, e = p.suffixedExpression(); e != nil {
+      return e
+    }
+  }
+
+  if e := p.expect("="); e != nil {
+    return e
+  }
+
+  return p.expressionList()
+}
+
+// primaryExpression parses a name or a parenthesized expression.
+func (p *parser) primaryExpression() (bool, error) {
+  if p.tok.kind == tokenName {
+    return true, p.advance()
+  }
+
+  if p.is("(") {
+    line := p.tok.line
+    if e := p.advance(); e != nil {
+      return false, e
+    }
+    if e := p.expression(); e != nil {
+      return false, e
+    }
+    return false, p.expectMatch(")", "(", line)
+  }
+
+  return false, p.errorf("unexpected symbol near '%s'", p.tok)
+}
+
+// suffixedExpression parses a primary expression followed by any number of
+// field accesses, indexes and calls.  It reports whether the result can be
+// assigned to and whether it is a function call.
+func (p *parser) suffixedExpression() (assignable, call bool, e error) {
+  if assignable, e = p.primaryExpression(); e != nil {
+    return
+  }
+
+  for {
+    switch {
+    case p.is("."):
+      if e = p.advance(); e != nil {
+        return
+      }
+      if e = p.name(); e != nil {
+        return
+      }
+      assignable, call = true, false
+
+    case p.is("["):
+      if e = p.advance(); e != nil {
+        return
+      }
+      if e = p.expression(); e != nil {
+        return
+      }
+      if e = p.expect("]"); e != nil {
+        return
+      }
+      assignable, call = true, false
+
+    case p.is(":"):
+      if e = p.advance(); e != nil {
+        return
+      }
+      if e = p.name(); e != nil {
+        return
+      }
+      if e = p.callArguments(); e != nil {
+        return
+      }
+      assignable, call = false, true
+
+    case p.is("(") || p.is("{") || p.tok.kind == tokenString:
+      if e = p.callArguments(); e != nil {
+        return
+      }
+      assignable, call = false, true
+
+    default:
+      return
+    }
+  }
+}
+
+// callArguments parses the arguments of a function call.
+func (p *parser) callArguments() error {
+  switch {
+  case p.tok.kind == tokenString:
+    return p.advance()
+
+  case p.is("{"):
+    return p.tableConstructor()
+
+  case p.is("("):
+    line := p.tok.line
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if !p.is(")") {
+      if e := p.expressionList(); e != nil {
+        return e
+      }
+    }
+    return p.expectMatch(")", "(", line)
+  }
+
+  return p.errorf("function arguments expected near '%s'", p.tok)
+}
+
+// tableConstructor parses a table constructor.
+func (p *parser) tableConstructor() error {
+  line := p.tok.line
+  if e := p.expect("{"); e != nil {
+    return e
+  }
+
+  for !p.is("}") {
+    if e := p.tableField(); e != nil {
+      return e
+    }
+
+    if p.is(",") || p.is(";") {
+      if e := p.advance(); e != nil {
+        return e
+      }
+      continue
+    }
+
+    break
+  }
+
+  return p.expectMatch("}", "{", line)
+}
+
+// tableField parses a single field of a table constructor.
+func (p *parser) tableField() error {
+  if p.is("[") {
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.expression(); e != nil {
+      return e
+    }
+    if e := p.expect("]"); e != nil {
+      return e
+    }
+    if e := p.expect("="); e != nil {
+      return e
+    }
+    return p.expression()
+  }
+
+  if p.tok.kind == tokenName {
+    next, e := p.peek()
+    if e != nil {
+      return e
+    }
+
+    if next.kind == tokenSymbol && next.text == "=" {
+      if e := p.advance(); e != nil {
+        return e
+      }
+      if e := p.advance(); e != nil {
+        return e
+      }
+      return p.expression()
+    }
+  }
+
+  return p.expression()
+}
+
+// expressionList parses a comma separated list of expressions.
+func (p *parser) expressionList() error {
+  if e := p.expression(); e != nil {
+    return e
+  }
+
+  for p.is(",") {
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.expression(); e != nil {
+      return e
+    }
+  }
+
+  return nil
+}
+
+// expression parses a complete expression.
+func (p *parser) expression() error {
+  return p.subExpression(0)
+}
+
+// subExpression parses an expression whose binary operators bind tighter than
+// the provided limit.
+func (p *parser) subExpression(limit int) error {
+  if p.is("not") || p.is("-") || p.is("#") {
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.subExpression(unaryPriority); e != nil {
+      return e
+    }
+  } else if e := p.simpleExpression(); e != nil {
+    return e
+  }
+
+  for {
+    if p.tok.kind != tokenSymbol && p.tok.kind != tokenKeyword {
+      return nil
+    }
+
+    priority, found := binaryPriority[p.tok.text]
+    if !found || priority[0] <= limit {
+      return nil
+    }
+
+    if e := p.advance(); e != nil {
+      return e
+    }
+    if e := p.subExpression(priority[1]); e != nil {
+      return e
+    }
+  }
+}
+
+// simpleExpression parses a literal, function, table constructor or suffixed
+// expression.
+func (p *parser) simpleExpression() error {
+  switch {
+  case p.tok.kind == tokenNumber || p.tok.kind == tokenString:
+    return p.advance()
+
+  case p.is("nil") || p.is("true") || p.is("false"):
+    return p.advance()
+
+  case p.is("..."):
+    if !p.vararg {
+      return p.errorf("cannot use '...' outside a vararg function near '...'")
+    }
+    return p.advance()
+
+  case p.is("{"):
+    return p.tableConstructor()
+
+  case p.is("function"):
+    line := p.tok.line
+    if e := p.advance(); e != nil {
+      return e
+    }
+    return p.functionBody(line)
+  }
+
+  _, _, e := p.suffixedExpression()
+  return e
+}
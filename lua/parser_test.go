@@ -0,0 +1,78 @@
+package lua
+
+import (
+  "testing"
+)
+
+const validScript = `#!/usr/bin/lua
+-- Advisor script
+local advisor = create_advice_mayor("0x4A5E8E0D")
+advisor.trigger = "game.g_city_rating < 20"
+advisor.title = [[Your approval is falling]]
+
+function advisor:message(a, b, ...)
+  local t = { 1, 2; x = a, ["y"] = b, f = function() return nil end, }
+  for i = 1, #t, 2 do
+    if t[i] == nil then break elseif i > 3 then t[i] = -i ^ 2 ^ 3 else t.x = not t.x end
+  end
+  for k, v in pairs(t) do print(k .. v, ...) end
+  while true do repeat local n = 1 until n >= 1 break end
+  do local x, y = (a or b) and 1, "s" end
+  return select('#', ...)
+end
+
+g = advisor:message{ 1 } .. tostring "x"
+return
+`
+
+func TestCheckValid(t *testing.T) {
+  if e := Check("advisor", validScript); e != nil {
+    t.Error(e)
+  }
+
+  if e := Check("empty", ""); e != nil {
+    t.Error(e)
+  }
+}
+
+func TestCheckErrors(t *testing.T) {
+  tests := []struct {
+    source string
+    line int
+    message string
+  }{
+    {"x = 1\ny = ", 2, "unexpected symbol near '<eof>'"},
+    {"if x then\n  y = 1\n", 3, "'end' expected (to close 'if' at line 1) near '<eof>'"},
+    {"break", 1, "no loop to break near 'break'"},
+    {"while true do\n  local f = function() break end\nend", 2, "no loop to break near 'break'"},
+    {"function f()\n  return ...\nend", 2, "cannot use '...' outside a vararg function near '...'"},
+    {"f() = 1", 1, "syntax error near '='"},
+    {"x", 1, "syntax error near '<eof>'"},
+    {"return 1\nx = 2", 2, "'end' expected near 'x'"},
+    {"for x do end", 1, "'=' or 'in' expected near 'do'"},
+    {"local t = { 1 2 }", 1, "'}' expected near '2'"},
+    {"x = 'unterminated", 1, "unfinished string"},
+    {"end", 1, "'<eof>' expected near 'end'"},
+  }
+
+  for _, test := range tests {
+    e := Check("test.lua", test.source)
+
+    syntaxError, ok := e.(*SyntaxError)
+    if !ok {
+      t.Errorf("Expected a SyntaxError for %q, got %v", test.source, e)
+      continue
+    }
+
+    if syntaxError.Name != "test.lua" || syntaxError.Line != test.line || syntaxError.Message != test.message {
+      t.Errorf("Unexpected error for %q: %v", test.source, e)
+    }
+  }
+}
+
+func TestSyntaxErrorString(t *testing.T) {
+  e := &SyntaxError{Name: "a.lua", Line: 3, Message: "unexpected symbol near ')'"}
+  if e.Error() != "a.lua:3: unexpected symbol near ')'" {
+    t.Errorf("Unexpected error string %q", e.Error())
+  }
+}
@@ -0,0 +1,86 @@
+package lua
+
+import (
+  "fmt"
+  "io"
+  "strings"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// SCRIPT_TYPE_ID is the TypeId used by Lua script entries, such as the advisor
+// and mission scripts.
+const SCRIPT_TYPE_ID uint32 = 0xCA63E2A3
+
+// List returns the TGIs of the Lua script entries in the provided DBPF, in the
+// order they are stored.
+func List(dbpf *godbpf.DBPF) []*entry.DBPFEntryTGI {
+  var result []*entry.DBPFEntryTGI
+  for _, e := range dbpf.FindByType(SCRIPT_TYPE_ID) {
+    result = append(result, e.TGI)
+  }
+
+  return result
+}
+
+// Read returns the text of the script entry identified by the provided
+// DBPFEntryTGI, decompressing it if needed.
+func Read(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI) (string, error) {
+  data, e := dbpf.GetUncompressedData(tgi)
+  if e != nil {
+    return "", e
+  }
+
+  return string(data), nil
+}
+
+// Write stores the provided text in the script entry identified by the provided
+// DBPFEntryTGI.  An existing entry keeps its position and compression state,
+// while a missing one is added as a compressed entry.  The text isn't checked;
+// use Check or SaveChecked for that.
+func Write(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI, source string) error {
+  if dbpf.Find(tgi) == nil {
+    dbpf.AddCompressedEntry(tgi, []byte(source))
+    return nil
+  }
+
+  return dbpf.SetUncompressedData(tgi, []byte(source))
+}
+
+// CheckAll checks the syntax of every script entry in the provided DBPF and
+// returns the errors found, one per broken script.  Each SyntaxError is named
+// after the TGI of its entry.
+func CheckAll(dbpf *godbpf.DBPF) []error {
+  var errors []error
+  for _, tgi := range List(dbpf) {
+    source, e := Read(dbpf, tgi)
+    if e != nil {
+      errors = append(errors, e)
+      continue
+    }
+
+    if e := Check(fmt.Sprintf("{%s}", tgi), source); e != nil {
+      errors = append(errors, e)
+    }
+  }
+
+  return errors
+}
+
+// SaveChecked checks the syntax of every script entry in the provided DBPF and
+// saves it to the provided Writer only if they are all valid.  Otherwise, nothing
+// is written and an error listing every problem is returned.
+func SaveChecked(dbpf *godbpf.DBPF, w io.Writer) error {
+  errors := CheckAll(dbpf)
+  if len(errors) == 0 {
+    return dbpf.Save(w)
+  }
+
+  messages := make([]string, len(errors))
+  for i, e := range errors {
+    messages[i] = e.Error()
+  }
+
+  return fmt.Errorf("%d script(s) failed the syntax check:\n%s", len(errors), strings.Join(messages, "\n"))
+}
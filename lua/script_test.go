@@ -0,0 +1,85 @@
+package lua
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func TestListReadWrite(t *testing.T) {
+  dbpf := godbpf.New()
+  compressed := &entry.DBPFEntryTGI{TypeId: SCRIPT_TYPE_ID, GroupId: 0x4A5E8EF6, InstanceId: 1}
+  plain := &entry.DBPFEntryTGI{TypeId: SCRIPT_TYPE_ID, GroupId: 0x4A5E8EF6, InstanceId: 2}
+  other := &entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x4A5E8EF6, InstanceId: 3}
+
+  dbpf.AddCompressedEntry(compressed, []byte("x = 1"))
+  plainEntry := entry.NewEntry(plain)
+  plainEntry.SetData([]byte("y = 2"))
+  dbpf.AddEntry(plainEntry)
+  dbpf.AddEntry(entry.NewEntry(other))
+
+  tgis := List(dbpf)
+  if len(tgis) != 2 || !tgis[0].Equals(compressed) || !tgis[1].Equals(plain) {
+    t.Fatalf("Unexpected script list %v", tgis)
+  }
+
+  if source, e := Read(dbpf, compressed); e != nil || source != "x = 1" {
+    t.Errorf("Unexpected compressed script %q, %v", source, e)
+  }
+
+  if e := Write(dbpf, compressed, "x = 10"); e != nil {
+    t.Fatal(e)
+  }
+  if !dbpf.IsCompressed(compressed) {
+    t.Error("Expected the script to stay compressed")
+  }
+  if source, _ := Read(dbpf, compressed); source != "x = 10" {
+    t.Errorf("Unexpected updated script %q", source)
+  }
+
+  if e := Write(dbpf, plain, "y = 20"); e != nil {
+    t.Fatal(e)
+  }
+  if dbpf.IsCompressed(plain) || string(plainEntry.GetData()) != "y = 20" {
+    t.Error("Expected the uncompressed script to be replaced in place")
+  }
+
+  added := &entry.DBPFEntryTGI{TypeId: SCRIPT_TYPE_ID, GroupId: 0x4A5E8EF6, InstanceId: 4}
+  if e := Write(dbpf, added, "z = 3"); e != nil {
+    t.Fatal(e)
+  }
+  if !dbpf.IsCompressed(added) || len(List(dbpf)) != 3 {
+    t.Error("Expected the new script to be added compressed")
+  }
+
+  if _, e := Read(dbpf, &entry.DBPFEntryTGI{TypeId: SCRIPT_TYPE_ID, GroupId: 0, InstanceId: 0}); e == nil {
+    t.Error("Expected an error reading a missing script")
+  }
+}
+
+func TestSaveChecked(t *testing.T) {
+  dbpf := godbpf.New()
+  good := &entry.DBPFEntryTGI{TypeId: SCRIPT_TYPE_ID, GroupId: 0x4A5E8EF6, InstanceId: 1}
+  bad := &entry.DBPFEntryTGI{TypeId: SCRIPT_TYPE_ID, GroupId: 0x4A5E8EF6, InstanceId: 2}
+  dbpf.AddCompressedEntry(good, []byte("x = 1"))
+  dbpf.AddCompressedEntry(bad, []byte("if x then\n"))
+
+  buf := new(bytes.Buffer)
+  e := SaveChecked(dbpf, buf)
+  if e == nil || buf.Len() != 0 {
+    t.Fatal("Expected SaveChecked to refuse a broken script")
+  }
+  if !strings.Contains(e.Error(), "1 script(s)") || !strings.Contains(e.Error(), bad.String() + "}:2:") {
+    t.Errorf("Unexpected error %v", e)
+  }
+
+  if e := Write(dbpf, bad, "if x then end\n"); e != nil {
+    t.Fatal(e)
+  }
+  if e := SaveChecked(dbpf, buf); e != nil || buf.Len() == 0 {
+    t.Errorf("Expected SaveChecked to save valid scripts, got %v", e)
+  }
+}
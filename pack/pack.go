@@ -0,0 +1,468 @@
+package pack
+
+import (
+  "bytes"
+  "encoding/binary"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sort"
+  "strconv"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/util"
+)
+
+// MANIFEST_NAME is the name of the manifest file in an unpacked directory.
+const MANIFEST_NAME = "manifest.json"
+
+// MANIFEST_VERSION is the version of the manifest format written by Unpack.
+const MANIFEST_VERSION = 1
+
+// Manifest records everything about a DBPF file that isn't held in the entry
+// files of an unpacked directory, so that Pack can rebuild the file exactly.
+type Manifest struct {
+  Version int `json:"version"`
+  Header Header `json:"header"`
+
+  // Entries lists the entries in the order of the index.
+  Entries []*Entry `json:"entries"`
+
+  // Layout lists the parts of the file that follow the header, in the order
+  // they are stored.
+  Layout []*Segment `json:"layout"`
+}
+
+// Header holds the header fields that can't be computed from the entries.  The
+// timestamps are the raw values stored in the file.
+type Header struct {
+  MajorVersion uint32 `json:"majorVersion"`
+  MinorVersion uint32 `json:"minorVersion"`
+  UserMajorVersion uint32 `json:"userMajorVersion"`
+  UserMinorVersion uint32 `json:"userMinorVersion"`
+  Flags uint32 `json:"flags"`
+  Created uint32 `json:"created"`
+  Modified uint32 `json:"modified"`
+  IndexMajorVersion uint32 `json:"indexMajorVersion"`
+  IndexMinorVersion uint32 `json:"indexMinorVersion"`
+  HoleCount uint32 `json:"holeCount"`
+
+  // HoleOffset is only used when the file has no hole index, in which case
+  // the offset doesn't point to anything.
+  HoleOffset uint32 `json:"holeOffset,omitempty"`
+
+  // IndexOffset is only used when the index is empty, in which case it isn't
+  // part of the layout.
+  IndexOffset uint32 `json:"indexOffset,omitempty"`
+
+  // Reserved holds the last 32 bytes of the header in hexadecimal.
+  Reserved string `json:"reserved"`
+}
+
+// Entry describes an entry of the index.
+type Entry struct {
+  TGI string `json:"tgi"`
+
+  // File is the name of the file holding the data of the entry.  It is empty
+  // for entries sharing the data of another entry and for empty entries.
+  File string `json:"file,omitempty"`
+
+  // Location is the location stored in the index for an empty entry, which
+  // isn't part of the layout.
+  Location uint32 `json:"location,omitempty"`
+
+  // Compressed indicates that the entry is stored compressed.  Its file holds
+  // the uncompressed data, unless Raw is set.
+  Compressed bool `json:"compressed,omitempty"`
+
+  // Raw indicates that the file of a compressed entry holds the data exactly
+  // as stored, because compressing the uncompressed data again wouldn't give
+  // back the same bytes.
+  Raw bool `json:"raw,omitempty"`
+
+  // SharedWith is the index of an earlier entry whose data this entry uses.
+  SharedWith *int `json:"sharedWith,omitempty"`
+}
+
+// Segment is one part of the file following the header: the data of an entry,
+// the index, the hole index, or bytes that don't belong to any of these.
+type Segment struct {
+  Entry *int `json:"entry,omitempty"`
+  Index bool `json:"index,omitempty"`
+
+  // Holes holds the hole index in hexadecimal.
+  Holes string `json:"holes,omitempty"`
+
+  // Gap holds unused bytes in hexadecimal.
+  Gap string `json:"gap,omitempty"`
+}
+
+// region is a range of bytes of a DBPF file, used while working out its
+// layout.
+type region struct {
+  start, size uint32
+  segment *Segment
+}
+
+// Unpack writes the entries of the provided DBPF file to the named directory,
+// one file per entry, along with a manifest.  Before writing anything, it
+// checks that Pack rebuilds the exact same bytes from the result.
+func Unpack(data []byte, dir string) error {
+  m, files, e := analyze(data)
+  if e != nil {
+    return e
+  }
+
+  rebuilt, e := build(m, func(name string) ([]byte, error) { return files[name], nil })
+  if e != nil {
+    return e
+  }
+  if !bytes.Equal(rebuilt, data) {
+    return errors.New("The file can't be unpacked losslessly")
+  }
+
+  if e := os.MkdirAll(dir, 0755); e != nil {
+    return e
+  }
+
+  for name, content := range files {
+    if e := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); e != nil {
+      return e
+    }
+  }
+
+  manifest, e := json.MarshalIndent(m, "", "  ")
+  if e != nil {
+    return e
+  }
+
+  return ioutil.WriteFile(filepath.Join(dir, MANIFEST_NAME), append(manifest, '\n'), 0644)
+}
+
+// Pack rebuilds a DBPF file from a directory written by Unpack.  Entry files
+// may have been edited: the layout of the file is kept, with everything after
+// an edited entry moved as needed, and the DIR entry is updated with the new
+// uncompressed sizes.
+func Pack(dir string) ([]byte, error) {
+  data, e := ioutil.ReadFile(filepath.Join(dir, MANIFEST_NAME))
+  if e != nil {
+    return nil, e
+  }
+
+  m := new(Manifest)
+  if e := json.Unmarshal(data, m); e != nil {
+    return nil, fmt.Errorf("%s: %v", MANIFEST_NAME, e)
+  }
+
+  if m.Version != MANIFEST_VERSION {
+    return nil, fmt.Errorf("Unsupported manifest version %d", m.Version)
+  }
+
+  return build(m, func(name string) ([]byte, error) {
+    return ioutil.ReadFile(filepath.Join(dir, name))
+  })
+}
+
+// analyze works out the manifest and entry files of the provided DBPF file.
+func analyze(data []byte) (*Manifest, map[string][]byte, error) {
+  if len(data) < godbpf.HEADER_SIZE || string(data[0:4]) != "DBPF" {
+    return nil, nil, errors.New("Not a DBPF file")
+  }
+
+  field := func(offset int) uint32 {
+    return binary.LittleEndian.Uint32(data[offset:offset + 4])
+  }
+
+  m := &Manifest{Version: MANIFEST_VERSION}
+  m.Header = Header{
+    MajorVersion: field(4),
+    MinorVersion: field(8),
+    UserMajorVersion: field(12),
+    UserMinorVersion: field(16),
+    Flags: field(20),
+    Created: field(24),
+    Modified: field(28),
+    IndexMajorVersion: field(32),
+    IndexMinorVersion: field(60),
+    HoleCount: field(48),
+    Reserved: hex.EncodeToString(data[64:96]),
+  }
+
+  count, indexOffset, holeOffset, holeSize := field(36), field(40), field(52), field(56)
+  if holeSize == 0 {
+    m.Header.HoleOffset = holeOffset
+  }
+  if uint64(indexOffset) + uint64(count) * godbpf.INDEX_ENTRY_SIZE > uint64(len(data)) {
+    return nil, nil, errors.New("The index extends past the end of the file")
+  }
+
+  regions := []*region{}
+  if count > 0 {
+    regions = append(regions, &region{start: indexOffset, size: count * godbpf.INDEX_ENTRY_SIZE, segment: &Segment{Index: true}})
+  } else {
+    m.Header.IndexOffset = indexOffset
+  }
+  if holeSize > 0 {
+    if uint64(holeOffset) + uint64(holeSize) > uint64(len(data)) {
+      return nil, nil, errors.New("The hole index extends past the end of the file")
+    }
+
+    holes := hex.EncodeToString(data[holeOffset:holeOffset + holeSize])
+    regions = append(regions, &region{start: holeOffset, size: holeSize, segment: &Segment{Holes: holes}})
+  }
+
+  // Read the index, noting which entries are compressed.
+  type record struct {
+    tgi *entry.DBPFEntryTGI
+    location, size uint32
+  }
+
+  records := make([]record, count)
+  compressed := make(map[string]bool)
+  for i := range records {
+    r := data[indexOffset + uint32(i) * godbpf.INDEX_ENTRY_SIZE:]
+    records[i] = record{
+      tgi: &entry.DBPFEntryTGI{TypeId: util.ReadUint32(r[0:4]), GroupId: util.ReadUint32(r[4:8]), InstanceId: util.ReadUint32(r[8:12])},
+      location: util.ReadUint32(r[12:16]),
+      size: util.ReadUint32(r[16:20]),
+    }
+
+    if uint64(records[i].location) + uint64(records[i].size) > uint64(len(data)) {
+      return nil, nil, fmt.Errorf("Entry {%s} extends past the end of the file", records[i].tgi)
+    }
+
+    if records[i].tgi.Equals(entry.DIR_ENTRY_TGI) {
+      dir := data[records[i].location:records[i].location + records[i].size]
+      for j := 0; j + 16 <= len(dir); j += 16 {
+        compressed[hex.EncodeToString(dir[j:j + 12])] = true
+      }
+    }
+  }
+
+  files := make(map[string][]byte)
+  shared := make(map[[2]uint32]int)
+  for i, r := range records {
+    e := &Entry{TGI: formatTGI(r.tgi)}
+    m.Entries = append(m.Entries, e)
+
+    if r.size == 0 {
+      e.Location = r.location
+      continue
+    }
+
+    if first, found := shared[[2]uint32{r.location, r.size}]; found {
+      e.SharedWith = &first
+      continue
+    }
+    shared[[2]uint32{r.location, r.size}] = i
+
+    stored := data[r.location:r.location + r.size]
+    tgiBytes := make([]byte, 12)
+    r.tgi.Bytes(tgiBytes)
+    e.Compressed = compressed[hex.EncodeToString(tgiBytes)]
+    e.File = fmt.Sprintf("%04d-%08X-%08X-%08X.bin", i, r.tgi.TypeId, r.tgi.GroupId, r.tgi.InstanceId)
+
+    files[e.File] = stored
+    if e.Compressed {
+      if uncompressed, err := godbpf.Decompress(stored); err == nil && bytes.Equal(godbpf.Compress(uncompressed), stored) {
+        files[e.File] = uncompressed
+      } else {
+        e.Raw = true
+      }
+    }
+
+    index := i
+    regions = append(regions, &region{start: r.location, size: r.size, segment: &Segment{Entry: &index}})
+  }
+
+  // Lay out the regions in the order they are stored, filling the space
+  // between them with gaps.
+  sort.SliceStable(regions, func(i, j int) bool { return regions[i].start < regions[j].start })
+
+  position := uint32(godbpf.HEADER_SIZE)
+  for _, r := range regions {
+    if r.start < position {
+      return nil, nil, fmt.Errorf("Overlapping data at offset %d can't be unpacked", r.start)
+    }
+
+    if r.start > position {
+      m.Layout = append(m.Layout, &Segment{Gap: hex.EncodeToString(data[position:r.start])})
+    }
+
+    m.Layout = append(m.Layout, r.segment)
+    position = r.start + r.size
+  }
+
+  if position < uint32(len(data)) {
+    m.Layout = append(m.Layout, &Segment{Gap: hex.EncodeToString(data[position:])})
+  }
+
+  return m, files, nil
+}
+
+// formatTGI returns the manifest form of a TGI.
+func formatTGI(tgi *entry.DBPFEntryTGI) string {
+  return fmt.Sprintf("%08X-%08X-%08X", tgi.TypeId, tgi.GroupId, tgi.InstanceId)
+}
+
+// parseTGI parses the manifest form of a TGI.
+func parseTGI(s string) (*entry.DBPFEntryTGI, error) {
+  if len(s) != 26 || s[8] != '-' || s[17] != '-' {
+    return nil, fmt.Errorf("Invalid TGI %q", s)
+  }
+
+  ids := make([]uint32, 3)
+  for i, part := range []string{s[0:8], s[9:17], s[18:26]} {
+    id, e := strconv.ParseUint(part, 16, 32)
+    if e != nil {
+      return nil, fmt.Errorf("Invalid TGI %q", s)
+    }
+    ids[i] = uint32(id)
+  }
+
+  return &entry.DBPFEntryTGI{TypeId: ids[0], GroupId: ids[1], InstanceId: ids[2]}, nil
+}
+
+// build creates a DBPF file from the provided manifest, reading the entry
+// files with the provided function.
+func build(m *Manifest, read func(name string) ([]byte, error)) ([]byte, error) {
+  tgis := make([]*entry.DBPFEntryTGI, len(m.Entries))
+  stored := make([][]byte, len(m.Entries))
+  uncompressedSizes := make(map[string]uint32)
+  dirIndex := -1
+
+  for i, e := range m.Entries {
+    tgi, err := parseTGI(e.TGI)
+    if err != nil {
+      return nil, err
+    }
+    tgis[i] = tgi
+
+    if e.SharedWith != nil {
+      if *e.SharedWith < 0 || *e.SharedWith >= i || m.Entries[*e.SharedWith].SharedWith != nil {
+        return nil, fmt.Errorf("Entry %s shares the data of an invalid entry", e.TGI)
+      }
+      continue
+    }
+
+    if e.File == "" {
+      continue
+    }
+
+    data, err := read(e.File)
+    if err != nil {
+      return nil, err
+    }
+
+    switch {
+    case e.Compressed && !e.Raw:
+      uncompressedSizes[e.TGI] = uint32(len(data))
+      data = godbpf.Compress(data)
+    case e.Compressed:
+      if uncompressed, err := godbpf.Decompress(data); err == nil {
+        uncompressedSizes[e.TGI] = uint32(len(uncompressed))
+      }
+    }
+
+    if tgi.Equals(entry.DIR_ENTRY_TGI) {
+      dirIndex = i
+    }
+    stored[i] = data
+  }
+
+  // Bring the DIR entry up to date with the sizes of the compressed entries.
+  if dirIndex >= 0 {
+    dir := make([]byte, len(stored[dirIndex]))
+    copy(dir, stored[dirIndex])
+    for j := 0; j + 16 <= len(dir); j += 16 {
+      tgi := &entry.DBPFEntryTGI{TypeId: util.ReadUint32(dir[j:j + 4]), GroupId: util.ReadUint32(dir[j + 4:j + 8]), InstanceId: util.ReadUint32(dir[j + 8:j + 12])}
+      if size, found := uncompressedSizes[formatTGI(tgi)]; found {
+        copy(dir[j + 12:j + 16], util.WriteUint32(size))
+      }
+    }
+    stored[dirIndex] = dir
+  }
+
+  buf := bytes.NewBuffer(make([]byte, godbpf.HEADER_SIZE))
+  locations := make([]uint32, len(m.Entries))
+  placed := make([]bool, len(m.Entries))
+  indexOffset, holeOffset, holeSize := -1, m.Header.HoleOffset, uint32(0)
+  if len(m.Entries) == 0 {
+    indexOffset = int(m.Header.IndexOffset)
+  }
+
+  for _, s := range m.Layout {
+    switch {
+    case s.Entry != nil:
+      i := *s.Entry
+      if i < 0 || i >= len(m.Entries) || m.Entries[i].File == "" || placed[i] {
+        return nil, fmt.Errorf("Layout refers to an invalid entry %d", i)
+      }
+
+      placed[i] = true
+      locations[i] = uint32(buf.Len())
+      buf.Write(stored[i])
+    case s.Index:
+      indexOffset = buf.Len()
+      buf.Write(make([]byte, len(m.Entries) * godbpf.INDEX_ENTRY_SIZE))
+    case s.Holes != "":
+      holes, err := hex.DecodeString(s.Holes)
+      if err != nil {
+        return nil, fmt.Errorf("Invalid hole index: %v", err)
+      }
+      holeOffset, holeSize = uint32(buf.Len()), uint32(len(holes))
+      buf.Write(holes)
+    default:
+      gap, err := hex.DecodeString(s.Gap)
+      if err != nil {
+        return nil, fmt.Errorf("Invalid gap: %v", err)
+      }
+      buf.Write(gap)
+    }
+  }
+
+  if indexOffset < 0 {
+    return nil, errors.New("Layout doesn't place the index")
+  }
+
+  data := buf.Bytes()
+  for i, e := range m.Entries {
+    source := i
+    if e.SharedWith != nil {
+      source = *e.SharedWith
+    } else if e.File == "" {
+      locations[i] = e.Location
+    } else if !placed[i] {
+      return nil, fmt.Errorf("Layout doesn't place entry %s", e.TGI)
+    }
+
+    r := data[indexOffset + i * godbpf.INDEX_ENTRY_SIZE:]
+    tgis[i].Bytes(r[0:12])
+    copy(r[12:16], util.WriteUint32(locations[source]))
+    copy(r[16:20], util.WriteUint32(uint32(len(stored[source]))))
+  }
+
+  reserved, e := hex.DecodeString(m.Header.Reserved)
+  if e != nil || len(reserved) != 32 {
+    return nil, errors.New("Reserved header bytes must be 32 bytes in hexadecimal")
+  }
+
+  h := m.Header
+  copy(data[0:4], "DBPF")
+  for i, v := range []uint32{
+    h.MajorVersion, h.MinorVersion, h.UserMajorVersion, h.UserMinorVersion, h.Flags,
+    h.Created, h.Modified, h.IndexMajorVersion,
+    uint32(len(m.Entries)), uint32(indexOffset), uint32(len(m.Entries) * godbpf.INDEX_ENTRY_SIZE),
+    h.HoleCount, holeOffset, holeSize, h.IndexMinorVersion,
+  } {
+    copy(data[4 + i * 4:], util.WriteUint32(v))
+  }
+  copy(data[64:96], reserved)
+
+  return data, nil
+}
@@ -0,0 +1,208 @@
+package pack
+
+import (
+  "bytes"
+  "encoding/binary"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+var (
+  compressedTGI = &entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x1, InstanceId: 0x1}
+  plainTGI = &entry.DBPFEntryTGI{TypeId: 0x856DDBAC, GroupId: 0x2, InstanceId: 0x2}
+)
+
+// savedDBPF returns a DBPF file written by godbpf.Save.
+func savedDBPF(t *testing.T) []byte {
+  dbpf := godbpf.New()
+  dbpf.MajorVersion = 1
+  dbpf.IndexMajorVersion = 7
+  dbpf.UserMajorVersion = 9
+  dbpf.CreatedDate = time.Unix(1457487328, 0)
+  dbpf.ModifiedDate = time.Unix(1457490790, 0)
+
+  dbpf.AddCompressedEntry(compressedTGI, []byte("abcabcabcabcabcabcabc uncompressed text"))
+  plain := entry.NewEntry(plainTGI)
+  plain.SetData([]byte("plain data"))
+  dbpf.AddEntry(plain)
+
+  buf := new(bytes.Buffer)
+  dbpf.Save(buf)
+  return buf.Bytes()
+}
+
+// handmadeDBPF returns a DBPF file with its index before the data, a gap, a
+// hole index, two entries sharing their data, an empty entry, and a DIR
+// record for an entry that doesn't hold a valid compressed stream.
+func handmadeDBPF() []byte {
+  put := func(b []byte, values ...uint32) {
+    for i, v := range values {
+      binary.LittleEndian.PutUint32(b[i * 4:], v)
+    }
+  }
+
+  data := make([]byte, 96 + 5 * 20)
+  copy(data, "DBPF")
+
+  dirRecord := make([]byte, 16)
+  put(dirRecord, 0xAA, 0xBB, 0xCC, 99)
+
+  type part struct {
+    tgi [3]uint32
+    data []byte
+  }
+  parts := []part{
+    {[3]uint32{0xAA, 0xBB, 0xCC}, []byte("\x05\x00\x00\x00not qfs")},
+    {[3]uint32{0x11, 0x22, 0x33}, []byte("shared")},
+    {[3]uint32{0xE86B1EEF, 0xE86B1EEF, 0x286B1F03}, dirRecord},
+  }
+
+  locations := make([]uint32, len(parts))
+  for i, p := range parts {
+    data = append(data, 0xEE, 0xEE, 0xEE)
+    locations[i] = uint32(len(data))
+    data = append(data, p.data...)
+  }
+
+  holeOffset := uint32(len(data))
+  data = append(data, 1, 2, 3, 4, 5, 6, 7, 8, 0xFF)
+
+  put(data[4:], 1, 0, 0, 0, 0, 100, 200, 7, 5, 96, 100, 1, holeOffset, 9, 0)
+  data[64] = 0x42
+
+  index := data[96:]
+  put(index[0:], 0xAA, 0xBB, 0xCC, locations[0], uint32(len(parts[0].data)))
+  put(index[20:], 0x11, 0x22, 0x33, locations[1], uint32(len(parts[1].data)))
+  put(index[40:], 0x11, 0x22, 0x34, locations[1], uint32(len(parts[1].data)))
+  put(index[60:], 0x44, 0x55, 0x66, 0, 0)
+  put(index[80:], 0xE86B1EEF, 0xE86B1EEF, 0x286B1F03, locations[2], 16)
+
+  return data
+}
+
+func TestUnpackAndPackSavedDBPF(t *testing.T) {
+  dir := t.TempDir()
+
+  original := savedDBPF(t)
+  if e := Unpack(original, dir); e != nil {
+    t.Fatal(e)
+  }
+
+  text, e := ioutil.ReadFile(filepath.Join(dir, "0000-6534284A-00000001-00000001.bin"))
+  if e != nil || string(text) != "abcabcabcabcabcabcabc uncompressed text" {
+    t.Errorf("Expected the compressed entry to be unpacked uncompressed, got %q, %v", text, e)
+  }
+
+  packed, e := Pack(dir)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if !bytes.Equal(packed, original) {
+    t.Error("Expected Pack to rebuild the original bytes")
+  }
+}
+
+func TestUnpackAndPackHandmadeDBPF(t *testing.T) {
+  dir := t.TempDir()
+
+  original := handmadeDBPF()
+  if e := Unpack(original, dir); e != nil {
+    t.Fatal(e)
+  }
+
+  manifest, _ := ioutil.ReadFile(filepath.Join(dir, MANIFEST_NAME))
+  for _, expected := range []string{`"raw": true`, `"sharedWith": 1`, `"holes": "0102030405060708ff"`, `"gap": "eeeeee"`, `"userMajorVersion": 0`} {
+    if !strings.Contains(string(manifest), expected) {
+      t.Errorf("Expected %s in the manifest:\n%s", expected, manifest)
+    }
+  }
+
+  packed, e := Pack(dir)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if !bytes.Equal(packed, original) {
+    t.Errorf("Expected Pack to rebuild the original bytes:\n% X\n% X", packed, original)
+  }
+}
+
+func TestPackEditedEntry(t *testing.T) {
+  dir := t.TempDir()
+
+  if e := Unpack(savedDBPF(t), dir); e != nil {
+    t.Fatal(e)
+  }
+
+  edited := strings.Repeat("edited text that is longer than before ", 4)
+  ioutil.WriteFile(filepath.Join(dir, "0000-6534284A-00000001-00000001.bin"), []byte(edited), 0644)
+
+  packed, e := Pack(dir)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  dbpf, e := godbpf.Parse(bytes.NewReader(packed))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if data, e := dbpf.GetUncompressedData(compressedTGI); e != nil || string(data) != edited {
+    t.Errorf("Unexpected edited entry %q, %v", data, e)
+  }
+
+  if size, _ := dbpf.Find(entry.DIR_ENTRY_TGI).GetUncompressedSize(compressedTGI); size != uint32(len(edited)) {
+    t.Errorf("Expected the DIR entry to be updated, got %d", size)
+  }
+
+  if data, _ := dbpf.GetUncompressedData(plainTGI); string(data) != "plain data" {
+    t.Errorf("Expected the following entry to be moved, got %q", data)
+  }
+
+  if dbpf.UserMajorVersion != 9 || dbpf.ModifiedDate.Unix() != 1457490790 {
+    t.Errorf("Expected the header fields to be kept, got %+v", dbpf)
+  }
+}
+
+func TestUnpackErrors(t *testing.T) {
+  overlapping := handmadeDBPF()
+  binary.LittleEndian.PutUint32(overlapping[96 + 12:], 100)
+
+  for name, data := range map[string][]byte{
+    "short": []byte("DBPF"),
+    "magic": append([]byte("XXXX"), make([]byte, 96)...),
+    "overlap": overlapping,
+  } {
+    if e := Unpack(data, filepath.Join(os.TempDir(), "unused")); e == nil {
+      t.Errorf("Expected an error for the %s file", name)
+    }
+  }
+}
+
+func TestPackErrors(t *testing.T) {
+  dir := t.TempDir()
+
+  if _, e := Pack(dir); e == nil {
+    t.Error("Expected an error without a manifest")
+  }
+
+  for _, manifest := range []string{
+    `{"version": 2}`,
+    `{"version": 1, "header": {"reserved": "00"}, "layout": [{"index": true}]}`,
+    `{"version": 1, "entries": [{"tgi": "bad"}], "layout": [{"index": true}]}`,
+    `{"version": 1, "entries": [{"tgi": "00000001-00000002-00000003", "file": "missing.bin"}], "layout": [{"index": true}]}`,
+  } {
+    ioutil.WriteFile(filepath.Join(dir, MANIFEST_NAME), []byte(manifest), 0644)
+    if _, e := Pack(dir); e == nil {
+      t.Errorf("Expected an error for %s", manifest)
+    }
+  }
+}
@@ -0,0 +1,277 @@
+package godbpf
+
+import (
+  "context"
+  "fmt"
+  "io"
+  "runtime"
+  "sync"
+
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/util"
+)
+
+// The functions of this file spread compression and decompression over
+// several goroutines.  Each of them takes a number of workers, which defaults
+// to the number of CPUs when it isn't positive.  Results are gathered by
+// position rather than in the order work completes, so that the output, and
+// the error returned when several items fail, doesn't depend on scheduling.
+
+// parallel calls work for each index from 0 to count - 1 using up to n
+// goroutines.  No more work is started once an index fails, or once the
+// context is done.  It returns the error of the lowest index that failed, which
+// doesn't depend on scheduling since the indexes are started in order, or the
+// error of the context if it's done before all the work is started.
+func parallel(ctx context.Context, n, count int, work func(i int) error) error {
+  if n <= 0 {
+    n = runtime.GOMAXPROCS(0)
+  }
+  if n > count {
+    n = count
+  }
+
+  // Failing work stops more from being started as if the context was done.
+  dispatch, stop := context.WithCancel(ctx)
+  defer stop()
+
+  errs := make([]error, count)
+  indexes := make(chan int)
+  var wg sync.WaitGroup
+  for w := 0; w < n; w++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := range indexes {
+        if errs[i] = work(i); errs[i] != nil {
+          stop()
+        }
+      }
+    }()
+  }
+
+  var cancelled error
+  for i := 0; i < count && cancelled == nil; i++ {
+    // Check the context first, since select picks at random among the cases
+    // that are ready.
+    if cancelled = dispatch.Err(); cancelled != nil {
+      break
+    }
+
+    select {
+    case indexes <- i:
+    case <-dispatch.Done():
+      cancelled = dispatch.Err()
+    }
+  }
+  close(indexes)
+  wg.Wait()
+
+  if cancelled != nil && ctx.Err() != nil {
+    return ctx.Err()
+  }
+
+  for _, e := range errs {
+    if e != nil {
+      return e
+    }
+  }
+
+  return nil
+}
+
+// CompressAll compresses each of the provided buffers as Compress does, using
+// up to n goroutines, and returns the results in the same order.
+func CompressAll(ctx context.Context, n int, data [][]byte) ([][]byte, error) {
+  compressed := make([][]byte, len(data))
+  e := parallel(ctx, n, len(data), func(i int) error {
+    compressed[i] = Compress(data[i])
+    return nil
+  })
+  if e != nil {
+    return nil, e
+  }
+
+  return compressed, nil
+}
+
+// AddCompressedEntries adds the provided entries, which hold uncompressed
+// data, to the receiver as AddCompressedEntry would, compressing them with up
+// to n goroutines.  The provided entries are left unchanged.  Nothing is
+// added if the context is done before all the entries are compressed.
+func (dbpf *DBPF) AddCompressedEntries(ctx context.Context, n int, entries []*entry.DBPFEntry) error {
+  if len(entries) == 0 {
+    return nil
+  }
+
+  data := make([][]byte, len(entries))
+  for i, e := range entries {
+    data[i] = e.GetData()
+  }
+
+  compressed, e := CompressAll(ctx, n, data)
+  if e != nil {
+    return e
+  }
+
+  dbpf.mutex.Lock()
+  defer dbpf.mutex.Unlock()
+
+  records := make([]byte, 0, 16 * len(entries))
+  for i, e := range entries {
+    en := entry.NewEntry(e.TGI)
+    en.SetData(compressed[i])
+    dbpf.entries.PushBack(en)
+    records = appendDirRecord(records, e.TGI, len(data[i]))
+  }
+
+  appendDirRecords(dbpf.getDirEntry(), records)
+  return nil
+}
+
+// CompressEntries compresses the data of the uncompressed entries of the
+// receiver whose TGI matches the provided function, using up to n goroutines.
+// The entries keep their position, and their records are added to the DIR
+// entry in that order.  The receiver is left unchanged if the context is done
+// before all the entries are compressed.  The write lock is held throughout,
+// so other goroutines only see the receiver before or after the change.
+func (dbpf *DBPF) CompressEntries(ctx context.Context, n int, match func(tgi *entry.DBPFEntryTGI) bool) error {
+  dbpf.mutex.Lock()
+  defer dbpf.mutex.Unlock()
+
+  var sizes map[entry.DBPFEntryTGI]uint32
+  if dirEntry := dbpf.find(entry.DIR_ENTRY_TGI); dirEntry != nil {
+    sizes = dirEntry.UncompressedSizes()
+  }
+
+  var selected []*entry.DBPFEntry
+  var data [][]byte
+  for elem := dbpf.entries.Front(); elem != nil; elem = elem.Next() {
+    e, ok := elem.Value.(*entry.DBPFEntry)
+    if !ok {
+      continue
+    }
+
+    if _, compressed := sizes[*e.TGI]; compressed || e.TGI.Equals(entry.DIR_ENTRY_TGI) || !match(e.TGI) {
+      continue
+    }
+
+    selected = append(selected, e)
+    data = append(data, e.GetData())
+  }
+
+  if len(selected) == 0 {
+    return nil
+  }
+
+  compressed, e := CompressAll(ctx, n, data)
+  if e != nil {
+    return e
+  }
+
+  records := make([]byte, 0, 16 * len(selected))
+  for i, e := range selected {
+    e.SetData(compressed[i])
+    records = appendDirRecord(records, e.TGI, len(data[i]))
+  }
+
+  appendDirRecords(dbpf.getDirEntry(), records)
+  return nil
+}
+
+// appendDirRecord appends a DIR record for the entry with the provided TGI
+// and uncompressed size to the provided records.
+func appendDirRecord(records []byte, tgi *entry.DBPFEntryTGI, size int) []byte {
+  record := make([]byte, 16)
+  tgi.Bytes(record)
+  copy(record[12:16], util.WriteUint32(uint32(size)))
+
+  return append(records, record...)
+}
+
+// appendDirRecords adds the provided records to the DIR entry at once, rather
+// than copying its data for each of them as DBPFEntry.AddEntry does.
+func appendDirRecords(dirEntry *entry.DBPFEntry, records []byte) {
+  data := make([]byte, 0, dirEntry.Size() + uint32(len(records)))
+  data = append(data, dirEntry.GetData()...)
+  dirEntry.SetData(append(data, records...))
+}
+
+// SaveCompressed writes the receiver to the provided Writer as Save does, with
+// the uncompressed entries whose TGI matches the provided function compressed
+// using up to n goroutines.  Unlike CompressEntries, the receiver is left
+// unchanged.
+func (dbpf *DBPF) SaveCompressed(ctx context.Context, w io.Writer, n int, match func(tgi *entry.DBPFEntryTGI) bool) error {
+  clone := dbpf.Snapshot()
+  if e := clone.CompressEntries(ctx, n, match); e != nil {
+    return e
+  }
+
+  return clone.Save(w)
+}
+
+// DecompressAll returns a copy of each entry of the receiver except the DIR
+// entry, in the order they are stored, holding its uncompressed data.  The
+// compressed entries are decompressed using up to n goroutines.  When several
+// entries can't be decompressed, the error of the first one is returned.
+func (dbpf *DBPF) DecompressAll(ctx context.Context, n int) ([]*entry.DBPFEntry, error) {
+  var result []*entry.DBPFEntry
+  e := dbpf.decompressEach(ctx, n, func(count int) {
+    result = make([]*entry.DBPFEntry, count)
+  }, func(i int, e *entry.DBPFEntry, compressed bool) error {
+    result[i] = e
+    return nil
+  })
+  if e != nil {
+    return nil, e
+  }
+
+  return result, nil
+}
+
+// DecompressEach calls f with a copy of each entry of the receiver except the
+// DIR entry, holding its uncompressed data, along with whether the entry is
+// stored compressed.  The entries are decompressed and handed to f by up to n
+// goroutines, as soon as each of them is ready, so f may be called
+// concurrently and in any order.  Only the entries being handled are held in
+// memory at once.  No more entries are handled once f fails, and the error of
+// the first entry that failed, in the order they are stored, is returned.
+func (dbpf *DBPF) DecompressEach(ctx context.Context, n int, f func(e *entry.DBPFEntry, compressed bool) error) error {
+  return dbpf.decompressEach(ctx, n, func(int) {}, func(i int, e *entry.DBPFEntry, compressed bool) error {
+    return f(e, compressed)
+  })
+}
+
+// decompressEach implements DecompressAll and DecompressEach.  It calls start
+// with the number of entries before calling f with each of them and its
+// position.
+func (dbpf *DBPF) decompressEach(ctx context.Context, n int, start func(count int), f func(i int, e *entry.DBPFEntry, compressed bool) error) error {
+  snapshot := dbpf.Snapshot()
+
+  var sizes map[entry.DBPFEntryTGI]uint32
+  if dirEntry := snapshot.Find(entry.DIR_ENTRY_TGI); dirEntry != nil {
+    sizes = dirEntry.UncompressedSizes()
+  }
+
+  var sources []*entry.DBPFEntry
+  for _, e := range snapshot.Entries() {
+    if !e.TGI.Equals(entry.DIR_ENTRY_TGI) {
+      sources = append(sources, e)
+    }
+  }
+
+  start(len(sources))
+  return parallel(ctx, n, len(sources), func(i int) error {
+    data := sources[i].GetData()
+    _, compressed := sizes[*sources[i].TGI]
+    if compressed {
+      var e error
+      if data, e = Decompress(data); e != nil {
+        return fmt.Errorf("Compressed entry {%s}: %v", sources[i].TGI, e)
+      }
+    }
+
+    e := entry.NewEntry(sources[i].TGI)
+    e.SetData(data)
+    return f(i, e, compressed)
+  })
+}
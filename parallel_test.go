@@ -0,0 +1,191 @@
+package godbpf
+
+import (
+  "bytes"
+  "context"
+  "errors"
+  "fmt"
+  "strings"
+  "sync"
+  "testing"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func uncompressedEntries(count int) []*entry.DBPFEntry {
+  entries := make([]*entry.DBPFEntry, count)
+  for i := range entries {
+    entries[i] = entry.NewEntry(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: uint32(i)})
+    entries[i].SetData([]byte(strings.Repeat(fmt.Sprintf("entry %d ", i), 20 + i)))
+  }
+
+  return entries
+}
+
+func TestCompressAll(t *testing.T) {
+  data := [][]byte{[]byte("first first first first"), []byte(""), []byte("third")}
+  compressed, e := CompressAll(context.Background(), 2, data)
+  if e != nil || len(compressed) != 3 {
+    t.Fatalf("Unexpected result %v, %v", compressed, e)
+  }
+
+  for i := range data {
+    if !bytes.Equal(compressed[i], Compress(data[i])) {
+      t.Errorf("Unexpected compressed data %v for %q", compressed[i], data[i])
+    }
+  }
+}
+
+func TestAddCompressedEntries(t *testing.T) {
+  var saved [][]byte
+  for _, n := range []int{1, 3, 0} {
+    dbpf := New()
+    if e := dbpf.AddCompressedEntries(context.Background(), n, uncompressedEntries(20)); e != nil {
+      t.Fatal(e)
+    }
+
+    for i, e := range uncompressedEntries(20) {
+      if data, err := dbpf.GetUncompressedData(e.TGI); err != nil || !bytes.Equal(data, e.GetData()) {
+        t.Errorf("Unexpected data for entry %d: %q, %v", i, data, err)
+      }
+    }
+
+    buf := new(bytes.Buffer)
+    dbpf.Save(buf)
+    saved = append(saved, buf.Bytes())
+  }
+
+  if !bytes.Equal(saved[0], saved[1]) || !bytes.Equal(saved[0], saved[2]) {
+    t.Error("Expected the same bytes regardless of the number of goroutines")
+  }
+}
+
+func TestCompressEntries(t *testing.T) {
+  dbpf := New()
+  for _, e := range uncompressedEntries(10) {
+    dbpf.AddEntry(e)
+  }
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: 0x3, GroupId: 0x2, InstanceId: 0x1}, []byte("already compressed"))
+
+  odd := func(tgi *entry.DBPFEntryTGI) bool {
+    return tgi.InstanceId % 2 == 1
+  }
+
+  expected := new(bytes.Buffer)
+  dbpf.SaveCompressed(context.Background(), expected, 4, odd)
+
+  before := new(bytes.Buffer)
+  dbpf.Save(before)
+  if bytes.Equal(before.Bytes(), expected.Bytes()) {
+    t.Fatal("Expected SaveCompressed to compress entries")
+  }
+
+  if e := dbpf.CompressEntries(context.Background(), 4, odd); e != nil {
+    t.Fatal(e)
+  }
+
+  after := new(bytes.Buffer)
+  dbpf.Save(after)
+  if !bytes.Equal(after.Bytes(), expected.Bytes()) {
+    t.Error("Expected SaveCompressed to give the same bytes as CompressEntries followed by Save")
+  }
+
+  for i, e := range uncompressedEntries(10) {
+    if dbpf.IsCompressed(e.TGI) != (i % 2 == 1) {
+      t.Errorf("Unexpected compression of entry %d", i)
+    }
+    if data, err := dbpf.GetUncompressedData(e.TGI); err != nil || !bytes.Equal(data, e.GetData()) {
+      t.Errorf("Unexpected data for entry %d: %q, %v", i, data, err)
+    }
+  }
+
+  if sizes := dbpf.GetDirEntry().UncompressedSizes(); len(sizes) != 6 {
+    t.Errorf("Expected 6 DIR records, got %v", sizes)
+  }
+}
+
+func TestDecompressAll(t *testing.T) {
+  dbpf := New()
+  entries := uncompressedEntries(12)
+  dbpf.AddEntry(entries[0])
+  dbpf.AddCompressedEntries(context.Background(), 0, entries[1:])
+
+  decompressed, e := dbpf.DecompressAll(context.Background(), 3)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(decompressed) != len(entries) {
+    t.Fatalf("Expected %d entries, got %d", len(entries), len(decompressed))
+  }
+  for i := range entries {
+    if !decompressed[i].TGI.Equals(entries[i].TGI) || !bytes.Equal(decompressed[i].GetData(), entries[i].GetData()) {
+      t.Errorf("Unexpected entry %d: %v", i, decompressed[i])
+    }
+  }
+
+  // Corrupt two entries: the error of the first one is returned.
+  for _, i := range []uint32{7, 4} {
+    dbpf.Find(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: i}).SetData([]byte("bad"))
+  }
+  for n := 1; n <= 8; n++ {
+    if _, e := dbpf.DecompressAll(context.Background(), n); e == nil || !strings.Contains(e.Error(), "0x00000004") {
+      t.Errorf("Expected the error of entry 4, got %v", e)
+    }
+  }
+}
+
+func TestDecompressEach(t *testing.T) {
+  dbpf := New()
+  entries := uncompressedEntries(12)
+  dbpf.AddEntry(entries[0])
+  dbpf.AddCompressedEntries(context.Background(), 0, entries[1:])
+
+  var mutex sync.Mutex
+  handled := make(map[uint32]bool)
+  e := dbpf.DecompressEach(context.Background(), 3, func(e *entry.DBPFEntry, compressed bool) error {
+    mutex.Lock()
+    defer mutex.Unlock()
+
+    i := e.TGI.InstanceId
+    if handled[i] || compressed != (i != 0) || !bytes.Equal(e.GetData(), entries[i].GetData()) {
+      t.Errorf("Unexpected entry %d, compressed: %t", i, compressed)
+    }
+    handled[i] = true
+    return nil
+  })
+  if e != nil || len(handled) != len(entries) {
+    t.Fatalf("Expected %d entries, got %d, %v", len(entries), len(handled), e)
+  }
+
+  // Once an entry fails, no more entries are handled.
+  calls := 0
+  failure := errors.New("Failure")
+  e = dbpf.DecompressEach(context.Background(), 1, func(e *entry.DBPFEntry, compressed bool) error {
+    calls++
+    if e.TGI.InstanceId == 2 {
+      return failure
+    }
+    return nil
+  })
+  if e != failure || calls != 3 {
+    t.Errorf("Expected the failure after 3 calls, got %v after %d", e, calls)
+  }
+}
+
+func TestParallelCancelled(t *testing.T) {
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+
+  dbpf := New()
+  if e := dbpf.AddCompressedEntries(ctx, 2, uncompressedEntries(5)); e != context.Canceled {
+    t.Errorf("Expected context.Canceled, got %v", e)
+  }
+  if dbpf.Len() != 0 {
+    t.Errorf("Expected no entries to be added, got %d", dbpf.Len())
+  }
+
+  if _, e := dbpf.DecompressAll(ctx, 2); e != nil {
+    t.Errorf("Expected no error without entries, got %v", e)
+  }
+}
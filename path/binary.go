@@ -0,0 +1,127 @@
+package path
+
+import (
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "io"
+)
+
+// The binary form stores the same fields as the text form, in the same order:
+//
+//  uint32 major version, uint32 minor version
+//  uint32 transit path count
+//  uint32 stop path count (from version 1.1)
+//  transit paths: uint8 transport, class, entry, exit, junction (from version
+//                 1.2) and point count, then 3 float32 values per point
+//  stop paths: uint8 UK flag, transport, entry and exit, then 3 float32 values
+//
+// All values are little endian.  Comments aren't stored.
+
+// decodeBinary reads the binary form of an SC4Path file.
+func decodeBinary(r io.Reader) (*File, error) {
+  f := &File{Binary: true}
+
+  if e := binary.Read(r, binary.LittleEndian, &f.MajorVersion); e != nil {
+    return nil, e
+  }
+  if e := binary.Read(r, binary.LittleEndian, &f.MinorVersion); e != nil {
+    return nil, e
+  }
+  if f.MajorVersion != 1 {
+    return nil, fmt.Errorf("Unsupported SC4Path version %d.%d", f.MajorVersion, f.MinorVersion)
+  }
+
+  var transitCount, stopCount uint32
+  if e := binary.Read(r, binary.LittleEndian, &transitCount); e != nil {
+    return nil, e
+  }
+  if f.hasStopPaths() {
+    if e := binary.Read(r, binary.LittleEndian, &stopCount); e != nil {
+      return nil, e
+    }
+  }
+
+  for i := uint32(0); i < transitCount; i++ {
+    var header [5]uint8
+    fields := header[:5]
+    if !f.hasJunctions() {
+      fields = header[:4]
+    }
+
+    if _, e := io.ReadFull(r, fields); e != nil {
+      return nil, e
+    }
+
+    var count uint8
+    if e := binary.Read(r, binary.LittleEndian, &count); e != nil {
+      return nil, e
+    }
+
+    p := &TransitPath{
+      Transport: TransportType(header[0]),
+      Class: header[1],
+      Entry: Side(header[2]),
+      Exit: Side(header[3]),
+      Junction: header[4] != 0,
+      Points: make([]Point, count),
+    }
+
+    if e := binary.Read(r, binary.LittleEndian, p.Points); e != nil {
+      return nil, e
+    }
+
+    f.TransitPaths = append(f.TransitPaths, p)
+  }
+
+  for i := uint32(0); i < stopCount; i++ {
+    var header [4]uint8
+    if _, e := io.ReadFull(r, header[:]); e != nil {
+      return nil, e
+    }
+
+    p := &StopPath{UK: header[0] != 0, Transport: TransportType(header[1]), Entry: Side(header[2]), Exit: Side(header[3])}
+    if e := binary.Read(r, binary.LittleEndian, &p.Point); e != nil {
+      return nil, e
+    }
+
+    f.StopPaths = append(f.StopPaths, p)
+  }
+
+  return f, nil
+}
+
+// encodeBinary writes the binary form of the receiver to the provided Buffer.
+func (f *File) encodeBinary(buf *bytes.Buffer) error {
+  if !f.hasStopPaths() && len(f.StopPaths) > 0 {
+    return fmt.Errorf("Version %d.%d files can't hold stop paths", f.MajorVersion, f.MinorVersion)
+  }
+
+  binary.Write(buf, binary.LittleEndian, f.MajorVersion)
+  binary.Write(buf, binary.LittleEndian, f.MinorVersion)
+  binary.Write(buf, binary.LittleEndian, uint32(len(f.TransitPaths)))
+  if f.hasStopPaths() {
+    binary.Write(buf, binary.LittleEndian, uint32(len(f.StopPaths)))
+  }
+
+  for _, p := range f.TransitPaths {
+    if len(p.Points) > 0xFF {
+      return fmt.Errorf("Transit path %q has %d points, the most allowed is 255", p.Comment, len(p.Points))
+    }
+
+    buf.Write([]byte{ byte(p.Transport), p.Class, byte(p.Entry), byte(p.Exit) })
+    if f.hasJunctions() {
+      buf.WriteByte(byte(boolToInt(p.Junction)))
+    }
+
+    buf.WriteByte(byte(len(p.Points)))
+    binary.Write(buf, binary.LittleEndian, p.Points)
+  }
+
+  for _, p := range f.StopPaths {
+    buf.Write([]byte{ byte(boolToInt(p.UK)), byte(p.Transport), byte(p.Entry), byte(p.Exit) })
+    binary.Write(buf, binary.LittleEndian, p.Point)
+  }
+
+  return nil
+}
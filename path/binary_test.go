@@ -0,0 +1,71 @@
+package path
+
+import (
+  "bytes"
+  "testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+  f := &File{
+    Binary: true,
+    MajorVersion: 1,
+    MinorVersion: 2,
+    TransitPaths: []*TransitPath{
+      &TransitPath{Transport: Train, Class: 2, Entry: South, Exit: North, Junction: true, Points: []Point{{X: 1, Y: -8}, {X: 1, Y: 8, Z: 0.5}}},
+    },
+    StopPaths: []*StopPath{&StopPath{UK: true, Transport: Car, Entry: West, Exit: East, Point: Point{X: 2, Y: 3}}},
+  }
+
+  buf := new(bytes.Buffer)
+  if e := f.Encode(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  // Header, one transit path with 2 points and one stop path.
+  if buf.Len() != 16 + 6 + 24 + 4 + 12 {
+    t.Errorf("Unexpected size %d", buf.Len())
+  }
+
+  decoded, e := Decode(bytes.NewReader(buf.Bytes()))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if !decoded.Binary || decoded.MinorVersion != 2 || len(decoded.TransitPaths) != 1 || len(decoded.StopPaths) != 1 {
+    t.Fatalf("Unexpected file %+v", decoded)
+  }
+
+  p := decoded.TransitPaths[0]
+  if p.Transport != Train || p.Class != 2 || p.Entry != South || !p.Junction || len(p.Points) != 2 || p.Points[1].Z != 0.5 {
+    t.Errorf("Unexpected path %+v", p)
+  }
+
+  if s := decoded.StopPaths[0]; !s.UK || s.Exit != East || s.Point.Y != 3 {
+    t.Errorf("Unexpected stop path %+v", s)
+  }
+}
+
+func TestDecodeBinaryVersion10(t *testing.T) {
+  data := []byte{ 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1, 1, 0, 2, 0 }
+
+  f, e := Decode(bytes.NewReader(data))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(f.TransitPaths) != 1 || f.TransitPaths[0].Exit != East || len(f.TransitPaths[0].Points) != 0 {
+    t.Errorf("Unexpected file %+v", f)
+  }
+}
+
+func TestDecodeTruncatedBinary(t *testing.T) {
+  data := []byte{ 1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 2, 1 }
+
+  if _, e := Decode(bytes.NewReader(data)); e == nil {
+    t.Error()
+  }
+
+  if _, e := Decode(bytes.NewReader([]byte{ 2, 0, 0, 0, 0, 0, 0, 0 })); e == nil {
+    t.Error()
+  }
+}
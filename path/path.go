@@ -0,0 +1,190 @@
+package path
+
+import (
+  "bufio"
+  "bytes"
+  "fmt"
+  "io"
+)
+
+// PATH_TYPE_ID is the TypeId used by SC4Path entries.
+const PATH_TYPE_ID uint32 = 0x296678F7
+
+// TransportType identifies the kind of traffic that follows a path.
+type TransportType uint8
+
+const (
+  Car TransportType = 1
+  Sim TransportType = 2
+  Train TransportType = 3
+  Subway TransportType = 4
+  ElTrain TransportType = 6
+  Monorail TransportType = 7
+)
+
+// String returns the name of the receiver TransportType.
+func (t TransportType) String() string {
+  switch t {
+  case Car:
+    return "Car"
+  case Sim:
+    return "Sim"
+  case Train:
+    return "Train"
+  case Subway:
+    return "Subway"
+  case ElTrain:
+    return "ElTrain"
+  case Monorail:
+    return "Monorail"
+  }
+
+  return fmt.Sprintf("Transport%d", uint8(t))
+}
+
+// Side identifies the edge of the tile where a path enters or exits.
+type Side uint8
+
+const (
+  West Side = 0
+  North Side = 1
+  East Side = 2
+  South Side = 3
+
+  // NoSide is used by paths that start or end inside the tile.
+  NoSide Side = 255
+)
+
+// String returns the name of the receiver Side.
+func (s Side) String() string {
+  switch s {
+  case West:
+    return "West"
+  case North:
+    return "North"
+  case East:
+    return "East"
+  case South:
+    return "South"
+  case NoSide:
+    return "None"
+  }
+
+  return fmt.Sprintf("Side%d", uint8(s))
+}
+
+// Point is a coordinate within a tile.  X and Y range from -8 to 8 across the
+// tile and Z is the height.
+type Point struct {
+  X, Y, Z float32
+}
+
+// TransitPath is a path followed by traffic across a tile.
+type TransitPath struct {
+  // Comment is the text of the comment line preceding the path in the text
+  // form, without the leading "--".
+  Comment string
+
+  // Transport is the kind of traffic that follows the path.
+  Transport TransportType
+
+  // Class distinguishes paths with the same transport type, entry and exit.
+  Class uint8
+
+  // Entry and Exit are the tile edges where the path begins and ends.
+  Entry, Exit Side
+
+  // Junction marks paths that are part of a junction.  It is only stored by
+  // version 1.2 files.
+  Junction bool
+
+  // Points lists the coordinates of the path, from entry to exit.
+  Points []Point
+}
+
+// StopPath marks where traffic following a path can stop.
+type StopPath struct {
+  // Comment is the text of the comment line preceding the stop path in the
+  // text form, without the leading "--".
+  Comment string
+
+  // UK indicates the stop path is used for left hand driving.
+  UK bool
+
+  // Transport is the kind of traffic that stops there.
+  Transport TransportType
+
+  // Entry and Exit identify the path the stop applies to.
+  Entry, Exit Side
+
+  // Point is where the traffic stops.
+  Point Point
+}
+
+// File holds the contents of an SC4Path entry.
+type File struct {
+  // Binary indicates the file was decoded from, and will be encoded to, the
+  // binary form rather than the text form.
+  Binary bool
+
+  // MajorVersion and MinorVersion give the version of the file.  Stop paths
+  // are only stored from version 1.1 and junction flags from version 1.2.
+  MajorVersion, MinorVersion uint32
+
+  // TransitPaths lists the paths across the tile.
+  TransitPaths []*TransitPath
+
+  // StopPaths lists the stop paths of the tile.
+  StopPaths []*StopPath
+}
+
+// New creates an empty text SC4Path file using version 1.1.
+func New() *File {
+  return &File{MajorVersion: 1, MinorVersion: 1}
+}
+
+// hasStopPaths indicates whether the version of the receiver stores stop
+// paths.
+func (f *File) hasStopPaths() bool {
+  return f.MajorVersion > 1 || f.MinorVersion >= 1
+}
+
+// hasJunctions indicates whether the version of the receiver stores the
+// junction flag of transit paths.
+func (f *File) hasJunctions() bool {
+  return f.MajorVersion > 1 || f.MinorVersion >= 2
+}
+
+// TEXT_SIGNATURE is the first line of text SC4Path files.
+const TEXT_SIGNATURE = "SC4PATHS"
+
+// Decode reads an SC4Path file from the provided Reader.  Text files are
+// recognized by their signature; anything else is decoded as a binary file.
+func Decode(r io.Reader) (*File, error) {
+  br := bufio.NewReader(r)
+  if signature, e := br.Peek(len(TEXT_SIGNATURE)); e == nil && string(signature) == TEXT_SIGNATURE {
+    return decodeText(br)
+  }
+
+  return decodeBinary(br)
+}
+
+// Encode writes the receiver to the provided Writer, in binary form if its
+// Binary field is set and in text form otherwise.
+func (f *File) Encode(w io.Writer) error {
+  buf := new(bytes.Buffer)
+
+  var e error
+  if f.Binary {
+    e = f.encodeBinary(buf)
+  } else {
+    e = f.encodeText(buf)
+  }
+
+  if e != nil {
+    return e
+  }
+
+  _, e = buf.WriteTo(w)
+  return e
+}
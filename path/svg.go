@@ -0,0 +1,83 @@
+package path
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+)
+
+// The colours used to draw the paths of each transport type.
+var transportColors = map[TransportType]string{
+  Car: "#D02020",
+  Sim: "#20A020",
+  Train: "#6040A0",
+  Subway: "#2060D0",
+  ElTrain: "#D08020",
+  Monorail: "#20A0A0",
+}
+
+// colorOf returns the colour used to draw paths of the provided transport type.
+func colorOf(t TransportType) string {
+  if c, found := transportColors[t]; found {
+    return c
+  }
+
+  return "#000000"
+}
+
+// RenderSVG draws the paths of the receiver as an SVG image of size by size
+// pixels showing the whole tile, with north at the top.  Each transit path is
+// drawn as a line ending in an arrow, and each stop path as a circle.
+func (f *File) RenderSVG(w io.Writer, size int) error {
+  buf := new(bytes.Buffer)
+  scale := float64(size) / 16
+
+  // Tile coordinates range from -8 to 8, with Y increasing towards the north.
+  toSVG := func(p Point) (float64, float64) {
+    return (float64(p.X) + 8) * scale, (8 - float64(p.Y)) * scale
+  }
+
+  fmt.Fprintf(buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", size, size, size, size)
+  buf.WriteString("  <defs>\n")
+  for _, t := range []TransportType{Car, Sim, Train, Subway, ElTrain, Monorail} {
+    fmt.Fprintf(buf, "    <marker id=\"arrow-%s\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\"><path d=\"M 0 0 L 10 5 L 0 10 z\" fill=\"%s\"/></marker>\n", t, colorOf(t))
+  }
+  buf.WriteString("  </defs>\n")
+  fmt.Fprintf(buf, "  <rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"#FFFFFF\" stroke=\"#808080\"/>\n", size, size)
+
+  for _, p := range f.TransitPaths {
+    if len(p.Points) == 0 {
+      continue
+    }
+
+    buf.WriteString("  <polyline points=\"")
+    for i, point := range p.Points {
+      x, y := toSVG(point)
+      if i > 0 {
+        buf.WriteString(" ")
+      }
+      fmt.Fprintf(buf, "%.2f,%.2f", x, y)
+    }
+
+    fmt.Fprintf(buf, "\" fill=\"none\" stroke=\"%s\" stroke-width=\"2\"", colorOf(p.Transport))
+    if _, found := transportColors[p.Transport]; found {
+      fmt.Fprintf(buf, " marker-end=\"url(#arrow-%s)\"", p.Transport)
+    }
+    fmt.Fprintf(buf, "><title>%s %d: %s to %s", p.Transport, p.Class, p.Entry, p.Exit)
+    if p.Comment != "" {
+      fmt.Fprintf(buf, " (%s)", p.Comment)
+    }
+    buf.WriteString("</title></polyline>\n")
+  }
+
+  for _, p := range f.StopPaths {
+    x, y := toSVG(p.Point)
+    fmt.Fprintf(buf, "  <circle cx=\"%.2f\" cy=\"%.2f\" r=\"%.2f\" fill=\"%s\"><title>Stop %s: %s to %s</title></circle>\n",
+      x, y, scale / 2, colorOf(p.Transport), p.Transport, p.Entry, p.Exit)
+  }
+
+  buf.WriteString("</svg>\n")
+
+  _, e := buf.WriteTo(w)
+  return e
+}
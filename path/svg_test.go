@@ -0,0 +1,32 @@
+package path
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+func TestRenderSVG(t *testing.T) {
+  f, e := Decode(strings.NewReader(sampleText))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  buf := new(bytes.Buffer)
+  if e := f.RenderSVG(buf, 160); e != nil {
+    t.Fatal(e)
+  }
+
+  svg := buf.String()
+  if strings.Count(svg, "<polyline ") != 2 || strings.Count(svg, "<circle ") != 1 {
+    t.Errorf("Unexpected SVG %s", svg)
+  }
+
+  if !strings.Contains(svg, "points=\"0.00,110.00 160.00,110.00\"") {
+    t.Errorf("Car path wasn't mapped to tile coordinates: %s", svg)
+  }
+
+  if !strings.Contains(svg, "Car 1: West to East (Car_1_0_2)") {
+    t.Error()
+  }
+}
@@ -0,0 +1,275 @@
+package path
+
+import (
+  "bufio"
+  "bytes"
+  "errors"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+)
+
+// textScanner returns the meaningful lines of a text SC4Path file one at a
+// time, keeping track of the last comment line seen.
+type textScanner struct {
+  scanner *bufio.Scanner
+  line int
+  comment string
+}
+
+// next returns the next line that isn't blank or a comment.
+func (s *textScanner) next() (string, error) {
+  for s.scanner.Scan() {
+    s.line++
+    line := strings.TrimSpace(s.scanner.Text())
+
+    if line == "" {
+      continue
+    }
+
+    if strings.HasPrefix(line, "--") {
+      s.comment = strings.TrimSpace(strings.TrimPrefix(line, "--"))
+      continue
+    }
+
+    return line, nil
+  }
+
+  if e := s.scanner.Err(); e != nil {
+    return "", e
+  }
+
+  return "", io.ErrUnexpectedEOF
+}
+
+// takeComment returns the last comment seen and forgets it.
+func (s *textScanner) takeComment() string {
+  comment := s.comment
+  s.comment = ""
+  return comment
+}
+
+// nextInt reads the next line as an unsigned integer no greater than max.
+func (s *textScanner) nextInt(max uint64) (uint64, error) {
+  line, e := s.next()
+  if e != nil {
+    return 0, e
+  }
+
+  v, e := strconv.ParseUint(line, 10, 32)
+  if e != nil || v > max {
+    return 0, fmt.Errorf("Line %d: invalid value %q", s.line, line)
+  }
+
+  return v, nil
+}
+
+// nextPoint reads the next line as a comma separated coordinate.
+func (s *textScanner) nextPoint() (Point, error) {
+  line, e := s.next()
+  if e != nil {
+    return Point{}, e
+  }
+
+  fields := strings.Split(line, ",")
+  if len(fields) != 3 {
+    return Point{}, fmt.Errorf("Line %d: invalid coordinate %q", s.line, line)
+  }
+
+  var values [3]float32
+  for i, field := range fields {
+    v, e := strconv.ParseFloat(strings.TrimSpace(field), 32)
+    if e != nil {
+      return Point{}, fmt.Errorf("Line %d: invalid coordinate %q", s.line, line)
+    }
+
+    values[i] = float32(v)
+  }
+
+  return Point{X: values[0], Y: values[1], Z: values[2]}, nil
+}
+
+// decodeText reads the text form of an SC4Path file.
+func decodeText(r io.Reader) (*File, error) {
+  s := &textScanner{scanner: bufio.NewScanner(r)}
+
+  if line, e := s.next(); e != nil || line != TEXT_SIGNATURE {
+    return nil, errors.New("Invalid SC4Path signature")
+  }
+
+  f := &File{}
+  version, e := s.next()
+  if e != nil {
+    return nil, e
+  }
+  if _, e := fmt.Sscanf(version, "%d.%d", &f.MajorVersion, &f.MinorVersion); e != nil {
+    return nil, fmt.Errorf("Line %d: invalid version %q", s.line, version)
+  }
+
+  transitCount, e := s.nextInt(0xFFFFFFFF)
+  if e != nil {
+    return nil, e
+  }
+
+  stopCount := uint64(0)
+  if f.hasStopPaths() {
+    if stopCount, e = s.nextInt(0xFFFFFFFF); e != nil {
+      return nil, e
+    }
+  }
+
+  for i := uint64(0); i < transitCount; i++ {
+    p, e := f.decodeTextTransitPath(s)
+    if e != nil {
+      return nil, e
+    }
+
+    f.TransitPaths = append(f.TransitPaths, p)
+  }
+
+  for i := uint64(0); i < stopCount; i++ {
+    p, e := decodeTextStopPath(s)
+    if e != nil {
+      return nil, e
+    }
+
+    f.StopPaths = append(f.StopPaths, p)
+  }
+
+  return f, nil
+}
+
+// decodeTextTransitPath reads a single transit path.
+func (f *File) decodeTextTransitPath(s *textScanner) (*TransitPath, error) {
+  var values [4]uint64
+  for i := range values {
+    v, e := s.nextInt(0xFF)
+    if e != nil {
+      return nil, e
+    }
+
+    values[i] = v
+  }
+
+  p := &TransitPath{
+    Comment: s.takeComment(),
+    Transport: TransportType(values[0]),
+    Class: uint8(values[1]),
+    Entry: Side(values[2]),
+    Exit: Side(values[3]),
+  }
+
+  if f.hasJunctions() {
+    junction, e := s.nextInt(1)
+    if e != nil {
+      return nil, e
+    }
+
+    p.Junction = junction == 1
+  }
+
+  count, e := s.nextInt(0xFF)
+  if e != nil {
+    return nil, e
+  }
+
+  for i := uint64(0); i < count; i++ {
+    point, e := s.nextPoint()
+    if e != nil {
+      return nil, e
+    }
+
+    p.Points = append(p.Points, point)
+  }
+
+  return p, nil
+}
+
+// decodeTextStopPath reads a single stop path.
+func decodeTextStopPath(s *textScanner) (*StopPath, error) {
+  var values [4]uint64
+  for i := range values {
+    v, e := s.nextInt(0xFF)
+    if e != nil {
+      return nil, e
+    }
+
+    values[i] = v
+  }
+
+  point, e := s.nextPoint()
+  if e != nil {
+    return nil, e
+  }
+
+  return &StopPath{
+    Comment: s.takeComment(),
+    UK: values[0] != 0,
+    Transport: TransportType(values[1]),
+    Entry: Side(values[2]),
+    Exit: Side(values[3]),
+    Point: point,
+  }, nil
+}
+
+// encodeText writes the text form of the receiver to the provided Buffer.
+func (f *File) encodeText(buf *bytes.Buffer) error {
+  if !f.hasStopPaths() && len(f.StopPaths) > 0 {
+    return fmt.Errorf("Version %d.%d files can't hold stop paths", f.MajorVersion, f.MinorVersion)
+  }
+
+  fmt.Fprintf(buf, "%s\n%d.%d\n%d\n", TEXT_SIGNATURE, f.MajorVersion, f.MinorVersion, len(f.TransitPaths))
+  if f.hasStopPaths() {
+    fmt.Fprintf(buf, "%d\n", len(f.StopPaths))
+  }
+
+  for _, p := range f.TransitPaths {
+    writeComment(buf, p.Comment)
+    fmt.Fprintf(buf, "%d\n%d\n%d\n%d\n", p.Transport, p.Class, p.Entry, p.Exit)
+    if f.hasJunctions() {
+      fmt.Fprintf(buf, "%d\n", boolToInt(p.Junction))
+    }
+
+    fmt.Fprintf(buf, "%d\n", len(p.Points))
+    for _, point := range p.Points {
+      writePoint(buf, point)
+    }
+  }
+
+  for _, p := range f.StopPaths {
+    writeComment(buf, p.Comment)
+    fmt.Fprintf(buf, "%d\n%d\n%d\n%d\n", boolToInt(p.UK), p.Transport, p.Entry, p.Exit)
+    writePoint(buf, p.Point)
+  }
+
+  return nil
+}
+
+// writeComment writes a comment line, unless the comment is empty.
+func writeComment(buf *bytes.Buffer, comment string) {
+  if comment != "" {
+    fmt.Fprintf(buf, "-- %s\n", comment)
+  }
+}
+
+// writePoint writes a coordinate line using the shortest representation of
+// each value.
+func writePoint(buf *bytes.Buffer, p Point) {
+  fmt.Fprintf(buf, "%s,%s,%s\n", formatFloat(p.X), formatFloat(p.Y), formatFloat(p.Z))
+}
+
+// formatFloat formats a float32 value using the fewest digits needed to read it
+// back exactly.
+func formatFloat(v float32) string {
+  return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+// boolToInt converts a bool to the 0 or 1 used by SC4Path files.
+func boolToInt(b bool) int {
+  if b {
+    return 1
+  }
+
+  return 0
+}
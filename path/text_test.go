@@ -0,0 +1,123 @@
+package path
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+const sampleText = `SC4PATHS
+1.1
+2
+1
+-- Car_1_0_2
+1
+1
+0
+2
+2
+-8,-3,0
+8,-3,0
+
+-- Sim_1_1_3
+2
+1
+1
+3
+3
+-5.5,8,0
+-5.5,0,0.25
+-5.5,-8,0
+-- Stop
+0
+1
+0
+2
+0,-3,0
+`
+
+func TestDecodeText(t *testing.T) {
+  f, e := Decode(strings.NewReader(sampleText))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if f.Binary || f.MajorVersion != 1 || f.MinorVersion != 1 || len(f.TransitPaths) != 2 || len(f.StopPaths) != 1 {
+    t.Fatalf("Unexpected file %+v", f)
+  }
+
+  car := f.TransitPaths[0]
+  if car.Comment != "Car_1_0_2" || car.Transport != Car || car.Class != 1 || car.Entry != West || car.Exit != East || len(car.Points) != 2 {
+    t.Errorf("Unexpected path %+v", car)
+  }
+
+  sim := f.TransitPaths[1]
+  if sim.Transport != Sim || sim.Entry != North || sim.Exit != South || sim.Points[1] != (Point{X: -5.5, Y: 0, Z: 0.25}) {
+    t.Errorf("Unexpected path %+v", sim)
+  }
+
+  stop := f.StopPaths[0]
+  if stop.Comment != "Stop" || stop.UK || stop.Transport != Car || stop.Point != (Point{X: 0, Y: -3, Z: 0}) {
+    t.Errorf("Unexpected stop path %+v", stop)
+  }
+}
+
+func TestEncodeTextRoundTrip(t *testing.T) {
+  f, e := Decode(strings.NewReader(sampleText))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  buf := new(bytes.Buffer)
+  if e := f.Encode(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  expected := strings.Replace(sampleText, "8,-3,0\n\n", "8,-3,0\n", 1)
+  if buf.String() != expected {
+    t.Errorf("Expected:\n%s\nbut was:\n%s", expected, buf.String())
+  }
+}
+
+func TestDecodeTextWithJunctions(t *testing.T) {
+  text := "SC4PATHS\n1.2\n1\n0\n7\n2\n255\n2\n1\n2\n0,0,0\n8,0,0\n"
+
+  f, e := Decode(strings.NewReader(text))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  p := f.TransitPaths[0]
+  if p.Transport != Monorail || p.Entry != NoSide || !p.Junction || len(p.Points) != 2 {
+    t.Errorf("Unexpected path %+v", p)
+  }
+
+  buf := new(bytes.Buffer)
+  f.Encode(buf)
+  if buf.String() != text {
+    t.Errorf("Expected:\n%s\nbut was:\n%s", text, buf.String())
+  }
+}
+
+func TestDecodeInvalidText(t *testing.T) {
+  texts := []string{
+    "SC4PATHS\nx\n",
+    "SC4PATHS\n1.0\n1\n1\n1\n",
+    "SC4PATHS\n1.0\n1\n1\n1\n0\n2\n1\n0,0\n",
+    "SC4PATHS\n1.0\n1\n1\n1\n0\n300\n",
+  }
+
+  for _, text := range texts {
+    if _, e := Decode(strings.NewReader(text)); e == nil {
+      t.Errorf("Expected an error for %q", text)
+    }
+  }
+}
+
+func TestEncodeStopPathsInVersion10(t *testing.T) {
+  f := &File{MajorVersion: 1, StopPaths: []*StopPath{&StopPath{Transport: Car}}}
+
+  if e := f.Encode(new(bytes.Buffer)); e == nil {
+    t.Error()
+  }
+}
@@ -1,10 +1,15 @@
 package qfs
 
 import (
+  "errors"
   "io"
   "encoding/binary"
 )
 
+// ErrCorrupt is returned by Decode when a sequence refers to bytes outside of
+// the uncompressed data.
+var ErrCorrupt = errors.New("Corrupt compressed data")
+
 // Encode takes the bytes contained in the provided byte slice, encodes them, and
 // writes them to the Writer.
 func Encode(w io.Writer, data []byte) error {
@@ -25,7 +30,7 @@ func Encode(w io.Writer, data []byte) error {
   for i := 0; i < len(data); i++ {
     v := data[i]
     if -1 != offsetToLastOccurence[v] {
-      repeatCount := firstByteRepeatCount(data[i:])
+      repeatCount := matchLength(data, offsetToLastOccurence[v], i)
       if compressed, e := writeCompressible(w, data[nextWritePos:i], repeatCount, uint32(i - offsetToLastOccurence[v])); e != nil {
         return e
       } else if compressed {
@@ -47,11 +52,12 @@ func Encode(w io.Writer, data []byte) error {
   return nil
 }
 
-// firstByteRepeatCount counts how many times the first byte of the provided
-// slice is repeated consecutively.
-func firstByteRepeatCount(s []byte) uint32 {
-  i := 1
-  for ; i < len(s) && s[0] == s[i]; i++ {}
+// matchLength counts how many bytes starting at pos repeat those starting at
+// the earlier position from, up to the longest copy a control code can hold.
+// The bytes may overlap, as when a single byte is repeated.
+func matchLength(data []byte, from, pos int) uint32 {
+  i := 0
+  for ; pos + i < len(data) && i < 1028 && data[from + i] == data[pos + i]; i++ {}
 
   return uint32(i)
 }
@@ -64,15 +70,15 @@ func writeCompressible(w io.Writer, data []byte, copyCount, copyOffset uint32) (
   compressed := false
 
   if copyCount >= 3 && copyCount <= 10 && copyOffset <= 1024 {
-    // 3 to 10 repeating bytes and copyOffset no greater than 1024: can be compressed
+    // 3 to 10 copied bytes and copyOffset no greater than 1024: can be compressed
     // with 2 control bytes
     control = createTwoControlByteBlock(uint32(len(data)), copyCount, copyOffset)
   } else if copyCount >= 4 && copyCount <= 67 && copyOffset <= 16384 {
-    // 4 to 67 repeating bytes and copyOffset no greater than 16384: can be compressed
+    // 4 to 67 copied bytes and copyOffset no greater than 16384: can be compressed
     // with 3 control bytes
     control = createThreeControlByteBlock(uint32(len(data)), copyCount, copyOffset)
   } else if copyCount >= 5 && copyCount <= 1028 && copyOffset <= 131072 {
-    // 5 to 1028 repeating bytes and copyOffset no greater than 131072: can be compressed
+    // 5 to 1028 copied bytes and copyOffset no greater than 131072: can be compressed
     // with 4 control bytes
     control = createFourControlByteBlock(uint32(len(data)), copyCount, copyOffset)
   }
@@ -248,7 +254,7 @@ func Decode(r io.Reader) ([]byte, error) {
   if _, e := r.Read(buffer[0:3]); e != nil {
     return nil, e
   }
-  size := uint32(buffer[0] << 16 | buffer[1] << 8 | buffer[2])
+  size := uint32(buffer[0]) << 16 | uint32(buffer[1]) << 8 | uint32(buffer[2])
 
   // Create the output byte slice
   output := make([]byte, size)
@@ -297,27 +303,31 @@ func decodeSequence(control byte, r io.Reader, output []byte, outputPos *int) er
     }
     f = decodeFourByteSequence
     break
-  case control & 0xE4 == 0xE0:
-    // 1-byte control code
-    f = decodeOneByteSequence
-    break
   case control & 0xFC == 0xFC:
     // Special 1-byte control code (terminator)
     f = decodeFinalSequence
     break
+  case control & 0xE0 == 0xE0:
+    // 1-byte control code
+    f = decodeOneByteSequence
+    break
   }
 
   proceeding, count, offset := f(buffer)
   pos := *outputPos
-  if n, e := r.Read(output[pos:pos + proceeding]); e != nil {
+  if pos + proceeding + count > len(output) || offset > pos + proceeding {
+    return ErrCorrupt
+  }
+  if n, e := io.ReadFull(r, output[pos:pos + proceeding]); e != nil {
     return e
   } else {
     *outputPos += n
   }
   if 0 < offset {
-    b := output[*outputPos - offset]
+    // Copy byte by byte, since the bytes copied may overlap those written.
+    p := *outputPos
     for i := 0; i < count; i++ {
-      output[*outputPos + i] = b
+      output[p + i] = output[p - offset + i]
     }
 
     *outputPos += count
@@ -327,42 +337,42 @@ func decodeSequence(control byte, r io.Reader, output []byte, outputPos *int) er
 }
 
 // decodeFourByteSequence decodes a 4-byte control sequence to extract the number
-// of proceeding bytes, the number of times to write the repeating byte, and the
-// offset of the repeating byte.
+// of proceeding bytes, the number of bytes to copy, and the offset they are copied
+// from.
 func decodeFourByteSequence(control []byte) (proceeding, count, offset int) {
   proceeding = int(control[0] & 0x3)
-  count = int(control[0] & 0xC << 6 + control[3] + 5)
-  offset = int(control[0] & 0x10 << 12 + control[1] << 8 + control[2] + 1)
+  count = int(control[0] & 0xC) << 6 + int(control[3]) + 5
+  offset = int(control[0] & 0x10) << 12 + int(control[1]) << 8 + int(control[2]) + 1
 
   return
 }
 
 // decodeThreeByteSequence decodes a 3-byte control sequence to extract the number
-// of procceeding bytes, the number of times to write the repeating byte, and the
-// offset of the repeating byte.
+// of procceeding bytes, the number of bytes to copy, and the offset they are copied
+// from.
 func decodeThreeByteSequence(control []byte) (proceeding, count, offset int) {
   proceeding = int(control[1] & 0xC0 >> 6 & 0x3)
-  count = int(control[0] & 0x3F + 4)
-  offset = int(control[1] & 0x3F << 8 + control[2] + 1)
+  count = int(control[0] & 0x3F) + 4
+  offset = int(control[1] & 0x3F) << 8 + int(control[2]) + 1
 
   return
 }
 
 // decodeTwoByteSequence decodes a 2-byte control sequence to extract the number
-// of procceeding bytes, the number of times to write the repeating byte, and the
-// offset of the repeating byte.
+// of procceeding bytes, the number of bytes to copy, and the offset they are copied
+// from.
 func decodeTwoByteSequence(control []byte) (proceeding, count, offset int) {
   proceeding = int(control[0] & 0x3)
-  count = int(control[0] & 0x1C >> 2 + 3)
-  offset = int(control[0] & 0x60 << 3 + control[1] + 1)
+  count = int(control[0] & 0x1C) >> 2 + 3
+  offset = int(control[0] & 0x60) << 3 + int(control[1]) + 1
 
   return
 }
 
 // decodeOneByteSequence decodes a 1-byte control sequence to extract the number
-// of procceeding bytes.  The repeating count and offset is simply set to zero.
+// of procceeding bytes.  The copy count and offset are simply set to zero.
 func decodeOneByteSequence(control []byte) (proceeding, count, offset int) {
-  proceeding = int(control[0] & 0x1F << 2 + 4)
+  proceeding = int(control[0] & 0x1F) << 2 + 4
   count = 0
   offset = 0
 
@@ -370,7 +380,7 @@ func decodeOneByteSequence(control []byte) (proceeding, count, offset int) {
 }
 
 // decodeFinalSequence decodes a 1-byte control sequence to extract the number
-// of proceeding bytes.  The repeating count and offset is simply set to zero.
+// of proceeding bytes.  The copy count and offset are simply set to zero.
 func decodeFinalSequence(control []byte) (proceeding, count, offset int) {
   proceeding = int(control[0] & 0x3)
   count = 0
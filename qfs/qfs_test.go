@@ -4,6 +4,7 @@ import (
   "testing"
   "bytes"
   "errors"
+  "io"
 )
 
 func TestEncodeWithZeroBytes(t *testing.T) {
@@ -34,18 +35,19 @@ func TestEncodeWithSingleByte(t *testing.T) {
 
 // CreateSampleData creates a byte slice that can be used to test the Encode
 // function.  It takes a count and an offset argument and generates a sequence
-// of bytes that starts with the byte 0xAA, then consists of non repeating bytes
-// in the range of 0x00 to 0x7F (offset - 1), and then ends with a repeating chain of length
-// count of the byte 0xAA.
+// of offset bytes that starts with the byte 0xAA, followed by bytes in the
+// range of 0x00 to 0x7E among which no pair of consecutive bytes appears
+// twice, so that nothing in it can be copied.  It then ends with count bytes
+// repeating the sequence from its start.
 func CreateSampleData(count, offset int) []byte {
   result := make([]byte, offset + count)
   result[0] = byte(0xAA)
   for i := 1; i < offset; i++ {
-    result[i] = byte(i % 128)
+    result[i] = byte(i % 127 * (i / 127 + 1) % 127)
   }
 
   for i := 0; i < count; i++ {
-    result[offset + i] = byte(0xAA)
+    result[offset + i] = result[i]
   }
 
   return result
@@ -85,81 +87,15 @@ func TestEncodeWithA4ByteChainAndMediumOffset(t *testing.T) {
     t.Error()
   }
 
-  expected := []byte{ 0x10, 0xFB, 0x0, 0x4, 0x5, 0xFB, 0xAA,
-     0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
-     0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F,
-     0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F,
-     0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
-     0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
-     0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
-     0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F,
-     0xFB,
-     0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
-     0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
-     0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F,
-     0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F,
-     0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
-     0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
-     0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
-     0xFB,
-     0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F,
-     0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
-     0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
-     0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F,
-     0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F,
-     0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
-     0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
-     0xFB,
-     0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
-     0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F,
-     0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
-     0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
-     0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F,
-     0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F,
-     0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
-     0xFB,
-     0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
-     0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
-     0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F,
-     0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
-     0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
-     0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F,
-     0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F,
-     0xFB,
-     0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
-     0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
-     0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
-     0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F,
-     0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
-     0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
-     0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F,
-     0xFB,
-     0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F,
-     0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
-     0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
-     0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
-     0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F,
-     0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
-     0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
-     0xFB,
-     0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F,
-     0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F,
-     0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
-     0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
-     0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
-     0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F,
-     0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
-     0xFB,
-     0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
-     0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F,
-     0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F,
-     0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
-     0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
-     0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
-     0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F,
-     0xE3,
-     0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
-     0x80, 0x44, 0x0, 0x0, 0xFC }
+  // The first 1025 bytes are written as literals, 1024 of them with 1-byte
+  // control codes and the last one with the 3-byte control code copying 4
+  // bytes from 1025 bytes back.
+  expected := []byte{ 0x10, 0xFB, 0x0, 0x4, 0x5 }
+  for i := 0; i < 1008; i += 112 {
+    expected = append(append(expected, 0xFB), data[i:i + 112]...)
+  }
+  expected = append(append(expected, 0xE3), data[1008:1024]...)
+  expected = append(expected, 0x80, 0x44, 0x0, data[1024], 0xFC)
 
   CheckIfSlicesAreEqual(t, buffer.Bytes(), expected)
 }
@@ -186,7 +122,7 @@ func TestEncodeWithMultipleRepeatingChains(t *testing.T) {
     t.Error()
   }
 
-  expected := []byte{ 0x10, 0xFB, 0x0, 0x0, 0xF, 0xE0, 0xA5, 0x24, 0x5C, 0x71, 0x0, 0x3, 0x6, 0x5, 0x2E, 0x6A, 0xFE, 0x88, 0x04 }
+  expected := []byte{ 0x10, 0xFB, 0x0, 0x0, 0xF, 0xE1, 0xA5, 0x24, 0x5C, 0x71, 0xA5, 0xA5, 0xA5, 0x2E, 0x2, 0x0, 0x6A, 0x71, 0xFE, 0x88, 0x04 }
 
   CheckIfSlicesAreEqual(t, buffer.Bytes(), expected)
 }
@@ -225,13 +161,13 @@ func TestDecodeSingle3ByteChain(t *testing.T) {
     t.Error()
   }
 
-  expected := []byte{ 0x47, 0x69, 0x22, 0x47, 0x47, 0x47, 0x3D }
+  expected := []byte{ 0x47, 0x69, 0x22, 0x47, 0x69, 0x22, 0x3D }
 
   CheckIfSlicesAreEqual(t, data, expected)
 }
 
 func TestDecodeMultipleByteChains(t *testing.T) {
-  buffer := bytes.NewBuffer([]byte{ 0x10, 0xFB, 0x0, 0x0, 0xF, 0xE0, 0xA5, 0x24, 0x5C, 0x71, 0x0, 0x3, 0x6, 0x5, 0x2E, 0x6A, 0xFE, 0x88, 0x04 })
+  buffer := bytes.NewBuffer([]byte{ 0x10, 0xFB, 0x0, 0x0, 0xF, 0xE1, 0xA5, 0x24, 0x5C, 0x71, 0xA5, 0xA5, 0xA5, 0x2E, 0x2, 0x0, 0x6A, 0x71, 0xFE, 0x88, 0x04 })
 
   data, e := Decode(buffer)
   if e != nil {
@@ -243,6 +179,148 @@ func TestDecodeMultipleByteChains(t *testing.T) {
   CheckIfSlicesAreEqual(t, data, expected)
 }
 
+// TestDecodeBackReferences decodes streams written by hand following the
+// RefPack format, whose copies repeat several different bytes rather than a
+// single one, with each kind of control code.
+func TestDecodeBackReferences(t *testing.T) {
+  tests := []struct {
+    stream []byte
+    expected string
+  }{
+    { []byte{ 0x10, 0xFB, 0x0, 0x0, 0x9, 0x0F, 0x02, 'a', 'b', 'c', 0xFC }, "abcabcabc" },
+    { []byte{ 0x10, 0xFB, 0x0, 0x0, 0x14, 0xE0, 'h', 'e', 'l', 'l', 0x88, 0xC0, 0x06, 'o', ',', ' ', 0xFD, '!' }, "hello, hello, hello!" },
+    { []byte{ 0x10, 0xFB, 0x0, 0x0, 0x18, 0xE0, '0', '1', '2', '3', 0xC0, 0x0, 0x3, 0xF, 0xFC }, "012301230123012301230123" },
+  }
+
+  for _, test := range tests {
+    data, e := Decode(bytes.NewBuffer(test.stream))
+    if e != nil || string(data) != test.expected {
+      t.Errorf("Expected %q, got %q, %v", test.expected, data, e)
+    }
+  }
+}
+
+func TestEncodeCopiesEarlierBytes(t *testing.T) {
+  buffer := new(bytes.Buffer)
+  data := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog, "), 8)
+
+  if e := Encode(buffer, data); e != nil {
+    t.Fatal(e)
+  }
+
+  if buffer.Len() >= len(data) / 2 {
+    t.Errorf("Expected repeated text to be compressed, got %d bytes from %d", buffer.Len(), len(data))
+  }
+
+  decoded, e := Decode(bytes.NewReader(buffer.Bytes()))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  CheckIfSlicesAreEqual(t, decoded, data)
+}
+
+// CreateLiteralStream returns a compressed stream holding the provided data
+// as a sequence of 1-byte control codes with the provided lengths, each of
+// them followed by its proceeding bytes, and ending with the terminator.
+func CreateLiteralStream(data []byte, lengths ...int) []byte {
+  size := len(data)
+  stream := []byte{ 0x10, 0xFB, byte(size >> 16), byte(size >> 8), byte(size) }
+  pos := 0
+  for _, length := range lengths {
+    stream = append(stream, byte(0xE0 | (length - 4) >> 2))
+    stream = append(stream, data[pos:pos + length]...)
+    pos += length
+  }
+
+  return append(stream, 0xFC)
+}
+
+func TestDecodeSizeAbove255(t *testing.T) {
+  expected := make([]byte, 0x100)
+  for i := range expected {
+    expected[i] = byte(i)
+  }
+
+  data, e := Decode(bytes.NewBuffer(CreateLiteralStream(expected, 112, 112, 16, 16)))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  CheckIfSlicesAreEqual(t, data, expected)
+}
+
+func TestDecodeOneByteControlCodes(t *testing.T) {
+  // Control codes 0xE4 to 0xE7, 0xEC to 0xEF and 0xF4 to 0xF7 have the bit
+  // 0x04 set, and 0xFC to 0xFF also match the mask of the 1-byte control code.
+  expected := make([]byte, 20 + 52 + 84)
+  for i := range expected {
+    expected[i] = byte(i)
+  }
+
+  data, e := Decode(bytes.NewBuffer(CreateLiteralStream(expected, 20, 52, 84)))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  CheckIfSlicesAreEqual(t, data, expected)
+}
+
+func TestDecodeSequenceFields(t *testing.T) {
+  tests := []struct {
+    control []byte
+    decode func([]byte) (int, int, int)
+    proceeding, count, offset int
+  }{
+    { []byte{ 0x7F, 0xFF }, decodeTwoByteSequence, 3, 10, 1024 },
+    { []byte{ 0xBF, 0xFF, 0xFF }, decodeThreeByteSequence, 3, 67, 16384 },
+    { []byte{ 0xDF, 0xFF, 0xFF, 0xFF }, decodeFourByteSequence, 3, 1028, 131072 },
+    { []byte{ 0xFB }, decodeOneByteSequence, 112, 0, 0 },
+  }
+
+  for _, test := range tests {
+    proceeding, count, offset := test.decode(test.control)
+    if proceeding != test.proceeding || count != test.count || offset != test.offset {
+      t.Errorf("Control % X: expected %d, %d, %d, but got %d, %d, %d", test.control, test.proceeding, test.count, test.offset, proceeding, count, offset)
+    }
+  }
+}
+
+// ShortReader is a Reader returning at most 4 bytes from each call to Read.
+type ShortReader struct {
+  data []byte
+}
+
+func (r *ShortReader) Read(p []byte) (int, error) {
+  if len(r.data) == 0 {
+    return 0, io.EOF
+  }
+
+  if len(p) > 4 {
+    p = p[:4]
+  }
+  n := copy(p, r.data)
+  r.data = r.data[n:]
+
+  return n, nil
+}
+
+func TestDecodeWithShortReads(t *testing.T) {
+  buffer := new(bytes.Buffer)
+  data := CreateSampleData(20, 200)
+
+  if e := Encode(buffer, data); e != nil {
+    t.Fatal(e)
+  }
+
+  decoded, e := Decode(&ShortReader{buffer.Bytes()})
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  CheckIfSlicesAreEqual(t, decoded, data)
+}
+
 func CheckIfSlicesAreEqual(t *testing.T, actual []byte, expected []byte) {
   if len(actual) != len(expected) {
     t.Errorf("Actual slice size %d didn't match expected size %d", len(actual), len(expected))
@@ -285,3 +363,30 @@ func TestWithFailingWriter(t *testing.T) {
     t.Error()
   }
 }
+
+func TestDecodeLongChainWithLargeOffset(t *testing.T) {
+  buffer := new(bytes.Buffer)
+  data := CreateSampleData(600, 2000)
+
+  if e := Encode(buffer, data); e != nil {
+    t.Error()
+  }
+
+  decoded, e := Decode(bytes.NewReader(buffer.Bytes()))
+  if e != nil {
+    t.Error(e)
+  }
+
+  CheckIfSlicesAreEqual(t, decoded, data)
+}
+
+func TestDecodeCorruptData(t *testing.T) {
+  for _, stream := range [][]byte{
+    { 0x10, 0xFB, 0x0, 0x0, 0x1, 0xFD, 0x47, 0x48, 0x49 },
+    { 0x10, 0xFB, 0x0, 0x0, 0x7, 0x03, 0x09, 0x47, 0x69, 0x22, 0xFD, 0x3D },
+  } {
+    if _, e := Decode(bytes.NewBuffer(stream)); e != ErrCorrupt {
+      t.Errorf("Expected ErrCorrupt, got %v", e)
+    }
+  }
+}
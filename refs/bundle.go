@@ -0,0 +1,79 @@
+package refs
+
+import (
+  "time"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// Bundle collects the entries of the provided root DBPFs into a new DBPF,
+// along with every entry they depend on, directly or through other collected
+// entries.  Dependencies are looked up in the roots first, and then in the
+// provided Index.  Compressed entries are copied as they are stored, and the
+// DIR entry is rebuilt to match.  The dependencies that can't be found are
+// returned along with the new DBPF.  An error is returned when the data of an
+// entry of the Index can't be read from its file.
+func Bundle(index *Index, roots ...*godbpf.DBPF) (*godbpf.DBPF, []*Dependency, error) {
+  self := NewIndex()
+  for _, root := range roots {
+    self.Add("", root)
+  }
+
+  var queue []*source
+  included := make(map[entry.DBPFEntryTGI]bool)
+  add := func(src *source) {
+    if !included[*src.tgi] {
+      included[*src.tgi] = true
+      queue = append(queue, src)
+    }
+  }
+
+  for _, root := range roots {
+    for _, e := range root.Entries() {
+      if !e.TGI.Equals(entry.DIR_ENTRY_TGI) {
+        add(memorySource(root, e))
+      }
+    }
+  }
+
+  var missing []*Dependency
+  for i := 0; i < len(queue); i++ {
+    for _, d := range queue[i].dependencies() {
+      found := self.entries(d)
+      if len(found) == 0 && index != nil {
+        found = index.entries(d)
+      }
+
+      if len(found) == 0 {
+        missing = append(missing, d)
+      }
+      for _, p := range found {
+        add(p.source)
+      }
+    }
+  }
+
+  result := godbpf.New()
+  result.MajorVersion = 1
+  result.IndexMajorVersion = 7
+  result.CreatedDate = time.Now()
+  result.ModifiedDate = result.CreatedDate
+
+  for _, src := range queue {
+    data, size, e := src.stored()
+    if e != nil {
+      return nil, nil, e
+    }
+
+    copied := entry.NewEntry(src.tgi)
+    copied.SetData(data)
+    result.AddEntry(copied)
+
+    if src.compressed() {
+      result.GetDirEntry().AddEntry(src.tgi, size)
+    }
+  }
+
+  return result, missing, nil
+}
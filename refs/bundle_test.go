@@ -0,0 +1,101 @@
+package refs
+
+import (
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/fsh"
+  "github.com/marcboudreau/godbpf/lot"
+  "github.com/marcboudreau/godbpf/ltext"
+  "github.com/marcboudreau/godbpf/s3d"
+)
+
+func TestBundle(t *testing.T) {
+  set := sampleSet(t)
+  plugin := set[0]
+
+  // Move the building and its resources out of the lot's DBPF.
+  root := godbpf.New()
+  lotTGI := &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: lot.LOT_CONFIG_GROUP_ID, InstanceId: 0x12340000}
+  data, _ := plugin.GetUncompressedData(lotTGI)
+  root.AddCompressedEntry(lotTGI, data)
+
+  plugin.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: s3d.S3D_TYPE_ID, GroupId: 0xBADB57F1, InstanceId: 0x11110200}, modelWithTexture(0x77770000))
+  textures := godbpf.New()
+  addData(textures, &entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: s3d.TEXTURE_GROUP_ID, InstanceId: 0x77770000}, "texture")
+  addData(textures, &entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: s3d.TEXTURE_GROUP_ID, InstanceId: 0x77770001}, "unused texture")
+
+  index := NewIndex()
+  index.Add("plugin.dat", plugin)
+  index.Add("override.dat", set[1])
+  index.Add("textures.dat", textures)
+  checkBundle(t, index, root, lotTGI)
+
+  // The same files read from a folder, whose entries are read when needed.
+  dir := t.TempDir()
+  savedFile(t, dir, "1-plugin.dat", plugin)
+  savedFile(t, dir, "2-override.dat", set[1])
+  savedFile(t, dir, "3-textures.dat", textures)
+  index, e := ScanFolder(dir)
+  if e != nil {
+    t.Fatal(e)
+  }
+  checkBundle(t, index, root, lotTGI)
+}
+
+// checkBundle checks the bundle of the provided root made with the provided
+// Index holding the sample set and textures.
+func checkBundle(t *testing.T, index *Index, root *godbpf.DBPF, lotTGI *entry.DBPFEntryTGI) {
+  bundle, missing, e := Bundle(index, root)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  // The lot, the building, 4 models, the name, the icon, 5 lot textures and
+  // the model texture, along with the DIR entry.
+  if len(bundle.Entries()) != 15 {
+    t.Errorf("Unexpected entries %v", bundle.Entries())
+  }
+
+  if len(missing) != 1 || missing[0].Description != "property 0x88EDC902[12]: single T: 0x6534284A, G: *, I: 0x66660000" {
+    t.Errorf("Expected the prop to be missing, got %v", missing)
+  }
+
+  if bundle.Find(&entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: s3d.TEXTURE_GROUP_ID, InstanceId: 0x77770001}) != nil {
+    t.Error("Expected unused entries to be left out")
+  }
+  if bundle.Find(&entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0xBADB57F1, InstanceId: 0x11111000}) != nil {
+    t.Error("Expected models outside of the referenced block to be left out")
+  }
+
+  for _, tgi := range []*entry.DBPFEntryTGI{
+    lotTGI,
+    &entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: lot.TEXTURE_GROUP_ID, InstanceId: 0x55550004},
+    &entry.DBPFEntryTGI{TypeId: s3d.S3D_TYPE_ID, GroupId: 0xBADB57F1, InstanceId: 0x11110200},
+  } {
+    if !bundle.IsCompressed(tgi) {
+      t.Errorf("Expected {%s} to stay compressed", tgi)
+    }
+    if _, e := bundle.GetUncompressedData(tgi); e != nil {
+      t.Error(e)
+    }
+  }
+
+  if text, e := ltext.Read(bundle, &entry.DBPFEntryTGI{TypeId: ltext.LTEXT_TYPE_ID, GroupId: 0x6A231EAA, InstanceId: 0x22220000}); e != nil || text != "Override" {
+    t.Errorf("Expected the name from the last file, got %q, %v", text, e)
+  }
+}
+
+func TestBundleWithoutIndex(t *testing.T) {
+  set := sampleSet(t)
+  bundle, missing, e := Bundle(nil, set[0])
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(bundle.Entries()) != len(set[0].Entries()) || len(missing) != 1 {
+    t.Errorf("Expected every entry of the root with 1 missing prop, got %v and %v", bundle.Entries(), missing)
+  }
+}
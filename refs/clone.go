@@ -0,0 +1,404 @@
+package refs
+
+import (
+  "bytes"
+  "fmt"
+  "os"
+  "time"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/cache"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/iid"
+  "github.com/marcboudreau/godbpf/lot"
+  "github.com/marcboudreau/godbpf/s3d"
+)
+
+// source is an entry along with where its data is found: either the DBPF
+// holding it in memory, or the file holding it, which is only read when the
+// data is needed.
+type source struct {
+  tgi *entry.DBPFEntryTGI
+
+  dbpf *godbpf.DBPF
+  entry *entry.DBPFEntry
+
+  location *cache.Location
+}
+
+// memorySource creates a source for an entry of a DBPF held in memory.
+func memorySource(dbpf *godbpf.DBPF, e *entry.DBPFEntry) *source {
+  return &source{tgi: e.TGI, dbpf: dbpf, entry: e}
+}
+
+// fileSource creates a source for an entry of the named file.
+func fileSource(path string, ie *godbpf.IndexEntry) *source {
+  return &source{tgi: &ie.TGI, location: &cache.Location{Path: path, Entry: ie}}
+}
+
+// compressed checks if the receiver is stored compressed.
+func (s *source) compressed() bool {
+  if s.location != nil {
+    return s.location.Entry.Compressed
+  }
+
+  return s.dbpf.IsCompressed(s.tgi)
+}
+
+// data returns the uncompressed data of the receiver.
+func (s *source) data() ([]byte, error) {
+  if s.location != nil {
+    data, e := s.location.Read()
+    if e != nil {
+      return nil, fmt.Errorf("%s: %v", s.location.Path, e)
+    }
+    return data, nil
+  }
+
+  if !s.compressed() {
+    return s.entry.GetData(), nil
+  }
+
+  data, e := godbpf.Decompress(s.entry.GetData())
+  if e != nil {
+    return nil, fmt.Errorf("Compressed entry {%s}: %v", s.tgi, e)
+  }
+
+  return data, nil
+}
+
+// stored returns the data of the receiver as it is stored, along with its
+// uncompressed size when it is compressed.
+func (s *source) stored() ([]byte, uint32, error) {
+  if s.location != nil {
+    f, e := os.Open(s.location.Path)
+    if e != nil {
+      return nil, 0, e
+    }
+    defer f.Close()
+
+    raw := *s.location.Entry
+    raw.Compressed = false
+    data, e := godbpf.ReadEntry(f, &raw)
+    if e != nil {
+      return nil, 0, fmt.Errorf("%s: %v", s.location.Path, e)
+    }
+    return data, s.location.Entry.UncompressedSize, nil
+  }
+
+  var size uint32
+  if s.compressed() {
+    size, _ = s.dbpf.Find(entry.DIR_ENTRY_TGI).GetUncompressedSize(s.tgi)
+  }
+
+  return s.entry.GetData(), size, nil
+}
+
+// exemplar decodes the receiver if it is an exemplar or cohort.  It returns
+// nil for other entries and for exemplars that can't be decoded.
+func (s *source) exemplar() *exemplar.Exemplar {
+  if s.tgi.TypeId != exemplar.EXEMPLAR_TYPE_ID && s.tgi.TypeId != exemplar.COHORT_TYPE_ID {
+    return nil
+  }
+
+  data, e := s.data()
+  if e != nil {
+    return nil
+  }
+
+  ex, e := exemplar.Decode(bytes.NewReader(data))
+  if e != nil {
+    return nil
+  }
+
+  return ex
+}
+
+// lookup returns the entries of the provided DBPFs whose TGI satisfies the
+// provided function.  DBPFs later in the list take precedence, so only the last
+// entry found with each TGI is returned.
+func lookup(dbpfs []*godbpf.DBPF, match func(*entry.DBPFEntryTGI) bool) []*source {
+  var result []*source
+  positions := make(map[entry.DBPFEntryTGI]int)
+
+  for _, dbpf := range dbpfs {
+    for _, e := range dbpf.Entries() {
+      if e.TGI.Equals(entry.DIR_ENTRY_TGI) || !match(e.TGI) {
+        continue
+      }
+
+      if i, found := positions[*e.TGI]; found {
+        result[i] = memorySource(dbpf, e)
+        continue
+      }
+
+      positions[*e.TGI] = len(result)
+      result = append(result, memorySource(dbpf, e))
+    }
+  }
+
+  return result
+}
+
+// block is a block of resources that is cloned as a whole.
+type block struct {
+  typeId, groupId, first uint32
+  kind iid.Kind
+}
+
+// resolve finds the block referenced by the provided Ref in the provided
+// DBPFs, along with its entries.  References that only hold an InstanceId
+// resolve to the group of the first entry found.
+func resolve(ref *Ref, dbpfs []*godbpf.DBPF) (block, []*source) {
+  matches := lookup(dbpfs, ref.Matches)
+  if len(matches) == 0 {
+    return block{}, nil
+  }
+
+  b := block{typeId: ref.TypeId, groupId: ref.GroupId, first: ref.InstanceId, kind: ref.Kind}
+  if ref.AnyGroup {
+    b.groupId = matches[0].tgi.GroupId
+
+    var inGroup []*source
+    for _, m := range matches {
+      if m.tgi.GroupId == b.groupId {
+        inGroup = append(inGroup, m)
+      }
+    }
+    matches = inGroup
+  }
+
+  return b, matches
+}
+
+// references returns the references held by the receiver when it is an
+// exemplar or a model.  Models that can't be decoded hold none.
+func (s *source) references() []*Ref {
+  if ex := s.exemplar(); ex != nil {
+    return FromExemplar(ex)
+  }
+
+  if s.tgi.TypeId != s3d.S3D_TYPE_ID {
+    return nil
+  }
+
+  data, e := s.data()
+  if e != nil {
+    return nil
+  }
+
+  refs, e := FromModel(data)
+  if e != nil {
+    return nil
+  }
+
+  return refs
+}
+
+// lotsPlacing returns the lot configurations found in the provided DBPFs that
+// place the building with the provided InstanceId.
+func lotsPlacing(instanceId uint32, dbpfs []*godbpf.DBPF) []*source {
+  var result []*source
+  for _, s := range lookup(dbpfs, func(tgi *entry.DBPFEntryTGI) bool { return tgi.TypeId == exemplar.EXEMPLAR_TYPE_ID }) {
+    ex := s.exemplar()
+    if ex == nil || !isLotConfig(ex) {
+      continue
+    }
+
+    l, e := lot.FromExemplar(ex)
+    if e != nil {
+      continue
+    }
+
+    for _, o := range l.ObjectsOfType(lot.Building) {
+      if o.InstanceID() == instanceId {
+        result = append(result, s)
+        break
+      }
+    }
+  }
+
+  return result
+}
+
+// isLotConfig checks if the provided exemplar describes a lot.
+func isLotConfig(ex *exemplar.Exemplar) bool {
+  p := ex.Property(lot.EXEMPLAR_TYPE_PROPERTY)
+  if p == nil {
+    return false
+  }
+
+  values := p.Uint32Values()
+  return len(values) == 1 && values[0] == lot.LOT_CONFIG_EXEMPLAR_TYPE
+}
+
+// Clone copies the resource identified by root to a new DBPF, along with every
+// resource it refers to, directly or through other copied resources, that can
+// be found in the provided DBPFs.  DBPFs later in the list take precedence.
+// References are those held by exemplars, as returned by FromExemplar, and the
+// textures of models.  When the root is an exemplar placed as a building by
+// lots, those lots are copied as well.
+//
+// Each copied block of resources is given a new block of instance IDs from the
+// provided Allocator, and the references held by the copied exemplars and
+// models are rewritten to match.  References to resources that can't be found,
+// such as those provided by the game, are left unchanged.  A resource found
+// through several overlapping references is copied once, and each of these
+// references is rewritten to where the resource went, which fails when a
+// reference covers resources copied with different blocks.  The Allocator is
+// only changed when the clone succeeds.
+//
+// The returned map gives the new TGI of each copied resource, keyed by its
+// original TGI.
+func Clone(root *entry.DBPFEntryTGI, alloc *iid.Allocator, dbpfs ...*godbpf.DBPF) (*godbpf.DBPF, map[entry.DBPFEntryTGI]*entry.DBPFEntryTGI, error) {
+  rootRef := &Ref{TypeId: root.TypeId, GroupId: root.GroupId, InstanceId: root.InstanceId, Kind: iid.Single}
+  rootBlock, rootEntries := resolve(rootRef, dbpfs)
+  if len(rootEntries) == 0 {
+    return nil, nil, fmt.Errorf("No entry found with TGI {%s}", root)
+  }
+
+  // Walk the references, recording the blocks in the order they are found.
+  // An entry that is part of several blocks is only copied with the first,
+  // which owns it.
+  var order []block
+  entries := make(map[block][]*source)
+  owners := make(map[entry.DBPFEntryTGI]block)
+
+  add := func(b block, sources []*source) {
+    if _, found := entries[b]; found {
+      return
+    }
+
+    var owned []*source
+    for _, s := range sources {
+      if _, found := owners[*s.tgi]; !found {
+        owners[*s.tgi] = b
+        owned = append(owned, s)
+      }
+    }
+
+    order = append(order, b)
+    entries[b] = owned
+  }
+
+  add(rootBlock, rootEntries)
+  if ex := rootEntries[0].exemplar(); ex != nil && !isLotConfig(ex) {
+    for _, l := range lotsPlacing(root.InstanceId, dbpfs) {
+      add(block{typeId: l.tgi.TypeId, groupId: l.tgi.GroupId, first: l.tgi.InstanceId, kind: iid.Single}, []*source{l})
+    }
+  }
+
+  for i := 0; i < len(order); i++ {
+    for _, s := range entries[order[i]] {
+      for _, ref := range s.references() {
+        if b, sources := resolve(ref, dbpfs); len(sources) > 0 {
+          add(b, sources)
+        }
+      }
+    }
+  }
+
+  // Only the blocks owning entries are given instance IDs, taken from a copy
+  // of the Allocator that replaces it once everything else succeeded.
+  planned := alloc.Copy()
+  bases := make(map[block]uint32)
+  for _, b := range order {
+    if len(entries[b]) == 0 {
+      continue
+    }
+
+    base, e := planned.Allocate(b.kind)
+    if e != nil {
+      return nil, nil, e
+    }
+    bases[b] = base
+  }
+
+  moved := func(s *source) uint32 {
+    owner := owners[*s.tgi]
+    return bases[owner] + s.tgi.InstanceId - owner.first
+  }
+
+  // target returns the new InstanceId of a reference, or false when it refers
+  // to resources that aren't copied.
+  target := func(ref *Ref) (uint32, bool, error) {
+    _, sources := resolve(ref, dbpfs)
+    if len(sources) == 0 {
+      return 0, false, nil
+    }
+
+    delta := moved(sources[0]) - sources[0].tgi.InstanceId
+    for _, s := range sources[1:] {
+      if moved(s) - s.tgi.InstanceId != delta {
+        return 0, false, fmt.Errorf("Resources referenced by %s were copied with different blocks", ref)
+      }
+    }
+
+    return ref.InstanceId + delta, true, nil
+  }
+
+  result := godbpf.New()
+  result.MajorVersion = 1
+  result.IndexMajorVersion = 7
+  result.CreatedDate = time.Now()
+  result.ModifiedDate = result.CreatedDate
+
+  renamed := make(map[entry.DBPFEntryTGI]*entry.DBPFEntryTGI)
+  for _, b := range order {
+    for _, s := range entries[b] {
+      tgi := &entry.DBPFEntryTGI{TypeId: s.tgi.TypeId, GroupId: s.tgi.GroupId, InstanceId: moved(s)}
+
+      data, e := s.data()
+      if e != nil {
+        return nil, nil, e
+      }
+
+      if ex := s.exemplar(); ex != nil {
+        for _, ref := range FromExemplar(ex) {
+          id, found, e := target(ref)
+          if e != nil {
+            return nil, nil, e
+          }
+          if found {
+            if e := ref.Set(ex, id); e != nil {
+              return nil, nil, e
+            }
+          }
+        }
+
+        if data, e = ex.Bytes(); e != nil {
+          return nil, nil, e
+        }
+      } else if refs := s.references(); len(refs) > 0 {
+        ids := make(map[uint32]uint32)
+        for _, ref := range refs {
+          id, found, e := target(ref)
+          if e != nil {
+            return nil, nil, e
+          }
+          if found {
+            ids[ref.InstanceId] = id
+          }
+        }
+
+        if data, e = s3d.SetTextureIds(data, ids); e != nil {
+          return nil, nil, e
+        }
+      }
+
+      if s.compressed() {
+        result.AddCompressedEntry(tgi, data)
+      } else {
+        copied := entry.NewEntry(tgi)
+        copied.SetData(data)
+        result.AddEntry(copied)
+      }
+
+      renamed[*s.tgi] = tgi
+    }
+  }
+
+  *alloc = *planned
+  return result, renamed, nil
+}
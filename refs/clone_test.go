@@ -0,0 +1,241 @@
+package refs
+
+import (
+  "bytes"
+  "encoding/binary"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/fsh"
+  "github.com/marcboudreau/godbpf/iid"
+  "github.com/marcboudreau/godbpf/lot"
+  "github.com/marcboudreau/godbpf/ltext"
+  "github.com/marcboudreau/godbpf/s3d"
+)
+
+const buildingGroup uint32 = 0x07BDDF1C
+
+func addData(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI, data string) {
+  e := entry.NewEntry(tgi)
+  e.SetData([]byte(data))
+  dbpf.AddEntry(e)
+}
+
+// sampleSet returns a plugin holding a lot, its building and their resources,
+// along with a second plugin that overrides the name of the building.
+func sampleSet(t *testing.T) []*godbpf.DBPF {
+  b := lot.NewBuilder("Sample", 1, 1)
+  b.PlaceBuilding(0x44440000, 8, 8, 8, 8, 0)
+  b.PlaceTexture(0x55550000, 0, 0, 0)
+  b.PlaceProp(0x66660000, 4, 4, 1, 1, 0)
+  b.IncludeExemplar(&entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: buildingGroup, InstanceId: 0x44440000}, buildingExemplar())
+
+  plugin, e := b.Build(0x12340000)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  for _, offset := range []uint32{0x000, 0x110, 0x430} {
+    addData(plugin, &entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0xBADB57F1, InstanceId: 0x11110000 + offset}, "model")
+  }
+  addData(plugin, &entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0xBADB57F1, InstanceId: 0x11111000}, "other model")
+  ltext.Write(plugin, &entry.DBPFEntryTGI{TypeId: ltext.LTEXT_TYPE_ID, GroupId: 0x6A231EAA, InstanceId: 0x22220000}, "Sample")
+  addData(plugin, &entry.DBPFEntryTGI{TypeId: godbpf.PNG_TYPE_ID, GroupId: godbpf.MENU_ICON_GROUP_ID, InstanceId: 0x33330000}, "icon")
+  for zoom := uint32(0); zoom < 5; zoom++ {
+    plugin.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: lot.TEXTURE_GROUP_ID, InstanceId: 0x55550000 + zoom}, []byte("texture"))
+  }
+
+  override := godbpf.New()
+  ltext.Write(override, &entry.DBPFEntryTGI{TypeId: ltext.LTEXT_TYPE_ID, GroupId: 0x6A231EAA, InstanceId: 0x22220000}, "Override")
+
+  return []*godbpf.DBPF{plugin, override}
+}
+
+// sampleModel encodes a model with a single material using the provided
+// textures.
+func sampleModel(textures ...uint32) []byte {
+  mats := new(bytes.Buffer)
+  binary.Write(mats, binary.LittleEndian, []uint16{1, 1})
+  binary.Write(mats, binary.LittleEndian, uint32(1))
+  mats.Write(make([]byte, 15))
+  mats.Write([]byte{byte(len(textures))})
+  for _, id := range textures {
+    binary.Write(mats, binary.LittleEndian, id)
+    mats.Write(make([]byte, 7))
+  }
+
+  buf := new(bytes.Buffer)
+  buf.WriteString("3DMD")
+  binary.Write(buf, binary.LittleEndian, uint32(16 + mats.Len()))
+  buf.WriteString("MATS")
+  binary.Write(buf, binary.LittleEndian, uint32(8 + mats.Len()))
+  buf.Write(mats.Bytes())
+
+  return buf.Bytes()
+}
+
+func decodeExemplar(t *testing.T, dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI) *exemplar.Exemplar {
+  data, e := dbpf.GetUncompressedData(tgi)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  ex, e := exemplar.Decode(bytes.NewReader(data))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  return ex
+}
+
+func TestClone(t *testing.T) {
+  alloc, _ := iid.New(0x80000000, 0x8000FFFF)
+  root := &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: lot.LOT_CONFIG_GROUP_ID, InstanceId: 0x12340000}
+
+  result, renamed, e := Clone(root, alloc, sampleSet(t)...)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  // The lot and building exemplars, 3 models, the name, the icon and 5
+  // textures, along with the DIR entry.
+  if len(result.Entries()) != 13 || len(renamed) != 12 {
+    t.Fatalf("Unexpected entries %v", result.Entries())
+  }
+
+  newLot := renamed[*root]
+  if newLot.InstanceId != 0x80000000 || newLot.GroupId != lot.LOT_CONFIG_GROUP_ID {
+    t.Errorf("Unexpected lot TGI {%s}", newLot)
+  }
+
+  newBuilding := renamed[entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: buildingGroup, InstanceId: 0x44440000}]
+  if newBuilding == nil || newBuilding.InstanceId != 0x80000001 {
+    t.Fatalf("Unexpected building TGI {%v}", newBuilding)
+  }
+
+  lotRefs := FromExemplar(decodeExemplar(t, result, newLot))
+  if lotRefs[0].InstanceId != newBuilding.InstanceId || lotRefs[1].InstanceId != 0x80000010 || lotRefs[2].InstanceId != 0x66660000 {
+    t.Errorf("Unexpected lot references %v", lotRefs)
+  }
+
+  buildingRefs := FromExemplar(decodeExemplar(t, result, newBuilding))
+  if buildingRefs[0].InstanceId != 0x80001000 || buildingRefs[1].InstanceId != 0x80000002 || buildingRefs[2].InstanceId != 0x80000003 {
+    t.Errorf("Unexpected building references %v", buildingRefs)
+  }
+
+  if result.Find(&entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0xBADB57F1, InstanceId: 0x80001430}) == nil {
+    t.Error("Expected the models to keep their zoom and rotation offsets")
+  }
+
+  texture := &entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: lot.TEXTURE_GROUP_ID, InstanceId: 0x80000014}
+  if data, e := result.GetUncompressedData(texture); e != nil || string(data) != "texture" || !result.IsCompressed(texture) {
+    t.Errorf("Expected a compressed texture, got %q, %v", data, e)
+  }
+
+  if text, e := ltext.Read(result, &entry.DBPFEntryTGI{TypeId: ltext.LTEXT_TYPE_ID, GroupId: 0x6A231EAA, InstanceId: 0x80000002}); e != nil || text != "Override" {
+    t.Errorf("Expected the name from the last DBPF, got %q, %v", text, e)
+  }
+}
+
+func TestCloneBuilding(t *testing.T) {
+  dbpfs := sampleSet(t)
+  model := &entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0xBADB57F1, InstanceId: 0x11110000}
+  dbpfs[0].SetUncompressedData(model, sampleModel(0x77770000, 0x12345678))
+  addData(dbpfs[0], &entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: s3d.TEXTURE_GROUP_ID, InstanceId: 0x77770000}, "model texture")
+
+  alloc, _ := iid.New(0x80000000, 0x8000FFFF)
+  root := &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: buildingGroup, InstanceId: 0x44440000}
+  result, renamed, e := Clone(root, alloc, dbpfs...)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  // The entries cloned from the lot, along with the texture of the model.
+  if len(result.Entries()) != 14 || len(renamed) != 13 {
+    t.Fatalf("Unexpected entries %v", result.Entries())
+  }
+
+  if newBuilding := renamed[*root]; newBuilding.InstanceId != 0x80000000 {
+    t.Errorf("Unexpected building TGI {%s}", newBuilding)
+  }
+
+  newLot := renamed[entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: lot.LOT_CONFIG_GROUP_ID, InstanceId: 0x12340000}]
+  if newLot == nil || newLot.InstanceId != 0x80000001 {
+    t.Fatalf("Expected the lot of the building to be cloned, got {%v}", newLot)
+  }
+  if lotRefs := FromExemplar(decodeExemplar(t, result, newLot)); lotRefs[0].InstanceId != 0x80000000 {
+    t.Errorf("Expected the lot to place the new building, got %v", lotRefs)
+  }
+
+  data, e := result.GetUncompressedData(&entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0xBADB57F1, InstanceId: 0x80001000})
+  if e != nil {
+    t.Fatal(e)
+  }
+  if ids, e := s3d.TextureIds(data); e != nil || len(ids) != 2 || ids[0] != 0x80000004 || ids[1] != 0x12345678 {
+    t.Errorf("Unexpected texture IDs %X, %v", ids, e)
+  }
+  if result.Find(&entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: s3d.TEXTURE_GROUP_ID, InstanceId: 0x80000004}) == nil {
+    t.Error("Expected the texture of the model to be cloned")
+  }
+
+  if !alloc.IsUsed(0x80001000, 0x80001000) {
+    t.Error("Expected the allocations to be kept")
+  }
+}
+
+func TestCloneOverlappingReferences(t *testing.T) {
+  b := lot.NewBuilder("Sample", 2, 1)
+  b.PlaceTexture(0x55550000, 0, 0, 0)
+  b.PlaceTexture(0x55550002, 1, 0, 0)
+  plugin, e := b.Build(0x12340000)
+  if e != nil {
+    t.Fatal(e)
+  }
+  for zoom := uint32(0); zoom < 5; zoom++ {
+    addData(plugin, &entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: lot.TEXTURE_GROUP_ID, InstanceId: 0x55550000 + zoom}, "texture")
+  }
+
+  alloc, _ := iid.New(0x80000000, 0x8000FFFF)
+  root := &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: lot.LOT_CONFIG_GROUP_ID, InstanceId: 0x12340000}
+  result, renamed, e := Clone(root, alloc, plugin)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  // The second texture only covers entries copied with the first one, so it
+  // points into its block rather than into a block of its own.
+  lotRefs := FromExemplar(decodeExemplar(t, result, renamed[*root]))
+  if len(lotRefs) != 2 || lotRefs[0].InstanceId != 0x80000010 || lotRefs[1].InstanceId != 0x80000012 {
+    t.Errorf("Unexpected lot references %v", lotRefs)
+  }
+  if alloc.IsUsed(0x80000020, 0x8000FFFF) {
+    t.Error("Expected no block to be allocated for the second texture")
+  }
+
+  // Once it also covers an entry of its own, it can't point to both blocks.
+  addData(plugin, &entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: lot.TEXTURE_GROUP_ID, InstanceId: 0x55550010}, "texture")
+  alloc, _ = iid.New(0x80000000, 0x8000FFFF)
+  if _, _, e := Clone(root, alloc, plugin); e == nil {
+    t.Error("Expected an error for a reference covering entries copied with different blocks")
+  }
+  if alloc.IsUsed(0x80000000, 0x8000FFFF) {
+    t.Error("Expected the allocator to be left unchanged after an error")
+  }
+}
+
+func TestCloneErrors(t *testing.T) {
+  alloc, _ := iid.New(0x80000000, 0x80000000)
+  if _, _, e := Clone(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x3}, alloc, sampleSet(t)...); e == nil {
+    t.Error("Expected an error for a missing root")
+  }
+
+  root := &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: lot.LOT_CONFIG_GROUP_ID, InstanceId: 0x12340000}
+  if _, _, e := Clone(root, alloc, sampleSet(t)...); e == nil {
+    t.Error("Expected an error when the allocator runs out of instance IDs")
+  }
+  if alloc.IsUsed(0x80000000, 0x80000000) {
+    t.Error("Expected the allocator to be left unchanged after an error")
+  }
+}
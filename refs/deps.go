@@ -0,0 +1,255 @@
+package refs
+
+import (
+  "bytes"
+  "fmt"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/cache"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/fsh"
+  "github.com/marcboudreau/godbpf/iid"
+  "github.com/marcboudreau/godbpf/s3d"
+  "github.com/marcboudreau/godbpf/ui"
+)
+
+// Dependency is a reference held by an entry of a DBPF to another resource.
+type Dependency struct {
+  // From is the TGI of the entry holding the reference.
+  From *entry.DBPFEntryTGI
+
+  // Description tells where the reference is held in the entry.
+  Description string
+
+  // Refs lists the resources that satisfy the dependency; any of them will
+  // do.
+  Refs []*Ref
+}
+
+// String returns a description of the receiver.
+func (d *Dependency) String() string {
+  return fmt.Sprintf("%08X-%08X-%08X %s", d.From.TypeId, d.From.GroupId, d.From.InstanceId, d.Description)
+}
+
+// Dependencies returns the references held by the entries of the provided
+// DBPF: those found by FromExemplar along with the parent cohorts of
+// exemplars, the textures of the materials of S3D models, and the images used
+// by UI layouts.  Entries that can't be decoded are skipped.
+func Dependencies(dbpf *godbpf.DBPF) []*Dependency {
+  var result []*Dependency
+  for _, e := range dbpf.Entries() {
+    result = append(result, memorySource(dbpf, e).dependencies()...)
+  }
+
+  return result
+}
+
+// dependencies returns the references held by the receiver, as described for
+// Dependencies.
+func (src *source) dependencies() []*Dependency {
+  var result []*Dependency
+  tgi := src.tgi
+
+  switch tgi.TypeId {
+  case exemplar.EXEMPLAR_TYPE_ID, exemplar.COHORT_TYPE_ID:
+    ex := src.exemplar()
+    if ex == nil {
+      return nil
+    }
+
+    if ex.HasParent() {
+      parent := &Ref{TypeId: ex.Parent.TypeId, GroupId: ex.Parent.GroupId, InstanceId: ex.Parent.InstanceId, Kind: iid.Single}
+      result = append(result, &Dependency{From: tgi, Description: "parent cohort", Refs: []*Ref{parent}})
+    }
+
+    for _, ref := range FromExemplar(ex) {
+      result = append(result, &Dependency{From: tgi, Description: ref.String(), Refs: []*Ref{ref}})
+    }
+  case s3d.S3D_TYPE_ID:
+    data, e := src.data()
+    if e != nil {
+      return nil
+    }
+
+    ids, e := s3d.TextureIds(data)
+    if e != nil {
+      return nil
+    }
+
+    for _, id := range ids {
+      ref := &Ref{TypeId: fsh.FSH_TYPE_ID, GroupId: s3d.TEXTURE_GROUP_ID, InstanceId: id, Kind: iid.Single}
+      result = append(result, &Dependency{From: tgi, Description: fmt.Sprintf("material texture 0x%08X", id), Refs: []*Ref{ref}})
+    }
+  case ui.UI_TYPE_ID:
+    data, e := src.data()
+    if e != nil {
+      return nil
+    }
+
+    f, e := ui.Parse(bytes.NewReader(data))
+    if e != nil {
+      return nil
+    }
+
+    for _, image := range f.Images() {
+      d := &Dependency{From: tgi, Description: image.String()}
+      for _, typeId := range ui.ImageTypeIds {
+        d.Refs = append(d.Refs, &Ref{TypeId: typeId, GroupId: image.GroupId, InstanceId: image.InstanceId, Kind: iid.Single})
+      }
+      result = append(result, d)
+    }
+  }
+
+  return result
+}
+
+// provided is a resource provided by a file.
+type provided struct {
+  *source
+  file string
+}
+
+// Index records which files provide which resources.  Files added later take
+// precedence, like plugins loaded later by the game.
+type Index struct {
+  // Files lists the files added to the index, in the order they were added.
+  Files []string
+
+  // Broken lists the files left out by FromCache and ScanFolder since their
+  // index couldn't be read.
+  Broken []*cache.File
+
+  byTGI map[entry.DBPFEntryTGI]*provided
+  byType map[uint32][]*provided
+}
+
+// NewIndex creates an empty Index.
+func NewIndex() *Index {
+  return &Index{byTGI: make(map[entry.DBPFEntryTGI]*provided), byType: make(map[uint32][]*provided)}
+}
+
+// Add records the resources of the provided DBPF as provided by the named
+// file.
+func (x *Index) Add(file string, dbpf *godbpf.DBPF) {
+  x.Files = append(x.Files, file)
+  for _, e := range dbpf.Entries() {
+    if !e.TGI.Equals(entry.DIR_ENTRY_TGI) {
+      x.add(&provided{memorySource(dbpf, e), file})
+    }
+  }
+}
+
+// AddFile records the resources of the provided cached file.  Only its index
+// is kept: the data of an entry is read from the file when it's needed.
+func (x *Index) AddFile(f *cache.File) {
+  x.Files = append(x.Files, f.Path)
+  for _, ie := range f.Entries {
+    if !ie.TGI.Equals(entry.DIR_ENTRY_TGI) {
+      x.add(&provided{fileSource(f.Path, ie), f.Path})
+    }
+  }
+}
+
+// add records the provided resource.
+func (x *Index) add(p *provided) {
+  x.byTGI[*p.tgi] = p
+  x.byType[p.tgi.TypeId] = append(x.byType[p.tgi.TypeId], p)
+}
+
+// Provider returns the file providing the provided Dependency, or false if
+// none of the files in the index does.
+func (x *Index) Provider(d *Dependency) (string, bool) {
+  if found := x.entries(d); len(found) > 0 {
+    return found[0].file, true
+  }
+
+  return "", false
+}
+
+// entries returns the entries satisfying the provided Dependency, taken from
+// the first of its Refs found in the index.  All the entries of the referenced
+// block are returned, with only the last one added for each TGI.  References
+// that only hold an InstanceId resolve to the group of the last entry added.
+func (x *Index) entries(d *Dependency) []*provided {
+  for _, ref := range d.Refs {
+    if ref.Kind == iid.Single && !ref.AnyGroup {
+      if p, found := x.byTGI[entry.DBPFEntryTGI{TypeId: ref.TypeId, GroupId: ref.GroupId, InstanceId: ref.InstanceId}]; found {
+        return []*provided{p}
+      }
+      continue
+    }
+
+    var result []*provided
+    seen := make(map[entry.DBPFEntryTGI]bool)
+    candidates := x.byType[ref.TypeId]
+    for i := len(candidates) - 1; i >= 0; i-- {
+      tgi := candidates[i].tgi
+      if !ref.Matches(tgi) || seen[*tgi] || (len(result) > 0 && tgi.GroupId != result[0].tgi.GroupId) {
+        continue
+      }
+
+      seen[*tgi] = true
+      result = append(result, candidates[i])
+    }
+
+    if len(result) > 0 {
+      return result
+    }
+  }
+
+  return nil
+}
+
+// FromCache creates an Index of the DBPF files of the provided Cache, added in
+// load order.  Files whose index couldn't be read are left out, and listed in
+// Broken.
+func FromCache(c *cache.Cache) *Index {
+  x := NewIndex()
+  for _, f := range c.DBPFs() {
+    x.AddFile(f)
+  }
+  x.Broken = c.Broken()
+
+  return x
+}
+
+// ScanFolder creates an Index of the DBPF files found in the named directory
+// and its subdirectories, as FromCache does with a Cache of the directory.
+func ScanFolder(dir string) (*Index, error) {
+  c := cache.New()
+  if _, e := c.Refresh(dir); e != nil {
+    return nil, e
+  }
+
+  return FromCache(c), nil
+}
+
+// Resolution is a Dependency along with the file providing it.
+type Resolution struct {
+  *Dependency
+
+  // File is the name of the file providing the dependency.  It is empty when
+  // the dependency is missing.
+  File string
+}
+
+// Resolve finds the file providing each of the dependencies of the provided
+// DBPF, which is named name.  Dependencies provided by the DBPF itself are
+// resolved to name, the others are looked up in the provided Index.
+func Resolve(dbpf *godbpf.DBPF, name string, index *Index) []*Resolution {
+  self := NewIndex()
+  self.Add(name, dbpf)
+
+  var result []*Resolution
+  for _, d := range Dependencies(dbpf) {
+    file, found := self.Provider(d)
+    if !found && index != nil {
+      file, _ = index.Provider(d)
+    }
+
+    result = append(result, &Resolution{Dependency: d, File: file})
+  }
+
+  return result
+}
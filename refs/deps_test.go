@@ -0,0 +1,153 @@
+package refs
+
+import (
+  "bytes"
+  "encoding/binary"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/fsh"
+  "github.com/marcboudreau/godbpf/lot"
+  "github.com/marcboudreau/godbpf/s3d"
+)
+
+// modelWithTexture returns an S3D model with a single material using the
+// texture with the provided instance ID.
+func modelWithTexture(textureId uint32) []byte {
+  mats := []byte{1, 0, 1, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+  texture := make([]byte, 4)
+  binary.LittleEndian.PutUint32(texture, textureId)
+  mats = append(mats, texture...)
+  mats = append(mats, 0, 0, 0, 0, 0, 0, 0)
+
+  data := append([]byte("3DMD\x00\x00\x00\x00MATS"), byte(8 + len(mats)), 0, 0, 0)
+  return append(data, mats...)
+}
+
+func savedFile(t *testing.T, dir, name string, dbpf *godbpf.DBPF) string {
+  buf := new(bytes.Buffer)
+  if e := dbpf.Save(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  path := filepath.Join(dir, name)
+  if e := ioutil.WriteFile(path, buf.Bytes(), 0644); e != nil {
+    t.Fatal(e)
+  }
+
+  return path
+}
+
+func TestDependencies(t *testing.T) {
+  dbpf := godbpf.New()
+
+  ex := buildingExemplar()
+  ex.Parent = &entry.DBPFEntryTGI{TypeId: exemplar.COHORT_TYPE_ID, GroupId: 0xB03697D1, InstanceId: 0x1}
+  data, _ := ex.Bytes()
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: buildingGroup, InstanceId: 0x44440000}, data)
+
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: s3d.S3D_TYPE_ID, GroupId: 0xBADB57F1, InstanceId: 0x11110000}, modelWithTexture(0x77770000))
+  addData(dbpf, &entry.DBPFEntryTGI{TypeId: 0, GroupId: 0x96A006B0, InstanceId: 0x1}, "<LEGACY clsid=GZWinBMP id=0x1 captionres={0x6A231EAA,0x2C5B0E8F} image={0x12345678,0x9ABCDEF0}>\r\n")
+  addData(dbpf, &entry.DBPFEntryTGI{TypeId: s3d.S3D_TYPE_ID, GroupId: 0xBADB57F1, InstanceId: 0x99990000}, "broken model")
+
+  deps := Dependencies(dbpf)
+  expected := []string{
+    "6534284A-07BDDF1C-44440000 parent cohort",
+    "6534284A-07BDDF1C-44440000 property 0x27812821[2]: model T: 0x5AD0E817, G: 0xBADB57F1, I: 0x11110000",
+    "6534284A-07BDDF1C-44440000 property 0x8A416A99[2]: single T: 0x2026960B, G: 0x6A231EAA, I: 0x22220000",
+    "6534284A-07BDDF1C-44440000 property 0x8A2602B8[0]: single T: 0x856DDBAC, G: 0x6A386D26, I: 0x33330000",
+    "5AD0E817-BADB57F1-11110000 material texture 0x77770000",
+    "00000000-96A006B0-00000001 window 0x00000001 image={0x12345678,0x9abcdef0}",
+  }
+
+  if len(deps) != len(expected) {
+    t.Fatalf("Unexpected dependencies %v", deps)
+  }
+  for i, d := range deps {
+    if d.String() != expected[i] {
+      t.Errorf("Expected %s, got %s", expected[i], d)
+    }
+  }
+
+  if len(deps[5].Refs) != 2 {
+    t.Errorf("Expected a UI image to be satisfied by a PNG or an FSH entry, got %v", deps[5].Refs)
+  }
+}
+
+func TestResolve(t *testing.T) {
+  dir, e := ioutil.TempDir("", "godbpf")
+  if e != nil {
+    t.Fatal(e)
+  }
+  defer os.RemoveAll(dir)
+
+  set := sampleSet(t)
+  plugin := set[0]
+  plugin.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: s3d.S3D_TYPE_ID, GroupId: 0xBADB57F1, InstanceId: 0x11110100}, modelWithTexture(0x77770000))
+  plugin.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: s3d.S3D_TYPE_ID, GroupId: 0xBADB57F1, InstanceId: 0x11110200}, modelWithTexture(0x88880000))
+
+  textures := godbpf.New()
+  addData(textures, &entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: s3d.TEXTURE_GROUP_ID, InstanceId: 0x77770000}, "texture")
+  props := godbpf.New()
+  addData(props, &entry.DBPFEntryTGI{TypeId: exemplar.EXEMPLAR_TYPE_ID, GroupId: 0x1, InstanceId: 0x66660000}, "prop")
+
+  os.Mkdir(filepath.Join(dir, "b"), 0755)
+  older := savedFile(t, dir, "a.dat", textures)
+  newer := savedFile(t, filepath.Join(dir, "b"), "textures.dat", textures)
+  propFile := savedFile(t, dir, "props.SC4Desc", props)
+  ioutil.WriteFile(filepath.Join(dir, "readme.txt"), []byte("not a plugin"), 0644)
+  ioutil.WriteFile(filepath.Join(dir, "broken.dat"), []byte("DBPF is too short"), 0644)
+
+  index, e := ScanFolder(dir)
+  if e != nil {
+    t.Fatal(e)
+  }
+  if len(index.Files) != 3 || index.Files[0] != older || index.Files[2] != newer {
+    t.Errorf("Unexpected files %v", index.Files)
+  }
+  if len(index.Broken) != 1 || index.Broken[0].Path != filepath.Join(dir, "broken.dat") {
+    t.Errorf("Expected the broken file to be left out, got %v", index.Broken)
+  }
+
+  files := make(map[string]string)
+  for _, r := range Resolve(plugin, "plugin.dat", index) {
+    files[r.Description] = r.File
+  }
+
+  for description, expected := range map[string]string{
+    "property 0x88EDC900[11]: single T: 0x6534284A, G: *, I: 0x44440000": "plugin.dat",
+    "property 0x88EDC901[11]: texture T: 0x7AB50E44, G: 0x0986135E, I: 0x55550000": "plugin.dat",
+    "property 0x88EDC902[12]: single T: 0x6534284A, G: *, I: 0x66660000": propFile,
+    "property 0x27812821[2]: model T: 0x5AD0E817, G: 0xBADB57F1, I: 0x11110000": "plugin.dat",
+    "material texture 0x77770000": newer,
+    "material texture 0x88880000": "",
+  } {
+    if file, found := files[description]; !found || file != expected {
+      t.Errorf("Expected %s to be provided by %q, got %q", description, expected, file)
+    }
+  }
+
+  if _, e := ScanFolder(filepath.Join(dir, "missing")); e == nil {
+    t.Error("Expected an error for a missing folder")
+  }
+}
+
+func TestIndexProviderPrecedence(t *testing.T) {
+  tgi := &entry.DBPFEntryTGI{TypeId: lot.LOT_CONFIG_EXEMPLAR_TYPE, GroupId: 0x2, InstanceId: 0x3}
+  dbpf := godbpf.New()
+  addData(dbpf, tgi, "data")
+
+  index := NewIndex()
+  index.Add("first.dat", dbpf)
+  index.Add("second.dat", dbpf)
+
+  d := &Dependency{From: tgi, Refs: []*Ref{&Ref{TypeId: tgi.TypeId, AnyGroup: true, InstanceId: 0x3}}}
+  if file, found := index.Provider(d); !found || file != "second.dat" {
+    t.Errorf("Expected the last file to be the provider, got %q", file)
+  }
+}
@@ -0,0 +1,163 @@
+package refs
+
+import (
+  "fmt"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/fsh"
+  "github.com/marcboudreau/godbpf/iid"
+  "github.com/marcboudreau/godbpf/lot"
+  "github.com/marcboudreau/godbpf/ltext"
+  "github.com/marcboudreau/godbpf/s3d"
+)
+
+// The IDs of the exemplar properties that refer to other resources.
+const (
+  // RESOURCE_KEY_TYPE_0 and RESOURCE_KEY_TYPE_1 hold the TGI of the model of
+  // a building or prop, with the InstanceId of its first zoom level and
+  // rotation.
+  RESOURCE_KEY_TYPE_0 uint32 = 0x27812820
+  RESOURCE_KEY_TYPE_1 uint32 = 0x27812821
+
+  // USER_VISIBLE_NAME_KEY and ITEM_DESCRIPTION_KEY hold the TGI of an LTEXT
+  // entry.
+  USER_VISIBLE_NAME_KEY uint32 = 0x8A416A99
+  ITEM_DESCRIPTION_KEY uint32 = 0xCA416AB5
+
+  // ITEM_ICON holds the InstanceId of a menu icon.
+  ITEM_ICON uint32 = 0x8A2602B8
+)
+
+// Ref is a reference held by an exemplar to a block of resources.
+type Ref struct {
+  // Property is the ID of the exemplar property holding the reference, and
+  // Index is the position of the InstanceId among its values.
+  Property uint32
+  Index int
+
+  // TypeId and GroupId identify the referenced resources.  When AnyGroup is
+  // set, the reference only holds a TypeId and an InstanceId, and GroupId is
+  // meaningless.
+  TypeId uint32
+  GroupId uint32
+  AnyGroup bool
+
+  // InstanceId is the first instance ID of the referenced block, and Kind
+  // tells how many instance IDs it covers.
+  InstanceId uint32
+  Kind iid.Kind
+}
+
+// String returns a string representation of the receiver.
+func (r *Ref) String() string {
+  group := fmt.Sprintf("0x%08X", r.GroupId)
+  if r.AnyGroup {
+    group = "*"
+  }
+
+  return fmt.Sprintf("property 0x%08X[%d]: %s T: 0x%08X, G: %s, I: 0x%08X", r.Property, r.Index, r.Kind, r.TypeId, group, r.InstanceId)
+}
+
+// Matches checks if the resource identified by the provided DBPFEntryTGI is
+// part of the block referenced by the receiver.
+func (r *Ref) Matches(tgi *entry.DBPFEntryTGI) bool {
+  if tgi.TypeId != r.TypeId || (!r.AnyGroup && tgi.GroupId != r.GroupId) {
+    return false
+  }
+
+  return tgi.InstanceId >= r.InstanceId && uint64(tgi.InstanceId) < uint64(r.InstanceId) + uint64(r.Kind.Span())
+}
+
+// Set changes the InstanceId held by the reference in the provided exemplar,
+// which must be the one the receiver was found in.
+func (r *Ref) Set(ex *exemplar.Exemplar, instanceId uint32) error {
+  p := ex.Property(r.Property)
+  if p == nil || r.Index >= len(p.Values) {
+    return fmt.Errorf("Exemplar has no value for %s", r)
+  }
+
+  switch p.Values[r.Index].(type) {
+  case uint32:
+    p.Values[r.Index] = instanceId
+  case int32:
+    p.Values[r.Index] = int32(instanceId)
+  default:
+    return fmt.Errorf("Property 0x%08X doesn't hold an instance ID at %d", r.Property, r.Index)
+  }
+
+  r.InstanceId = instanceId
+  return nil
+}
+
+// FromExemplar returns the references held by the provided exemplar: models,
+// LTEXT entries and menu icons of buildings and props, as well as the
+// buildings, props and textures placed on lots.  The parent cohort isn't
+// included, since cohorts are meant to be shared.
+func FromExemplar(ex *exemplar.Exemplar) []*Ref {
+  var result []*Ref
+
+  for _, p := range ex.Properties {
+    values := p.Uint32Values()
+
+    switch {
+    case p.ID == RESOURCE_KEY_TYPE_0 || p.ID == RESOURCE_KEY_TYPE_1:
+      for i := 0; i + 3 <= len(values); i += 3 {
+        result = append(result, &Ref{Property: p.ID, Index: i + 2, TypeId: values[i], GroupId: values[i + 1], InstanceId: values[i + 2], Kind: iid.Model})
+      }
+    case p.ID == USER_VISIBLE_NAME_KEY || p.ID == ITEM_DESCRIPTION_KEY:
+      if len(values) == 3 && values[0] == ltext.LTEXT_TYPE_ID {
+        result = append(result, &Ref{Property: p.ID, Index: 2, TypeId: values[0], GroupId: values[1], InstanceId: values[2], Kind: iid.Single})
+      }
+    case p.ID == ITEM_ICON:
+      if len(values) == 1 {
+        result = append(result, &Ref{Property: p.ID, Index: 0, TypeId: godbpf.PNG_TYPE_ID, GroupId: godbpf.MENU_ICON_GROUP_ID, InstanceId: values[0], Kind: iid.Single})
+      }
+    case p.ID >= lot.LOT_OBJECT_PROPERTY && p.ID <= lot.LAST_LOT_OBJECT_PROPERTY:
+      if ref := lotObjectRef(p.ID, values); ref != nil {
+        result = append(result, ref)
+      }
+    }
+  }
+
+  return result
+}
+
+// lotObjectRef returns the reference held by a lot object property, whose
+// last value is the InstanceId of the resource it places.
+func lotObjectRef(id uint32, values []uint32) *Ref {
+  if len(values) < 12 {
+    return nil
+  }
+
+  ref := &Ref{Property: id, Index: len(values) - 1, InstanceId: values[len(values) - 1]}
+  switch lot.ObjectType(values[0]) {
+  case lot.Building, lot.Prop:
+    ref.TypeId, ref.AnyGroup, ref.Kind = exemplar.EXEMPLAR_TYPE_ID, true, iid.Single
+  case lot.Texture:
+    ref.TypeId, ref.GroupId, ref.Kind = fsh.FSH_TYPE_ID, lot.TEXTURE_GROUP_ID, iid.Texture
+  default:
+    return nil
+  }
+
+  return ref
+}
+
+// FromModel returns the references held by the provided S3D model to the
+// textures of its materials.  Since they aren't held by an exemplar, their
+// Property and Index are meaningless, and they are changed with
+// s3d.SetTextureIds rather than Set.
+func FromModel(data []byte) ([]*Ref, error) {
+  ids, e := s3d.TextureIds(data)
+  if e != nil {
+    return nil, e
+  }
+
+  var result []*Ref
+  for _, id := range ids {
+    result = append(result, &Ref{TypeId: fsh.FSH_TYPE_ID, GroupId: s3d.TEXTURE_GROUP_ID, InstanceId: id, Kind: iid.Single})
+  }
+
+  return result, nil
+}
@@ -0,0 +1,95 @@
+package refs
+
+import (
+  "testing"
+
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/exemplar"
+  "github.com/marcboudreau/godbpf/iid"
+  "github.com/marcboudreau/godbpf/lot"
+  "github.com/marcboudreau/godbpf/ltext"
+)
+
+func buildingExemplar() *exemplar.Exemplar {
+  ex := exemplar.New()
+  ex.Properties = []*exemplar.Property{
+    exemplar.NewProperty(0x10, exemplar.Uint32, uint32(0x2)),
+    exemplar.NewProperty(RESOURCE_KEY_TYPE_1, exemplar.Uint32, uint32(0x5AD0E817), uint32(0xBADB57F1), uint32(0x11110000)),
+    exemplar.NewProperty(USER_VISIBLE_NAME_KEY, exemplar.Uint32, ltext.LTEXT_TYPE_ID, uint32(0x6A231EAA), uint32(0x22220000)),
+    exemplar.NewProperty(ITEM_ICON, exemplar.Uint32, uint32(0x33330000)),
+  }
+
+  return ex
+}
+
+func TestFromExemplar(t *testing.T) {
+  b := lot.NewBuilder("Sample", 1, 1)
+  b.PlaceBuilding(0x44440000, 8, 8, 8, 8, 0)
+  b.PlaceTexture(0x55550000, 0, 0, 0)
+  b.PlaceProp(0x66660000, 4, 4, 1, 1, 0)
+  lotExemplar, e := b.Lot().ToExemplar()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  refs := append(FromExemplar(buildingExemplar()), FromExemplar(lotExemplar)...)
+  expected := []string{
+    "property 0x27812821[2]: model T: 0x5AD0E817, G: 0xBADB57F1, I: 0x11110000",
+    "property 0x8A416A99[2]: single T: 0x2026960B, G: 0x6A231EAA, I: 0x22220000",
+    "property 0x8A2602B8[0]: single T: 0x856DDBAC, G: 0x6A386D26, I: 0x33330000",
+    "property 0x88EDC900[11]: single T: 0x6534284A, G: *, I: 0x44440000",
+    "property 0x88EDC901[11]: texture T: 0x7AB50E44, G: 0x0986135E, I: 0x55550000",
+    "property 0x88EDC902[12]: single T: 0x6534284A, G: *, I: 0x66660000",
+  }
+
+  if len(refs) != len(expected) {
+    t.Fatalf("Unexpected references %v", refs)
+  }
+  for i, ref := range refs {
+    if ref.String() != expected[i] {
+      t.Errorf("Expected %s, got %s", expected[i], ref)
+    }
+  }
+}
+
+func TestRefMatches(t *testing.T) {
+  model := &Ref{TypeId: 0x5AD0E817, GroupId: 0x1, InstanceId: 0x11110000, Kind: iid.Model}
+  for tgi, expected := range map[entry.DBPFEntryTGI]bool{
+    entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0x1, InstanceId: 0x11110000}: true,
+    entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0x1, InstanceId: 0x11110430}: true,
+    entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0x1, InstanceId: 0x11111000}: false,
+    entry.DBPFEntryTGI{TypeId: 0x5AD0E817, GroupId: 0x2, InstanceId: 0x11110000}: false,
+    entry.DBPFEntryTGI{TypeId: 0x7AB50E44, GroupId: 0x1, InstanceId: 0x11110000}: false,
+  } {
+    if model.Matches(&tgi) != expected {
+      t.Errorf("Expected Matches(%s) to be %v", &tgi, expected)
+    }
+  }
+
+  any := &Ref{TypeId: 0x6534284A, AnyGroup: true, InstanceId: 0xFFFFFFFF, Kind: iid.Single}
+  if !any.Matches(&entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x9, InstanceId: 0xFFFFFFFF}) {
+    t.Error("Expected a reference without a group to match any group")
+  }
+}
+
+func TestRefSet(t *testing.T) {
+  ex := buildingExemplar()
+  refs := FromExemplar(ex)
+
+  if e := refs[0].Set(ex, 0x77770000); e != nil {
+    t.Fatal(e)
+  }
+  if v := ex.Property(RESOURCE_KEY_TYPE_1).Values[2]; v != uint32(0x77770000) {
+    t.Errorf("Unexpected value %v", v)
+  }
+
+  ex.Property(ITEM_ICON).Values[0] = "text"
+  if e := refs[2].Set(ex, 0x1); e == nil {
+    t.Error("Expected an error for a value that isn't an instance ID")
+  }
+
+  ex.RemoveProperty(USER_VISIBLE_NAME_KEY)
+  if e := refs[1].Set(ex, 0x1); e == nil {
+    t.Error("Expected an error for a missing property")
+  }
+}
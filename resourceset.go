@@ -0,0 +1,348 @@
+package godbpf
+
+import (
+  "container/list"
+  "fmt"
+  "io"
+  "sort"
+  "sync"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// Mount is a DBPF added to a ResourceSet.  Its entries are either held in
+// memory by a DBPF, or read when needed from a ReaderAt.
+type Mount struct {
+  // Name identifies the mount, usually with the path of its file.
+  Name string
+
+  // Priority orders the mounts: entries of mounts with a higher priority
+  // shadow those of mounts with a lower one.  Among mounts of equal priority,
+  // the one mounted last wins, as with files loaded later by the game.
+  Priority int
+
+  // seq is the position of the mount in the order of the calls to Mount.
+  seq int
+
+  // reader holds the data of the entries of mounts added with MountReader.
+  reader io.ReaderAt
+}
+
+// Resource is an entry of a mount of a ResourceSet.
+type Resource struct {
+  TGI entry.DBPFEntryTGI
+  Mount *Mount
+
+  // Compressed indicates that the entry is stored compressed.
+  Compressed bool
+
+  set *ResourceSet
+  dbpfEntry *entry.DBPFEntry
+  index *IndexEntry
+}
+
+// ResourceSet is a single namespace of resources assembled from many DBPFs, as
+// the game sees the base .dat files and the plugins.  When several mounts hold
+// the same TGI, the one with the highest priority wins and the others are
+// shadowed.  Decoded resources are kept in a cache holding a limited number of
+// them, dropping the least recently used one first.
+//
+// Mounting isn't safe while other goroutines use the ResourceSet, but once
+// every DBPF is mounted, resources can be looked up and decoded from several
+// goroutines at once.
+type ResourceSet struct {
+  mounts []*Mount
+  resources map[entry.DBPFEntryTGI][]*Resource
+
+  mutex sync.Mutex
+  cacheSize int
+  lru *list.List
+  cached map[cacheKey]*list.Element
+}
+
+// cacheKey identifies a decoded resource in the cache of a ResourceSet.  Kind
+// distinguishes the decoders used for the same resource, the uncompressed data
+// being cached with an empty kind.
+type cacheKey struct {
+  resource *Resource
+  kind string
+}
+
+// cacheItem is an element of the cache of a ResourceSet.
+type cacheItem struct {
+  key cacheKey
+  value interface{}
+}
+
+// NewResourceSet creates an empty ResourceSet whose cache holds up to the
+// provided number of decoded resources.  A size of 0 disables the cache.
+func NewResourceSet(cacheSize int) *ResourceSet {
+  return &ResourceSet{
+    resources: make(map[entry.DBPFEntryTGI][]*Resource),
+    cacheSize: cacheSize,
+    lru: list.New(),
+    cached: make(map[cacheKey]*list.Element),
+  }
+}
+
+// Mount adds the entries of the provided DBPF to the receiver.  The DIR entry
+// isn't part of the namespace.  The receiver works on a Snapshot of the DBPF,
+// so later changes to the DBPF aren't seen.
+func (rs *ResourceSet) Mount(name string, priority int, dbpf *DBPF) *Mount {
+  m := rs.newMount(name, priority)
+  dbpf = dbpf.Snapshot()
+
+  dirEntry := dbpf.Find(entry.DIR_ENTRY_TGI)
+  for _, e := range dbpf.Entries() {
+    if e.TGI.Equals(entry.DIR_ENTRY_TGI) {
+      continue
+    }
+
+    r := &Resource{TGI: *e.TGI, Mount: m, set: rs, dbpfEntry: e}
+    if dirEntry != nil {
+      _, r.Compressed = dirEntry.GetUncompressedSize(e.TGI)
+    }
+    rs.add(r)
+  }
+
+  return m
+}
+
+// MountReader adds the entries of the DBPF file of the provided size read from
+// the provided ReaderAt to the receiver.  Only its header and index are read
+// here; the data of an entry is read when it is first needed, so the ReaderAt
+// must remain usable as long as the receiver is.
+func (rs *ResourceSet) MountReader(name string, priority int, r io.ReaderAt, size int64) (*Mount, error) {
+  _, entries, e := ReadIndex(r, size)
+  if e != nil {
+    return nil, fmt.Errorf("%s: %v", name, e)
+  }
+
+  m := rs.newMount(name, priority)
+  m.reader = r
+
+  for _, ie := range entries {
+    if ie.TGI.Equals(entry.DIR_ENTRY_TGI) {
+      continue
+    }
+
+    rs.add(&Resource{TGI: ie.TGI, Mount: m, Compressed: ie.Compressed, set: rs, index: ie})
+  }
+
+  return m, nil
+}
+
+// newMount creates a mount of the receiver.
+func (rs *ResourceSet) newMount(name string, priority int) *Mount {
+  m := &Mount{Name: name, Priority: priority, seq: len(rs.mounts)}
+  rs.mounts = append(rs.mounts, m)
+
+  return m
+}
+
+// shadows checks if the receiver wins over the provided mount.
+func (m *Mount) shadows(other *Mount) bool {
+  if m.Priority != other.Priority {
+    return m.Priority > other.Priority
+  }
+
+  return m.seq > other.seq
+}
+
+// add inserts the provided resource among those with the same TGI, which are
+// kept with the winning one first.  Within a mount holding the same TGI more
+// than once, the first entry wins, as with DBPF.Find.
+func (rs *ResourceSet) add(r *Resource) {
+  stack := rs.resources[r.TGI]
+  i := sort.Search(len(stack), func(i int) bool {
+    return r.Mount.shadows(stack[i].Mount)
+  })
+
+  stack = append(stack, nil)
+  copy(stack[i + 1:], stack[i:])
+  stack[i] = r
+  rs.resources[r.TGI] = stack
+}
+
+// Mounts returns the mounts of the receiver, from the one with the lowest
+// precedence to the one with the highest.
+func (rs *ResourceSet) Mounts() []*Mount {
+  mounts := append([]*Mount(nil), rs.mounts...)
+  sort.Slice(mounts, func(i, j int) bool {
+    return mounts[j].shadows(mounts[i])
+  })
+
+  return mounts
+}
+
+// Find returns the winning resource with the provided TGI, or nil if no mount
+// holds it.
+func (rs *ResourceSet) Find(tgi *entry.DBPFEntryTGI) *Resource {
+  if stack := rs.resources[*tgi]; len(stack) > 0 {
+    return stack[0]
+  }
+
+  return nil
+}
+
+// FindAll returns every resource with the provided TGI, the winning one first
+// followed by those it shadows.
+func (rs *ResourceSet) FindAll(tgi *entry.DBPFEntryTGI) []*Resource {
+  return append([]*Resource(nil), rs.resources[*tgi]...)
+}
+
+// Select returns the winning resources whose TGI matches the provided function,
+// sorted by TGI.
+func (rs *ResourceSet) Select(match func(tgi *entry.DBPFEntryTGI) bool) []*Resource {
+  var result []*Resource
+  for tgi, stack := range rs.resources {
+    if match(&tgi) {
+      result = append(result, stack[0])
+    }
+  }
+
+  sort.Slice(result, func(i, j int) bool {
+    return result[i].TGI.Less(&result[j].TGI)
+  })
+
+  return result
+}
+
+// FindByType returns the winning resources with the provided TypeId, sorted by
+// TGI.
+func (rs *ResourceSet) FindByType(typeId uint32) []*Resource {
+  return rs.Select(func(tgi *entry.DBPFEntryTGI) bool {
+    return tgi.TypeId == typeId
+  })
+}
+
+// Shadowed returns the resources that are shadowed by another one, grouped by
+// TGI with the winning resource first, sorted by TGI.
+func (rs *ResourceSet) Shadowed() [][]*Resource {
+  var result [][]*Resource
+  for _, stack := range rs.resources {
+    if len(stack) > 1 {
+      result = append(result, append([]*Resource(nil), stack...))
+    }
+  }
+
+  sort.Slice(result, func(i, j int) bool {
+    return result[i][0].TGI.Less(&result[j][0].TGI)
+  })
+
+  return result
+}
+
+// GetUncompressedData returns the data of the winning resource with the
+// provided TGI.
+func (rs *ResourceSet) GetUncompressedData(tgi *entry.DBPFEntryTGI) ([]byte, error) {
+  r := rs.Find(tgi)
+  if r == nil {
+    return nil, fmt.Errorf("No entry found with TGI {%s}", tgi)
+  }
+
+  return r.Data()
+}
+
+// Data returns the uncompressed data of the receiver.  The returned slice is
+// shared with the cache and must not be modified.
+func (r *Resource) Data() ([]byte, error) {
+  value, e := r.Decode("", func(data []byte) (interface{}, error) {
+    return data, nil
+  })
+  if e != nil {
+    return nil, e
+  }
+
+  return value.([]byte), nil
+}
+
+// Decode returns the result of the provided decoder applied to the
+// uncompressed data of the receiver.  Results are cached under the provided
+// kind, which should name the decoder, so that each kind of decoding happens
+// once per resource while it stays in the cache.  Errors aren't cached.
+func (r *Resource) Decode(kind string, decode func(data []byte) (interface{}, error)) (interface{}, error) {
+  key := cacheKey{r, kind}
+  if value, found := r.set.lookup(key); found {
+    return value, nil
+  }
+
+  data, e := r.read()
+  if e != nil {
+    return nil, fmt.Errorf("%s: %v", r.Mount.Name, e)
+  }
+
+  value, e := decode(data)
+  if e != nil {
+    return nil, fmt.Errorf("%s: entry {%s}: %v", r.Mount.Name, &r.TGI, e)
+  }
+
+  r.set.store(key, value)
+  return value, nil
+}
+
+// read returns the uncompressed data of the receiver from its mount.
+func (r *Resource) read() ([]byte, error) {
+  if r.index != nil {
+    return ReadEntry(r.Mount.reader, r.index)
+  }
+
+  if !r.Compressed {
+    return r.dbpfEntry.GetData(), nil
+  }
+
+  data, e := Decompress(r.dbpfEntry.GetData())
+  if e != nil {
+    return nil, fmt.Errorf("Compressed entry {%s}: %v", &r.TGI, e)
+  }
+
+  return data, nil
+}
+
+// lookup returns the cached value with the provided key, marking it as the
+// most recently used.
+func (rs *ResourceSet) lookup(key cacheKey) (interface{}, bool) {
+  rs.mutex.Lock()
+  defer rs.mutex.Unlock()
+
+  elem, found := rs.cached[key]
+  if !found {
+    return nil, false
+  }
+
+  rs.lru.MoveToFront(elem)
+  return elem.Value.(*cacheItem).value, true
+}
+
+// store adds a value to the cache, dropping the least recently used ones once
+// it is full.
+func (rs *ResourceSet) store(key cacheKey, value interface{}) {
+  if rs.cacheSize <= 0 {
+    return
+  }
+
+  rs.mutex.Lock()
+  defer rs.mutex.Unlock()
+
+  if elem, found := rs.cached[key]; found {
+    elem.Value.(*cacheItem).value = value
+    rs.lru.MoveToFront(elem)
+    return
+  }
+
+  rs.cached[key] = rs.lru.PushFront(&cacheItem{key, value})
+  for rs.lru.Len() > rs.cacheSize {
+    oldest := rs.lru.Back()
+    rs.lru.Remove(oldest)
+    delete(rs.cached, oldest.Value.(*cacheItem).key)
+  }
+}
+
+// CacheLen returns the number of decoded resources held in the cache of the
+// receiver.
+func (rs *ResourceSet) CacheLen() int {
+  rs.mutex.Lock()
+  defer rs.mutex.Unlock()
+
+  return rs.lru.Len()
+}
@@ -0,0 +1,151 @@
+package godbpf
+
+import (
+  "bytes"
+  "errors"
+  "testing"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func resourceDBPF(data map[uint32]string, compressed bool) *DBPF {
+  dbpf := New()
+  dbpf.MajorVersion = 1
+  dbpf.IndexMajorVersion = 7
+  for iid := uint32(1); iid <= 4; iid++ {
+    text, found := data[iid]
+    if !found {
+      continue
+    }
+
+    tgi := &entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: iid}
+    if compressed {
+      dbpf.AddCompressedEntry(tgi, []byte(text))
+    } else {
+      e := entry.NewEntry(tgi)
+      e.SetData([]byte(text))
+      dbpf.AddEntry(e)
+    }
+  }
+
+  return dbpf
+}
+
+func TestResourceSetPriority(t *testing.T) {
+  base := resourceDBPF(map[uint32]string{1: "base 1", 2: "base 2", 3: "base 3"}, true)
+  plugin := resourceDBPF(map[uint32]string{2: "plugin 2", 3: "plugin 3"}, false)
+  later := resourceDBPF(map[uint32]string{3: "later 3", 4: "later 4"}, true)
+
+  buf := new(bytes.Buffer)
+  if e := later.Save(buf); e != nil {
+    t.Fatal(e)
+  }
+
+  rs := NewResourceSet(10)
+  rs.Mount("plugin.dat", 1, plugin)
+  rs.Mount("SimCity_1.dat", 0, base)
+  if _, e := rs.MountReader("later.dat", 1, bytes.NewReader(buf.Bytes()), int64(buf.Len())); e != nil {
+    t.Fatal(e)
+  }
+
+  mounts := rs.Mounts()
+  if len(mounts) != 3 || mounts[0].Name != "SimCity_1.dat" || mounts[1].Name != "plugin.dat" || mounts[2].Name != "later.dat" {
+    t.Errorf("Unexpected mounts %v", mounts)
+  }
+
+  expected := map[uint32][]string{1: {"base 1"}, 2: {"plugin 2", "base 2"}, 3: {"later 3", "plugin 3", "base 3"}, 4: {"later 4"}}
+  for iid, texts := range expected {
+    tgi := &entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: iid}
+    all := rs.FindAll(tgi)
+    if len(all) != len(texts) || rs.Find(tgi) != all[0] {
+      t.Fatalf("Unexpected resources for 0x%X: %v", iid, all)
+    }
+
+    for i, r := range all {
+      if data, e := r.Data(); e != nil || string(data) != texts[i] {
+        t.Errorf("Expected %q for 0x%X, got %q, %v", texts[i], iid, data, e)
+      }
+    }
+  }
+
+  if r := rs.Find(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x9}); r != nil {
+    t.Errorf("Expected no resource, got %v", r)
+  }
+  if _, e := rs.GetUncompressedData(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x9}); e == nil {
+    t.Error("Expected an error for a missing resource")
+  }
+
+  if resources := rs.FindByType(0x1); len(resources) != 4 || resources[0].TGI.InstanceId != 1 || resources[3].Mount.Name != "later.dat" {
+    t.Errorf("Unexpected resources %v", resources)
+  }
+  if resources := rs.FindByType(entry.DIR_ENTRY_TGI.TypeId); len(resources) != 0 {
+    t.Errorf("Expected the DIR entries to be left out, got %v", resources)
+  }
+
+  shadowed := rs.Shadowed()
+  if len(shadowed) != 2 || len(shadowed[0]) != 2 || shadowed[0][0].Mount.Name != "plugin.dat" || len(shadowed[1]) != 3 {
+    t.Errorf("Unexpected shadowed resources %v", shadowed)
+  }
+}
+
+func TestResourceSetCache(t *testing.T) {
+  rs := NewResourceSet(2)
+  rs.Mount("a.dat", 0, resourceDBPF(map[uint32]string{1: "one", 2: "two", 3: "three"}, true))
+
+  decodes := 0
+  upper := func(data []byte) (interface{}, error) {
+    decodes++
+    return string(bytes.ToUpper(data)), nil
+  }
+
+  r1 := rs.Find(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x1})
+  r2 := rs.Find(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x2})
+  r3 := rs.Find(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x3})
+
+  for i := 0; i < 2; i++ {
+    if value, e := r1.Decode("upper", upper); e != nil || value != "ONE" {
+      t.Errorf("Unexpected value %v, %v", value, e)
+    }
+  }
+  if decodes != 1 {
+    t.Errorf("Expected a single decoding, got %d", decodes)
+  }
+
+  // Decoding r2 and then r1 again leaves r2 as the least recently used value,
+  // which is dropped when r3 is decoded.
+  r2.Decode("upper", upper)
+  r1.Decode("upper", upper)
+  r3.Decode("upper", upper)
+  if rs.CacheLen() != 2 || decodes != 3 {
+    t.Errorf("Unexpected cache of %d values after %d decodings", rs.CacheLen(), decodes)
+  }
+
+  r1.Decode("upper", upper)
+  r2.Decode("upper", upper)
+  if decodes != 4 {
+    t.Errorf("Expected only r2 to be decoded again, got %d decodings", decodes)
+  }
+
+  failing := func(data []byte) (interface{}, error) {
+    return nil, errors.New("Bad data")
+  }
+  if _, e := r1.Decode("failing", failing); e == nil {
+    t.Error("Expected the error of the decoder")
+  }
+
+  uncached := NewResourceSet(0)
+  uncached.Mount("a.dat", 0, resourceDBPF(map[uint32]string{1: "one"}, false))
+  if data, e := uncached.GetUncompressedData(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x1}); e != nil || string(data) != "one" || uncached.CacheLen() != 0 {
+    t.Errorf("Unexpected data %q, %v with %d cached values", data, e, uncached.CacheLen())
+  }
+}
+
+func TestResourceSetMountReaderError(t *testing.T) {
+  rs := NewResourceSet(1)
+  if _, e := rs.MountReader("bad.dat", 0, bytes.NewReader([]byte("not a DBPF file")), 15); e == nil {
+    t.Error("Expected an error for an invalid file")
+  }
+  if len(rs.Mounts()) != 0 {
+    t.Error("Expected the invalid file not to be mounted")
+  }
+}
@@ -0,0 +1,475 @@
+package rul
+
+import (
+  "bufio"
+  "bytes"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// RUL_TYPE_ID is the TypeId used by network rule entries.
+const RUL_TYPE_ID uint32 = 0x0A5BCF4B
+
+// Line is a single line of a RUL file.  It is one of *Blank, *Comment,
+// *Section, *Property, *Override or *Text.
+type Line interface {
+  // format returns the text of the line.  Lines that haven't been modified
+  // since they were parsed are returned exactly as they were read.
+  format() string
+}
+
+// Blank is an empty line, possibly holding whitespace.
+type Blank struct {
+  raw string
+}
+
+func (l *Blank) format() string {
+  return l.raw
+}
+
+// Comment is a line holding only a comment.
+type Comment struct {
+  // Text is the comment, without the leading ';'.
+  Text string
+
+  raw string
+  orig string
+}
+
+// NewComment creates a Comment line.
+func NewComment(text string) *Comment {
+  return &Comment{Text: text}
+}
+
+func (l *Comment) format() string {
+  if l.raw != "" && l.Text == l.orig {
+    return l.raw
+  }
+
+  return ";" + l.Text
+}
+
+// Section is a section header, such as [HighwayIntersectionInfo_0x00000001].
+type Section struct {
+  // Name is the name of the section, without the brackets.
+  Name string
+
+  // Comment is the trailing comment of the line, without the leading ';'.
+  Comment string
+
+  raw string
+  origName, origComment string
+}
+
+// NewSection creates a Section line.
+func NewSection(name string) *Section {
+  return &Section{Name: name}
+}
+
+func (l *Section) format() string {
+  if l.raw != "" && l.Name == l.origName && l.Comment == l.origComment {
+    return l.raw
+  }
+
+  return "[" + l.Name + "]" + formatComment(l.Comment)
+}
+
+// Property is a key and value line, such as CheckType = a - road: 0x02000200.
+type Property struct {
+  // Key and Value are the trimmed text on each side of the first '='.
+  Key, Value string
+
+  // Comment is the trailing comment of the line, without the leading ';'.
+  Comment string
+
+  raw string
+  origKey, origValue, origComment string
+}
+
+// NewProperty creates a Property line.
+func NewProperty(key, value string) *Property {
+  return &Property{Key: key, Value: value}
+}
+
+func (l *Property) format() string {
+  if l.raw != "" && l.Key == l.origKey && l.Value == l.origValue && l.Comment == l.origComment {
+    return l.raw
+  }
+
+  return l.Key + "=" + l.Value + formatComment(l.Comment)
+}
+
+// Tile is a network tile in an override rule: an instance ID with its rotation
+// and flip.
+type Tile struct {
+  IID uint32
+  Rotation uint8
+  Flip uint8
+}
+
+// String returns the text form of the receiver.
+func (t Tile) String() string {
+  return fmt.Sprintf("0x%08X,%d,%d", t.IID, t.Rotation, t.Flip)
+}
+
+// Override is an override rule: when the tiles in From are found next to each
+// other, they are replaced with the tiles in To.  Each side of the line starts
+// with a rule type, as in
+// 1,0x5D540000,0,0,0x5D540000,2,0=1,0x5D540100,0,0,0x5D540100,2,0.
+type Override struct {
+  // FromType and ToType are the rule types leading each side of the line.
+  FromType, ToType uint8
+
+  From, To []Tile
+
+  // Comment is the trailing comment of the line, without the leading ';'.
+  Comment string
+
+  raw string
+  origFromType, origToType uint8
+  origFrom, origTo []Tile
+  origComment string
+}
+
+// NewOverride creates an Override line whose sides both have the provided rule
+// type.
+func NewOverride(ruleType uint8, from, to []Tile) *Override {
+  return &Override{FromType: ruleType, ToType: ruleType, From: from, To: to}
+}
+
+func (l *Override) format() string {
+  if l.raw != "" && l.FromType == l.origFromType && l.ToType == l.origToType && tilesEqual(l.From, l.origFrom) && tilesEqual(l.To, l.origTo) && l.Comment == l.origComment {
+    return l.raw
+  }
+
+  return formatSide(l.FromType, l.From) + "=" + formatSide(l.ToType, l.To) + formatComment(l.Comment)
+}
+
+// Text is any other line, which is kept as is.
+type Text struct {
+  Raw string
+}
+
+func (l *Text) format() string {
+  return l.Raw
+}
+
+// File holds the lines of a RUL file in order.
+type File struct {
+  Lines []Line
+
+  // lineEnding is the line ending used by the file, either "\n" or "\r\n".
+  lineEnding string
+
+  // finalNewline indicates whether the last line ends with a line ending.
+  finalNewline bool
+}
+
+// New creates an empty RUL file.
+func New() *File {
+  return &File{lineEnding: "\r\n", finalNewline: true}
+}
+
+// Parse reads a RUL file from the provided Reader.
+func Parse(r io.Reader) (*File, error) {
+  data, e := readAll(r)
+  if e != nil {
+    return nil, e
+  }
+
+  f := &File{lineEnding: "\n", finalNewline: true}
+  if bytes.Contains(data, []byte("\r\n")) {
+    f.lineEnding = "\r\n"
+  }
+
+  if len(data) == 0 {
+    return f, nil
+  }
+  f.finalNewline = bytes.HasSuffix(data, []byte("\n"))
+
+  scanner := bufio.NewScanner(bytes.NewReader(data))
+  scanner.Buffer(make([]byte, 64 * 1024), len(data) + 1)
+  for scanner.Scan() {
+    f.Lines = append(f.Lines, parseLine(strings.TrimSuffix(scanner.Text(), "\r")))
+  }
+
+  return f, scanner.Err()
+}
+
+// readAll reads everything from the provided Reader.
+func readAll(r io.Reader) ([]byte, error) {
+  buf := new(bytes.Buffer)
+  _, e := buf.ReadFrom(r)
+  return buf.Bytes(), e
+}
+
+// parseLine determines the kind of the provided line and parses it.
+func parseLine(raw string) Line {
+  trimmed := strings.TrimSpace(raw)
+  if trimmed == "" {
+    return &Blank{raw: raw}
+  }
+
+  if strings.HasPrefix(trimmed, ";") {
+    text := trimmed[1:]
+    return &Comment{Text: text, raw: raw, orig: text}
+  }
+
+  content, comment := splitComment(trimmed)
+
+  if strings.HasPrefix(content, "[") && strings.HasSuffix(content, "]") {
+    name := content[1:len(content) - 1]
+    return &Section{Name: name, Comment: comment, raw: raw, origName: name, origComment: comment}
+  }
+
+  eq := strings.Index(content, "=")
+  if eq < 0 {
+    return &Text{Raw: raw}
+  }
+
+  left, right := content[:eq], content[eq + 1:]
+  if fromType, from, ok := parseSide(left); ok {
+    if toType, to, ok := parseSide(right); ok {
+      return &Override{
+        FromType: fromType, ToType: toType, From: from, To: to, Comment: comment,
+        raw: raw, origFromType: fromType, origToType: toType, origFrom: copyTiles(from), origTo: copyTiles(to), origComment: comment,
+      }
+    }
+  }
+
+  key, value := strings.TrimSpace(left), strings.TrimSpace(right)
+  return &Property{Key: key, Value: value, Comment: comment, raw: raw, origKey: key, origValue: value, origComment: comment}
+}
+
+// splitComment separates the trailing comment, if any, from the content of a
+// line.
+func splitComment(line string) (content, comment string) {
+  if i := strings.Index(line, ";"); i >= 0 {
+    return strings.TrimSpace(line[:i]), line[i + 1:]
+  }
+
+  return line, ""
+}
+
+// formatComment returns the text of a trailing comment.
+func formatComment(comment string) string {
+  if comment == "" {
+    return ""
+  }
+
+  return ";" + comment
+}
+
+// parseSide parses a side of an override rule: a comma separated list holding
+// the rule type followed by IID, rotation and flip triplets.
+func parseSide(s string) (uint8, []Tile, bool) {
+  fields := strings.Split(s, ",")
+  if len(fields) < 4 {
+    return 0, nil, false
+  }
+
+  ruleType, e := strconv.ParseUint(strings.TrimSpace(fields[0]), 0, 8)
+  if e != nil {
+    return 0, nil, false
+  }
+
+  tiles, ok := parseTiles(fields[1:])
+  return uint8(ruleType), tiles, ok
+}
+
+// parseTiles parses a list of IID, rotation and flip triplets.
+func parseTiles(fields []string) ([]Tile, bool) {
+  if len(fields) % 3 != 0 {
+    return nil, false
+  }
+
+  tiles := make([]Tile, 0, len(fields) / 3)
+  for i := 0; i < len(fields); i += 3 {
+    iid, e := strconv.ParseUint(strings.TrimSpace(fields[i]), 0, 32)
+    if e != nil {
+      return nil, false
+    }
+
+    rotation, e := strconv.ParseUint(strings.TrimSpace(fields[i + 1]), 0, 8)
+    if e != nil || rotation > 3 {
+      return nil, false
+    }
+
+    flip, e := strconv.ParseUint(strings.TrimSpace(fields[i + 2]), 0, 8)
+    if e != nil || flip > 1 {
+      return nil, false
+    }
+
+    tiles = append(tiles, Tile{IID: uint32(iid), Rotation: uint8(rotation), Flip: uint8(flip)})
+  }
+
+  return tiles, true
+}
+
+// formatSide formats a side of an override rule as a comma separated list.
+func formatSide(ruleType uint8, tiles []Tile) string {
+  parts := []string{strconv.Itoa(int(ruleType))}
+  for _, t := range tiles {
+    parts = append(parts, t.String())
+  }
+
+  return strings.Join(parts, ",")
+}
+
+// tilesEqual compares two lists of tiles.
+func tilesEqual(a, b []Tile) bool {
+  if len(a) != len(b) {
+    return false
+  }
+
+  for i := range a {
+    if a[i] != b[i] {
+      return false
+    }
+  }
+
+  return true
+}
+
+// copyTiles returns a copy of the provided list of tiles.
+func copyTiles(tiles []Tile) []Tile {
+  result := make([]Tile, len(tiles))
+  copy(result, tiles)
+  return result
+}
+
+// Format writes the receiver to the provided Writer.  Lines that haven't been
+// modified are written exactly as they were parsed.
+func (f *File) Format(w io.Writer) error {
+  buf := new(bytes.Buffer)
+  for i, l := range f.Lines {
+    buf.WriteString(l.format())
+    if i < len(f.Lines) - 1 || f.finalNewline {
+      buf.WriteString(f.lineEnding)
+    }
+  }
+
+  _, e := buf.WriteTo(w)
+  return e
+}
+
+// String returns the text of the receiver.
+func (f *File) String() string {
+  buf := new(bytes.Buffer)
+  f.Format(buf)
+  return buf.String()
+}
+
+// Sections returns the section headers of the receiver in order.
+func (f *File) Sections() []*Section {
+  var result []*Section
+  for _, l := range f.Lines {
+    if s, ok := l.(*Section); ok {
+      result = append(result, s)
+    }
+  }
+
+  return result
+}
+
+// Overrides returns the override rules of the receiver in order.
+func (f *File) Overrides() []*Override {
+  var result []*Override
+  for _, l := range f.Lines {
+    if o, ok := l.(*Override); ok {
+      result = append(result, o)
+    }
+  }
+
+  return result
+}
+
+// sectionRange returns the index of the header of the named section and the
+// index of the line that ends it.  The header index is -1 if the section isn't
+// found.
+func (f *File) sectionRange(name string) (int, int) {
+  start := -1
+  for i, l := range f.Lines {
+    s, ok := l.(*Section)
+    if !ok {
+      continue
+    }
+
+    if start >= 0 {
+      return start, i
+    }
+
+    if strings.EqualFold(s.Name, name) {
+      start = i
+    }
+  }
+
+  return start, len(f.Lines)
+}
+
+// Get returns the first property with the provided key in the named section.
+// Keys and section names are matched without regard to case.
+func (f *File) Get(section, key string) *Property {
+  start, end := f.sectionRange(section)
+  if start < 0 {
+    return nil
+  }
+
+  for _, l := range f.Lines[start + 1:end] {
+    if p, ok := l.(*Property); ok && strings.EqualFold(p.Key, key) {
+      return p
+    }
+  }
+
+  return nil
+}
+
+// Set updates the value of the first property with the provided key in the
+// named section.  If the section doesn't have that property, it is added after
+// the last non blank line of the section.  If the section doesn't exist, it is
+// added at the end of the receiver.
+func (f *File) Set(section, key, value string) {
+  if p := f.Get(section, key); p != nil {
+    p.Value = value
+    return
+  }
+
+  start, end := f.sectionRange(section)
+  if start < 0 {
+    f.Lines = append(f.Lines, NewSection(section), NewProperty(key, value))
+    return
+  }
+
+  insert := end
+  for insert > start + 1 {
+    if _, blank := f.Lines[insert - 1].(*Blank); !blank {
+      break
+    }
+    insert--
+  }
+
+  f.Lines = append(f.Lines, nil)
+  copy(f.Lines[insert + 1:], f.Lines[insert:])
+  f.Lines[insert] = NewProperty(key, value)
+}
+
+// Read parses the RUL entry identified by the provided DBPFEntryTGI.
+func Read(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI) (*File, error) {
+  data, e := dbpf.GetUncompressedData(tgi)
+  if e != nil {
+    return nil, e
+  }
+
+  return Parse(bytes.NewReader(data))
+}
+
+// Replace formats the provided file and stores it in the entry identified by
+// the provided DBPFEntryTGI, which keeps its position and compression state.
+func Replace(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI, f *File) error {
+  return dbpf.SetUncompressedData(tgi, []byte(f.String()))
+}
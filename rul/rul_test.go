@@ -0,0 +1,186 @@
+package rul
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+const sampleRUL0 = "; Sample puzzle pieces\r\n" +
+  "[HighwayIntersectionInfo_0x00000001]\r\n" +
+  "Piece = 0.0, 0.0, 0, 0, 0x02001500\r\n" +
+  "CellLayout =abc   ; first row\r\n" +
+  "CheckType = a - road: 0x02000200\r\n" +
+  "OneWayDir = 0\r\n" +
+  "\r\n" +
+  "[HighwayIntersectionInfo_0x00000002]\r\n" +
+  "Piece = 16.0, 0.0, 0, 0, 0x02001600\r\n"
+
+const sampleRUL2 = ";Road overrides\n" +
+  "1,0x5D540000,0,0,0x5D540000,2,0=1,0x5D540100,0,0,0x5d540100,2,0 ;straight\n" +
+  "  \n" +
+  "2,0x5D73A000,1,0,0x5D73A000,3,0=2,0x5D73A100,1,0,0x5D73A000,3,0\n" +
+  "RotFlip\n"
+
+func TestParseRUL0(t *testing.T) {
+  f, e := Parse(strings.NewReader(sampleRUL0))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(f.Lines) != 9 || len(f.Sections()) != 2 || len(f.Overrides()) != 0 {
+    t.Fatalf("Unexpected file %+v", f.Lines)
+  }
+
+  if c, ok := f.Lines[0].(*Comment); !ok || c.Text != " Sample puzzle pieces" {
+    t.Errorf("Unexpected comment %+v", f.Lines[0])
+  }
+
+  p := f.Get("highwayintersectioninfo_0x00000001", "celllayout")
+  if p == nil || p.Key != "CellLayout" || p.Value != "abc" || p.Comment != " first row" {
+    t.Errorf("Unexpected property %+v", p)
+  }
+
+  if p := f.Get("HighwayIntersectionInfo_0x00000002", "CheckType"); p != nil {
+    t.Error()
+  }
+
+  if f.String() != sampleRUL0 {
+    t.Errorf("Expected:\n%q\nbut was:\n%q", sampleRUL0, f.String())
+  }
+}
+
+func TestParseRUL2(t *testing.T) {
+  f, e := Parse(strings.NewReader(sampleRUL2))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  overrides := f.Overrides()
+  if len(overrides) != 2 {
+    t.Fatalf("Expected 2 overrides but found %d", len(overrides))
+  }
+
+  o := overrides[0]
+  if o.FromType != 1 || o.ToType != 1 || len(o.From) != 2 || len(o.To) != 2 || o.From[1] != (Tile{IID: 0x5D540000, Rotation: 2}) || o.To[1].IID != 0x5D540100 || o.Comment != "straight" {
+    t.Errorf("Unexpected override %+v", o)
+  }
+  if o := overrides[1]; o.FromType != 2 || o.ToType != 2 || o.From[0] != (Tile{IID: 0x5D73A000, Rotation: 1}) || o.To[0].IID != 0x5D73A100 {
+    t.Errorf("Unexpected override %+v", o)
+  }
+
+  if _, ok := f.Lines[2].(*Blank); !ok {
+    t.Error()
+  }
+
+  if _, ok := f.Lines[4].(*Text); !ok {
+    t.Error()
+  }
+
+  if f.String() != sampleRUL2 {
+    t.Errorf("Expected:\n%q\nbut was:\n%q", sampleRUL2, f.String())
+  }
+}
+
+func TestModifiedLinesAreReformatted(t *testing.T) {
+  f, e := Parse(strings.NewReader(sampleRUL2))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  f.Overrides()[1].To[0].Rotation = 3
+  f.Overrides()[1].ToType = 1
+  f.Lines[0].(*Comment).Text = "Edited"
+
+  expected := ";Edited\n" +
+    "1,0x5D540000,0,0,0x5D540000,2,0=1,0x5D540100,0,0,0x5d540100,2,0 ;straight\n" +
+    "  \n" +
+    "2,0x5D73A000,1,0,0x5D73A000,3,0=1,0x5D73A100,3,0,0x5D73A000,3,0\n" +
+    "RotFlip\n"
+
+  if f.String() != expected {
+    t.Errorf("Expected:\n%q\nbut was:\n%q", expected, f.String())
+  }
+}
+
+func TestSet(t *testing.T) {
+  f, e := Parse(strings.NewReader(sampleRUL0))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  f.Set("HighwayIntersectionInfo_0x00000001", "OneWayDir", "2")
+  f.Set("HighwayIntersectionInfo_0x00000001", "AutoTileBase", "0x0C000000")
+  f.Set("HighwayIntersectionInfo_0x00000003", "Piece", "0.0, 0.0, 0, 0, 0x02001700")
+
+  expected := "; Sample puzzle pieces\r\n" +
+    "[HighwayIntersectionInfo_0x00000001]\r\n" +
+    "Piece = 0.0, 0.0, 0, 0, 0x02001500\r\n" +
+    "CellLayout =abc   ; first row\r\n" +
+    "CheckType = a - road: 0x02000200\r\n" +
+    "OneWayDir=2\r\n" +
+    "AutoTileBase=0x0C000000\r\n" +
+    "\r\n" +
+    "[HighwayIntersectionInfo_0x00000002]\r\n" +
+    "Piece = 16.0, 0.0, 0, 0, 0x02001600\r\n" +
+    "[HighwayIntersectionInfo_0x00000003]\r\n" +
+    "Piece=0.0, 0.0, 0, 0, 0x02001700\r\n"
+
+  if f.String() != expected {
+    t.Errorf("Expected:\n%q\nbut was:\n%q", expected, f.String())
+  }
+}
+
+func TestFormatWithoutFinalNewline(t *testing.T) {
+  text := "[Section]\nKey=Value"
+
+  f, e := Parse(strings.NewReader(text))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if f.String() != text {
+    t.Errorf("Expected %q but was %q", text, f.String())
+  }
+
+  f = New()
+  f.Lines = append(f.Lines, NewSection("Section"), NewOverride(1, []Tile{{IID: 1}, {IID: 1, Rotation: 2}}, []Tile{{IID: 2, Flip: 1}, {IID: 1, Rotation: 2}}))
+  if f.String() != "[Section]\r\n1,0x00000001,0,0,0x00000001,2,0=1,0x00000002,0,1,0x00000001,2,0\r\n" {
+    t.Errorf("Unexpected text %q", f.String())
+  }
+}
+
+func TestReplace(t *testing.T) {
+  dbpf := godbpf.New()
+  tgi := &entry.DBPFEntryTGI{TypeId: RUL_TYPE_ID, GroupId: 0xAA5BCF57, InstanceId: 0x10000002}
+  other := &entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0x1, InstanceId: 0x1}
+
+  dbpf.AddCompressedEntry(tgi, []byte(sampleRUL2))
+  dbpf.AddEntry(entry.NewEntry(other))
+
+  f, e := Read(dbpf, tgi)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  f.Lines = append(f.Lines, NewOverride(1, []Tile{{IID: 0x5D540000}, {IID: 0x5D540000, Rotation: 2}}, []Tile{{IID: 0x5D540300}, {IID: 0x5D540000, Rotation: 2}}))
+  if e := Replace(dbpf, tgi, f); e != nil {
+    t.Fatal(e)
+  }
+
+  if !dbpf.IsCompressed(tgi) {
+    t.Error()
+  }
+
+  data, e := dbpf.GetUncompressedData(tgi)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if !bytes.HasSuffix(data, []byte("RotFlip\n1,0x5D540000,0,0,0x5D540000,2,0=1,0x5D540300,0,0,0x5D540000,2,0\n")) {
+    t.Errorf("Unexpected data %q", data)
+  }
+}
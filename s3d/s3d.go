@@ -0,0 +1,198 @@
+package s3d
+
+import (
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "strings"
+)
+
+// S3D_TYPE_ID is the TypeId used by 3D model entries.
+const S3D_TYPE_ID uint32 = 0x5AD0E817
+
+// TEXTURE_GROUP_ID is the GroupId of the FSH entries that hold the textures
+// used by model materials.
+const TEXTURE_GROUP_ID uint32 = 0x1ABE787D
+
+// An S3D model starts with the 3DMD signature and its total size, followed by
+// chunks that each start with a 4 character tag and their size, including
+// these 8 bytes.  The MATS chunk holds the materials:
+//
+//   uint16 major version, uint16 minor version, uint32 material count
+//   for each material:
+//     uint32 flags, uint8 alpha function, uint8 depth function,
+//     uint8 source blend, uint8 destination blend, uint16 alpha threshold,
+//     uint32 material class, uint8 reserved, uint8 texture count
+//     for each texture:
+//       uint32 texture instance ID, uint8 wrap S, uint8 wrap T,
+//       (minor version 5 and above) uint8 magnification filter,
+//       uint8 minification filter,
+//       uint16 animation rate, uint16 animation mode,
+//       uint8 name length, name
+
+// Texture is a texture used by a material of a model.
+type Texture struct {
+  // InstanceId is the InstanceId of the FSH entry holding the texture, in the
+  // TEXTURE_GROUP_ID group.
+  InstanceId uint32
+
+  // Name is the name of the texture, which is often empty.
+  Name string
+
+  // offset is the position of the InstanceId in the model.
+  offset int
+}
+
+// Material is a material of a model.
+type Material struct {
+  Flags uint32
+  Class uint32
+  Textures []*Texture
+}
+
+// reader reads little endian values from a byte slice, remembering the first
+// error.
+type reader struct {
+  data []byte
+  pos int
+  e error
+}
+
+// next returns the next n bytes, or nil once the data runs out.
+func (r *reader) next(n int) []byte {
+  if r.e != nil {
+    return nil
+  }
+  if n < 0 || r.pos + n > len(r.data) {
+    r.e = errors.New("S3D data ends unexpectedly")
+    return nil
+  }
+
+  result := r.data[r.pos:r.pos + n]
+  r.pos += n
+  return result
+}
+
+func (r *reader) uint8() uint8 {
+  if b := r.next(1); b != nil {
+    return b[0]
+  }
+  return 0
+}
+
+func (r *reader) uint16() uint16 {
+  if b := r.next(2); b != nil {
+    return binary.LittleEndian.Uint16(b)
+  }
+  return 0
+}
+
+func (r *reader) uint32() uint32 {
+  if b := r.next(4); b != nil {
+    return binary.LittleEndian.Uint32(b)
+  }
+  return 0
+}
+
+// Materials returns the materials of the provided model.  Models without a
+// MATS chunk have no materials.
+func Materials(data []byte) ([]*Material, error) {
+  if len(data) < 8 || string(data[0:4]) != "3DMD" {
+    return nil, errors.New("Not an S3D model")
+  }
+
+  for pos := 8; pos + 8 <= len(data); {
+    tag, size := string(data[pos:pos + 4]), int(binary.LittleEndian.Uint32(data[pos + 4:pos + 8]))
+    if size < 8 || pos + size > len(data) {
+      return nil, fmt.Errorf("Invalid size %d for chunk %q", size, tag)
+    }
+
+    if tag == "MATS" {
+      return decodeMaterials(data[pos + 8:pos + size], pos + 8)
+    }
+    pos += size
+  }
+
+  return nil, nil
+}
+
+// decodeMaterials decodes the content of a MATS chunk, found at the provided
+// position of the model.
+func decodeMaterials(data []byte, start int) ([]*Material, error) {
+  r := &reader{data: data}
+  r.uint16()
+  minor := r.uint16()
+  count := r.uint32()
+
+  var result []*Material
+  for i := uint32(0); i < count && r.e == nil; i++ {
+    m := &Material{Flags: r.uint32()}
+    r.next(6)
+    m.Class = r.uint32()
+    r.next(1)
+
+    textures := int(r.uint8())
+    for j := 0; j < textures && r.e == nil; j++ {
+      t := &Texture{offset: start + r.pos}
+      t.InstanceId = r.uint32()
+      r.next(2)
+      if minor >= 5 {
+        r.next(2)
+      }
+      r.next(4)
+      t.Name = strings.TrimRight(string(r.next(int(r.uint8()))), "\x00")
+      m.Textures = append(m.Textures, t)
+    }
+
+    result = append(result, m)
+  }
+
+  if r.e != nil {
+    return nil, r.e
+  }
+
+  return result, nil
+}
+
+// TextureIds returns the instance IDs of the textures used by the provided
+// model, without duplicates, in the order they first appear.
+func TextureIds(data []byte) ([]uint32, error) {
+  materials, e := Materials(data)
+  if e != nil {
+    return nil, e
+  }
+
+  var result []uint32
+  seen := make(map[uint32]bool)
+  for _, m := range materials {
+    for _, t := range m.Textures {
+      if !seen[t.InstanceId] {
+        seen[t.InstanceId] = true
+        result = append(result, t.InstanceId)
+      }
+    }
+  }
+
+  return result, nil
+}
+
+// SetTextureIds returns a copy of the provided model in which the instance ID
+// of each texture found in the provided map is replaced with the one it maps
+// to.
+func SetTextureIds(data []byte, ids map[uint32]uint32) ([]byte, error) {
+  materials, e := Materials(data)
+  if e != nil {
+    return nil, e
+  }
+
+  result := append([]byte(nil), data...)
+  for _, m := range materials {
+    for _, t := range m.Textures {
+      if id, found := ids[t.InstanceId]; found {
+        binary.LittleEndian.PutUint32(result[t.offset:], id)
+      }
+    }
+  }
+
+  return result, nil
+}
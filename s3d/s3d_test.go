@@ -0,0 +1,122 @@
+package s3d
+
+import (
+  "bytes"
+  "encoding/binary"
+  "testing"
+)
+
+// material describes a material written by sampleModel.
+type material struct {
+  flags uint32
+  textures []uint32
+}
+
+// sampleModel encodes a model with a HEAD chunk and a MATS chunk of the
+// provided minor version.
+func sampleModel(minor uint16, materials ...material) []byte {
+  mats := new(bytes.Buffer)
+  binary.Write(mats, binary.LittleEndian, []uint16{1, minor})
+  binary.Write(mats, binary.LittleEndian, uint32(len(materials)))
+  for _, m := range materials {
+    binary.Write(mats, binary.LittleEndian, m.flags)
+    mats.Write([]byte{1, 2, 3, 4, 0x80, 0})
+    binary.Write(mats, binary.LittleEndian, uint32(7))
+    mats.Write([]byte{0, byte(len(m.textures))})
+    for _, t := range m.textures {
+      binary.Write(mats, binary.LittleEndian, t)
+      mats.Write([]byte{0, 0})
+      if minor >= 5 {
+        mats.Write([]byte{1, 1})
+      }
+      mats.Write([]byte{0, 0, 0, 0, 5})
+      mats.WriteString("tex\x00\x00")
+    }
+  }
+
+  buf := new(bytes.Buffer)
+  buf.WriteString("3DMD")
+  binary.Write(buf, binary.LittleEndian, uint32(0))
+  buf.WriteString("HEAD")
+  binary.Write(buf, binary.LittleEndian, uint32(12))
+  buf.Write([]byte{1, 0, 5, 0})
+  buf.WriteString("MATS")
+  binary.Write(buf, binary.LittleEndian, uint32(8 + mats.Len()))
+  buf.Write(mats.Bytes())
+
+  data := buf.Bytes()
+  binary.LittleEndian.PutUint32(data[4:], uint32(len(data)))
+  return data
+}
+
+func TestMaterials(t *testing.T) {
+  for _, minor := range []uint16{1, 5} {
+    data := sampleModel(minor, material{0x1, []uint32{0x11110000}}, material{0x2, []uint32{0x22220000, 0x11110000}})
+
+    materials, e := Materials(data)
+    if e != nil {
+      t.Fatal(e)
+    }
+
+    if len(materials) != 2 || materials[1].Flags != 0x2 || materials[1].Class != 7 || len(materials[1].Textures) != 2 {
+      t.Fatalf("Unexpected materials %+v", materials)
+    }
+    if tex := materials[1].Textures[0]; tex.InstanceId != 0x22220000 || tex.Name != "tex" {
+      t.Errorf("Unexpected texture %+v", tex)
+    }
+
+    ids, e := TextureIds(data)
+    if e != nil || len(ids) != 2 || ids[0] != 0x11110000 || ids[1] != 0x22220000 {
+      t.Errorf("Unexpected texture IDs %X, %v", ids, e)
+    }
+  }
+}
+
+func TestMaterialsWithoutMATS(t *testing.T) {
+  data := sampleModel(1)
+  data = data[:20]
+
+  if materials, e := Materials(data); e != nil || len(materials) != 0 {
+    t.Errorf("Unexpected materials %v, %v", materials, e)
+  }
+}
+
+func TestMaterialsErrors(t *testing.T) {
+  data := sampleModel(1, material{0x1, []uint32{0x1}})
+
+  for name, d := range map[string][]byte{
+    "signature": []byte("XXXX\x00\x00\x00\x00"),
+    "chunk size": append(append([]byte{}, data[:12]...), 0xFF, 0xFF, 0, 0),
+    "truncated": append(append([]byte{}, data[:20]...), append([]byte("MATS\x10\x00\x00\x00"), 1, 0, 1, 0, 1, 0, 0, 0)...),
+  } {
+    if _, e := Materials(d); e == nil {
+      t.Errorf("Expected an error for the %s", name)
+    }
+  }
+}
+
+func TestSetTextureIds(t *testing.T) {
+  for _, minor := range []uint16{1, 5} {
+    data := sampleModel(minor, material{0x1, []uint32{0x11110000}}, material{0x2, []uint32{0x22220000, 0x11110000}})
+    original := append([]byte(nil), data...)
+
+    changed, e := SetTextureIds(data, map[uint32]uint32{0x11110000: 0x80000000})
+    if e != nil {
+      t.Fatal(e)
+    }
+
+    if !bytes.Equal(data, original) {
+      t.Error("Expected the provided model to be left unchanged")
+    }
+    if ids, e := TextureIds(changed); e != nil || len(ids) != 2 || ids[0] != 0x80000000 || ids[1] != 0x22220000 {
+      t.Errorf("Unexpected texture IDs %X, %v", ids, e)
+    }
+    if len(changed) != len(data) {
+      t.Errorf("Expected the size to be unchanged, got %d instead of %d", len(changed), len(data))
+    }
+  }
+
+  if _, e := SetTextureIds([]byte("model"), nil); e == nil {
+    t.Error("Expected an error for data that isn't a model")
+  }
+}
@@ -0,0 +1,40 @@
+package godbpf
+
+import (
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// Snapshot returns a DBPF holding the header fields and entries of the
+// receiver at the time of the call.  Later changes to either DBPF don't affect
+// the other, so a snapshot that no goroutine changes can be read freely,
+// including the entries returned by its Find and Entries methods, while the
+// receiver keeps changing.
+//
+// Taking a snapshot is cheap: the entries share their data with those of the
+// receiver, which is safe since data is replaced rather than changed in place.
+// The DIR entry, whose records are changed in place, is copied.
+func (dbpf *DBPF) Snapshot() *DBPF {
+  dbpf.mutex.RLock()
+  defer dbpf.mutex.RUnlock()
+
+  snapshot := New()
+  snapshot.MajorVersion, snapshot.MinorVersion = dbpf.MajorVersion, dbpf.MinorVersion
+  snapshot.UserMajorVersion, snapshot.UserMinorVersion, snapshot.Flags = dbpf.UserMajorVersion, dbpf.UserMinorVersion, dbpf.Flags
+  snapshot.IndexMajorVersion, snapshot.IndexMinorVersion = dbpf.IndexMajorVersion, dbpf.IndexMinorVersion
+  snapshot.CreatedDate, snapshot.ModifiedDate = dbpf.CreatedDate, dbpf.ModifiedDate
+
+  for elem := dbpf.entries.Front(); elem != nil; elem = elem.Next() {
+    e, ok := elem.Value.(*entry.DBPFEntry)
+    if !ok {
+      continue
+    }
+
+    clone := e.Clone()
+    if e.TGI.Equals(entry.DIR_ENTRY_TGI) {
+      clone.SetData(e.GetData())
+    }
+    snapshot.entries.PushBack(clone)
+  }
+
+  return snapshot
+}
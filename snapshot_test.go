@@ -0,0 +1,147 @@
+package godbpf
+
+import (
+  "bytes"
+  "context"
+  "fmt"
+  "sync"
+  "testing"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+// These tests are meant to be run with the race detector, as in
+// go test -race, which reports unsynchronized accesses they cause.
+
+func TestSnapshot(t *testing.T) {
+  dbpf := New()
+  dbpf.MajorVersion = 1
+  plain := &entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x1}
+  compressed := &entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x2}
+  e := entry.NewEntry(plain)
+  e.SetData([]byte("plain"))
+  dbpf.AddEntry(e)
+  dbpf.AddCompressedEntry(compressed, []byte("compressed"))
+
+  snapshot := dbpf.Snapshot()
+
+  dbpf.SetUncompressedData(plain, []byte("changed"))
+  dbpf.SetUncompressedData(compressed, []byte("changed too"))
+  dbpf.AddCompressedEntry(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x3}, []byte("added"))
+
+  if snapshot.MajorVersion != 1 || snapshot.Len() != 3 {
+    t.Errorf("Unexpected snapshot %+v", snapshot)
+  }
+  if data, e := snapshot.GetUncompressedData(plain); e != nil || string(data) != "plain" {
+    t.Errorf("Unexpected data %q, %v", data, e)
+  }
+  if data, e := snapshot.GetUncompressedData(compressed); e != nil || string(data) != "compressed" {
+    t.Errorf("Unexpected data %q, %v", data, e)
+  }
+  if size, _ := snapshot.GetDirEntry().GetUncompressedSize(compressed); size != 10 {
+    t.Errorf("Expected the DIR entry of the snapshot to be unchanged, got a size of %d", size)
+  }
+
+  first, second := new(bytes.Buffer), new(bytes.Buffer)
+  snapshot.Save(first)
+  snapshot.Snapshot().Save(second)
+  if !bytes.Equal(first.Bytes(), second.Bytes()) {
+    t.Error("Expected a snapshot of a snapshot to give the same bytes")
+  }
+}
+
+func TestConcurrentAccess(t *testing.T) {
+  dbpf := New()
+  tgi := func(i int) *entry.DBPFEntryTGI {
+    return &entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: uint32(i)}
+  }
+  dbpf.AddCompressedEntry(tgi(0), []byte("entry 0"))
+
+  var wg sync.WaitGroup
+  for w := 0; w < 4; w++ {
+    wg.Add(1)
+    go func(w int) {
+      defer wg.Done()
+      for i := 1; i <= 25; i++ {
+        id := w * 100 + i
+        if i % 2 == 0 {
+          dbpf.AddCompressedEntry(tgi(id), []byte(fmt.Sprintf("entry %d", id)))
+        } else {
+          e := entry.NewEntry(tgi(id))
+          e.SetData([]byte(fmt.Sprintf("entry %d", id)))
+          dbpf.AddEntry(e)
+        }
+        dbpf.SetUncompressedData(tgi(0), []byte(fmt.Sprintf("entry 0 changed by %d", w)))
+      }
+    }(w)
+
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := 0; i < 25; i++ {
+        if _, e := dbpf.GetUncompressedData(tgi(0)); e != nil {
+          t.Error(e)
+        }
+
+        dbpf.IsCompressed(tgi(0))
+        dbpf.Len()
+        dbpf.FindByType(0x1)
+        dbpf.Save(new(bytes.Buffer))
+
+        snapshot := dbpf.Snapshot()
+        for _, e := range snapshot.Entries() {
+          e.GetData()
+        }
+        if _, e := snapshot.DecompressAll(context.Background(), 2); e != nil {
+          t.Error(e)
+        }
+      }
+    }()
+  }
+  wg.Wait()
+
+  if dbpf.Len() != 102 {
+    t.Errorf("Expected 102 entries, got %d", dbpf.Len())
+  }
+
+  for w := 0; w < 4; w++ {
+    for i := 1; i <= 25; i++ {
+      id := w * 100 + i
+      if data, e := dbpf.GetUncompressedData(tgi(id)); e != nil || string(data) != fmt.Sprintf("entry %d", id) {
+        t.Errorf("Unexpected data %q for entry %d, %v", data, id, e)
+      }
+    }
+  }
+}
+
+func TestConcurrentViews(t *testing.T) {
+  dbpf := fsDBPF()
+  rs := NewResourceSet(2)
+  rs.Mount("a.dat", 0, dbpf)
+  fsys := FS(dbpf)
+  tgi := &entry.DBPFEntryTGI{TypeId: 0x6534284A, GroupId: 0xA8FBD372, InstanceId: 0x1}
+
+  var wg sync.WaitGroup
+  wg.Add(3)
+  go func() {
+    defer wg.Done()
+    for i := 0; i < 50; i++ {
+      dbpf.SetUncompressedData(tgi, []byte(fmt.Sprintf("changed %d", i)))
+    }
+  }()
+
+  for r := 0; r < 2; r++ {
+    go func() {
+      defer wg.Done()
+      for i := 0; i < 50; i++ {
+        if data, e := rs.GetUncompressedData(tgi); e != nil || string(data) != "Hello {{.}} and {{.}}" {
+          t.Errorf("Unexpected data %q, %v", data, e)
+        }
+        if data, e := fsys.ReadFile("6534284A/A8FBD372/00000001"); e != nil || string(data) != "Hello {{.}} and {{.}}" {
+          t.Errorf("Unexpected data %q, %v", data, e)
+        }
+      }
+    }()
+  }
+  wg.Wait()
+}
@@ -0,0 +1,176 @@
+package ui
+
+import (
+  "fmt"
+  "strings"
+)
+
+// parser splits a UI layout into its tags.
+type parser struct {
+  src string
+  pos int
+  line int
+}
+
+// errorf creates an error for the current line of the parser.
+func (p *parser) errorf(format string, args ...interface{}) error {
+  return fmt.Errorf("Line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// advance moves the parser to the provided position, counting the lines it
+// passes.
+func (p *parser) advance(pos int) {
+  p.line += strings.Count(p.src[p.pos:pos], "\n")
+  p.pos = pos
+}
+
+// nextTag returns the text preceding the next tag and the tag itself.  When
+// there are no more tags, found is false and leading holds the rest of the
+// text.
+func (p *parser) nextTag() (leading, tag string, found bool, e error) {
+  start := strings.IndexByte(p.src[p.pos:], '<')
+  if start < 0 {
+    leading = p.src[p.pos:]
+    p.advance(len(p.src))
+    return leading, "", false, nil
+  }
+
+  leading = p.src[p.pos:p.pos + start]
+  p.advance(p.pos + start)
+
+  quoted := false
+  for i := p.pos + 1; i < len(p.src); i++ {
+    switch p.src[i] {
+    case '"':
+      quoted = !quoted
+    case '>':
+      if !quoted {
+        tag = p.src[p.pos:i + 1]
+        p.advance(i + 1)
+        return leading, tag, true, nil
+      }
+    }
+  }
+
+  return "", "", false, p.errorf("Unterminated tag")
+}
+
+// windows parses the windows up to the end of the text, or up to the end of the
+// current CHILDREN block.  It also returns the text that follows the last
+// window.
+func (p *parser) windows(inChildren bool) ([]*Window, string, error) {
+  var result []*Window
+  for {
+    line := p.line
+    leading, tag, found, e := p.nextTag()
+    if e != nil {
+      return nil, "", e
+    }
+
+    if !found {
+      if inChildren {
+        return nil, "", p.errorf("Unclosed CHILDREN block")
+      }
+      return result, leading, nil
+    }
+
+    name := ""
+    if fields := strings.Fields(tag[1:len(tag) - 1]); len(fields) > 0 {
+      name = strings.ToUpper(fields[0])
+    }
+
+    switch name {
+    case "LEGACY":
+      w, e := parseLegacy(tag)
+      if e != nil {
+        return nil, "", fmt.Errorf("Line %d: %v", line + strings.Count(leading, "\n"), e)
+      }
+
+      w.leading = leading
+      result = append(result, w)
+
+    case "CHILDREN":
+      if len(result) == 0 || result[len(result) - 1].hasChildren {
+        return nil, "", p.errorf("CHILDREN block doesn't follow a window")
+      }
+
+      parent := result[len(result) - 1]
+      parent.hasChildren = true
+      parent.childrenLeading = leading
+      if parent.Children, parent.childrenTrailing, e = p.windows(true); e != nil {
+        return nil, "", e
+      }
+
+    case "/CHILDREN":
+      if !inChildren {
+        return nil, "", p.errorf("Unexpected </CHILDREN>")
+      }
+      return result, leading, nil
+
+    default:
+      return nil, "", p.errorf("Unknown tag %s", tag)
+    }
+  }
+}
+
+// parseLegacy parses the attributes of a LEGACY tag.
+func parseLegacy(tag string) (*Window, error) {
+  content := tag[1:len(tag) - 1]
+  content = strings.TrimLeft(content, " \t\r\n")[len("LEGACY"):]
+
+  w := &Window{raw: tag}
+  for {
+    content = strings.TrimLeft(content, " \t\r\n")
+    if content == "" {
+      break
+    }
+
+    end := strings.IndexAny(content, "= \t\r\n")
+    if end < 0 || !strings.HasPrefix(strings.TrimLeft(content[end:], " \t\r\n"), "=") {
+      return nil, fmt.Errorf("Attribute %q has no value", strings.Fields(content)[0])
+    }
+
+    key := content[:end]
+    if key == "" {
+      return nil, fmt.Errorf("Attribute without a key")
+    }
+
+    content = strings.TrimLeft(strings.TrimLeft(content[end:], " \t\r\n")[1:], " \t\r\n")
+
+    length, e := valueLength(content)
+    if e != nil {
+      return nil, fmt.Errorf("Attribute %q: %v", key, e)
+    }
+
+    w.Attributes = append(w.Attributes, Attribute{Key: key, Value: content[:length]})
+    content = content[length:]
+  }
+
+  w.orig = make([]Attribute, len(w.Attributes))
+  copy(w.orig, w.Attributes)
+
+  return w, nil
+}
+
+// valueLength returns the length of the attribute value at the start of the
+// provided text.  Values in parentheses, braces or quotes may hold whitespace.
+func valueLength(s string) (int, error) {
+  if s == "" {
+    return 0, nil
+  }
+
+  closing := map[byte]byte{'(': ')', '{': '}', '"': '"'}
+  if c, found := closing[s[0]]; found {
+    end := strings.IndexByte(s[1:], c)
+    if end < 0 {
+      return 0, fmt.Errorf("Missing closing %c", c)
+    }
+    return end + 2, nil
+  }
+
+  if end := strings.IndexAny(s, " \t\r\n"); end >= 0 {
+    return end, nil
+  }
+
+  return len(s), nil
+}
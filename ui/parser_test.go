@@ -0,0 +1,53 @@
+package ui
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestParseErrors(t *testing.T) {
+  tests := []struct {
+    source, message string
+  }{
+    {"<LEGACY clsid=GZWinGen", "Line 1: Unterminated tag"},
+    {"<LEGACY id=1>\r\n<CHILDREN>\r\n<LEGACY id=2>", "Line 3: Unclosed CHILDREN block"},
+    {"</CHILDREN>", "Line 1: Unexpected </CHILDREN>"},
+    {"\r\n<CHILDREN></CHILDREN>", "Line 2: CHILDREN block doesn't follow a window"},
+    {"<WINDOW id=1>", "Line 1: Unknown tag <WINDOW id=1>"},
+    {"\n\n<LEGACY id=1 winflag_visible>", "Line 3: Attribute \"winflag_visible\" has no value"},
+    {"<LEGACY id >", "Line 1: Attribute \"id\" has no value"},
+    {"<LEGACY area=(0,0,10,10>", "Line 1: Attribute \"area\": Missing closing )"},
+    {"<LEGACY =1>", "Line 1: Attribute without a key"},
+  }
+
+  for _, test := range tests {
+    _, e := Parse(strings.NewReader(test.source))
+    if e == nil || e.Error() != test.message {
+      t.Errorf("Expected %q for %q, got %v", test.message, test.source, e)
+    }
+  }
+}
+
+func TestParseAttributeValues(t *testing.T) {
+  w, e := parseLegacy("<LEGACY clsid=GZWinText caption=\"Hello > world\" area = ( 1, 2, 3, 4 ) image={0x1,0x2}>")
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  expected := []Attribute{
+    {"clsid", "GZWinText"},
+    {"caption", "\"Hello > world\""},
+    {"area", "( 1, 2, 3, 4 )"},
+    {"image", "{0x1,0x2}"},
+  }
+
+  if len(w.Attributes) != len(expected) {
+    t.Fatalf("Unexpected attributes %+v", w.Attributes)
+  }
+
+  for i := range expected {
+    if w.Attributes[i] != expected[i] {
+      t.Errorf("Attribute %d: expected %+v, got %+v", i, expected[i], w.Attributes[i])
+    }
+  }
+}
@@ -0,0 +1,454 @@
+package ui
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "strconv"
+  "strings"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/fsh"
+)
+
+// UI_TYPE_ID is the TypeId used by UI layout entries.
+const UI_TYPE_ID uint32 = 0x00000000
+
+// PNG_TYPE_ID is the TypeId used by the PNG images referenced by UI layouts.
+// It's the same as godbpf.PNG_TYPE_ID, which should be used instead.
+const PNG_TYPE_ID = godbpf.PNG_TYPE_ID
+
+// The class IDs of the common window types.
+const (
+  GENERIC_CLASS = "GZWinGen"
+  BUTTON_CLASS = "GZWinBtn"
+  TEXT_CLASS = "GZWinText"
+  BITMAP_CLASS = "GZWinBMP"
+  TEXT_EDIT_CLASS = "GZWinTextEdit"
+  SCROLLBAR_CLASS = "GZWinScrollbar2"
+  CUSTOM_CLASS = "GZWinCustom"
+)
+
+// Attribute is a single key and value pair of a LEGACY tag.  Value is kept as
+// it is written, including any parentheses, braces or quotes.
+type Attribute struct {
+  Key, Value string
+}
+
+// Rect is the area covered by a window, relative to its parent.
+type Rect struct {
+  X0, Y0, X1, Y1 int
+}
+
+// String returns the attribute form of the receiver.
+func (r Rect) String() string {
+  return fmt.Sprintf("(%d,%d,%d,%d)", r.X0, r.Y0, r.X1, r.Y1)
+}
+
+// Window is a window declared by a LEGACY tag, along with the windows declared
+// in the CHILDREN block that follows it.
+type Window struct {
+  Attributes []Attribute
+  Children []*Window
+
+  // leading holds the text preceding the tag.
+  leading string
+
+  // raw and orig hold the tag and attributes as they were parsed, so that an
+  // unmodified tag is written exactly as it was read.
+  raw string
+  orig []Attribute
+
+  // hasChildren indicates that the tag is followed by a CHILDREN block, even
+  // if it is empty.  childrenLeading and childrenTrailing hold the text that
+  // precedes the opening and closing tags of the block.
+  hasChildren bool
+  childrenLeading, childrenTrailing string
+}
+
+// NewWindow creates a Window of the provided class with the provided ID.
+func NewWindow(class string, id uint32) *Window {
+  return &Window{Attributes: []Attribute{
+    {Key: "clsid", Value: class},
+    {Key: "iid", Value: "IGZWin"},
+    {Key: "id", Value: fmt.Sprintf("0x%08x", id)},
+  }}
+}
+
+// Get returns the value of the attribute with the provided key.  Keys are
+// matched without regard to case.
+func (w *Window) Get(key string) (string, bool) {
+  for _, a := range w.Attributes {
+    if strings.EqualFold(a.Key, key) {
+      return a.Value, true
+    }
+  }
+
+  return "", false
+}
+
+// Set updates the value of the attribute with the provided key, or adds the
+// attribute after the existing ones.
+func (w *Window) Set(key, value string) {
+  for i, a := range w.Attributes {
+    if strings.EqualFold(a.Key, key) {
+      w.Attributes[i].Value = value
+      return
+    }
+  }
+
+  w.Attributes = append(w.Attributes, Attribute{Key: key, Value: value})
+}
+
+// Remove removes the attribute with the provided key, if present.
+func (w *Window) Remove(key string) {
+  for i, a := range w.Attributes {
+    if strings.EqualFold(a.Key, key) {
+      w.Attributes = append(w.Attributes[:i], w.Attributes[i + 1:]...)
+      return
+    }
+  }
+}
+
+// Class returns the class ID of the receiver, such as GZWinBtn.
+func (w *Window) Class() string {
+  class, _ := w.Get("clsid")
+  return class
+}
+
+// ID returns the ID of the receiver.
+func (w *Window) ID() (uint32, bool) {
+  value, found := w.Get("id")
+  if !found {
+    return 0, false
+  }
+
+  id, e := strconv.ParseUint(value, 0, 32)
+  return uint32(id), e == nil
+}
+
+// Area returns the area covered by the receiver.
+func (w *Window) Area() (Rect, error) {
+  value, found := w.Get("area")
+  if !found {
+    return Rect{}, fmt.Errorf("Window has no area")
+  }
+
+  values, e := parseTuple(value, "(", ")", 4)
+  if e != nil {
+    return Rect{}, e
+  }
+
+  return Rect{int(values[0]), int(values[1]), int(values[2]), int(values[3])}, nil
+}
+
+// SetArea updates the area covered by the receiver.
+func (w *Window) SetArea(r Rect) {
+  w.Set("area", r.String())
+}
+
+// Style returns the style of the receiver, which is empty when the window
+// doesn't have one.
+func (w *Window) Style() string {
+  style, _ := w.Get("style")
+  return style
+}
+
+// Image returns the GroupId and InstanceId of the image displayed by the
+// receiver.
+func (w *Window) Image() (groupId, instanceId uint32, found bool) {
+  value, found := w.Get("image")
+  if !found {
+    return 0, 0, false
+  }
+
+  gi, e := parseTuple(value, "{", "}", 2)
+  if e != nil {
+    return 0, 0, false
+  }
+
+  return uint32(gi[0]), uint32(gi[1]), true
+}
+
+// SetImage updates the image displayed by the receiver.
+func (w *Window) SetImage(groupId, instanceId uint32) {
+  w.Set("image", formatGI(groupId, instanceId))
+}
+
+// formatGI returns the attribute form of a GroupId and InstanceId.
+func formatGI(groupId, instanceId uint32) string {
+  return fmt.Sprintf("{0x%08x,0x%08x}", groupId, instanceId)
+}
+
+// parseTuple parses a comma separated list of integers between the provided
+// delimiters.
+func parseTuple(value, open, close string, count int) ([]int64, error) {
+  if !strings.HasPrefix(value, open) || !strings.HasSuffix(value, close) {
+    return nil, fmt.Errorf("Malformed value %s", value)
+  }
+
+  fields := strings.Split(value[len(open):len(value) - len(close)], ",")
+  if len(fields) != count {
+    return nil, fmt.Errorf("Expected %d values in %s", count, value)
+  }
+
+  result := make([]int64, count)
+  for i, field := range fields {
+    field = strings.TrimSpace(field)
+
+    n, e := strconv.ParseInt(field, 0, 64)
+    if e != nil {
+      // Identifiers such as 0xFFFFFFFF don't fit in a signed 32 bit value but
+      // are still valid.
+      u, ue := strconv.ParseUint(field, 0, 32)
+      if ue != nil {
+        return nil, fmt.Errorf("Malformed value %s: %v", value, e)
+      }
+      n = int64(u)
+    }
+
+    result[i] = n
+  }
+
+  return result, nil
+}
+
+// modified checks if the attributes of the receiver changed since it was
+// parsed.
+func (w *Window) modified() bool {
+  if w.raw == "" || len(w.Attributes) != len(w.orig) {
+    return true
+  }
+
+  for i := range w.Attributes {
+    if w.Attributes[i] != w.orig[i] {
+      return true
+    }
+  }
+
+  return false
+}
+
+// format writes the receiver and its children to the provided Buffer.  New
+// windows are written on their own line.
+func (w *Window) format(buf *bytes.Buffer, depth int) {
+  if w.raw == "" && w.leading == "" && (depth > 0 || buf.Len() > 0) {
+    buf.WriteString("\r\n")
+  } else {
+    buf.WriteString(w.leading)
+  }
+
+  if w.modified() {
+    buf.WriteString("<LEGACY")
+    for _, a := range w.Attributes {
+      buf.WriteString(" " + a.Key + "=" + a.Value)
+    }
+    buf.WriteString(">")
+  } else {
+    buf.WriteString(w.raw)
+  }
+
+  if !w.hasChildren && len(w.Children) == 0 {
+    return
+  }
+
+  if !w.hasChildren {
+    buf.WriteString("\r\n")
+  } else {
+    buf.WriteString(w.childrenLeading)
+  }
+  buf.WriteString("<CHILDREN>")
+
+  for _, child := range w.Children {
+    child.format(buf, depth + 1)
+  }
+
+  if !w.hasChildren {
+    buf.WriteString("\r\n")
+  } else {
+    buf.WriteString(w.childrenTrailing)
+  }
+  buf.WriteString("</CHILDREN>")
+}
+
+// File holds the top level windows of a UI layout.
+type File struct {
+  Windows []*Window
+
+  // trailing holds the text following the last tag.
+  trailing string
+}
+
+// Parse reads a UI layout from the provided Reader.
+func Parse(r io.Reader) (*File, error) {
+  data, e := ioutil.ReadAll(r)
+  if e != nil {
+    return nil, e
+  }
+
+  p := &parser{src: string(data), line: 1}
+  f := new(File)
+
+  f.Windows, f.trailing, e = p.windows(false)
+  if e != nil {
+    return nil, e
+  }
+
+  return f, nil
+}
+
+// Format writes the receiver to the provided Writer.  Windows that haven't been
+// modified are written exactly as they were parsed.
+func (f *File) Format(w io.Writer) error {
+  buf := new(bytes.Buffer)
+  for _, window := range f.Windows {
+    window.format(buf, 0)
+  }
+  buf.WriteString(f.trailing)
+
+  _, e := buf.WriteTo(w)
+  return e
+}
+
+// String returns the text of the receiver.
+func (f *File) String() string {
+  buf := new(bytes.Buffer)
+  f.Format(buf)
+  return buf.String()
+}
+
+// Walk calls the provided function for every window of the receiver, parents
+// before their children.  The depth of top level windows is 0.
+func (f *File) Walk(fn func(w *Window, depth int)) {
+  var walk func(windows []*Window, depth int)
+  walk = func(windows []*Window, depth int) {
+    for _, w := range windows {
+      fn(w, depth)
+      walk(w.Children, depth + 1)
+    }
+  }
+
+  walk(f.Windows, 0)
+}
+
+// Find returns the first window with the provided ID, or nil if there isn't
+// one.
+func (f *File) Find(id uint32) *Window {
+  var result *Window
+  f.Walk(func(w *Window, depth int) {
+    if windowId, ok := w.ID(); result == nil && ok && windowId == id {
+      result = w
+    }
+  })
+
+  return result
+}
+
+// FindByClass returns the windows of the provided class, in the order they
+// appear.
+func (f *File) FindByClass(class string) []*Window {
+  var result []*Window
+  f.Walk(func(w *Window, depth int) {
+    if strings.EqualFold(w.Class(), class) {
+      result = append(result, w)
+    }
+  })
+
+  return result
+}
+
+// ImageRef is a reference from a window attribute to an image.
+type ImageRef struct {
+  Window *Window
+  Key string
+  GroupId, InstanceId uint32
+}
+
+// String returns a description of the receiver.
+func (r *ImageRef) String() string {
+  id, _ := r.Window.ID()
+  return fmt.Sprintf("window 0x%08X %s=%s", id, r.Key, formatGI(r.GroupId, r.InstanceId))
+}
+
+// ImageKeys lists the window attributes holding the {group,instance} of an
+// image.  Other attributes hold such values for other kinds of entries, such as
+// captionres and tipres, which refer to LTEXT entries.
+var ImageKeys = []string{"image"}
+
+// Images returns the image references found in the attributes of every window
+// of the receiver, which are those named in ImageKeys.
+func (f *File) Images() []*ImageRef {
+  var result []*ImageRef
+  f.Walk(func(w *Window, depth int) {
+    for _, a := range w.Attributes {
+      if !isImageKey(a.Key) {
+        continue
+      }
+
+      if gi, e := parseTuple(a.Value, "{", "}", 2); e == nil {
+        result = append(result, &ImageRef{Window: w, Key: a.Key, GroupId: uint32(gi[0]), InstanceId: uint32(gi[1])})
+      }
+    }
+  })
+
+  return result
+}
+
+// isImageKey checks if the attribute with the provided key holds an image.
+func isImageKey(key string) bool {
+  for _, k := range ImageKeys {
+    if strings.EqualFold(k, key) {
+      return true
+    }
+  }
+
+  return false
+}
+
+// ImageTypeIds lists the TypeIds of the entries that can satisfy an image
+// reference.
+var ImageTypeIds = []uint32{godbpf.PNG_TYPE_ID, fsh.FSH_TYPE_ID}
+
+// MissingImages returns the image references of the receiver that don't match
+// an image entry in any of the provided DBPFs.
+func (f *File) MissingImages(dbpfs ...*godbpf.DBPF) []*ImageRef {
+  type gi struct {
+    group, instance uint32
+  }
+
+  available := make(map[gi]bool)
+  for _, dbpf := range dbpfs {
+    for _, typeId := range ImageTypeIds {
+      for _, e := range dbpf.FindByType(typeId) {
+        available[gi{e.TGI.GroupId, e.TGI.InstanceId}] = true
+      }
+    }
+  }
+
+  var result []*ImageRef
+  for _, ref := range f.Images() {
+    if !available[gi{ref.GroupId, ref.InstanceId}] {
+      result = append(result, ref)
+    }
+  }
+
+  return result
+}
+
+// Read parses the UI entry identified by the provided DBPFEntryTGI.
+func Read(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI) (*File, error) {
+  data, e := dbpf.GetUncompressedData(tgi)
+  if e != nil {
+    return nil, e
+  }
+
+  return Parse(bytes.NewReader(data))
+}
+
+// Replace formats the provided file and stores it in the entry identified by
+// the provided DBPFEntryTGI, which keeps its position and compression state.
+func Replace(dbpf *godbpf.DBPF, tgi *entry.DBPFEntryTGI, f *File) error {
+  return dbpf.SetUncompressedData(tgi, []byte(f.String()))
+}
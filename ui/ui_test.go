@@ -0,0 +1,199 @@
+package ui
+
+import (
+  "strings"
+  "testing"
+
+  "github.com/marcboudreau/godbpf"
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/fsh"
+)
+
+const sampleUI = "# Query window\r\n" +
+  "<LEGACY clsid=GZWinGen iid=IGZWin id=0x00000001 area=(0,0,300,200)  fillcolor=(0,0,0) winflag_visible=yes>\r\n" +
+  "<CHILDREN>\r\n" +
+  "  <LEGACY clsid=GZWinBtn iid=IGZWinBtn id=0x4A5E8E0D area=(10,10,50,50) style=radiocheck image={0x46a006b0,0x4a8c8e63}>\r\n" +
+  "  <LEGACY clsid=GZWinText iid=IGZWinText id=0x00000002 area=(60,10,290,30) caption=\"Query\">\r\n" +
+  "  <CHILDREN>\r\n" +
+  "  </CHILDREN>\r\n" +
+  "  <LEGACY clsid=GZWinBMP iid=IGZWinBMP id=0x00000003 area=(-5,0,0,5) image={0x46A006B0,0x0A000001}>\r\n" +
+  "</CHILDREN>\r\n"
+
+func TestParseAndFormatUnmodified(t *testing.T) {
+  f, e := Parse(strings.NewReader(sampleUI))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if len(f.Windows) != 1 || len(f.Windows[0].Children) != 3 {
+    t.Fatalf("Unexpected windows %+v", f.Windows)
+  }
+
+  if f.String() != sampleUI {
+    t.Errorf("Expected the unmodified layout to be written as is, got:\n%s", f.String())
+  }
+}
+
+func TestWindowAccessors(t *testing.T) {
+  f, e := Parse(strings.NewReader(sampleUI))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  button := f.Find(0x4A5E8E0D)
+  if button == nil {
+    t.Fatal("Expected to find the button")
+  }
+
+  if button.Class() != BUTTON_CLASS || button.Style() != "radiocheck" {
+    t.Errorf("Unexpected button %+v", button.Attributes)
+  }
+
+  if area, e := button.Area(); e != nil || area != (Rect{10, 10, 50, 50}) {
+    t.Errorf("Unexpected area %v, %v", area, e)
+  }
+
+  if g, i, found := button.Image(); !found || g != 0x46A006B0 || i != 0x4A8C8E63 {
+    t.Errorf("Unexpected image %08X %08X %v", g, i, found)
+  }
+
+  bitmap := f.Find(3)
+  if area, e := bitmap.Area(); e != nil || area != (Rect{-5, 0, 0, 5}) {
+    t.Errorf("Unexpected area %v, %v", area, e)
+  }
+
+  if _, e := f.Windows[0].Children[1].Area(); e != nil {
+    t.Error(e)
+  }
+  if _, _, found := f.Windows[0].Image(); found {
+    t.Error("Expected no image on the root window")
+  }
+
+  if f.Find(0x99) != nil {
+    t.Error("Expected no window with ID 0x99")
+  }
+
+  if texts := f.FindByClass("gzwintext"); len(texts) != 1 || texts[0] != f.Find(2) {
+    t.Errorf("Unexpected text windows %v", texts)
+  }
+
+  var depths []int
+  f.Walk(func(w *Window, depth int) { depths = append(depths, depth) })
+  if len(depths) != 4 || depths[0] != 0 || depths[3] != 1 {
+    t.Errorf("Unexpected walk depths %v", depths)
+  }
+}
+
+func TestEditPreservesAttributeOrder(t *testing.T) {
+  f, e := Parse(strings.NewReader(sampleUI))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  root := f.Windows[0]
+  root.SetArea(Rect{0, 0, 400, 250})
+  root.Set("tipflag", "yes")
+  root.Remove("fillcolor")
+
+  button := f.Find(0x4A5E8E0D)
+  button.SetImage(0x46A006B0, 0x12345678)
+
+  label := NewWindow(TEXT_CLASS, 4)
+  label.Set("caption", "\"New\"")
+  f.Find(2).Children = append(f.Find(2).Children, label)
+
+  expected := "# Query window\r\n" +
+    "<LEGACY clsid=GZWinGen iid=IGZWin id=0x00000001 area=(0,0,400,250) winflag_visible=yes tipflag=yes>\r\n" +
+    "<CHILDREN>\r\n" +
+    "  <LEGACY clsid=GZWinBtn iid=IGZWinBtn id=0x4A5E8E0D area=(10,10,50,50) style=radiocheck image={0x46a006b0,0x12345678}>\r\n" +
+    "  <LEGACY clsid=GZWinText iid=IGZWinText id=0x00000002 area=(60,10,290,30) caption=\"Query\">\r\n" +
+    "  <CHILDREN>\r\n" +
+    "<LEGACY clsid=GZWinText iid=IGZWin id=0x00000004 caption=\"New\">\r\n" +
+    "  </CHILDREN>\r\n" +
+    "  <LEGACY clsid=GZWinBMP iid=IGZWinBMP id=0x00000003 area=(-5,0,0,5) image={0x46A006B0,0x0A000001}>\r\n" +
+    "</CHILDREN>\r\n"
+
+  if f.String() != expected {
+    t.Errorf("Unexpected layout:\n%s", f.String())
+  }
+
+  if _, e := Parse(strings.NewReader(f.String())); e != nil {
+    t.Error(e)
+  }
+}
+
+func TestNewFile(t *testing.T) {
+  root := NewWindow(GENERIC_CLASS, 1)
+  root.Children = []*Window{NewWindow(BUTTON_CLASS, 2)}
+  f := &File{Windows: []*Window{root, NewWindow(GENERIC_CLASS, 3)}}
+
+  expected := "<LEGACY clsid=GZWinGen iid=IGZWin id=0x00000001>\r\n" +
+    "<CHILDREN>\r\n" +
+    "<LEGACY clsid=GZWinBtn iid=IGZWin id=0x00000002>\r\n" +
+    "</CHILDREN>\r\n" +
+    "<LEGACY clsid=GZWinGen iid=IGZWin id=0x00000003>"
+
+  if f.String() != expected {
+    t.Errorf("Unexpected layout:\n%s", f.String())
+  }
+}
+
+func TestImagesAndMissingImages(t *testing.T) {
+  f, e := Parse(strings.NewReader(sampleUI))
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  images := f.Images()
+  if len(images) != 2 || images[0].Key != "image" || images[1].InstanceId != 0x0A000001 {
+    t.Fatalf("Unexpected images %v", images)
+  }
+
+  // The caption and tip of a window refer to LTEXT entries, not to images.
+  f.Find(2).Set("captionres", "{0x6A231EAA,0x2C5B0E8F}")
+  f.Find(2).Set("tipres", "{0x6A231EAA,0x2C5B0E90}")
+  if images := f.Images(); len(images) != 2 {
+    t.Errorf("Expected only image attributes to be reported, got %v", images)
+  }
+
+  pngs := godbpf.New()
+  pngs.AddEntry(entry.NewEntry(&entry.DBPFEntryTGI{TypeId: godbpf.PNG_TYPE_ID, GroupId: 0x46A006B0, InstanceId: 0x4A8C8E63}))
+  fshs := godbpf.New()
+  fshs.AddEntry(entry.NewEntry(&entry.DBPFEntryTGI{TypeId: fsh.FSH_TYPE_ID, GroupId: 0x46A006B0, InstanceId: 0x0A000001}))
+
+  missing := f.MissingImages(pngs)
+  if len(missing) != 1 || missing[0].InstanceId != 0x0A000001 {
+    t.Errorf("Unexpected missing images %v", missing)
+  }
+
+  if missing[0].String() != "window 0x00000003 image={0x46a006b0,0x0a000001}" {
+    t.Errorf("Unexpected description %q", missing[0].String())
+  }
+
+  if missing := f.MissingImages(pngs, fshs); len(missing) != 0 {
+    t.Errorf("Expected no missing images, got %v", missing)
+  }
+}
+
+func TestReadAndReplace(t *testing.T) {
+  dbpf := godbpf.New()
+  tgi := &entry.DBPFEntryTGI{TypeId: UI_TYPE_ID, GroupId: 0x96A006B0, InstanceId: 0x8BD8F0C9}
+  dbpf.AddCompressedEntry(tgi, []byte(sampleUI))
+
+  f, e := Read(dbpf, tgi)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  f.Find(2).Set("caption", "\"Inspect\"")
+  if e := Replace(dbpf, tgi, f); e != nil {
+    t.Fatal(e)
+  }
+
+  if f, e = Read(dbpf, tgi); e != nil {
+    t.Fatal(e)
+  }
+  if caption, _ := f.Find(2).Get("caption"); caption != "\"Inspect\"" {
+    t.Errorf("Unexpected caption %s", caption)
+  }
+}
@@ -0,0 +1,174 @@
+package godbpf
+
+import (
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "math"
+
+  "github.com/marcboudreau/godbpf/entry"
+  "github.com/marcboudreau/godbpf/util"
+)
+
+// Writer writes a DBPF file one entry at a time, without holding the data of
+// the entries in memory: only their index records, and the DIR records of
+// compressed entries, are kept until Close writes them.  The header is written
+// last, which is why the Writer needs to seek back to the start of the file.
+type Writer struct {
+  // Header holds the header fields written by Close.  Its entries are
+  // ignored.  It starts with a major version of 1 and an index major version
+  // of 7, as expected by the game.
+  Header *DBPF
+
+  w io.WriteSeeker
+
+  // start is the position of the start of the file in w, and offset the
+  // position of the next entry relative to it.
+  start int64
+  offset uint32
+
+  index *bytes.Buffer
+  count uint32
+  dir *bytes.Buffer
+
+  // err is the first error met, which every later call returns.
+  err error
+}
+
+// NewWriter creates a Writer writing a DBPF file to the provided WriteSeeker,
+// starting at its current position.
+func NewWriter(w io.WriteSeeker) *Writer {
+  header := New()
+  header.MajorVersion = 1
+  header.IndexMajorVersion = 7
+
+  writer := &Writer{Header: header, w: w, offset: HEADER_SIZE, index: new(bytes.Buffer), dir: new(bytes.Buffer)}
+  if writer.start, writer.err = w.Seek(0, io.SeekCurrent); writer.err == nil {
+    // Leave room for the header, which is written by Close.
+    _, writer.err = w.Write(make([]byte, HEADER_SIZE))
+  }
+
+  return writer
+}
+
+// WriteEntry writes an entry with the provided TGI holding the data read from
+// the provided Reader, as is.
+func (w *Writer) WriteEntry(tgi *entry.DBPFEntryTGI, r io.Reader) error {
+  if w.err != nil {
+    return w.err
+  }
+
+  if tgi.Equals(entry.DIR_ENTRY_TGI) {
+    return errors.New("The DIR entry is written by Close")
+  }
+
+  n, e := io.Copy(w.w, r)
+  if e != nil {
+    w.err = fmt.Errorf("Failed to write entry {%s}: %v", tgi, e)
+    return w.err
+  }
+
+  return w.addIndexRecord(tgi, n)
+}
+
+// WriteCompressed writes an entry with the provided TGI holding the data read
+// from the provided Reader, compressed as by Compress.  The data of this entry
+// is held in memory while it's being compressed.
+func (w *Writer) WriteCompressed(tgi *entry.DBPFEntryTGI, r io.Reader) error {
+  if w.err != nil {
+    return w.err
+  }
+
+  data, e := ioutil.ReadAll(r)
+  if e != nil {
+    return fmt.Errorf("Failed to read entry {%s}: %v", tgi, e)
+  }
+
+  if uint64(len(data)) > math.MaxUint32 {
+    return fmt.Errorf("Entry {%s} is too large", tgi)
+  }
+
+  if e := w.WriteEntry(tgi, bytes.NewReader(Compress(data))); e != nil {
+    return e
+  }
+
+  record := make([]byte, 16)
+  tgi.Bytes(record)
+  copy(record[12:16], util.WriteUint32(uint32(len(data))))
+  w.dir.Write(record)
+
+  return nil
+}
+
+// addIndexRecord records the location and size of the entry with the provided
+// TGI, which was just written.
+func (w *Writer) addIndexRecord(tgi *entry.DBPFEntryTGI, size int64) error {
+  if int64(w.offset) + size > math.MaxUint32 {
+    w.err = errors.New("A DBPF file can't exceed 4 GiB")
+    return w.err
+  }
+
+  binary.Write(w.index, binary.LittleEndian, tgi.TypeId)
+  binary.Write(w.index, binary.LittleEndian, tgi.GroupId)
+  binary.Write(w.index, binary.LittleEndian, tgi.InstanceId)
+  binary.Write(w.index, binary.LittleEndian, w.offset)
+  binary.Write(w.index, binary.LittleEndian, uint32(size))
+
+  w.offset += uint32(size)
+  w.count++
+
+  return nil
+}
+
+// Close writes the DIR entry if any entry was compressed, followed by the
+// index, and then the header.  The WriteSeeker is left positioned at the end
+// of the file, and isn't closed.
+func (w *Writer) Close() error {
+  if w.err != nil {
+    return w.err
+  }
+
+  if w.dir.Len() > 0 {
+    size := int64(w.dir.Len())
+    if _, e := w.dir.WriteTo(w.w); e != nil {
+      w.err = fmt.Errorf("Failed to write the DIR entry: %v", e)
+      return w.err
+    }
+
+    if e := w.addIndexRecord(entry.DIR_ENTRY_TGI, size); e != nil {
+      return e
+    }
+  }
+
+  w.err = errors.New("The Writer is closed")
+
+  if int64(w.offset) + int64(w.index.Len()) > math.MaxUint32 {
+    return errors.New("A DBPF file can't exceed 4 GiB")
+  }
+
+  if _, e := w.index.WriteTo(w.w); e != nil {
+    return e
+  }
+
+  end, e := w.w.Seek(0, io.SeekCurrent)
+  if e != nil {
+    return e
+  }
+
+  header := new(bytes.Buffer)
+  w.Header.encodeHeader(header, w.count, w.offset)
+
+  if _, e := w.w.Seek(w.start, io.SeekStart); e != nil {
+    return e
+  }
+
+  if _, e := header.WriteTo(w.w); e != nil {
+    return e
+  }
+
+  _, e = w.w.Seek(end, io.SeekStart)
+  return e
+}
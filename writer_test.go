@@ -0,0 +1,161 @@
+package godbpf
+
+import (
+  "bytes"
+  "errors"
+  "io"
+  "io/ioutil"
+  "os"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/marcboudreau/godbpf/entry"
+)
+
+func tempFile(t *testing.T) *os.File {
+  f, e := ioutil.TempFile("", "godbpf")
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  return f
+}
+
+func TestWriter(t *testing.T) {
+  f := tempFile(t)
+  defer os.Remove(f.Name())
+  defer f.Close()
+
+  plain := &entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x3}
+  compressed := &entry.DBPFEntryTGI{TypeId: 0x4, GroupId: 0x5, InstanceId: 0x6}
+  text := strings.Repeat("compressed ", 100)
+
+  w := NewWriter(f)
+  w.Header.ModifiedDate = time.Unix(1457490790, 0)
+  if e := w.WriteEntry(plain, strings.NewReader("plain")); e != nil {
+    t.Fatal(e)
+  }
+  if e := w.WriteCompressed(compressed, strings.NewReader(text)); e != nil {
+    t.Fatal(e)
+  }
+  if e := w.WriteEntry(entry.DIR_ENTRY_TGI, strings.NewReader("")); e == nil {
+    t.Error("Expected an error for writing the DIR entry")
+  }
+  if e := w.Close(); e != nil {
+    t.Fatal(e)
+  }
+  if e := w.WriteEntry(plain, strings.NewReader("plain")); e == nil {
+    t.Error("Expected an error for writing to a closed Writer")
+  }
+
+  if end, _ := f.Seek(0, io.SeekCurrent); end != HEADER_SIZE + 5 + int64(len(Compress([]byte(text)))) + 16 + 3 * INDEX_ENTRY_SIZE {
+    t.Errorf("Expected the file to be positioned at its end, got %d", end)
+  }
+
+  f.Seek(0, io.SeekStart)
+  dbpf, e := Parse(f)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if dbpf.MajorVersion != 1 || dbpf.IndexMajorVersion != 7 || dbpf.ModifiedDate.Unix() != 1457490790 || dbpf.Len() != 3 {
+    t.Errorf("Unexpected header %+v", dbpf)
+  }
+
+  if data, e := dbpf.GetUncompressedData(plain); e != nil || string(data) != "plain" || dbpf.IsCompressed(plain) {
+    t.Errorf("Unexpected data %q, %v", data, e)
+  }
+  if data, e := dbpf.GetUncompressedData(compressed); e != nil || string(data) != text || !dbpf.IsCompressed(compressed) {
+    t.Errorf("Unexpected data %q, %v", data, e)
+  }
+}
+
+func TestWriterOffset(t *testing.T) {
+  f := tempFile(t)
+  defer os.Remove(f.Name())
+  defer f.Close()
+
+  f.Write([]byte("prefix"))
+  w := NewWriter(f)
+  w.WriteEntry(&entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x3}, strings.NewReader("data"))
+  if e := w.Close(); e != nil {
+    t.Fatal(e)
+  }
+
+  info, e := f.Stat()
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  header, entries, e := ReadIndex(io.NewSectionReader(f, 6, info.Size() - 6), info.Size() - 6)
+  if e != nil {
+    t.Fatal(e)
+  }
+
+  if header.MajorVersion != 1 || len(entries) != 1 || entries[0].Location != HEADER_SIZE || entries[0].Size != 4 {
+    t.Errorf("Unexpected entries %+v", entries)
+  }
+}
+
+func TestWriterEmpty(t *testing.T) {
+  f := tempFile(t)
+  defer os.Remove(f.Name())
+  defer f.Close()
+
+  if e := NewWriter(f).Close(); e != nil {
+    t.Fatal(e)
+  }
+
+  f.Seek(0, io.SeekStart)
+  data, _ := ioutil.ReadAll(f)
+
+  expected := new(bytes.Buffer)
+  empty := New()
+  empty.MajorVersion = 1
+  empty.IndexMajorVersion = 7
+  empty.Save(expected)
+  if !bytes.Equal(data, expected.Bytes()) {
+    t.Errorf("Expected the same bytes as Save, got %v", data)
+  }
+}
+
+// failingWriteSeeker fails every write after the first n bytes.
+type failingWriteSeeker struct {
+  n int
+  pos int64
+}
+
+func (f *failingWriteSeeker) Write(p []byte) (int, error) {
+  if len(p) > f.n {
+    return 0, errors.New("Disk full")
+  }
+
+  f.n -= len(p)
+  f.pos += int64(len(p))
+  return len(p), nil
+}
+
+func (f *failingWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+  if whence == io.SeekStart {
+    f.pos = offset
+  }
+
+  return f.pos, nil
+}
+
+func TestWriterError(t *testing.T) {
+  w := NewWriter(&failingWriteSeeker{n: HEADER_SIZE + 2})
+  tgi := &entry.DBPFEntryTGI{TypeId: 0x1, GroupId: 0x2, InstanceId: 0x3}
+  if e := w.WriteEntry(tgi, strings.NewReader("data")); e == nil {
+    t.Fatal("Expected an error for a failed write")
+  }
+
+  if e := w.Close(); e == nil {
+    t.Error("Expected the error to be returned by Close")
+  }
+
+  if e := NewWriter(&failingWriteSeeker{}).Close(); e == nil {
+    t.Error("Expected an error for a failed header")
+  }
+}